@@ -1,44 +1,539 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"log"
 	"net/http"
+	"net/url"
+	"strings"
+	"time"
 
+	"github.com/AnishMulay/Transaction-Conflict-Visualization/internal/backplane"
+	"github.com/AnishMulay/Transaction-Conflict-Visualization/internal/causality"
+	"github.com/AnishMulay/Transaction-Conflict-Visualization/internal/cluster"
 	"github.com/AnishMulay/Transaction-Conflict-Visualization/internal/concurrency"
+	"github.com/AnishMulay/Transaction-Conflict-Visualization/internal/httpapi"
+	"github.com/AnishMulay/Transaction-Conflict-Visualization/internal/layout"
+	"github.com/AnishMulay/Transaction-Conflict-Visualization/internal/netsim"
+	"github.com/AnishMulay/Transaction-Conflict-Visualization/internal/raft"
+	"github.com/AnishMulay/Transaction-Conflict-Visualization/internal/ratelimit"
+	"github.com/AnishMulay/Transaction-Conflict-Visualization/internal/replica"
+	"github.com/AnishMulay/Transaction-Conflict-Visualization/internal/saga"
+	"github.com/AnishMulay/Transaction-Conflict-Visualization/internal/tick"
+	"github.com/AnishMulay/Transaction-Conflict-Visualization/internal/twopc"
 	"github.com/AnishMulay/Transaction-Conflict-Visualization/internal/websocket"
 	"github.com/AnishMulay/Transaction-Conflict-Visualization/pkg/models"
+	"github.com/AnishMulay/Transaction-Conflict-Visualization/pkg/protocol"
 )
 
+// config is the validated command line
+type config struct {
+	addr             string
+	nodeID           string
+	peerList         string
+	backplaneSpec    string
+	roomNames        []string
+	replicaCount     int
+	mergeRule        replica.MergeRule
+	replicationDelay time.Duration
+	delayPolicy      concurrency.DelayPolicy
+	playerLimit      ratelimit.Limit
+	connectionLimit  ratelimit.Limit
+	strategy         concurrency.LockingStrategy
+	isolationLevel   concurrency.IsolationLevel
+	tickInterval     time.Duration
+	tickRule         tick.Rule
+	gameMap          *layout.Map
+	match            websocket.MatchConfig
+}
+
+// server is everything main wires together. gameState, controller and hub
+// belong to the default room, which also serves the HTTP APIs; hubs and
+// roomHubs hold the other replicas or rooms, if any.
+type server struct {
+	config
+	gameState       *models.GameState
+	controller      *concurrency.ConcurrencyController
+	hub             *websocket.Hub
+	member          *cluster.Cluster
+	network         *netsim.Network
+	replicas        *replica.Set
+	hubs            map[string]*websocket.Hub
+	coordinator     *twopc.Coordinator
+	roomHubs        map[string]*websocket.Hub
+	roomControllers map[string]*concurrency.ConcurrencyController
+	everyHub        []*websocket.Hub
+	roomSettings    *httpapi.RoomsAPI
+}
+
 func main() {
+	cfg := parseConfig()
+
 	fmt.Println("Real-time Multiplayer Game Server")
-	fmt.Println("Starting server on :8080...")
+	fmt.Printf("Starting server on %s...\n", cfg.addr)
+
+	s := &server{
+		config:          cfg,
+		hubs:            map[string]*websocket.Hub{},
+		roomHubs:        map[string]*websocket.Hub{},
+		roomControllers: map[string]*concurrency.ConcurrencyController{},
+		roomSettings:    httpapi.NewRoomsAPI(),
+	}
+	s.gameState, s.controller, s.hub = s.newRoom()
+
+	s.setupCluster()
+	s.setupBackplane()
+	s.setupReplicas()
+	s.setupRooms()
+	s.setupHubs()
+	s.setupHTTP()
+	s.logEndpoints()
+
+	log.Fatal(http.ListenAndServe(cfg.addr, nil))
+}
+
+// parseConfig reads and validates the command line, exiting on a bad flag
+func parseConfig() config {
+	addr := flag.String("addr", ":8080", "address to listen on")
+	nodeID := flag.String("node", "", "this server's ID in -peers; enables replicated mode")
+	peerList := flag.String("peers", "",
+		"replicated mode cluster members including this one, as id=host:port,... e.g. n1=localhost:8080,n2=localhost:8081,n3=localhost:8082")
+	backplaneSpec := flag.String("backplane", "",
+		"share the room named by -rooms with other instances over a pub/sub backplane: memory or redis://host:port")
+	roomList := flag.String("rooms", "lobby",
+		"rooms hosted by this server; more than one hosts several rooms, chosen by clients with /ws?room=arena, whose objects move together with two-phase commit, e.g. lobby,arena")
+	replicaCount := flag.Int("replicas", 0,
+		"run this many eventually consistent in-process replicas of the room, chosen by clients with /ws?replica=r1")
+	merge := flag.String("merge", "lww", "how replicas reconcile concurrent moves: lww or vector")
+	replicationDelay := flag.Duration("replication-delay", time.Second, "how long a move takes to reach the other replicas")
+	thinkTime := flag.String("think-time", "none",
+		"delay between propose and commit in every room: none, 50ms, fixed:50ms, uniform:10ms-100ms or normal:50ms,15ms; change one room's with POST /rooms/{room}?thinkTime=")
+	moveRate := flag.String("move-rate", "0",
+		"per-player move limit as rate/burst in moves per second, e.g. 20/40; 0 for unlimited")
+	locking := flag.String("locking", "optimistic",
+		"concurrency control for moves in every room: optimistic, 2pl (deadlock detection), wound-wait or wait-die; change one room's with POST /rooms/{room}?locking=")
+	isolation := flag.String("isolation", "serializable",
+		"isolation level of moves and scenarios in every room: read-uncommitted, read-committed, snapshot or serializable; change one room's with POST /rooms/{room}?isolation=")
+	connRate := flag.String("conn-rate", "0",
+		"per-connection message limit as rate/burst in messages per second, e.g. 50/100; 0 for unlimited")
+	tickInterval := flag.Duration("tick", 0,
+		"collect moves and commit them together once per tick, e.g. 100ms; 0 commits each move as it arrives")
+	tickRuleName := flag.String("tick-rule", "vector", "how the moves of a tick combine: vector, majority or first")
+	mapPath := flag.String("map", "", "map file with walls and goal zones for every room, e.g. maps/maze.txt; empty for an open 20x20 grid")
+	roundLength := flag.Duration("round", 0,
+		"play matches of timed rounds, scored by moving the object into a team's goal zone of -map, e.g. 60s; 0 for free play")
+	rounds := flag.Int("rounds", 3, "rounds in a match")
+	countdown := flag.Duration("countdown", 5*time.Second, "countdown before each round")
+	resultsTime := flag.Duration("results", 10*time.Second, "how long each round's results show before the next countdown")
+	minPlayers := flag.Int("min-players", 2, "connected players the lobby waits for before a round")
+	flag.Parse()
+
+	cfg := config{
+		addr:             *addr,
+		nodeID:           *nodeID,
+		peerList:         *peerList,
+		backplaneSpec:    *backplaneSpec,
+		replicaCount:     *replicaCount,
+		replicationDelay: *replicationDelay,
+		tickInterval:     *tickInterval,
+		match: websocket.MatchConfig{
+			Rounds:     *rounds,
+			MinPlayers: *minPlayers,
+			Countdown:  *countdown,
+			Round:      *roundLength,
+			Results:    *resultsTime,
+		},
+	}
+
+	var err error
+	if cfg.delayPolicy, err = concurrency.ParseDelayPolicy(*thinkTime); err != nil {
+		log.Fatalf("Invalid -think-time: %v", err)
+	}
+	if cfg.playerLimit, err = ratelimit.ParseLimit(*moveRate); err != nil {
+		log.Fatalf("Invalid -move-rate: %v", err)
+	}
+	if cfg.connectionLimit, err = ratelimit.ParseLimit(*connRate); err != nil {
+		log.Fatalf("Invalid -conn-rate: %v", err)
+	}
+	if cfg.strategy, err = concurrency.ParseLockingStrategy(*locking); err != nil {
+		log.Fatalf("Invalid -locking: %v", err)
+	}
+	if cfg.isolationLevel, err = concurrency.ParseIsolationLevel(*isolation); err != nil {
+		log.Fatalf("Invalid -isolation: %v", err)
+	}
+
+	cfg.roomNames = strings.Split(*roomList, ",")
+	seen := map[string]bool{}
+	for _, name := range cfg.roomNames {
+		if name == "" || seen[name] {
+			log.Fatalf("Invalid -rooms: room names must be distinct and not empty")
+		}
+		seen[name] = true
+	}
+
+	modes := 0
+	for _, enabled := range []bool{cfg.nodeID != "", cfg.backplaneSpec != "", cfg.replicaCount > 0, len(cfg.roomNames) > 1} {
+		if enabled {
+			modes++
+		}
+	}
+	if modes > 1 {
+		log.Fatal("-node, -backplane, -replicas and several -rooms cannot be combined")
+	}
 
-	// Initialize game state
-	gridSize := models.Position{X: 20, Y: 20}
-	gameState := models.NewGameState(gridSize)
+	if cfg.mergeRule, err = replica.ParseMergeRule(*merge); err != nil {
+		log.Fatalf("Invalid -merge: %v", err)
+	}
+
+	if cfg.tickRule, err = tick.ParseRule(*tickRuleName); err != nil {
+		log.Fatalf("Invalid -tick-rule: %v", err)
+	}
+	if cfg.tickInterval < 0 {
+		log.Fatal("Invalid -tick: must not be negative")
+	}
+
+	if *mapPath != "" {
+		loaded, err := layout.Load(*mapPath)
+		if err != nil {
+			log.Fatalf("Invalid -map: %v", err)
+		}
+		cfg.gameMap = &loaded
+	}
+
+	if *roundLength < 0 || *countdown < 0 || *resultsTime < 0 {
+		log.Fatal("Invalid -round, -countdown or -results: must not be negative")
+	}
+	if *roundLength > 0 {
+		if cfg.gameMap == nil || len(cfg.gameMap.Layout.Goals) == 0 {
+			log.Fatal("-round needs a -map with goal zones")
+		}
+		if *rounds < 1 || *minPlayers < 1 {
+			log.Fatal("Invalid -rounds or -min-players: must be at least 1")
+		}
+		if cfg.nodeID != "" || cfg.backplaneSpec != "" || cfg.replicaCount > 0 {
+			log.Fatal("-round cannot be combined with -node, -backplane or -replicas")
+		}
+		cfg.match.Start = cfg.gameMap.Start
+	}
+
+	return cfg
+}
+
+// newRoom creates a game state on the map with its controller and WebSocket hub
+func (s *server) newRoom() (*models.GameState, *concurrency.ConcurrencyController, *websocket.Hub) {
+	gameState := models.NewGameState(models.Position{X: 20, Y: 20})
+	if s.gameMap != nil {
+		gameState = s.gameMap.NewGameState()
+	}
 
-	// Initialize concurrency controller
 	controller := concurrency.NewConcurrencyController(gameState)
+	controller.SetThinkTime(s.delayPolicy)
+	controller.SetLockingStrategy(s.strategy)
+	controller.SetIsolationLevel(s.isolationLevel)
 
-	// Initialize WebSocket hub
 	hub := websocket.NewHub(gameState, controller)
-	go hub.Run()
+	hub.SetRateLimits(websocket.RateLimits{
+		PerConnection: s.connectionLimit,
+		PerPlayer:     s.playerLimit,
+	})
+	return gameState, controller, hub
+}
+
+// setupCluster starts replicated mode, in which commits go through a Raft
+// log shared with the peers
+func (s *server) setupCluster() {
+	if s.nodeID == "" {
+		return
+	}
+
+	peers, err := cluster.ParsePeers(s.peerList, s.nodeID)
+	if err != nil {
+		log.Fatalf("Invalid -peers: %v", err)
+	}
+
+	// Every node must agree on the replicated object
+	s.gameState.Object.ID = "replicated-object"
+
+	// This node only enforces its own links: to each peer and to its clients
+	network := netsim.New()
+	for peer := range peers {
+		network.AddLink(s.nodeID, peer)
+	}
+	network.AddLink(s.nodeID, netsim.Clients)
+	s.network = network
+
+	hub := s.hub
+	member := cluster.New(raft.DefaultConfig(s.nodeID, peers), raft.NewHTTPTransport(200*time.Millisecond), s.controller)
+	member.SetLinks(network)
+	member.OnApplied(hub.StateChanged)
+	member.OnProgress(hub.NetworkChanged)
+	member.Node().SetOnChange(func() {
+		hub.ClusterChanged()
+		hub.NetworkChanged()
+	})
+	hub.SetClusterStatus(member.Node().Status)
+	network.SetProgress(s.nodeID, member.Progress)
+	network.OnChange(hub.NetworkChanged)
+	hub.SetNetworkStatus(network.Status, func() bool { return network.Connected(s.nodeID, netsim.Clients) })
+	member.Register(http.DefaultServeMux)
+	member.Start()
+	s.member = member
+}
+
+// setupBackplane starts horizontally scaled mode, in which the hubs of every
+// instance share the room
+func (s *server) setupBackplane() {
+	if s.backplaneSpec == "" {
+		return
+	}
+
+	bus, err := backplane.Open(s.backplaneSpec)
+	if err != nil {
+		log.Fatalf("Invalid -backplane: %v", err)
+	}
+	if err := s.hub.UseBackplane(bus, s.roomNames[0]); err != nil {
+		log.Fatalf("Failed to join room %s: %v", s.roomNames[0], err)
+	}
+}
+
+// setupReplicas starts eventually consistent mode: independent in-process
+// replicas of the room, the first of which also serves the HTTP APIs
+func (s *server) setupReplicas() {
+	if s.replicaCount == 0 {
+		return
+	}
+
+	replicas := replica.NewSet(replica.Config{Rule: s.mergeRule, Delay: s.replicationDelay})
+	network := netsim.New()
+	replicas.SetLinks(network)
+	network.SetProgress("", replicas.Progress)
+
+	for i := 1; i <= s.replicaCount; i++ {
+		id := fmt.Sprintf("r%d", i)
+		for j := 1; j < i; j++ {
+			network.AddLink(fmt.Sprintf("r%d", j), id)
+		}
+		network.AddLink(id, netsim.Clients)
+
+		replicaState, replicaController, replicaHub := s.gameState, s.controller, s.hub
+		if i > 1 {
+			replicaState, replicaController, replicaHub = s.newRoom()
+		}
+
+		// Every replica must agree on the replicated object
+		replicaState.Object.ID = "replicated-object"
+
+		member := replicas.Add(id, replicaState, replicaController)
+		s.roomSettings.AddRoom(id, replicaController)
+		member.OnApplied(replicaHub.StateChanged)
+		replicaHub.SetReplicaStatus(func() models.ReplicaStatus { return replicas.Status(id) })
+		replicaHub.SetNetworkStatus(network.Status, func() bool { return network.Connected(id, netsim.Clients) })
+		s.hubs[id] = replicaHub
+	}
+	replicas.OnChange(func() {
+		for _, replicaHub := range s.hubs {
+			replicaHub.ReplicaChanged()
+			replicaHub.NetworkChanged()
+		}
+	})
+	network.OnChange(func() {
+		for _, replicaHub := range s.hubs {
+			replicaHub.NetworkChanged()
+		}
+	})
+	replicas.Register(http.DefaultServeMux)
+	replicas.Start()
+	s.replicas = replicas
+	s.network = network
+}
 
-	// Routes
+// setupRooms hosts every room of -rooms. With several, the first is the one
+// served by default and by the HTTP APIs, and a coordinator moves objects
+// across rooms atomically. Sagas move the objects of every room step by
+// step, in any mode, and each room's settings can be changed while it runs.
+func (s *server) setupRooms() {
+	if len(s.roomNames) > 1 {
+		coordinator := twopc.NewCoordinator()
+		for i, name := range s.roomNames {
+			roomController, roomHub := s.controller, s.hub
+			if i > 0 {
+				_, roomController, roomHub = s.newRoom()
+			}
+			coordinator.AddParticipant(name, twopc.NewRoom(name, roomController, roomHub.StateChanged))
+			roomHub.SetCoordinatorStatus(coordinator.Status)
+			s.roomHubs[name] = roomHub
+			s.roomControllers[name] = roomController
+			s.roomSettings.AddRoom(name, roomController)
+		}
+		coordinator.OnChange(func() {
+			for _, roomHub := range s.roomHubs {
+				roomHub.CoordinatorChanged()
+			}
+		})
+		coordinator.Register(http.DefaultServeMux)
+		s.coordinator = coordinator
+	} else if s.replicas == nil {
+		s.roomSettings.AddRoom(s.roomNames[0], s.controller)
+	}
+	s.roomSettings.Register(http.DefaultServeMux)
+
+	s.everyHub = []*websocket.Hub{s.hub}
+	for _, other := range s.hubs {
+		if other != s.hub {
+			s.everyHub = append(s.everyHub, other)
+		}
+	}
+	for _, other := range s.roomHubs {
+		if other != s.hub {
+			s.everyHub = append(s.everyHub, other)
+		}
+	}
+
+	sagas := saga.New()
+	if s.coordinator == nil {
+		sagas.AddRoom(s.roomNames[0], s.controller, s.hub.StateChanged)
+	}
+	for name, roomHub := range s.roomHubs {
+		sagas.AddRoom(name, s.roomControllers[name], roomHub.StateChanged)
+	}
+	sagas.OnTransition(func(current models.Saga) {
+		for _, each := range s.everyHub {
+			each.BroadcastSaga(current)
+		}
+	})
+	sagas.Register(http.DefaultServeMux)
+}
+
+// setupHubs gives every hub its logical clocks, tick and match settings and
+// starts it. Every room is a process with its own clocks: the node in
+// replicated mode, the replica or the room otherwise.
+func (s *server) setupHubs() {
+	tracker := causality.NewTracker()
+	for _, each := range s.everyHub {
+		process := s.roomNames[0]
+		for id, replicaHub := range s.hubs {
+			if replicaHub == each {
+				process = id
+			}
+		}
+		for name, roomHub := range s.roomHubs {
+			if roomHub == each {
+				process = name
+			}
+		}
+		if s.nodeID != "" {
+			process = s.nodeID
+		}
+		each.SetCausality(tracker, process)
+
+		if s.tickInterval > 0 {
+			each.SetTick(s.tickInterval, s.tickRule)
+		}
+		if s.match.Round > 0 {
+			each.SetMatch(s.match)
+		}
+		go each.Run()
+	}
+	tracker.Register(http.DefaultServeMux)
+}
+
+// setupHTTP registers the routes that are not part of a mode
+func (s *server) setupHTTP() {
 	http.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
 		w.Write([]byte("Server is running"))
 	})
 
-	http.HandleFunc("/ws", hub.ServeWS)
+	http.HandleFunc("/ws", func(w http.ResponseWriter, r *http.Request) {
+		// Clients of the eventually consistent mode pick a replica
+		if id := r.URL.Query().Get("replica"); id != "" && s.replicas != nil {
+			replicaHub, ok := s.hubs[id]
+			if !ok {
+				http.Error(w, "unknown replica "+id, http.StatusNotFound)
+				return
+			}
+			replicaHub.ServeWS(w, r)
+			return
+		}
+		// Clients of the multi-room mode pick a room
+		if name := r.URL.Query().Get("room"); name != "" && s.coordinator != nil {
+			roomHub, ok := s.roomHubs[name]
+			if !ok {
+				http.Error(w, "unknown room "+name, http.StatusNotFound)
+				return
+			}
+			roomHub.ServeWS(w, r)
+			return
+		}
+		s.hub.ServeWS(w, r)
+	})
+
+	// Conditional updates over plain HTTP, sharing the game's object and controller
+	httpapi.NewObjectAPI(s.gameState, s.controller, s.hub.StateChanged).Register(http.DefaultServeMux)
+	httpapi.NewLocksAPI(s.controller).Register(http.DefaultServeMux)
+	httpapi.NewScenariosAPI(s.controller).Register(http.DefaultServeMux)
+
+	if s.network != nil {
+		s.network.Register(http.DefaultServeMux)
+	}
+
+	// JSON Schema for every message type, for frontend and bot validation
+	http.Handle("/schema/", http.StripPrefix("/schema/", protocol.SchemaHandler()))
 
 	// Serve static files for frontend
 	http.Handle("/", http.FileServer(http.Dir("../../frontend/build/")))
+}
 
-	log.Printf("Server starting on :8080")
-	log.Printf("WebSocket endpoint: ws://localhost:8080/ws")
-	log.Printf("Health check: http://localhost:8080/health")
+// logEndpoints logs where everything is served and how the rooms are set up
+func (s *server) logEndpoints() {
+	host := s.addr
+	if strings.HasPrefix(host, ":") {
+		host = "localhost" + host
+	}
 
-	log.Fatal(http.ListenAndServe(":8080", nil))
+	log.Printf("Server starting on %s", s.addr)
+	log.Printf("WebSocket endpoint: ws://%s/ws", host)
+	log.Printf("Health check: http://%s/health", host)
+	log.Printf("Object API: http://%s/object", host)
+	log.Printf("Wait-for graph: http://%s/locks", host)
+	log.Printf("Anomaly scenarios: http://%s/scenarios", host)
+	log.Printf("Room settings: http://%s/rooms", host)
+	log.Printf("Message schemas: http://%s/schema/", host)
+	if s.member != nil {
+		log.Printf("Cluster status: http://%s/cluster/status (node %s)", host, s.nodeID)
+	}
+	if s.replicas != nil {
+		log.Printf("Replicas: %d merging by %s, /ws?replica=r1 ... and http://%s/replicas", s.replicaCount, s.mergeRule, host)
+	}
+	if s.coordinator != nil {
+		log.Printf("Rooms: %s, /ws?room=%s ... and two-phase commit at http://%s/twopc", strings.Join(s.roomNames, ", "), s.roomNames[0], host)
+	}
+	log.Printf("Happens-before history: http://%s/causality", host)
+	log.Printf("Sagas: http://%s/sagas over %s", host, strings.Join(s.roomNames, ", "))
+	if s.network != nil {
+		log.Printf("Network simulator: http://%s/network", host)
+	}
+	if s.backplaneSpec != "" {
+		backplaneURL := s.backplaneSpec
+		if parsed, err := url.Parse(backplaneURL); err == nil {
+			backplaneURL = parsed.Redacted()
+		}
+		log.Printf("Backplane: room %s over %s", s.roomNames[0], backplaneURL)
+	}
+	if s.gameMap != nil {
+		log.Printf("Map: %s, %dx%d with %d walls and %d goal zones", s.gameMap.Layout.Name, s.gameMap.Size.X, s.gameMap.Size.Y,
+			len(s.gameMap.Layout.Walls), len(s.gameMap.Layout.Goals))
+	}
+	if s.match.Round > 0 {
+		log.Printf("Match mode: %d rounds of %v for %d teams, waiting for %d players", s.match.Rounds, s.match.Round,
+			len(s.gameMap.Layout.Goals), s.match.MinPlayers)
+	}
+	if s.tickInterval > 0 {
+		log.Printf("Tick mode: moves combine by %s every %s", s.tickRule, s.tickInterval)
+	}
+	log.Printf("Think time: %s", s.delayPolicy)
+	log.Printf("Locking strategy: %s", s.strategy)
+	log.Printf("Isolation level: %s", s.isolationLevel)
+	log.Printf("Rate limits: %s per player, %s per connection", s.playerLimit, s.connectionLimit)
 }
@@ -0,0 +1,56 @@
+// Package backplane carries messages between server instances so that hubs in
+// different processes can share rooms. A backplane is a publish/subscribe bus:
+// every subscriber of a channel receives every message published on it, in
+// one order that all subscribers agree on.
+package backplane
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// ErrClosed is returned by a backplane that has been closed
+var ErrClosed = errors.New("backplane closed")
+
+// Backplane is a publish/subscribe bus shared by server instances.
+//
+// Every subscriber of a channel receives its messages in the same order,
+// including messages it published itself. A subscription's handler is called
+// for one message at a time, so a slow handler delays only its own
+// subscription.
+type Backplane interface {
+	Publish(channel string, message []byte) error
+	Subscribe(channel string, handler func(message []byte)) (Subscription, error)
+	Close() error
+}
+
+// Subscription stops delivering messages once closed
+type Subscription interface {
+	Close() error
+}
+
+// Open connects to the backplane described by spec: "memory" for one shared
+// by the hubs of this process, or a redis://[:password@]host[:port] URL
+func Open(spec string) (Backplane, error) {
+	if spec == "memory" {
+		return NewMemory(), nil
+	}
+
+	if !strings.HasPrefix(spec, "redis://") {
+		return nil, fmt.Errorf("unknown backplane %q, use memory or redis://host:port", spec)
+	}
+
+	parsed, err := url.Parse(spec)
+	if err != nil {
+		return nil, fmt.Errorf("invalid backplane URL: %w", err)
+	}
+
+	address := parsed.Host
+	if parsed.Port() == "" {
+		address += ":6379"
+	}
+	password, _ := parsed.User.Password()
+	return NewRedis(address, password)
+}
@@ -0,0 +1,131 @@
+package backplane
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recorder collects what a subscription receives
+type recorder struct {
+	mu       sync.Mutex
+	messages []string
+}
+
+func (r *recorder) handle(message []byte) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.messages = append(r.messages, string(message))
+}
+
+func (r *recorder) received() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.messages...)
+}
+
+// waitFor polls until r has received count messages
+func (r *recorder) waitFor(t *testing.T, count int) []string {
+	t.Helper()
+	deadline := time.Now().Add(3 * time.Second)
+	for len(r.received()) < count {
+		if time.Now().After(deadline) {
+			t.Fatalf("Received %d messages, expected %d", len(r.received()), count)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	return r.received()
+}
+
+// testBackplane checks the ordering and isolation guarantees of Backplane
+func testBackplane(t *testing.T, bus Backplane) {
+	first, second, other := &recorder{}, &recorder{}, &recorder{}
+	for _, subscribe := range []struct {
+		channel  string
+		recorder *recorder
+	}{{"room:a", first}, {"room:a", second}, {"room:b", other}} {
+		if _, err := bus.Subscribe(subscribe.channel, subscribe.recorder.handle); err != nil {
+			t.Fatalf("Subscribe failed: %v", err)
+		}
+	}
+
+	// Two publishers race; both subscribers must still agree on one order
+	const perPublisher = 50
+	var wg sync.WaitGroup
+	for publisher := 0; publisher < 2; publisher++ {
+		wg.Add(1)
+		go func(publisher int) {
+			defer wg.Done()
+			for i := 0; i < perPublisher; i++ {
+				if err := bus.Publish("room:a", []byte(fmt.Sprintf("p%d-%d", publisher, i))); err != nil {
+					t.Errorf("Publish failed: %v", err)
+				}
+			}
+		}(publisher)
+	}
+	wg.Wait()
+
+	got, want := second.waitFor(t, 2*perPublisher), first.waitFor(t, 2*perPublisher)
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("Subscribers disagree on the order:\n%v\n%v", want, got)
+	}
+
+	// Each publisher's own messages keep their order
+	next := map[byte]int{}
+	for _, message := range want {
+		var publisher byte
+		var i int
+		fmt.Sscanf(message, "p%c-%d", &publisher, &i)
+		if i != next[publisher] {
+			t.Fatalf("Publisher %c's messages out of order: %v", publisher, want)
+		}
+		next[publisher]++
+	}
+
+	if received := other.received(); len(received) != 0 {
+		t.Errorf("Expected nothing on another channel, got %v", received)
+	}
+}
+
+func TestMemoryBackplane(t *testing.T) {
+	bus := NewMemory()
+	defer bus.Close()
+	testBackplane(t, bus)
+}
+
+func TestClosedSubscriptionStopsReceiving(t *testing.T) {
+	bus := NewMemory()
+	defer bus.Close()
+
+	kept, closed := &recorder{}, &recorder{}
+	bus.Subscribe("room", kept.handle)
+	subscription, _ := bus.Subscribe("room", closed.handle)
+
+	bus.Publish("room", []byte("before"))
+	closed.waitFor(t, 1)
+	subscription.Close()
+	bus.Publish("room", []byte("after"))
+
+	kept.waitFor(t, 2)
+	if received := closed.received(); len(received) != 1 {
+		t.Errorf("Expected only the message before closing, got %v", received)
+	}
+
+	bus.Close()
+	if err := bus.Publish("room", nil); err != ErrClosed {
+		t.Errorf("Expected ErrClosed after Close, got %v", err)
+	}
+}
+
+func TestOpen(t *testing.T) {
+	if bus, err := Open("memory"); err != nil {
+		t.Errorf("Expected a memory backplane, got %v", err)
+	} else {
+		bus.Close()
+	}
+
+	if _, err := Open("kafka://localhost"); err == nil {
+		t.Error("Expected an unknown backplane to be rejected")
+	}
+}
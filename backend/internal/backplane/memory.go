@@ -0,0 +1,142 @@
+package backplane
+
+import "sync"
+
+// Memory is a backplane within one process, for tests and for several hubs
+// sharing rooms in the same server
+type Memory struct {
+	mu          sync.Mutex
+	subscribers map[string]map[*memorySubscription]bool
+	closed      bool
+}
+
+// memorySubscription queues messages for its handler so that publishers never
+// wait for subscribers
+type memorySubscription struct {
+	memory  *Memory
+	channel string
+	handler func([]byte)
+
+	mu     sync.Mutex
+	queue  [][]byte
+	ready  chan struct{}
+	done   chan struct{}
+	closed bool
+}
+
+// NewMemory creates an empty in-process backplane
+func NewMemory() *Memory {
+	return &Memory{subscribers: make(map[string]map[*memorySubscription]bool)}
+}
+
+// Publish queues message for every subscriber of channel. Queuing under the
+// backplane lock gives every subscriber the same order.
+func (m *Memory) Publish(channel string, message []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.closed {
+		return ErrClosed
+	}
+
+	for subscription := range m.subscribers[channel] {
+		subscription.enqueue(append([]byte(nil), message...))
+	}
+	return nil
+}
+
+// Subscribe calls handler with every message published on channel from now on
+func (m *Memory) Subscribe(channel string, handler func(message []byte)) (Subscription, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.closed {
+		return nil, ErrClosed
+	}
+
+	subscription := &memorySubscription{
+		memory:  m,
+		channel: channel,
+		handler: handler,
+		ready:   make(chan struct{}, 1),
+		done:    make(chan struct{}),
+	}
+	if m.subscribers[channel] == nil {
+		m.subscribers[channel] = make(map[*memorySubscription]bool)
+	}
+	m.subscribers[channel][subscription] = true
+
+	go subscription.deliver()
+	return subscription, nil
+}
+
+// Close ends every subscription
+func (m *Memory) Close() error {
+	m.mu.Lock()
+	subscriptions := m.subscribers
+	m.subscribers = make(map[string]map[*memorySubscription]bool)
+	m.closed = true
+	m.mu.Unlock()
+
+	for _, channel := range subscriptions {
+		for subscription := range channel {
+			subscription.stop()
+		}
+	}
+	return nil
+}
+
+func (s *memorySubscription) enqueue(message []byte) {
+	s.mu.Lock()
+	s.queue = append(s.queue, message)
+	s.mu.Unlock()
+
+	select {
+	case s.ready <- struct{}{}:
+	default:
+	}
+}
+
+// deliver hands queued messages to the handler one at a time
+func (s *memorySubscription) deliver() {
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-s.ready:
+		}
+
+		s.mu.Lock()
+		batch := s.queue
+		s.queue = nil
+		s.mu.Unlock()
+
+		for _, message := range batch {
+			select {
+			case <-s.done:
+				return
+			default:
+			}
+			s.handler(message)
+		}
+	}
+}
+
+// Close stops delivery. A message being handled is finished.
+func (s *memorySubscription) Close() error {
+	s.memory.mu.Lock()
+	delete(s.memory.subscribers[s.channel], s)
+	s.memory.mu.Unlock()
+
+	s.stop()
+	return nil
+}
+
+func (s *memorySubscription) stop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.closed {
+		s.closed = true
+		close(s.done)
+	}
+}
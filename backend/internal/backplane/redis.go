@@ -0,0 +1,357 @@
+package backplane
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	redisDialTimeout = 2 * time.Second
+	redisIOTimeout   = 2 * time.Second
+
+	// redisRetryDelay paces reconnection attempts of a lost subscription
+	redisRetryDelay = 500 * time.Millisecond
+)
+
+// Redis is a backplane on Redis pub/sub, spoken directly over RESP. Redis
+// executes PUBLISH commands one at a time, which gives every subscriber the
+// same order. Publishing shares one connection; each subscription has its
+// own. A subscription whose connection drops reconnects and resubscribes,
+// missing whatever was published meanwhile.
+type Redis struct {
+	address  string
+	password string
+
+	mu            sync.Mutex
+	conn          *redisConn
+	subscriptions map[*redisSubscription]bool
+	closed        bool
+}
+
+// redisSubscription reads messages of one channel on a dedicated connection
+type redisSubscription struct {
+	redis   *Redis
+	channel string
+	handler func([]byte)
+
+	mu     sync.Mutex
+	conn   *redisConn
+	closed bool
+}
+
+// redisConn is a connection speaking RESP
+type redisConn struct {
+	net.Conn
+	reader *bufio.Reader
+	writer *bufio.Writer
+}
+
+// redisError is an error reply from the server
+type redisError string
+
+func (e redisError) Error() string {
+	return "redis: " + string(e)
+}
+
+// NewRedis connects to the Redis server at address, authenticating with
+// password unless it is empty
+func NewRedis(address, password string) (*Redis, error) {
+	r := &Redis{
+		address:       address,
+		password:      password,
+		subscriptions: make(map[*redisSubscription]bool),
+	}
+
+	// Fail fast if the server is not there
+	conn, err := r.dial()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := conn.do("PING"); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	r.conn = conn
+	return r, nil
+}
+
+func (r *Redis) dial() (*redisConn, error) {
+	netConn, err := net.DialTimeout("tcp", r.address, redisDialTimeout)
+	if err != nil {
+		return nil, err
+	}
+
+	conn := &redisConn{Conn: netConn, reader: bufio.NewReader(netConn), writer: bufio.NewWriter(netConn)}
+	if r.password != "" {
+		if _, err := conn.do("AUTH", r.password); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	return conn, nil
+}
+
+// Publish sends message to the subscribers of channel. A broken connection is
+// replaced on the next call.
+func (r *Redis) Publish(channel string, message []byte) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.closed {
+		return ErrClosed
+	}
+
+	if r.conn == nil {
+		conn, err := r.dial()
+		if err != nil {
+			return err
+		}
+		r.conn = conn
+	}
+
+	_, err := r.conn.do("PUBLISH", channel, string(message))
+	var serverErr redisError
+	if err != nil && !errors.As(err, &serverErr) {
+		r.conn.Close()
+		r.conn = nil
+	}
+	return err
+}
+
+// Subscribe calls handler with every message published on channel from now
+// on. It returns once the server has confirmed the subscription.
+func (r *Redis) Subscribe(channel string, handler func(message []byte)) (Subscription, error) {
+	subscription := &redisSubscription{redis: r, channel: channel, handler: handler}
+	conn, err := subscription.connect()
+	if err != nil {
+		return nil, err
+	}
+	subscription.conn = conn
+
+	r.mu.Lock()
+	if r.closed {
+		r.mu.Unlock()
+		conn.Close()
+		return nil, ErrClosed
+	}
+	r.subscriptions[subscription] = true
+	r.mu.Unlock()
+
+	go subscription.receive()
+	return subscription, nil
+}
+
+// Close closes the publishing connection and every subscription
+func (r *Redis) Close() error {
+	r.mu.Lock()
+	r.closed = true
+	subscriptions := r.subscriptions
+	r.subscriptions = make(map[*redisSubscription]bool)
+	if r.conn != nil {
+		r.conn.Close()
+		r.conn = nil
+	}
+	r.mu.Unlock()
+
+	for subscription := range subscriptions {
+		subscription.stop()
+	}
+	return nil
+}
+
+// connect opens a connection subscribed to the channel
+func (s *redisSubscription) connect() (*redisConn, error) {
+	conn, err := s.redis.dial()
+	if err != nil {
+		return nil, err
+	}
+
+	reply, err := conn.do("SUBSCRIBE", s.channel)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if fields, ok := reply.([]interface{}); !ok || len(fields) != 3 || fields[0] != "subscribe" {
+		conn.Close()
+		return nil, fmt.Errorf("redis: unexpected reply to SUBSCRIBE: %v", reply)
+	}
+
+	// Messages arrive whenever they are published
+	conn.SetDeadline(time.Time{})
+	return conn, nil
+}
+
+// receive hands messages to the handler until the subscription is closed,
+// reconnecting when the connection drops
+func (s *redisSubscription) receive() {
+	for {
+		s.mu.Lock()
+		conn, closed := s.conn, s.closed
+		s.mu.Unlock()
+		if closed {
+			return
+		}
+
+		err := s.read(conn)
+		conn.Close()
+
+		for {
+			s.mu.Lock()
+			closed = s.closed
+			s.mu.Unlock()
+			if closed {
+				return
+			}
+
+			log.Printf("Backplane subscription to %s lost (%v), reconnecting", s.channel, err)
+			time.Sleep(redisRetryDelay)
+
+			if conn, err = s.connect(); err == nil {
+				break
+			}
+		}
+
+		s.mu.Lock()
+		if s.closed {
+			conn.Close()
+		}
+		s.conn = conn
+		s.mu.Unlock()
+	}
+}
+
+// read delivers messages from conn until it fails
+func (s *redisSubscription) read(conn *redisConn) error {
+	for {
+		reply, err := conn.readReply()
+		if err != nil {
+			return err
+		}
+
+		fields, ok := reply.([]interface{})
+		if !ok || len(fields) != 3 || fields[0] != "message" {
+			continue
+		}
+		if message, ok := fields[2].(string); ok {
+			s.handler([]byte(message))
+		}
+	}
+}
+
+// Close unsubscribes by closing the subscription's connection
+func (s *redisSubscription) Close() error {
+	s.redis.mu.Lock()
+	delete(s.redis.subscriptions, s)
+	s.redis.mu.Unlock()
+
+	s.stop()
+	return nil
+}
+
+func (s *redisSubscription) stop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.closed {
+		s.closed = true
+		s.conn.Close()
+	}
+}
+
+// do sends a command and reads its reply
+func (c *redisConn) do(args ...string) (interface{}, error) {
+	c.SetDeadline(time.Now().Add(redisIOTimeout))
+	if err := c.writeCommand(args...); err != nil {
+		return nil, err
+	}
+
+	reply, err := c.readReply()
+	if err != nil {
+		return nil, err
+	}
+	if serverErr, ok := reply.(redisError); ok {
+		return nil, serverErr
+	}
+	return reply, nil
+}
+
+// writeCommand sends args as a RESP array of bulk strings
+func (c *redisConn) writeCommand(args ...string) error {
+	fmt.Fprintf(c.writer, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(c.writer, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+	return c.writer.Flush()
+}
+
+// readReply reads one RESP value: a string for simple and bulk strings, an
+// int64, nil, a redisError or a []interface{} of these
+func (c *redisConn) readReply() (interface{}, error) {
+	line, err := c.readLine()
+	if err != nil {
+		return nil, err
+	}
+	if line == "" {
+		return nil, errors.New("redis: empty reply")
+	}
+
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+
+	case '-':
+		return redisError(line[1:]), nil
+
+	case ':':
+		return strconv.ParseInt(line[1:], 10, 64)
+
+	case '$':
+		size, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, fmt.Errorf("redis: bad bulk length %q", line)
+		}
+		if size < 0 {
+			return nil, nil
+		}
+		data := make([]byte, size+2)
+		if _, err := io.ReadFull(c.reader, data); err != nil {
+			return nil, err
+		}
+		return string(data[:size]), nil
+
+	case '*':
+		count, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, fmt.Errorf("redis: bad array length %q", line)
+		}
+		if count < 0 {
+			return nil, nil
+		}
+		values := make([]interface{}, count)
+		for i := range values {
+			if values[i], err = c.readReply(); err != nil {
+				return nil, err
+			}
+		}
+		return values, nil
+	}
+
+	return nil, fmt.Errorf("redis: unexpected reply %q", line)
+}
+
+func (c *redisConn) readLine() (string, error) {
+	line, err := c.reader.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	if len(line) < 2 || line[len(line)-2] != '\r' {
+		return "", fmt.Errorf("redis: malformed line %q", line)
+	}
+	return line[:len(line)-2], nil
+}
@@ -0,0 +1,155 @@
+package backplane
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"os"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeRedis serves the pub/sub subset of Redis: PING, AUTH, PUBLISH and
+// SUBSCRIBE. Like Redis it runs one command at a time.
+type fakeRedis struct {
+	listener net.Listener
+
+	mu          sync.Mutex
+	subscribers map[string][]*redisConn
+	conns       []net.Conn
+}
+
+func newFakeRedis(t *testing.T) *fakeRedis {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+
+	server := &fakeRedis{listener: listener, subscribers: make(map[string][]*redisConn)}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			netConn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			server.mu.Lock()
+			server.conns = append(server.conns, netConn)
+			server.mu.Unlock()
+			go server.serve(&redisConn{Conn: netConn, reader: bufio.NewReader(netConn), writer: bufio.NewWriter(netConn)})
+		}
+	}()
+	return server
+}
+
+func (s *fakeRedis) address() string {
+	return s.listener.Addr().String()
+}
+
+// dropConnections disconnects every client, as a server restart would
+func (s *fakeRedis) dropConnections() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, conn := range s.conns {
+		conn.Close()
+	}
+	s.conns = nil
+	s.subscribers = make(map[string][]*redisConn)
+}
+
+func (s *fakeRedis) serve(conn *redisConn) {
+	defer conn.Close()
+	for {
+		request, err := conn.readReply()
+		if err != nil {
+			return
+		}
+		args, _ := request.([]interface{})
+		if len(args) == 0 {
+			return
+		}
+
+		s.mu.Lock()
+		switch args[0] {
+		case "PING":
+			fmt.Fprint(conn.writer, "+PONG\r\n")
+		case "AUTH":
+			fmt.Fprint(conn.writer, "+OK\r\n")
+		case "SUBSCRIBE":
+			channel := args[1].(string)
+			s.subscribers[channel] = append(s.subscribers[channel], conn)
+			fmt.Fprintf(conn.writer, "*3\r\n$9\r\nsubscribe\r\n$%d\r\n%s\r\n:1\r\n", len(channel), channel)
+		case "PUBLISH":
+			channel, message := args[1].(string), args[2].(string)
+			for _, subscriber := range s.subscribers[channel] {
+				fmt.Fprintf(subscriber.writer, "*3\r\n$7\r\nmessage\r\n$%d\r\n%s\r\n$%d\r\n%s\r\n",
+					len(channel), channel, len(message), message)
+				subscriber.writer.Flush()
+			}
+			fmt.Fprintf(conn.writer, ":%d\r\n", len(s.subscribers[channel]))
+		default:
+			fmt.Fprintf(conn.writer, "-ERR unknown command '%s'\r\n", args[0])
+		}
+		conn.writer.Flush()
+		s.mu.Unlock()
+	}
+}
+
+func TestRedisBackplane(t *testing.T) {
+	server := newFakeRedis(t)
+	bus, err := NewRedis(server.address(), "secret")
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer bus.Close()
+	testBackplane(t, bus)
+}
+
+func TestRedisSubscriptionReconnects(t *testing.T) {
+	server := newFakeRedis(t)
+	bus, err := Open("redis://" + server.address())
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer bus.Close()
+
+	received := &recorder{}
+	bus.Subscribe("room", received.handle)
+	bus.Publish("room", []byte("before"))
+	received.waitFor(t, 1)
+
+	server.dropConnections()
+
+	// The first publish after the drop may find the old connection dead, and
+	// messages published before the subscription is back are lost
+	deadline := time.Now().Add(5 * time.Second)
+	for len(received.received()) < 2 {
+		if time.Now().After(deadline) {
+			t.Fatal("Subscription did not recover")
+		}
+		bus.Publish("room", []byte("after"))
+		time.Sleep(50 * time.Millisecond)
+	}
+	if got := received.received(); got[len(got)-1] != "after" {
+		t.Errorf("Expected messages after reconnecting, got %v", got)
+	}
+}
+
+// TestRealRedisBackplane runs against a local redis-server, e.g.
+// REDIS_ADDR=localhost:6379 go test ./internal/backplane
+func TestRealRedisBackplane(t *testing.T) {
+	address := os.Getenv("REDIS_ADDR")
+	if address == "" {
+		t.Skip("REDIS_ADDR not set")
+	}
+
+	bus, err := NewRedis(address, os.Getenv("REDIS_PASSWORD"))
+	if err != nil {
+		t.Fatalf("Failed to connect to %s: %v", address, err)
+	}
+	defer bus.Close()
+	testBackplane(t, bus)
+}
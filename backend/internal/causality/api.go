@@ -0,0 +1,56 @@
+package causality
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+)
+
+// defaultHistoryLimit is how many events GET /causality returns by default
+const defaultHistoryLimit = 100
+
+// Register adds the causality routes to mux:
+//
+//	GET /causality               the most recent events, at most ?limit=,
+//	                             in happens-before order with their messages
+//	GET /causality/compare       how the events ?a= and ?b= relate
+func (t *Tracker) Register(mux *http.ServeMux) {
+	mux.HandleFunc("GET /causality", t.history)
+	mux.HandleFunc("GET /causality/compare", t.compare)
+}
+
+func (t *Tracker) history(w http.ResponseWriter, r *http.Request) {
+	limit := defaultHistoryLimit
+	if value := r.URL.Query().Get("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 || parsed > historySize {
+			http.Error(w, "limit must be between 1 and "+strconv.Itoa(historySize), http.StatusBadRequest)
+			return
+		}
+		limit = parsed
+	}
+	writeJSON(w, http.StatusOK, t.History(limit))
+}
+
+func (t *Tracker) compare(w http.ResponseWriter, r *http.Request) {
+	a, errA := strconv.ParseUint(r.URL.Query().Get("a"), 10, 64)
+	b, errB := strconv.ParseUint(r.URL.Query().Get("b"), 10, 64)
+	if errA != nil || errB != nil {
+		http.Error(w, "a and b must be event IDs", http.StatusBadRequest)
+		return
+	}
+
+	order, err := t.Compare(a, b)
+	if errors.Is(err, ErrUnknownEvent) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	writeJSON(w, http.StatusOK, order)
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
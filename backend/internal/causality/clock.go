@@ -0,0 +1,81 @@
+package causality
+
+// VectorClock counts, per replica, the writes of that replica that happened
+// before a point
+type VectorClock map[string]uint64
+
+// Ordering is how two vector clocks relate
+type Ordering int
+
+const (
+	Equal Ordering = iota
+	Before
+	After
+	Concurrent
+)
+
+// Copy returns an independent copy of v
+func (v VectorClock) Copy() VectorClock {
+	copied := make(VectorClock, len(v))
+	for id, count := range v {
+		copied[id] = count
+	}
+	return copied
+}
+
+// Merge raises v to include everything other has seen
+func (v VectorClock) Merge(other VectorClock) {
+	for id, count := range other {
+		if count > v[id] {
+			v[id] = count
+		}
+	}
+}
+
+// Compare reports whether v happened before, after or concurrently with other
+func (v VectorClock) Compare(other VectorClock) Ordering {
+	less, greater := false, false
+	for id, count := range v {
+		if count > other[id] {
+			greater = true
+		}
+	}
+	for id, count := range other {
+		if count > v[id] {
+			less = true
+		}
+	}
+
+	switch {
+	case less && greater:
+		return Concurrent
+	case less:
+		return Before
+	case greater:
+		return After
+	}
+	return Equal
+}
+
+// Missing counts the writes other has seen that v has not
+func (v VectorClock) Missing(other VectorClock) int {
+	missing := 0
+	for id, count := range other {
+		if count > v[id] {
+			missing += int(count - v[id])
+		}
+	}
+	return missing
+}
+
+func (o Ordering) String() string {
+	switch o {
+	case Before:
+		return "before"
+	case After:
+		return "after"
+	case Concurrent:
+		return "concurrent"
+	}
+	return "equal"
+}
@@ -0,0 +1,25 @@
+package causality
+
+import "testing"
+
+func TestVectorClockCompare(t *testing.T) {
+	tests := []struct {
+		a, b VectorClock
+		want Ordering
+	}{
+		{VectorClock{}, VectorClock{}, Equal},
+		{VectorClock{"r1": 1}, VectorClock{"r1": 1, "r2": 0}, Equal},
+		{VectorClock{"r1": 1}, VectorClock{"r1": 2}, Before},
+		{VectorClock{"r1": 2, "r2": 1}, VectorClock{"r1": 1}, After},
+		{VectorClock{"r1": 1}, VectorClock{"r2": 1}, Concurrent},
+	}
+	for _, test := range tests {
+		if got := test.a.Compare(test.b); got != test.want {
+			t.Errorf("%v vs %v: expected %d, got %d", test.a, test.b, test.want, got)
+		}
+	}
+
+	if missing := (VectorClock{"r1": 1}).Missing(VectorClock{"r1": 3, "r2": 2}); missing != 4 {
+		t.Errorf("Expected 4 missing writes, got %d", missing)
+	}
+}
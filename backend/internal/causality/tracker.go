@@ -0,0 +1,250 @@
+// Package causality gives events logical time. Every process, a player's
+// client or a server room, has a Lamport clock and a vector clock; a Tracker
+// keeps both for every process it hears of and records recent events, so
+// that moves, commits and conflicts can be ordered across clients whose wall
+// clocks disagree.
+//
+// A server room is a single process even though it handles moves
+// concurrently: the tracker serializes its events, which is one of the
+// orders its goroutines could have run in.
+package causality
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/AnishMulay/Transaction-Conflict-Visualization/pkg/models"
+)
+
+// historySize is how many recent events a tracker keeps
+const historySize = 500
+
+// Kinds of events
+const (
+	KindSend     = "send"
+	KindReceive  = "receive"
+	KindCommit   = "commit"
+	KindConflict = "conflict"
+	KindAbort    = "abort"
+)
+
+// ErrUnknownEvent is returned for events that are not, or no longer, in the
+// history
+var ErrUnknownEvent = errors.New("unknown event")
+
+// Stamp is the logical time of an event, carried by the messages it sends
+type Stamp struct {
+	Lamport uint64
+	Clock   VectorClock
+}
+
+// Event describes what happened; the tracker fills in the rest
+type Event struct {
+	Label         string
+	TransactionID string
+	RequestID     string
+}
+
+// process is the logical time of one process after its latest event
+type process struct {
+	lamport uint64
+	clock   VectorClock
+}
+
+// Tracker keeps the logical clocks of every process and their recent events
+type Tracker struct {
+	mu          sync.Mutex
+	processes   map[string]*process
+	order       []string
+	events      []models.CausalEvent
+	nextEvent   uint64
+	nextMessage uint64
+}
+
+// NewTracker creates a tracker that has heard of no process
+func NewTracker() *Tracker {
+	return &Tracker{processes: make(map[string]*process)}
+}
+
+// Local records an event inside process
+func (t *Tracker) Local(name, kind string, event Event) Stamp {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	p := t.process(name)
+	p.tick(name)
+	t.record(name, kind, "", 0, event, p, false)
+	return p.stamp()
+}
+
+// Send records process sending a message to peer and returns the stamp the
+// message carries
+func (t *Tracker) Send(name, peer string, event Event) Stamp {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	p := t.process(name)
+	p.tick(name)
+	t.nextMessage++
+	t.record(name, KindSend, peer, t.nextMessage, event, p, false)
+	return p.stamp()
+}
+
+// Receive records process receiving a message from sender stamped with sent,
+// along with the send itself. A message without a stamp is taken to have
+// been sent right after the sender's previous send.
+func (t *Tracker) Receive(name, sender string, sent Stamp, event Event) Stamp {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	// The sender's side, as reported or made up. A reported stamp that went
+	// backwards is moved forward to keep the sender's events in order.
+	from := t.process(sender)
+	reported := sent.Lamport > 0
+	own := from.clock[sender]
+	if reported {
+		from.lamport = max(from.lamport+1, sent.Lamport)
+		from.clock.Merge(sent.Clock)
+	} else {
+		from.lamport++
+	}
+	from.clock[sender] = max(own+1, from.clock[sender])
+	t.nextMessage++
+	message := t.nextMessage
+	t.record(sender, KindSend, name, message, event, from, !reported)
+
+	// The receiver merges what the sender knew
+	p := t.process(name)
+	p.lamport = max(p.lamport, from.lamport)
+	p.clock.Merge(from.clock)
+	p.tick(name)
+	t.record(name, KindReceive, sender, message, event, p, false)
+	return p.stamp()
+}
+
+// Now returns the logical time of process without recording an event, for
+// messages that are not worth a place in the history
+func (t *Tracker) Now(name string) Stamp {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.process(name).stamp()
+}
+
+// process returns the clocks of a process, creating them when it is new.
+// Caller must hold t.mu.
+func (t *Tracker) process(name string) *process {
+	p, ok := t.processes[name]
+	if !ok {
+		p = &process{clock: VectorClock{}}
+		t.processes[name] = p
+		t.order = append(t.order, name)
+	}
+	return p
+}
+
+// tick advances the clocks of the process called name for its next event
+func (p *process) tick(name string) {
+	p.lamport++
+	p.clock[name]++
+}
+
+// stamp returns a copy of the process's logical time
+func (p *process) stamp() Stamp {
+	return Stamp{Lamport: p.lamport, Clock: p.clock.Copy()}
+}
+
+// record adds an event of a process whose clocks were just advanced to the
+// history. Caller must hold t.mu.
+func (t *Tracker) record(name, kind, peer string, message uint64, event Event, p *process, inferred bool) {
+	t.nextEvent++
+	t.events = append(t.events, models.CausalEvent{
+		ID:            t.nextEvent,
+		Process:       name,
+		Kind:          kind,
+		Lamport:       p.lamport,
+		Clock:         p.clock.Copy(),
+		Peer:          peer,
+		Message:       message,
+		Label:         event.Label,
+		TransactionID: event.TransactionID,
+		RequestID:     event.RequestID,
+		Inferred:      inferred,
+		Timestamp:     time.Now(),
+	})
+	if len(t.events) > historySize {
+		t.events = t.events[len(t.events)-historySize:]
+	}
+}
+
+// History returns up to limit of the most recent events, ordered by Lamport
+// time with ties broken by process, which is consistent with happens-before
+func (t *Tracker) History(limit int) models.CausalHistory {
+	t.mu.Lock()
+	first := 0
+	if limit > 0 && len(t.events) > limit {
+		first = len(t.events) - limit
+	}
+	events := append([]models.CausalEvent(nil), t.events[first:]...)
+	t.mu.Unlock()
+
+	sort.SliceStable(events, func(i, j int) bool {
+		if events[i].Lamport != events[j].Lamport {
+			return events[i].Lamport < events[j].Lamport
+		}
+		return events[i].Process < events[j].Process
+	})
+
+	history := models.CausalHistory{
+		Processes: []string{},
+		Events:    events,
+		Messages:  []models.CausalMessage{},
+		Timestamp: time.Now(),
+	}
+	seen := make(map[string]bool)
+	sends := make(map[uint64]uint64)
+	for _, event := range events {
+		if !seen[event.Process] {
+			seen[event.Process] = true
+			history.Processes = append(history.Processes, event.Process)
+		}
+		switch event.Kind {
+		case KindSend:
+			if event.Message != 0 {
+				sends[event.Message] = event.ID
+			}
+		case KindReceive:
+			if send, ok := sends[event.Message]; ok {
+				history.Messages = append(history.Messages, models.CausalMessage{Send: send, Receive: event.ID})
+			}
+		}
+	}
+	return history
+}
+
+// Compare reports how the events with IDs a and b relate by their vector
+// clocks
+func (t *Tracker) Compare(a, b uint64) (models.CausalOrder, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var order models.CausalOrder
+	var found int
+	for _, event := range t.events {
+		if event.ID == a {
+			order.A = event
+			found++
+		}
+		if event.ID == b {
+			order.B = event
+			found++
+		}
+	}
+	if found < 2 {
+		return models.CausalOrder{}, ErrUnknownEvent
+	}
+
+	// Every event advances its process's entry, so only an event compared
+	// with itself is equal
+	order.Relation = VectorClock(order.A.Clock).Compare(order.B.Clock).String()
+	return order, nil
+}
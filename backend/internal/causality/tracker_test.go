@@ -0,0 +1,105 @@
+package causality
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+// eventOf returns the latest event of a kind in a process
+func eventOf(t *testing.T, tracker *Tracker, process, kind string) uint64 {
+	t.Helper()
+	history := tracker.History(0)
+	for i := len(history.Events) - 1; i >= 0; i-- {
+		if event := history.Events[i]; event.Process == process && event.Kind == kind {
+			return event.ID
+		}
+	}
+	t.Fatalf("No %s event in %s", kind, process)
+	return 0
+}
+
+func relation(t *testing.T, tracker *Tracker, a, b uint64) string {
+	t.Helper()
+	order, err := tracker.Compare(a, b)
+	if err != nil {
+		t.Fatalf("Compare failed: %v", err)
+	}
+	return order.Relation
+}
+
+func TestMessagesOrderEventsAcrossProcesses(t *testing.T) {
+	tracker := NewTracker()
+
+	// alice's move is committed and acknowledged
+	tracker.Receive("server", "alice", Stamp{}, Event{Label: "move up"})
+	tracker.Local("server", KindCommit, Event{Label: "v2"})
+	ack := tracker.Send("server", "alice", Event{Label: "moveAck"})
+	commit := eventOf(t, tracker, "server", KindCommit)
+
+	// bob moves after seeing the ack; carol moves without knowing of it
+	bobClock := ack.Clock.Copy()
+	bobClock["bob"] = 1
+	tracker.Receive("server", "bob", Stamp{Lamport: ack.Lamport + 1, Clock: bobClock}, Event{Label: "move left"})
+	bobSend := eventOf(t, tracker, "bob", KindSend)
+	tracker.Receive("server", "carol", Stamp{}, Event{Label: "move down"})
+	carolSend := eventOf(t, tracker, "carol", KindSend)
+
+	if got := relation(t, tracker, commit, bobSend); got != "before" {
+		t.Errorf("Expected the commit before bob's move, got %s", got)
+	}
+	if got := relation(t, tracker, carolSend, commit); got != "concurrent" {
+		t.Errorf("Expected carol's move concurrent with the commit, got %s", got)
+	}
+	if got := relation(t, tracker, eventOf(t, tracker, "server", KindReceive), carolSend); got != "after" {
+		t.Errorf("Expected the server to receive carol's move after she sent it, got %s", got)
+	}
+
+	history := tracker.History(0)
+	// carol's made-up send is as early as alice's
+	if fmt.Sprint(history.Processes) != "[alice carol server bob]" {
+		t.Errorf("Expected processes in order of their first event, got %v", history.Processes)
+	}
+	if len(history.Messages) != 3 {
+		t.Fatalf("Expected the three moves paired with their receives, got %+v", history.Messages)
+	}
+	lamport := map[uint64]uint64{}
+	for i, event := range history.Events {
+		lamport[event.ID] = event.Lamport
+		if i > 0 && event.Lamport < history.Events[i-1].Lamport {
+			t.Errorf("Expected events in Lamport order, got %+v", history.Events)
+		}
+		if event.Inferred != (event.Process == "alice" || event.Process == "carol") {
+			t.Errorf("Expected only the unstamped sends inferred, got %+v", event)
+		}
+	}
+	for _, message := range history.Messages {
+		if lamport[message.Receive] <= lamport[message.Send] {
+			t.Errorf("Expected every receive later than its send, got %+v", message)
+		}
+	}
+}
+
+func TestReportedStampCannotGoBackwards(t *testing.T) {
+	tracker := NewTracker()
+
+	tracker.Receive("server", "alice", Stamp{Lamport: 10, Clock: VectorClock{"alice": 3}}, Event{})
+	tracker.Receive("server", "alice", Stamp{Lamport: 4, Clock: VectorClock{"alice": 1}}, Event{})
+
+	history := tracker.History(0)
+	var sends []uint64
+	for _, event := range history.Events {
+		if event.Process == "alice" {
+			sends = append(sends, event.Lamport)
+			if event.Lamport == 11 && event.Clock["alice"] != 4 {
+				t.Errorf("Expected alice's second send to advance her own entry, got %v", event.Clock)
+			}
+		}
+	}
+	if len(sends) != 2 || sends[0] != 10 || sends[1] != 11 {
+		t.Errorf("Expected alice's sends at Lamport 10 and 11, got %v", sends)
+	}
+	if _, err := tracker.Compare(1, 99); !errors.Is(err, ErrUnknownEvent) {
+		t.Errorf("Expected an unknown event, got %v", err)
+	}
+}
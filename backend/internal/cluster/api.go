@@ -0,0 +1,86 @@
+package cluster
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/AnishMulay/Transaction-Conflict-Visualization/internal/raft"
+)
+
+const (
+	defaultFailure = 5 * time.Second
+	maxFailure     = time.Minute
+)
+
+// Register adds the Raft RPC routes and the cluster routes to mux:
+//
+//	POST /cluster/propose  the leader's end of commit forwarding
+//	GET  /cluster/status   this node's view of the cluster
+//	GET  /cluster/progress how far this node has got, for its peers
+//	POST /cluster/fail     simulate a crash of this node for ?duration=
+func (c *Cluster) Register(mux *http.ServeMux) {
+	raft.Register(mux, c.node)
+	mux.HandleFunc("POST /cluster/propose", c.propose)
+	mux.HandleFunc("GET /cluster/status", c.status)
+	mux.HandleFunc("GET /cluster/progress", c.progress)
+	mux.HandleFunc("POST /cluster/fail", c.fail)
+}
+
+func (c *Cluster) propose(w http.ResponseWriter, r *http.Request) {
+	if from := r.Header.Get(nodeHeader); from != "" && !c.connected(from) {
+		http.Error(w, "link to "+from+" is cut", http.StatusServiceUnavailable)
+		return
+	}
+
+	command, err := io.ReadAll(r.Body)
+	if err != nil || !json.Valid(command) {
+		http.Error(w, "command must be JSON", http.StatusBadRequest)
+		return
+	}
+
+	index, term, err := c.node.Propose(command)
+	if errors.Is(err, raft.ErrNotLeader) {
+		http.Error(w, err.Error(), http.StatusMisdirectedRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, proposal{Index: index, Term: term})
+}
+
+func (c *Cluster) status(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, c.node.Status())
+}
+
+func (c *Cluster) progress(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, c.localProgress())
+}
+
+// fail takes this node down for ?duration= (default 5s, at most a minute).
+// The other nodes keep serving while a majority remains.
+func (c *Cluster) fail(w http.ResponseWriter, r *http.Request) {
+	duration := defaultFailure
+	if value := r.URL.Query().Get("duration"); value != "" {
+		parsed, err := time.ParseDuration(value)
+		if err != nil || parsed <= 0 || parsed > maxFailure {
+			http.Error(w, "duration must be a positive duration of at most 1m", http.StatusBadRequest)
+			return
+		}
+		duration = parsed
+	}
+
+	c.node.Fail(duration)
+	writeJSON(w, http.StatusOK, c.node.Status())
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
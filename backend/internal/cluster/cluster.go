@@ -0,0 +1,294 @@
+// Package cluster replicates a room's commits across server processes. Each
+// process runs a Raft node whose state machine is the concurrency
+// controller: moves run as transactions on any node, and their commits are
+// applied on every node in log order before the mover is acknowledged.
+package cluster
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/AnishMulay/Transaction-Conflict-Visualization/internal/concurrency"
+	"github.com/AnishMulay/Transaction-Conflict-Visualization/internal/raft"
+	"github.com/AnishMulay/Transaction-Conflict-Visualization/pkg/models"
+)
+
+const (
+	// commitTimeout bounds how long a commit waits to be replicated and applied
+	commitTimeout = 3 * time.Second
+	// progressInterval is how often a node asks its peers how far they have got
+	progressInterval = 250 * time.Millisecond
+	// nodeHeader names the node a forwarded commit comes from
+	nodeHeader = "X-Cluster-Node"
+)
+
+// Cluster is this process's membership in a replicated room. It implements
+// concurrency.Replicator: the leader proposes commits itself, and followers
+// forward them to the leader and wait until they apply locally.
+type Cluster struct {
+	node       *raft.Node
+	controller *concurrency.ConcurrencyController
+	client     *http.Client
+	links      raft.Links
+	onApplied  func()
+	onProgress func()
+	stop       chan struct{}
+	stopOnce   sync.Once
+
+	mu    sync.Mutex
+	peers map[string]models.ReplicaProgress
+}
+
+// applyResult is what applying a commit produced on this node
+type applyResult struct {
+	record concurrency.CommitRecord
+	err    error
+}
+
+// proposal is the leader's answer to a forwarded commit
+type proposal struct {
+	Index uint64 `json:"index"`
+	Term  uint64 `json:"term"`
+}
+
+// New creates the cluster member for controller. Call Start to join.
+func New(config raft.Config, transport raft.Transport, controller *concurrency.ConcurrencyController) *Cluster {
+	c := &Cluster{
+		controller: controller,
+		client:     &http.Client{Timeout: commitTimeout},
+		stop:       make(chan struct{}),
+		peers:      make(map[string]models.ReplicaProgress),
+	}
+	c.node = raft.NewNode(config, transport, c.apply)
+	return c
+}
+
+// ParsePeers parses a comma-separated list of id=host:port cluster members,
+// returning every member other than self
+func ParsePeers(spec, self string) (map[string]string, error) {
+	peers := make(map[string]string)
+	found := false
+
+	for _, member := range strings.Split(spec, ",") {
+		id, address, ok := strings.Cut(strings.TrimSpace(member), "=")
+		if !ok || id == "" || address == "" {
+			return nil, fmt.Errorf("invalid cluster member %q, use id=host:port", member)
+		}
+		if id == self {
+			found = true
+			continue
+		}
+		peers[id] = address
+	}
+
+	if !found {
+		return nil, fmt.Errorf("node %q is not in the member list", self)
+	}
+	return peers, nil
+}
+
+// Node returns the Raft node
+func (c *Cluster) Node() *raft.Node {
+	return c.node
+}
+
+// OnApplied registers fn to run after every commit this node applies, whoever
+// proposed it. Call it before Start.
+func (c *Cluster) OnApplied(fn func()) {
+	c.onApplied = fn
+}
+
+// OnProgress registers fn to run when a peer's reported progress changes.
+// Call it before Start.
+func (c *Cluster) OnProgress(fn func()) {
+	c.onProgress = fn
+}
+
+// SetLinks makes Raft and commit forwarding honour simulated network links.
+// Call it before Start.
+func (c *Cluster) SetLinks(links raft.Links) {
+	c.links = links
+	c.node.SetLinks(links)
+}
+
+// Start joins the cluster and routes the controller's commits through it
+func (c *Cluster) Start() {
+	c.controller.SetReplicator(c)
+	c.node.Start()
+	go c.watchPeers()
+}
+
+// Stop leaves the cluster for good
+func (c *Cluster) Stop() {
+	c.stopOnce.Do(func() { close(c.stop) })
+	c.node.Stop()
+}
+
+// connected reports whether the simulated link to peer is up
+func (c *Cluster) connected(peer string) bool {
+	return c.links == nil || c.links.Connected(c.node.ID(), peer)
+}
+
+// Replicate implements concurrency.Replicator
+func (c *Cluster) Replicate(commit concurrency.ReplicatedCommit) (concurrency.CommitRecord, error) {
+	command, err := json.Marshal(commit)
+	if err != nil {
+		return concurrency.CommitRecord{}, err
+	}
+
+	index, term, err := c.node.Propose(command)
+	var notLeader *raft.NotLeaderError
+	if errors.As(err, &notLeader) && notLeader.LeaderAddress != "" {
+		index, term, err = c.forward(notLeader.LeaderID, notLeader.LeaderAddress, command)
+	}
+	if err != nil {
+		return concurrency.CommitRecord{}, fmt.Errorf("%w: %v", concurrency.ErrUnavailable, err)
+	}
+
+	// Wait for the local copy so the mover's next read sees its own commit
+	value, err := c.node.Wait(index, term, commitTimeout)
+	if err != nil {
+		return concurrency.CommitRecord{}, fmt.Errorf("%w: %v", concurrency.ErrUnavailable, err)
+	}
+
+	result := value.(applyResult)
+	return result.record, result.err
+}
+
+// forward proposes a command on the leader
+func (c *Cluster) forward(leaderID, leader string, command []byte) (index, term uint64, err error) {
+	if !c.connected(leaderID) {
+		return 0, 0, fmt.Errorf("link to leader %s is cut", leaderID)
+	}
+
+	request, err := http.NewRequest(http.MethodPost, "http://"+leader+"/cluster/propose", bytes.NewReader(command))
+	if err != nil {
+		return 0, 0, err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set(nodeHeader, c.node.ID())
+
+	resp, err := c.client.Do(request)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return 0, 0, fmt.Errorf("leader %s refused the commit: %s", leader, resp.Status)
+	}
+
+	var accepted proposal
+	if err := json.NewDecoder(resp.Body).Decode(&accepted); err != nil {
+		return 0, 0, err
+	}
+	return accepted.Index, accepted.Term, nil
+}
+
+// apply is the Raft state machine
+func (c *Cluster) apply(entry raft.Entry) interface{} {
+	var commit concurrency.ReplicatedCommit
+	if err := json.Unmarshal(entry.Command, &commit); err != nil {
+		log.Printf("Skipping undecodable log entry %d: %v", entry.Index, err)
+		return applyResult{err: err}
+	}
+
+	record, err := c.controller.ApplyCommit(commit)
+	if err == nil && c.onApplied != nil {
+		c.onApplied()
+	}
+	return applyResult{record: record, err: err}
+}
+
+// Progress reports how far this node and, as last heard, each peer have got
+func (c *Cluster) Progress() []models.ReplicaProgress {
+	progress := []models.ReplicaProgress{c.localProgress()}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, peer := range c.node.Status().Peers {
+		known, ok := c.peers[peer.ID]
+		if !ok {
+			known = models.ReplicaProgress{ID: peer.ID}
+		}
+		progress = append(progress, known)
+	}
+	return progress
+}
+
+// localProgress is how far this node has got
+func (c *Cluster) localProgress() models.ReplicaProgress {
+	status := c.node.Status()
+	object := c.controller.Object()
+	return models.ReplicaProgress{
+		ID:        status.NodeID,
+		Role:      status.Role,
+		Applied:   status.LastApplied,
+		Version:   object.Version,
+		Position:  object.Position,
+		Reachable: true,
+	}
+}
+
+// watchPeers polls every peer's progress over the simulated links
+func (c *Cluster) watchPeers() {
+	client := &http.Client{Timeout: progressInterval}
+	ticker := time.NewTicker(progressInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.stop:
+			return
+		case <-ticker.C:
+		}
+
+		changed := false
+		for _, peer := range c.node.Status().Peers {
+			progress, err := c.fetchProgress(client, peer)
+			c.mu.Lock()
+			known := c.peers[peer.ID]
+			if err != nil {
+				// Keep the last values heard, marked as stale
+				progress = known
+				progress.ID = peer.ID
+				progress.Reachable = false
+			}
+			if progress != known {
+				c.peers[peer.ID] = progress
+				changed = true
+			}
+			c.mu.Unlock()
+		}
+
+		if changed && c.onProgress != nil {
+			c.onProgress()
+		}
+	}
+}
+
+func (c *Cluster) fetchProgress(client *http.Client, peer models.RaftPeer) (models.ReplicaProgress, error) {
+	var progress models.ReplicaProgress
+	if !c.connected(peer.ID) {
+		return progress, fmt.Errorf("link to %s is cut", peer.ID)
+	}
+
+	resp, err := client.Get("http://" + peer.Address + "/cluster/progress")
+	if err != nil {
+		return progress, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return progress, fmt.Errorf("%s: %s", peer.ID, resp.Status)
+	}
+	err = json.NewDecoder(resp.Body).Decode(&progress)
+	return progress, err
+}
@@ -0,0 +1,247 @@
+package cluster
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/AnishMulay/Transaction-Conflict-Visualization/internal/concurrency"
+	"github.com/AnishMulay/Transaction-Conflict-Visualization/internal/netsim"
+	"github.com/AnishMulay/Transaction-Conflict-Visualization/internal/raft"
+	"github.com/AnishMulay/Transaction-Conflict-Visualization/pkg/models"
+)
+
+// testNode is one server of a test cluster
+type testNode struct {
+	gameState  *models.GameState
+	controller *concurrency.ConcurrencyController
+	member     *Cluster
+	network    *netsim.Network
+}
+
+// newTestCluster starts size members talking Raft over HTTP. They share one
+// simulated network, in which every link starts connected.
+func newTestCluster(t *testing.T, size int) []*testNode {
+	t.Helper()
+
+	muxes := make([]*http.ServeMux, size)
+	addresses := make(map[string]string)
+	network := netsim.New()
+	for i := range muxes {
+		muxes[i] = http.NewServeMux()
+		server := httptest.NewServer(muxes[i])
+		t.Cleanup(server.Close)
+		addresses[fmt.Sprintf("n%d", i+1)] = strings.TrimPrefix(server.URL, "http://")
+		for j := 0; j < i; j++ {
+			network.AddLink(fmt.Sprintf("n%d", j+1), fmt.Sprintf("n%d", i+1))
+		}
+	}
+
+	var nodes []*testNode
+	for i, mux := range muxes {
+		id := fmt.Sprintf("n%d", i+1)
+		peers := make(map[string]string)
+		for peer, address := range addresses {
+			if peer != id {
+				peers[peer] = address
+			}
+		}
+
+		gameState := models.NewGameState(models.Position{X: 10, Y: 10})
+		controller := concurrency.NewConcurrencyController(gameState)
+		config := raft.Config{ID: id, Peers: peers, ElectionTimeout: 100 * time.Millisecond, HeartbeatInterval: 20 * time.Millisecond}
+		member := New(config, raft.NewHTTPTransport(100*time.Millisecond), controller)
+		member.SetLinks(network)
+		member.Register(mux)
+		member.Start()
+		t.Cleanup(member.Stop)
+
+		nodes = append(nodes, &testNode{gameState: gameState, controller: controller, member: member, network: network})
+	}
+	return nodes
+}
+
+// move runs a move as a transaction on one node
+func (n *testNode) move(direction string) (*models.GameStateSnapshot, error) {
+	transaction, _ := n.controller.BeginTransaction("player-"+n.member.Node().ID(), direction)
+	if err := n.controller.ProposeMove(transaction.ID, direction); err != nil {
+		return nil, err
+	}
+	return n.controller.CommitTransaction(transaction.ID)
+}
+
+// moveWhenAvailable retries a move while the cluster has no leader
+func (n *testNode) moveWhenAvailable(t *testing.T, direction string) *models.GameStateSnapshot {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		snapshot, err := n.move(direction)
+		if err == nil {
+			return snapshot
+		}
+		if !errors.Is(err, concurrency.ErrUnavailable) || time.Now().After(deadline) {
+			t.Fatalf("Move %s on %s failed: %v", direction, n.member.Node().ID(), err)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+// waitForObject polls until every listed node's object is at version
+func waitForObject(t *testing.T, nodes []*testNode, version int64, position models.Position) {
+	t.Helper()
+	deadline := time.Now().Add(3 * time.Second)
+	for _, node := range nodes {
+		for {
+			object := node.gameState.GetState().Object
+			if object.Version == version && object.Position == position {
+				break
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("%s has %+v, expected version %d at %+v", node.member.Node().ID(), object, version, position)
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+	}
+}
+
+func leaderOf(nodes []*testNode) *testNode {
+	for _, node := range nodes {
+		if node.member.Node().Status().Role == string(raft.RoleLeader) {
+			return node
+		}
+	}
+	return nil
+}
+
+func TestCommitsReplicateToEveryNode(t *testing.T) {
+	nodes := newTestCluster(t, 3)
+
+	// The first move waits out the election, then goes through whichever node
+	first := nodes[0].moveWhenAvailable(t, "up")
+	if first.Object.Version != 2 || first.Object.Position != (models.Position{X: 5, Y: 4}) {
+		t.Fatalf("Unexpected first commit %+v", first.Object)
+	}
+	waitForObject(t, nodes, 2, models.Position{X: 5, Y: 4})
+
+	// A follower forwards its commit to the leader
+	var follower *testNode
+	for _, node := range nodes {
+		if node != leaderOf(nodes) {
+			follower = node
+		}
+	}
+	if snapshot := follower.moveWhenAvailable(t, "left"); snapshot.Object.Version != 3 {
+		t.Fatalf("Expected the forwarded move at version 3, got %+v", snapshot.Object)
+	}
+	waitForObject(t, nodes, 3, models.Position{X: 4, Y: 4})
+
+	// The version check runs in the replicated state machine, so a stale move
+	// conflicts whichever node it runs on
+	stale, _ := follower.controller.BeginTransactionAt("stale", "r1", 2)
+	follower.controller.ProposeMove(stale.ID, "down")
+	_, err := follower.controller.CommitTransaction(stale.ID)
+	var conflict *concurrency.ConflictError
+	if !errors.As(err, &conflict) || conflict.CurrentVersion != 3 || conflict.Winner == nil {
+		t.Fatalf("Expected a conflict with a winner, got %v", err)
+	}
+	waitForObject(t, nodes, 3, models.Position{X: 4, Y: 4})
+}
+
+func TestClusterStaysAvailableWhenLeaderFails(t *testing.T) {
+	nodes := newTestCluster(t, 3)
+	nodes[0].moveWhenAvailable(t, "up")
+	// Followers read their own replica, so let it catch up first
+	waitForObject(t, nodes, 2, models.Position{X: 5, Y: 4})
+
+	leader := leaderOf(nodes)
+	if leader == nil {
+		t.Fatal("No leader after the first commit")
+	}
+	leader.member.Node().Fail(time.Second)
+
+	if _, err := leader.move("down"); !errors.Is(err, concurrency.ErrUnavailable) {
+		t.Errorf("Expected the failed node to refuse moves, got %v", err)
+	}
+
+	var survivors []*testNode
+	for _, node := range nodes {
+		if node != leader {
+			survivors = append(survivors, node)
+		}
+	}
+
+	// The other two elect a new leader and keep committing
+	survivors[0].moveWhenAvailable(t, "left")
+	waitForObject(t, survivors, 3, models.Position{X: 4, Y: 4})
+	survivors[1].moveWhenAvailable(t, "left")
+	waitForObject(t, survivors, 4, models.Position{X: 3, Y: 4})
+
+	// The failed node catches up once it is back
+	waitForObject(t, nodes, 4, models.Position{X: 3, Y: 4})
+}
+
+// waitForProgress polls until node reports peer's progress as want
+func waitForProgress(t *testing.T, node *testNode, peer string, want func(models.ReplicaProgress) bool) models.ReplicaProgress {
+	t.Helper()
+	deadline := time.Now().Add(3 * time.Second)
+	for {
+		for _, progress := range node.member.Progress() {
+			if progress.ID == peer && want(progress) {
+				return progress
+			}
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("%s never reported the expected progress of %s: %+v", node.member.Node().ID(), peer, node.member.Progress())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestPartitionedFollowerFallsBehindAndRecovers(t *testing.T) {
+	nodes := newTestCluster(t, 3)
+	nodes[0].moveWhenAvailable(t, "up")
+	waitForObject(t, nodes, 2, models.Position{X: 5, Y: 4})
+
+	leader := leaderOf(nodes)
+	var isolated, other *testNode
+	for _, node := range nodes {
+		switch {
+		case node == leader:
+		case isolated == nil:
+			isolated = node
+		default:
+			other = node
+		}
+	}
+	isolatedID := isolated.member.Node().ID()
+	waitForProgress(t, other, isolatedID, func(p models.ReplicaProgress) bool { return p.Reachable && p.Version == 2 })
+	if err := leader.network.Partition([][]string{{isolatedID}, {leader.member.Node().ID(), other.member.Node().ID()}}); err != nil {
+		t.Fatalf("Partition failed: %v", err)
+	}
+
+	// The isolated follower cannot reach a majority, the others carry on
+	if _, err := isolated.move("down"); !errors.Is(err, concurrency.ErrUnavailable) {
+		t.Errorf("Expected the isolated node to refuse moves, got %v", err)
+	}
+	majority := []*testNode{leader, other}
+	other.moveWhenAvailable(t, "left")
+	waitForObject(t, majority, 3, models.Position{X: 4, Y: 4})
+
+	// Its last known progress stays behind the majority's
+	stale := waitForProgress(t, other, isolatedID, func(p models.ReplicaProgress) bool { return !p.Reachable })
+	if stale.Version != 2 {
+		t.Errorf("Expected the isolated node last seen at version 2, got %+v", stale)
+	}
+	if object := isolated.gameState.GetState().Object; object.Version != 2 {
+		t.Errorf("Expected the isolated node to stay at version 2, got %+v", object)
+	}
+
+	// Healed, it catches up and is reported as such
+	leader.network.HealAll()
+	waitForObject(t, nodes, 3, models.Position{X: 4, Y: 4})
+	waitForProgress(t, other, isolatedID, func(p models.ReplicaProgress) bool { return p.Reachable && p.Version == 3 })
+}
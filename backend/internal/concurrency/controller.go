@@ -6,21 +6,40 @@ import (
 	"sync"
 	"time"
 
+	"github.com/AnishMulay/Transaction-Conflict-Visualization/internal/causality"
 	"github.com/AnishMulay/Transaction-Conflict-Visualization/pkg/models"
 )
 
 var (
 	ErrVersionMismatch = errors.New("version mismatch: concurrent modification detected")
 	ErrInvalidMove     = errors.New("invalid move: out of bounds")
+	ErrWall            = errors.New("invalid move: blocked by a wall")
 	ErrNoTransaction   = errors.New("no active transaction")
+	ErrNoProposal      = errors.New("no move proposed")
 )
 
-// ConcurrencyController manages optimistic concurrency control
+// commitHistorySize is how many recent commits are kept to explain conflicts
+const commitHistorySize = 256
+
+// ConcurrencyController manages concurrency control for moves, either
+// optimistically or with two-phase locking
 type ConcurrencyController struct {
 	mu                 sync.RWMutex
 	gameState          *models.GameState
 	activeTransactions map[string]*Transaction
 	conflictStats      ConflictStats
+	thinkTime          DelayPolicy
+	strategy           LockingStrategy
+	isolation          IsolationLevel
+	locks              *LockManager
+	ssi                *ssiTracker
+	commits            map[int64]CommitRecord
+	onAnomaly          func(models.Anomaly)
+	onCommit           []func(CommitRecord)
+	replicator         Replicator
+	prepared           *preparedTransaction
+	causality          *causality.Tracker
+	process            string
 }
 
 // Transaction represents an optimistic transaction
@@ -31,14 +50,102 @@ type Transaction struct {
 	InitialVersion  int64
 	ProposedChanges *models.GameObject
 	RequestID       string
+	// Strategy is the controller's locking strategy when the transaction began
+	Strategy LockingStrategy
+	// Isolation is the controller's isolation level when the transaction began
+	Isolation IsolationLevel
+	// pinned is set when the client supplied the version it read, which must
+	// then be checked even under two-phase locking
+	pinned bool
+	// retry is set when the server runs a move again after it lost the
+	// version check, so its conflicts are not counted as the client's
+	retry bool
+	// ssi holds the read and write sets of an optimistic serializable
+	// transaction, keyed by resource. It is nil at other isolation levels and
+	// under two-phase locking, whose locks already serialize moves.
+	ssi *ssiTransaction
+}
+
+// CommitRecord describes a committed transaction and the version it produced
+type CommitRecord struct {
+	TransactionID string
+	PlayerID      string
+	RequestID     string
+	Version       int64
+	Position      models.Position
+	CommittedAt   time.Time
+}
+
+// ConflictError reports a transaction that lost the optimistic version check.
+// It wraps ErrVersionMismatch.
+type ConflictError struct {
+	TransactionID    string
+	PlayerID         string
+	RequestID        string
+	ReadVersion      int64
+	CurrentVersion   int64
+	ProposedPosition models.Position
+	// Winner is the commit that first moved the object past ReadVersion.
+	// It is nil if that commit is no longer in the history.
+	Winner *CommitRecord
+}
+
+func (e *ConflictError) Error() string {
+	return fmt.Sprintf("%v: expected version %d, got %d",
+		ErrVersionMismatch, e.ReadVersion, e.CurrentVersion)
 }
 
-// ConflictStats tracks concurrency conflicts for analysis
+func (e *ConflictError) Unwrap() error {
+	return ErrVersionMismatch
+}
+
+// Response converts the conflict into the payload reported to the losing client
+func (e *ConflictError) Response() models.ConflictResponse {
+	response := models.ConflictResponse{
+		Message: fmt.Sprintf("Read version %d but the object was already at version %d",
+			e.ReadVersion, e.CurrentVersion),
+		ExpectedVersion:  e.ReadVersion,
+		ActualVersion:    e.CurrentVersion,
+		RequestID:        e.RequestID,
+		TransactionID:    e.TransactionID,
+		ProposedPosition: e.ProposedPosition,
+		Timestamp:        time.Now(),
+	}
+
+	if e.Winner != nil {
+		response.Winner = &models.ConflictWinner{
+			TransactionID: e.Winner.TransactionID,
+			PlayerID:      e.Winner.PlayerID,
+			RequestID:     e.Winner.RequestID,
+			Version:       e.Winner.Version,
+			Position:      e.Winner.Position,
+		}
+	}
+
+	return response
+}
+
+// ConflictStats tracks concurrency conflicts for analysis. ConflictCount
+// counts write-write conflicts, from stale moves and from scenario
+// transactions that lost to a concurrent writer; SSIAborts counts moves and
+// scenario transactions aborted by serializable snapshot isolation to break
+// a cycle. RateLimitedMoves counts moves over a player's move budget and
+// RateLimitedMessages counts messages of any type over a connection's budget.
+// RetriedConflicts counts conflicts of moves the server was already retrying
+// on a client's behalf, which ConflictCount leaves out.
 type ConflictStats struct {
-	TotalTransactions int64
-	ConflictCount     int64
-	SuccessfulMoves   int64
-	AverageLatency    time.Duration
+	TotalTransactions   int64         `json:"totalTransactions"`
+	ConflictCount       int64         `json:"conflictCount"`
+	RetriedConflicts    int64         `json:"retriedConflicts"`
+	SuccessfulMoves     int64         `json:"successfulMoves"`
+	RateLimitedMoves    int64         `json:"rateLimitedMoves"`
+	RateLimitedMessages int64         `json:"rateLimitedMessages"`
+	Deadlocks           int64         `json:"deadlocks"`
+	Wounded             int64         `json:"wounded"`
+	Died                int64         `json:"died"`
+	Anomalies           int64         `json:"anomalies"`
+	SSIAborts           int64         `json:"ssiAborts"`
+	AverageLatency      time.Duration `json:"averageLatencyNs"`
 }
 
 // NewConcurrencyController creates a new concurrency controller
@@ -47,47 +154,330 @@ func NewConcurrencyController(gameState *models.GameState) *ConcurrencyControlle
 		gameState:          gameState,
 		activeTransactions: make(map[string]*Transaction),
 		conflictStats:      ConflictStats{},
+		thinkTime:          NoDelay{},
+		strategy:           StrategyOptimistic,
+		isolation:          IsolationSerializable,
+		locks:              NewLockManager(),
+		ssi:                newSSITracker(),
+		commits:            make(map[int64]CommitRecord),
+	}
+}
+
+// SetThinkTime configures the delay applied between ProposeMove and CommitTransaction
+func (cc *ConcurrencyController) SetThinkTime(policy DelayPolicy) {
+	if policy == nil {
+		policy = NoDelay{}
 	}
+
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	cc.thinkTime = policy
+}
+
+// ThinkTimePolicy returns the policy ThinkTime draws from
+func (cc *ConcurrencyController) ThinkTimePolicy() DelayPolicy {
+	cc.mu.RLock()
+	defer cc.mu.RUnlock()
+	return cc.thinkTime
 }
 
-// BeginTransaction starts an optimistic transaction for a move
+// ThinkTime returns how long the next transaction should wait before committing.
+// Callers must sleep without holding any controller locks so other transactions
+// can commit inside the window.
+func (cc *ConcurrencyController) ThinkTime() time.Duration {
+	cc.mu.RLock()
+	policy := cc.thinkTime
+	cc.mu.RUnlock()
+	return policy.Next()
+}
+
+// SetLockingStrategy switches between optimistic concurrency and two-phase
+// locking. Transactions already running keep the strategy they began with.
+func (cc *ConcurrencyController) SetLockingStrategy(strategy LockingStrategy) {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	cc.strategy = strategy
+}
+
+// LockingStrategy returns the strategy new transactions run under
+func (cc *ConcurrencyController) LockingStrategy() LockingStrategy {
+	cc.mu.RLock()
+	defer cc.mu.RUnlock()
+	return cc.strategy
+}
+
+// SetIsolationLevel sets the isolation level of new transactions. Below
+// snapshot isolation moves no longer fail on a version mismatch: a stale
+// move overwrites the moves committed since it read the object, and the lost
+// update is reported as an anomaly.
+func (cc *ConcurrencyController) SetIsolationLevel(level IsolationLevel) {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	cc.isolation = level
+}
+
+// IsolationLevel returns the isolation level new transactions run under
+func (cc *ConcurrencyController) IsolationLevel() IsolationLevel {
+	cc.mu.RLock()
+	defer cc.mu.RUnlock()
+	return cc.isolation
+}
+
+// OnAnomaly registers fn to run for every isolation anomaly that happens,
+// whether between moves or in a scenario
+func (cc *ConcurrencyController) OnAnomaly(fn func(models.Anomaly)) {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	cc.onAnomaly = fn
+}
+
+// OnCommit adds fn to the listeners run after every transaction this
+// controller commits, in the order they were added
+func (cc *ConcurrencyController) OnCommit(fn func(CommitRecord)) {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	cc.onCommit = append(cc.onCommit, fn)
+}
+
+// SetCausality makes the controller record every commit, conflict and other
+// failed commit as an event of process on tracker. Call it before running
+// transactions.
+func (cc *ConcurrencyController) SetCausality(tracker *causality.Tracker, process string) {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	cc.causality = tracker
+	cc.process = process
+}
+
+// recordEvent records an event of a transaction when causality is tracked
+func (cc *ConcurrencyController) recordEvent(kind string, transaction *Transaction, label string) {
+	cc.mu.RLock()
+	tracker, process := cc.causality, cc.process
+	cc.mu.RUnlock()
+	if tracker == nil {
+		return
+	}
+	tracker.Local(process, kind, causality.Event{
+		Label:         label,
+		TransactionID: transaction.ID,
+		RequestID:     transaction.RequestID,
+	})
+}
+
+// RunScenario plays a scripted anomaly scenario under an isolation level and
+// reports the anomaly if it happened
+func (cc *ConcurrencyController) RunScenario(name string, level IsolationLevel) (models.ScenarioResult, error) {
+	scenario, ok := FindScenario(name)
+	if !ok {
+		return models.ScenarioResult{}, fmt.Errorf("unknown scenario %q", name)
+	}
+
+	result := scenario.Run(level)
+
+	cc.mu.Lock()
+	cc.conflictStats.ConflictCount += int64(result.WriteConflicts)
+	cc.conflictStats.SSIAborts += int64(result.SSIAborts)
+	cc.mu.Unlock()
+
+	if result.Anomaly != nil {
+		cc.reportAnomaly(*result.Anomaly)
+	}
+	return result, nil
+}
+
+// reportAnomaly counts an anomaly and passes it to the observer. Callers must
+// not hold cc.mu or gameState.Mu.
+func (cc *ConcurrencyController) reportAnomaly(anomaly models.Anomaly) {
+	cc.mu.Lock()
+	cc.conflictStats.Anomalies++
+	onAnomaly := cc.onAnomaly
+	cc.mu.Unlock()
+
+	if onAnomaly != nil {
+		onAnomaly(anomaly)
+	}
+}
+
+// WaitForGraph returns the current lock table as a wait-for graph
+func (cc *ConcurrencyController) WaitForGraph() models.WaitForGraph {
+	graph := cc.locks.Graph()
+	graph.Strategy = string(cc.LockingStrategy())
+	return graph
+}
+
+// OnLocksChanged registers fn to run whenever the wait-for graph changes
+func (cc *ConcurrencyController) OnLocksChanged(fn func()) {
+	cc.locks.SetOnChange(fn)
+}
+
+// BeginTransaction starts a transaction for a move
 func (cc *ConcurrencyController) BeginTransaction(playerID, requestID string) (*Transaction, error) {
 	cc.mu.Lock()
 	defer cc.mu.Unlock()
 
 	snapshot := cc.gameState.GetState()
+	return cc.begin(playerID, requestID, snapshot.Object.Version), nil
+}
+
+// BeginTransactionAt starts an optimistic transaction on behalf of a client
+// that read the object at readVersion, for example through an HTTP If-Match
+// header. The commit fails with a ConflictError if the object has moved on.
+func (cc *ConcurrencyController) BeginTransactionAt(playerID, requestID string, readVersion int64) (*Transaction, error) {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+
+	transaction := cc.begin(playerID, requestID, readVersion)
+	transaction.pinned = true
+	return transaction, nil
+}
+
+// BeginRetry starts a transaction that runs a move again after an earlier
+// attempt lost the version check, on the server's own initiative rather than
+// the client's. Its conflicts are counted in RetriedConflicts.
+func (cc *ConcurrencyController) BeginRetry(playerID, requestID string) (*Transaction, error) {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+
+	snapshot := cc.gameState.GetState()
+	transaction := cc.begin(playerID, requestID, snapshot.Object.Version)
+	transaction.retry = true
+	return transaction, nil
+}
 
+// begin registers a new transaction. Caller must hold cc.mu.
+func (cc *ConcurrencyController) begin(playerID, requestID string, readVersion int64) *Transaction {
 	transaction := &Transaction{
 		ID:             fmt.Sprintf("%s-%s-%d", playerID, requestID, time.Now().UnixNano()),
 		PlayerID:       playerID,
 		StartTime:      time.Now(),
-		InitialVersion: snapshot.Object.Version,
+		InitialVersion: readVersion,
 		RequestID:      requestID,
+		Strategy:       cc.strategy,
+		Isolation:      cc.isolation,
+	}
+
+	if transaction.Isolation == IsolationSerializable && !transaction.Strategy.Locking() {
+		transaction.ssi = cc.ssi.begin(transaction.ID, readVersion)
 	}
 
 	cc.activeTransactions[transaction.ID] = transaction
 	cc.conflictStats.TotalTransactions++
 
-	return transaction, nil
+	return transaction
 }
 
-// ProposeMove validates and prepares a move within a transaction
+// Lock acquires a lock on resource for a transaction, blocking until it is
+// granted. Locks are held until the transaction commits or aborts. If the
+// transaction is chosen as a deadlock victim, or is wounded or dies under a
+// prevention strategy, it is aborted and a *DeadlockError or *AbortError is
+// returned.
+func (cc *ConcurrencyController) Lock(transactionID, resource string, mode LockMode) error {
+	cc.mu.RLock()
+	transaction, exists := cc.activeTransactions[transactionID]
+	cc.mu.RUnlock()
+	if !exists {
+		return ErrNoTransaction
+	}
+
+	// Wait without holding cc.mu so other transactions can commit and release
+	err := cc.locks.Acquire(transaction, resource, mode)
+	if err != nil {
+		cc.mu.Lock()
+		cc.recordLockAbort(err)
+		delete(cc.activeTransactions, transactionID)
+		cc.mu.Unlock()
+	}
+	return err
+}
+
+// ProposeMove validates and prepares a move within a transaction. Under
+// two-phase locking it first waits for an exclusive lock on the object. A
+// move into a wall of the room's layout fails with ErrWall.
 func (cc *ConcurrencyController) ProposeMove(transactionID, direction string) error {
-	cc.mu.Lock()
-	defer cc.mu.Unlock()
+	return cc.propose(transactionID, func(snapshot models.GameStateSnapshot) (models.Position, error) {
+		position := calculateNewPosition(snapshot.Object.Position, direction, snapshot.GridSize)
+		if snapshot.Layout.Blocked(position) {
+			return position, ErrWall
+		}
+		return position, nil
+	})
+}
+
+// ProposeOffset prepares moving the object by dx and dy cells within a
+// transaction, like ProposeMove one cell at a time: across first, then along,
+// stopping at the edges of the grid. A wall on the way fails the whole move
+// with ErrWall.
+func (cc *ConcurrencyController) ProposeOffset(transactionID string, dx, dy int) error {
+	return cc.propose(transactionID, func(snapshot models.GameStateSnapshot) (models.Position, error) {
+		position := snapshot.Object.Position
+		for _, step := range []struct {
+			cells     int
+			direction string
+		}{{dx, "right"}, {-dx, "left"}, {dy, "down"}, {-dy, "up"}} {
+			for i := 0; i < step.cells; i++ {
+				position = calculateNewPosition(position, step.direction, snapshot.GridSize)
+				if snapshot.Layout.Blocked(position) {
+					return position, ErrWall
+				}
+			}
+		}
+		return position, nil
+	})
+}
+
+// ProposePosition prepares placing the object at position within a
+// transaction, wherever it is now. Unlike a move it may jump over walls, so
+// it can put the object back at its start.
+func (cc *ConcurrencyController) ProposePosition(transactionID string, position models.Position) error {
+	return cc.propose(transactionID, func(models.GameStateSnapshot) (models.Position, error) {
+		return position, nil
+	})
+}
 
+// propose prepares the change of the object's position that move computes
+// from the state the transaction reads
+func (cc *ConcurrencyController) propose(transactionID string, move func(snapshot models.GameStateSnapshot) (models.Position, error)) error {
+	cc.mu.RLock()
 	transaction, exists := cc.activeTransactions[transactionID]
+	cc.mu.RUnlock()
 	if !exists {
 		return ErrNoTransaction
 	}
 
+	if transaction.Strategy.Locking() {
+		if err := cc.Lock(transactionID, ObjectResource(cc.gameState.GetState().Object.ID), LockExclusive); err != nil {
+			return err
+		}
+	}
+
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+
+	if _, exists := cc.activeTransactions[transactionID]; !exists {
+		return ErrNoTransaction
+	}
+
 	snapshot := cc.gameState.GetState()
-	newPosition := calculateNewPosition(snapshot.Object.Position, direction, snapshot.GridSize)
+
+	// Holding the lock, the transaction reads the latest committed version
+	if transaction.Strategy.Locking() && !transaction.pinned {
+		transaction.InitialVersion = snapshot.Object.Version
+	}
+	newPosition, err := move(snapshot)
+	if err != nil {
+		return err
+	}
 
 	if !isValidPosition(newPosition, snapshot.GridSize) {
 		return ErrInvalidMove
 	}
 
+	if transaction.ssi != nil {
+		resource := ObjectResource(snapshot.Object.ID)
+		cc.ssi.read(transactionID, resource)
+		cc.ssi.write(transactionID, resource)
+	}
+
 	transaction.ProposedChanges = &models.GameObject{
 		ID:          snapshot.Object.ID,
 		Position:    newPosition,
@@ -98,63 +488,204 @@ func (cc *ConcurrencyController) ProposeMove(transactionID, direction string) er
 	return nil
 }
 
-// CommitTransaction attempts to commit the transaction using optimistic concurrency
+// CommitTransaction attempts to commit the transaction using optimistic
+// concurrency. Under two-phase locking the version check always passes, and
+// below snapshot isolation it is skipped. An optimistic serializable
+// transaction that passes it can still fail with an *SSIError if its read
+// and write sets would complete a dependency cycle. With a replicator the
+// commit is acknowledged only once the replicated log has applied it.
 func (cc *ConcurrencyController) CommitTransaction(transactionID string) (*models.GameStateSnapshot, error) {
-	cc.mu.Lock()
-	defer cc.mu.Unlock()
+	// Strict 2PL: locks are released once the outcome is decided
+	defer cc.locks.ReleaseAll(transactionID)
 
+	cc.mu.Lock()
 	transaction, exists := cc.activeTransactions[transactionID]
 	if !exists {
+		cc.mu.Unlock()
 		return nil, ErrNoTransaction
 	}
+	delete(cc.activeTransactions, transactionID)
+	replicator := cc.replicator
+
+	// A transaction wounded under wound-wait lost its locks and must not write
+	if err := cc.locks.Seal(transactionID); err != nil {
+		cc.recordLockAbort(err)
+		cc.mu.Unlock()
+		cc.recordEvent(causality.KindAbort, transaction, err.Error())
+		return nil, err
+	}
+	if transaction.ProposedChanges == nil {
+		cc.ssi.abort(transactionID)
+		cc.mu.Unlock()
+		return nil, ErrNoProposal
+	}
+	if err := cc.checkSerializable(transaction); err != nil {
+		cc.mu.Unlock()
+		cc.recordEvent(causality.KindAbort, transaction, err.Error())
+		return nil, err
+	}
+	cc.mu.Unlock()
+
+	commit := ReplicatedCommit{
+		TransactionID: transaction.ID,
+		PlayerID:      transaction.PlayerID,
+		RequestID:     transaction.RequestID,
+		Position:      transaction.ProposedChanges.Position,
+		CommittedAt:   transaction.ProposedChanges.LastUpdated,
+		ReadVersion:   transaction.InitialVersion,
+		// A version the client pinned, such as an HTTP If-Match, is a
+		// precondition and is checked at every level
+		Checked: transaction.Isolation.checksWrites() || transaction.pinned,
+	}
+
+	var record CommitRecord
+	var err error
+	if replicator != nil {
+		record, err = replicator.Replicate(commit)
+	} else {
+		record, err = cc.ApplyCommit(commit)
+	}
+
+	cc.mu.Lock()
+	var anomaly *models.Anomaly
+	if err != nil {
+		if errors.Is(err, ErrVersionMismatch) {
+			if transaction.retry {
+				cc.conflictStats.RetriedConflicts++
+			} else {
+				cc.conflictStats.ConflictCount++
+			}
+		}
+		cc.ssi.abort(transactionID)
+		cc.mu.Unlock()
+
+		var conflict *ConflictError
+		if errors.As(err, &conflict) {
+			cc.recordEvent(causality.KindConflict, transaction, fmt.Sprintf("read v%d, object at v%d", conflict.ReadVersion, conflict.CurrentVersion))
+		} else {
+			cc.recordEvent(causality.KindAbort, transaction, err.Error())
+		}
+		return nil, err
+	}
+
+	// Without the check, a move computed from an older version silently
+	// replaces the moves committed since
+	if readVersion, baseVersion := transaction.ProposedChanges.Version-1, record.Version-1; readVersion != baseVersion {
+		anomaly = cc.lostUpdate(transaction, readVersion, baseVersion)
+	}
+
+	if transaction.ssi != nil {
+		cc.ssi.commit(transactionID, record.Version)
+	}
+	cc.conflictStats.SuccessfulMoves++
+	cc.conflictStats.AverageLatency = updateAverageLatency(
+		cc.conflictStats.AverageLatency,
+		time.Since(transaction.StartTime),
+		cc.conflictStats.SuccessfulMoves,
+	)
+	listeners := cc.onCommit
+	cc.mu.Unlock()
+
+	// Reported without holding locks, as the observer may broadcast
+	if anomaly != nil {
+		cc.reportAnomaly(*anomaly)
+	}
+	cc.recordEvent(causality.KindCommit, transaction, fmt.Sprintf("v%d at (%d, %d)", record.Version, record.Position.X, record.Position.Y))
+	for _, listener := range listeners {
+		listener(record)
+	}
+
+	snapshot := cc.gameState.GetState()
+	snapshot.Object = &models.GameObject{
+		ID:          snapshot.Object.ID,
+		Position:    record.Position,
+		Version:     record.Version,
+		LastUpdated: record.CommittedAt,
+	}
+	snapshot.Players = make(map[string]*models.Player)
+	return &snapshot, nil
+}
+
+// ApplyCommit runs a commit's version check against the current state and
+// installs it. It is the state machine of the replicated log: every node
+// applies the same commits in the same order and reaches the same state and
+// the same results. Stats are left to the node that ran the transaction.
+func (cc *ConcurrencyController) ApplyCommit(commit ReplicatedCommit) (CommitRecord, error) {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
 
-	defer delete(cc.activeTransactions, transactionID)
+	// A prepared transaction has promised the object to its coordinator
+	if cc.prepared != nil {
+		cc.prepared.blocked++
+		return CommitRecord{}, cc.prepared.inDoubt()
+	}
+	return cc.applyCommit(commit)
+}
 
+// applyCommit checks and installs a commit. Caller must hold cc.mu.
+func (cc *ConcurrencyController) applyCommit(commit ReplicatedCommit) (CommitRecord, error) {
 	// Critical section: check version and commit atomically
 	cc.gameState.Mu.Lock()
 	defer cc.gameState.Mu.Unlock()
 
 	currentVersion := cc.gameState.Object.Version
 
-	// Optimistic concurrency check
-	if transaction.InitialVersion != currentVersion {
-		cc.conflictStats.ConflictCount++
-		return nil, fmt.Errorf("%w: expected version %d, got %d",
-			ErrVersionMismatch, transaction.InitialVersion, currentVersion)
+	if commit.Checked && commit.ReadVersion != currentVersion {
+		conflict := &ConflictError{
+			TransactionID:    commit.TransactionID,
+			PlayerID:         commit.PlayerID,
+			RequestID:        commit.RequestID,
+			ReadVersion:      commit.ReadVersion,
+			CurrentVersion:   currentVersion,
+			ProposedPosition: commit.Position,
+		}
+		if winner, ok := cc.commits[commit.ReadVersion+1]; ok {
+			conflict.Winner = &winner
+		}
+		return CommitRecord{}, conflict
 	}
 
-	// Commit the changes
-	cc.gameState.Object.Position = transaction.ProposedChanges.Position
-	cc.gameState.Object.Version = transaction.ProposedChanges.Version
-	cc.gameState.Object.LastUpdated = transaction.ProposedChanges.LastUpdated
+	cc.gameState.Object.Position = commit.Position
+	cc.gameState.Object.Version = currentVersion + 1
+	cc.gameState.Object.LastUpdated = commit.CommittedAt
 	cc.gameState.Version++
 
-	cc.conflictStats.SuccessfulMoves++
-	cc.conflictStats.AverageLatency = updateAverageLatency(
-		cc.conflictStats.AverageLatency,
-		time.Since(transaction.StartTime),
-		cc.conflictStats.SuccessfulMoves,
-	)
+	record := CommitRecord{
+		TransactionID: commit.TransactionID,
+		PlayerID:      commit.PlayerID,
+		RequestID:     commit.RequestID,
+		Version:       cc.gameState.Object.Version,
+		Position:      commit.Position,
+		CommittedAt:   commit.CommittedAt,
+	}
+	cc.recordCommit(record)
+	return record, nil
+}
 
-	return &models.GameStateSnapshot{
-		Object: &models.GameObject{
-			ID:          cc.gameState.Object.ID,
-			Position:    cc.gameState.Object.Position,
-			Version:     cc.gameState.Object.Version,
-			LastUpdated: cc.gameState.Object.LastUpdated,
-		},
-		Players:    make(map[string]*models.Player), // Copy current players
-		Version:    cc.gameState.Version,
-		MaxPlayers: cc.gameState.MaxPlayers,
-		GridSize:   cc.gameState.GridSize,
-	}, nil
-}
-
-// AbortTransaction cancels a transaction
+// AbortTransaction cancels a transaction and releases its locks
 func (cc *ConcurrencyController) AbortTransaction(transactionID string) {
 	cc.mu.Lock()
-	defer cc.mu.Unlock()
 	delete(cc.activeTransactions, transactionID)
+	cc.ssi.abort(transactionID)
+	cc.mu.Unlock()
+
+	cc.locks.ReleaseAll(transactionID)
+}
+
+// RecordRateLimitedMove counts a move rejected by its player's move limit
+// before it reached a transaction
+func (cc *ConcurrencyController) RecordRateLimitedMove() {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	cc.conflictStats.RateLimitedMoves++
+}
+
+// RecordRateLimitedMessage counts a message rejected by its connection's
+// message limit before it was handled
+func (cc *ConcurrencyController) RecordRateLimitedMessage() {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	cc.conflictStats.RateLimitedMessages++
 }
 
 // GetConflictStats returns current concurrency statistics
@@ -164,6 +695,62 @@ func (cc *ConcurrencyController) GetConflictStats() ConflictStats {
 	return cc.conflictStats
 }
 
+// checkSerializable runs serializable snapshot isolation's commit check on a
+// transaction's read and write sets, aborting it if committing could close a
+// cycle. A move whose version check will fail is left to fail it, so that a
+// stale move still counts as a write-write conflict: the first committer
+// wins before dependencies are considered. Caller must hold cc.mu.
+func (cc *ConcurrencyController) checkSerializable(transaction *Transaction) error {
+	if transaction.ssi == nil || transaction.InitialVersion != cc.gameState.GetState().Object.Version {
+		return nil
+	}
+	if err := cc.ssi.check(transaction.ID); err != nil {
+		cc.ssi.abort(transaction.ID)
+		cc.conflictStats.SSIAborts++
+		return err
+	}
+	return nil
+}
+
+// lostUpdate describes the moves overwritten by a transaction that read
+// readVersion and committed on top of currentVersion. Caller must hold cc.mu.
+func (cc *ConcurrencyController) lostUpdate(transaction *Transaction, readVersion, currentVersion int64) *models.Anomaly {
+	transactions := []string{transaction.ID}
+	for version := readVersion + 1; version <= currentVersion; version++ {
+		if record, ok := cc.commits[version]; ok {
+			transactions = append(transactions, record.TransactionID)
+		}
+	}
+
+	return &models.Anomaly{
+		Kind:           models.AnomalyLostUpdate,
+		IsolationLevel: string(transaction.Isolation),
+		Description: fmt.Sprintf("Move by %s was computed from version %d but committed over version %d, overwriting %d move(s)",
+			transaction.PlayerID, readVersion, currentVersion, currentVersion-readVersion),
+		Transactions: transactions,
+		DetectedAt:   time.Now(),
+	}
+}
+
+// recordLockAbort counts a transaction aborted by the lock manager. Caller
+// must hold cc.mu.
+func (cc *ConcurrencyController) recordLockAbort(err error) {
+	switch {
+	case errors.Is(err, ErrDeadlock):
+		cc.conflictStats.Deadlocks++
+	case errors.Is(err, ErrWounded):
+		cc.conflictStats.Wounded++
+	case errors.Is(err, ErrDied):
+		cc.conflictStats.Died++
+	}
+}
+
+// recordCommit adds a commit to the bounded history. Caller must hold cc.mu.
+func (cc *ConcurrencyController) recordCommit(record CommitRecord) {
+	cc.commits[record.Version] = record
+	delete(cc.commits, record.Version-commitHistorySize)
+}
+
 // Helper functions
 func calculateNewPosition(current models.Position, direction string, gridSize models.Position) models.Position {
 	newPos := current
@@ -3,6 +3,7 @@ package concurrency
 import (
 	"errors"
 	"testing"
+	"time"
 
 	"github.com/AnishMulay/Transaction-Conflict-Visualization/pkg/models"
 )
@@ -34,6 +35,34 @@ func TestConcurrencyController(t *testing.T) {
 	}
 }
 
+func TestWallsBlockMoves(t *testing.T) {
+	gameState := models.NewGameState(models.Position{X: 10, Y: 10})
+	gameState.Layout = models.NewLayout("test", []models.Position{{X: 6, Y: 5}, {X: 5, Y: 7}}, nil)
+	controller := NewConcurrencyController(gameState)
+
+	transaction, _ := controller.BeginTransaction("player1", "into-wall")
+	if err := controller.ProposeMove(transaction.ID, "right"); !errors.Is(err, ErrWall) {
+		t.Errorf("Expected a move into the wall to fail with ErrWall, got %v", err)
+	}
+	controller.AbortTransaction(transaction.ID)
+
+	// A longer move fails when any cell on its way is a wall
+	transaction, _ = controller.BeginTransaction("player1", "through-wall")
+	if err := controller.ProposeOffset(transaction.ID, 0, 3); !errors.Is(err, ErrWall) {
+		t.Errorf("Expected a move through the wall to fail with ErrWall, got %v", err)
+	}
+	controller.AbortTransaction(transaction.ID)
+
+	transaction, _ = controller.BeginTransaction("player1", "around-wall")
+	if err := controller.ProposeOffset(transaction.ID, -2, 3); err != nil {
+		t.Fatalf("Expected a move around the wall to pass, got %v", err)
+	}
+	snapshot, err := controller.CommitTransaction(transaction.ID)
+	if err != nil || snapshot.Object.Position != (models.Position{X: 3, Y: 8}) {
+		t.Errorf("Expected the object at (3, 8), got %+v, %v", snapshot, err)
+	}
+}
+
 func TestConcurrencyConflict(t *testing.T) {
 	gridSize := models.Position{X: 10, Y: 10}
 	gameState := models.NewGameState(gridSize)
@@ -63,3 +92,191 @@ func TestConcurrencyConflict(t *testing.T) {
 		t.Errorf("Expected version mismatch error, got: %v", err2)
 	}
 }
+
+func TestConflictErrorDetails(t *testing.T) {
+	gridSize := models.Position{X: 10, Y: 10}
+	gameState := models.NewGameState(gridSize)
+	controller := NewConcurrencyController(gameState)
+
+	tx1, _ := controller.BeginTransaction("player1", "req1")
+	tx2, _ := controller.BeginTransaction("player2", "req2")
+
+	controller.ProposeMove(tx1.ID, "right")
+	controller.ProposeMove(tx2.ID, "up")
+
+	if _, err := controller.CommitTransaction(tx1.ID); err != nil {
+		t.Fatalf("First commit should succeed: %v", err)
+	}
+
+	// A third commit moves the object further before tx2 tries to commit
+	tx3, _ := controller.BeginTransaction("player1", "req3")
+	controller.ProposeMove(tx3.ID, "down")
+	if _, err := controller.CommitTransaction(tx3.ID); err != nil {
+		t.Fatalf("Third commit should succeed: %v", err)
+	}
+
+	_, err := controller.CommitTransaction(tx2.ID)
+
+	var conflict *ConflictError
+	if !errors.As(err, &conflict) {
+		t.Fatalf("Expected *ConflictError, got %v", err)
+	}
+
+	if conflict.ReadVersion != 1 || conflict.CurrentVersion != 3 {
+		t.Errorf("Expected read v1 and current v3, got v%d and v%d", conflict.ReadVersion, conflict.CurrentVersion)
+	}
+
+	if conflict.ProposedPosition != (models.Position{X: 5, Y: 4}) {
+		t.Errorf("Expected proposed position (5,4), got %v", conflict.ProposedPosition)
+	}
+
+	if conflict.Winner == nil {
+		t.Fatal("Expected the winning commit")
+	}
+
+	// The winner is the commit that first invalidated the read, not the latest one
+	if conflict.Winner.TransactionID != tx1.ID || conflict.Winner.PlayerID != "player1" {
+		t.Errorf("Expected winner %s, got %s", tx1.ID, conflict.Winner.TransactionID)
+	}
+
+	if conflict.Winner.Version != 2 || conflict.Winner.Position != (models.Position{X: 6, Y: 5}) {
+		t.Errorf("Expected winner at v2 (6,5), got v%d %v", conflict.Winner.Version, conflict.Winner.Position)
+	}
+}
+
+func TestTwoPhaseLockingSerializesMoves(t *testing.T) {
+	gameState := models.NewGameState(models.Position{X: 10, Y: 10})
+	controller := NewConcurrencyController(gameState)
+	controller.SetLockingStrategy(StrategyTwoPhaseLocking)
+
+	tx1, _ := controller.BeginTransaction("player1", "req1")
+	tx2, _ := controller.BeginTransaction("player2", "req2")
+
+	if err := controller.ProposeMove(tx1.ID, "right"); err != nil {
+		t.Fatalf("Failed to propose: %v", err)
+	}
+
+	// tx2 read version 1 too, but waits for the object lock instead of conflicting
+	proposed := make(chan error, 1)
+	go func() { proposed <- controller.ProposeMove(tx2.ID, "down") }()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for len(controller.WaitForGraph().Edges) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("tx2 never waited for the object lock")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	if _, err := controller.CommitTransaction(tx1.ID); err != nil {
+		t.Fatalf("First commit failed: %v", err)
+	}
+	if err := <-proposed; err != nil {
+		t.Fatalf("Second propose failed: %v", err)
+	}
+
+	snapshot, err := controller.CommitTransaction(tx2.ID)
+	if err != nil {
+		t.Fatalf("Second commit should not conflict under 2PL: %v", err)
+	}
+	if snapshot.Object.Position != (models.Position{X: 6, Y: 6}) || snapshot.Object.Version != 3 {
+		t.Errorf("Expected both moves applied, got %+v", snapshot.Object)
+	}
+
+	stats := controller.GetConflictStats()
+	if stats.ConflictCount != 0 || stats.SuccessfulMoves != 2 {
+		t.Errorf("Unexpected stats %+v", stats)
+	}
+	if graph := controller.WaitForGraph(); len(graph.Transactions) != 0 || graph.Strategy != "2pl" {
+		t.Errorf("Expected an empty 2pl lock table, got %+v", graph)
+	}
+}
+
+func TestWoundedTransactionCannotCommit(t *testing.T) {
+	gameState := models.NewGameState(models.Position{X: 10, Y: 10})
+	controller := NewConcurrencyController(gameState)
+	controller.SetLockingStrategy(StrategyWoundWait)
+
+	older, _ := controller.BeginTransaction("player1", "req1")
+	time.Sleep(time.Millisecond)
+	younger, _ := controller.BeginTransaction("player2", "req2")
+
+	// The younger transaction takes the object lock first, then the older one wounds it
+	if err := controller.ProposeMove(younger.ID, "left"); err != nil {
+		t.Fatalf("Failed to propose: %v", err)
+	}
+	if err := controller.ProposeMove(older.ID, "right"); err != nil {
+		t.Fatalf("Expected the older transaction to wound and proceed: %v", err)
+	}
+
+	if _, err := controller.CommitTransaction(younger.ID); !errors.Is(err, ErrWounded) {
+		t.Fatalf("Expected the wounded transaction to fail, got %v", err)
+	}
+	snapshot, err := controller.CommitTransaction(older.ID)
+	if err != nil {
+		t.Fatalf("Older commit failed: %v", err)
+	}
+	if snapshot.Object.Position != (models.Position{X: 6, Y: 5}) {
+		t.Errorf("Expected only the older move applied, got %+v", snapshot.Object.Position)
+	}
+
+	if stats := controller.GetConflictStats(); stats.Wounded != 1 || stats.SuccessfulMoves != 1 {
+		t.Errorf("Unexpected stats %+v", stats)
+	}
+}
+
+func TestReadCommittedLosesUpdates(t *testing.T) {
+	gameState := models.NewGameState(models.Position{X: 10, Y: 10})
+	controller := NewConcurrencyController(gameState)
+	controller.SetIsolationLevel(IsolationReadCommitted)
+
+	var anomalies []models.Anomaly
+	controller.OnAnomaly(func(anomaly models.Anomaly) { anomalies = append(anomalies, anomaly) })
+
+	tx1, _ := controller.BeginTransaction("player1", "req1")
+	tx2, _ := controller.BeginTransaction("player2", "req2")
+	controller.ProposeMove(tx1.ID, "right")
+	controller.ProposeMove(tx2.ID, "down")
+
+	if _, err := controller.CommitTransaction(tx1.ID); err != nil {
+		t.Fatalf("First commit failed: %v", err)
+	}
+	snapshot, err := controller.CommitTransaction(tx2.ID)
+	if err != nil {
+		t.Fatalf("Read committed should not reject the stale move: %v", err)
+	}
+
+	// tx2 moved down from (5, 5), erasing tx1's move right
+	if snapshot.Object.Position != (models.Position{X: 5, Y: 6}) || snapshot.Object.Version != 3 {
+		t.Errorf("Expected the stale move to overwrite, got %+v", snapshot.Object)
+	}
+
+	if len(anomalies) != 1 {
+		t.Fatalf("Expected one anomaly, got %+v", anomalies)
+	}
+	anomaly := anomalies[0]
+	if anomaly.Kind != models.AnomalyLostUpdate || len(anomaly.Transactions) != 2 || anomaly.Transactions[1] != tx1.ID {
+		t.Errorf("Unexpected anomaly %+v", anomaly)
+	}
+	if stats := controller.GetConflictStats(); stats.Anomalies != 1 || stats.ConflictCount != 0 {
+		t.Errorf("Unexpected stats %+v", stats)
+	}
+}
+
+func TestOnCommitRunsEveryListener(t *testing.T) {
+	controller := NewConcurrencyController(models.NewGameState(models.Position{X: 10, Y: 10}))
+
+	var calls []string
+	controller.OnCommit(func(record CommitRecord) { calls = append(calls, "first:"+record.RequestID) })
+	controller.OnCommit(func(record CommitRecord) { calls = append(calls, "second:"+record.RequestID) })
+
+	transaction, _ := controller.BeginTransaction("player1", "req1")
+	controller.ProposeMove(transaction.ID, "up")
+	if _, err := controller.CommitTransaction(transaction.ID); err != nil {
+		t.Fatalf("Commit failed: %v", err)
+	}
+
+	if len(calls) != 2 || calls[0] != "first:req1" || calls[1] != "second:req1" {
+		t.Errorf("Expected both listeners in order, got %v", calls)
+	}
+}
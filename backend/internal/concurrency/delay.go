@@ -0,0 +1,136 @@
+package concurrency
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"time"
+)
+
+// DelayPolicy decides how long a transaction "thinks" between ProposeMove
+// and CommitTransaction. Longer think times widen the conflict window.
+type DelayPolicy interface {
+	Next() time.Duration
+	String() string
+}
+
+// NoDelay commits immediately after the move is proposed
+type NoDelay struct{}
+
+func (NoDelay) Next() time.Duration { return 0 }
+func (NoDelay) String() string      { return "none" }
+
+// FixedDelay waits the same amount of time for every transaction
+type FixedDelay time.Duration
+
+func (d FixedDelay) Next() time.Duration { return time.Duration(d) }
+func (d FixedDelay) String() string      { return "fixed:" + time.Duration(d).String() }
+
+// UniformDelay waits a random duration in [Min, Max]
+type UniformDelay struct {
+	Min time.Duration
+	Max time.Duration
+}
+
+func (d UniformDelay) Next() time.Duration {
+	if d.Max <= d.Min {
+		return d.Min
+	}
+	return d.Min + time.Duration(rand.Int63n(int64(d.Max-d.Min)+1))
+}
+
+func (d UniformDelay) String() string {
+	return fmt.Sprintf("uniform:%s-%s", d.Min, d.Max)
+}
+
+// NormalDelay waits a normally distributed duration, clamped at zero
+type NormalDelay struct {
+	Mean   time.Duration
+	StdDev time.Duration
+}
+
+func (d NormalDelay) Next() time.Duration {
+	delay := time.Duration(rand.NormFloat64()*float64(d.StdDev)) + d.Mean
+	if delay < 0 {
+		return 0
+	}
+	return delay
+}
+
+func (d NormalDelay) String() string {
+	return fmt.Sprintf("normal:%s,%s", d.Mean, d.StdDev)
+}
+
+// ParseDelayPolicy parses a think time specification. Accepted forms:
+//
+//	none
+//	50ms                 (same as fixed:50ms)
+//	fixed:50ms
+//	uniform:10ms-100ms
+//	normal:50ms,15ms     (mean, standard deviation)
+func ParseDelayPolicy(spec string) (DelayPolicy, error) {
+	spec = strings.TrimSpace(spec)
+	if spec == "" || spec == "none" {
+		return NoDelay{}, nil
+	}
+
+	kind, args, found := strings.Cut(spec, ":")
+	if !found {
+		kind, args = "fixed", spec
+	}
+
+	switch kind {
+	case "fixed":
+		d, err := parseNonNegativeDuration(args)
+		if err != nil {
+			return nil, err
+		}
+		return FixedDelay(d), nil
+
+	case "uniform":
+		lo, hi, ok := strings.Cut(args, "-")
+		if !ok {
+			return nil, fmt.Errorf("uniform delay needs min-max, got %q", args)
+		}
+		minDelay, err := parseNonNegativeDuration(lo)
+		if err != nil {
+			return nil, err
+		}
+		maxDelay, err := parseNonNegativeDuration(hi)
+		if err != nil {
+			return nil, err
+		}
+		if maxDelay < minDelay {
+			return nil, fmt.Errorf("uniform delay max %s is below min %s", maxDelay, minDelay)
+		}
+		return UniformDelay{Min: minDelay, Max: maxDelay}, nil
+
+	case "normal":
+		mean, dev, ok := strings.Cut(args, ",")
+		if !ok {
+			return nil, fmt.Errorf("normal delay needs mean,stddev, got %q", args)
+		}
+		meanDelay, err := parseNonNegativeDuration(mean)
+		if err != nil {
+			return nil, err
+		}
+		stdDev, err := parseNonNegativeDuration(dev)
+		if err != nil {
+			return nil, err
+		}
+		return NormalDelay{Mean: meanDelay, StdDev: stdDev}, nil
+	}
+
+	return nil, fmt.Errorf("unknown delay policy %q", kind)
+}
+
+func parseNonNegativeDuration(s string) (time.Duration, error) {
+	d, err := time.ParseDuration(strings.TrimSpace(s))
+	if err != nil {
+		return 0, fmt.Errorf("invalid delay %q: %w", s, err)
+	}
+	if d < 0 {
+		return 0, fmt.Errorf("invalid delay %q: must not be negative", s)
+	}
+	return d, nil
+}
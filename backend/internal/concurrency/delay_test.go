@@ -0,0 +1,79 @@
+package concurrency
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/AnishMulay/Transaction-Conflict-Visualization/pkg/models"
+)
+
+func TestParseDelayPolicy(t *testing.T) {
+	cases := []struct {
+		spec string
+		want DelayPolicy
+	}{
+		{"", NoDelay{}},
+		{"none", NoDelay{}},
+		{"50ms", FixedDelay(50 * time.Millisecond)},
+		{"fixed:1s", FixedDelay(time.Second)},
+		{"uniform:10ms-100ms", UniformDelay{Min: 10 * time.Millisecond, Max: 100 * time.Millisecond}},
+		{"normal:50ms,15ms", NormalDelay{Mean: 50 * time.Millisecond, StdDev: 15 * time.Millisecond}},
+	}
+
+	for _, tc := range cases {
+		got, err := ParseDelayPolicy(tc.spec)
+		if err != nil {
+			t.Errorf("ParseDelayPolicy(%q) returned error: %v", tc.spec, err)
+			continue
+		}
+		if got != tc.want {
+			t.Errorf("ParseDelayPolicy(%q) = %v, want %v", tc.spec, got, tc.want)
+		}
+	}
+
+	for _, spec := range []string{"fixed:-1s", "uniform:100ms-10ms", "uniform:10ms", "normal:5ms", "poisson:1s", "soon"} {
+		if _, err := ParseDelayPolicy(spec); err == nil {
+			t.Errorf("ParseDelayPolicy(%q) should fail", spec)
+		}
+	}
+}
+
+func TestUniformDelayBounds(t *testing.T) {
+	policy := UniformDelay{Min: 5 * time.Millisecond, Max: 10 * time.Millisecond}
+	for i := 0; i < 1000; i++ {
+		if d := policy.Next(); d < policy.Min || d > policy.Max {
+			t.Fatalf("Delay %s outside [%s, %s]", d, policy.Min, policy.Max)
+		}
+	}
+}
+
+func TestThinkTimeWidensConflictWindow(t *testing.T) {
+	gridSize := models.Position{X: 10, Y: 10}
+	gameState := models.NewGameState(gridSize)
+	controller := NewConcurrencyController(gameState)
+	controller.SetThinkTime(FixedDelay(20 * time.Millisecond))
+
+	// Both transactions have proposed before either commits, as when each
+	// thinks while the other one arrives
+	first, _ := controller.BeginTransaction("player1", "req1")
+	second, _ := controller.BeginTransaction("player2", "req2")
+	controller.ProposeMove(first.ID, "right")
+	controller.ProposeMove(second.ID, "right")
+
+	if delay := controller.ThinkTime(); delay != 20*time.Millisecond {
+		t.Fatalf("Expected a think time of 20ms, got %v", delay)
+	}
+
+	// The second commits inside the first one's think time
+	if _, err := controller.CommitTransaction(second.ID); err != nil {
+		t.Fatalf("Expected the second transaction to commit, got %v", err)
+	}
+	if _, err := controller.CommitTransaction(first.ID); !errors.Is(err, ErrVersionMismatch) {
+		t.Fatalf("Expected the first transaction to conflict, got %v", err)
+	}
+
+	if stats := controller.GetConflictStats(); stats.ConflictCount != 1 {
+		t.Errorf("Expected 1 conflict, got %d", stats.ConflictCount)
+	}
+}
@@ -0,0 +1,280 @@
+package concurrency
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+var (
+	// ErrWriteConflict is returned by a snapshot transaction whose write set
+	// was changed by a transaction that committed after it began
+	ErrWriteConflict = errors.New("write-write conflict: another transaction committed first")
+	// ErrSerializationFailure is returned by a serializable transaction whose
+	// commit could complete a dependency cycle. See SSIError.
+	ErrSerializationFailure = errors.New("serialization failure")
+	// ErrTransactionDone is returned when a finished transaction is used again
+	ErrTransactionDone = errors.New("transaction already committed or aborted")
+)
+
+// IsolationLevel is the isolation a room's transactions run under
+type IsolationLevel string
+
+const (
+	// IsolationReadUncommitted reads the latest write, committed or not
+	IsolationReadUncommitted IsolationLevel = "read-uncommitted"
+	// IsolationReadCommitted reads the latest committed value at each read
+	// and lets the last writer win
+	IsolationReadCommitted IsolationLevel = "read-committed"
+	// IsolationSnapshot reads from a snapshot taken when the transaction
+	// began and aborts the second of two concurrent writers. It is what
+	// many databases call repeatable read.
+	IsolationSnapshot IsolationLevel = "snapshot"
+	// IsolationSerializable is serializable snapshot isolation: snapshot
+	// isolation that also tracks read/write dependencies between concurrent
+	// transactions and aborts those that could complete a cycle
+	IsolationSerializable IsolationLevel = "serializable"
+)
+
+// ParseIsolationLevel parses an isolation level name
+func ParseIsolationLevel(name string) (IsolationLevel, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "read-uncommitted", "ru":
+		return IsolationReadUncommitted, nil
+	case "read-committed", "rc":
+		return IsolationReadCommitted, nil
+	case "snapshot", "repeatable-read", "rr", "si":
+		return IsolationSnapshot, nil
+	case "", "serializable":
+		return IsolationSerializable, nil
+	}
+	return "", fmt.Errorf("unknown isolation level %q, use read-uncommitted, read-committed, snapshot or serializable", name)
+}
+
+// checksWrites reports whether commits fail when a concurrent transaction
+// wrote the same data first
+func (l IsolationLevel) checksWrites() bool {
+	return l == IsolationSnapshot || l == IsolationSerializable
+}
+
+// valueVersion is one committed value of a key
+type valueVersion struct {
+	value         int64
+	commitTS      int64
+	transactionID string
+}
+
+// dirtyWrite is an uncommitted value visible under read uncommitted
+type dirtyWrite struct {
+	value         int64
+	transactionID string
+}
+
+// VersionedStore is a small multi-version key/value store used to show what
+// each isolation level lets through. Every committed value is kept with the
+// logical timestamp of the commit that wrote it.
+type VersionedStore struct {
+	mu       sync.Mutex
+	clock    int64
+	versions map[string][]valueVersion
+	dirty    map[string]dirtyWrite
+	ssi      *ssiTracker
+}
+
+// StoreTransaction is a transaction against a VersionedStore. Writes are
+// buffered until Commit, except that read uncommitted transactions publish
+// them to other read uncommitted readers immediately.
+type StoreTransaction struct {
+	ID        string
+	Isolation IsolationLevel
+	StartTS   int64
+
+	store  *VersionedStore
+	writes map[string]int64
+	done   bool
+}
+
+// NewVersionedStore creates a store holding initial as its first committed state
+func NewVersionedStore(initial map[string]int64) *VersionedStore {
+	store := &VersionedStore{
+		versions: make(map[string][]valueVersion),
+		dirty:    make(map[string]dirtyWrite),
+		ssi:      newSSITracker(),
+	}
+	for key, value := range initial {
+		store.versions[key] = []valueVersion{{value: value, transactionID: "initial"}}
+	}
+	return store
+}
+
+// Begin starts a transaction that sees everything committed so far
+func (s *VersionedStore) Begin(id string, level IsolationLevel) *StoreTransaction {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if level == IsolationSerializable {
+		s.ssi.begin(id, s.clock)
+	}
+
+	return &StoreTransaction{
+		ID:        id,
+		Isolation: level,
+		StartTS:   s.clock,
+		store:     s,
+		writes:    make(map[string]int64),
+	}
+}
+
+// Snapshot returns the latest committed value of every key
+func (s *VersionedStore) Snapshot() map[string]int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	values := make(map[string]int64, len(s.versions))
+	for key, versions := range s.versions {
+		values[key] = versions[len(versions)-1].value
+	}
+	return values
+}
+
+// latest returns the newest committed version of a key visible at ts, or the
+// newest overall when ts is negative. Caller must hold s.mu.
+func (s *VersionedStore) latest(key string, ts int64) (valueVersion, bool) {
+	versions := s.versions[key]
+	for i := len(versions) - 1; i >= 0; i-- {
+		if ts < 0 || versions[i].commitTS <= ts {
+			return versions[i], true
+		}
+	}
+	return valueVersion{}, false
+}
+
+// Read returns the value of key as the transaction's isolation level sees it.
+// A missing key reads as 0.
+func (t *StoreTransaction) Read(key string) (int64, error) {
+	if t.done {
+		return 0, ErrTransactionDone
+	}
+	if value, ok := t.writes[key]; ok {
+		return value, nil
+	}
+
+	s := t.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if t.Isolation == IsolationReadUncommitted {
+		if write, ok := s.dirty[key]; ok {
+			return write.value, nil
+		}
+	}
+
+	ts := int64(-1)
+	if t.Isolation.checksWrites() {
+		ts = t.StartTS
+	}
+
+	version, _ := s.latest(key, ts)
+	if t.Isolation == IsolationSerializable {
+		s.ssi.read(t.ID, key)
+	}
+	return version.value, nil
+}
+
+// Write buffers a new value for key
+func (t *StoreTransaction) Write(key string, value int64) error {
+	if t.done {
+		return ErrTransactionDone
+	}
+	t.writes[key] = value
+
+	switch t.Isolation {
+	case IsolationReadUncommitted:
+		t.store.mu.Lock()
+		t.store.dirty[key] = dirtyWrite{value: value, transactionID: t.ID}
+		t.store.mu.Unlock()
+	case IsolationSerializable:
+		t.store.mu.Lock()
+		t.store.ssi.write(t.ID, key)
+		t.store.mu.Unlock()
+	}
+	return nil
+}
+
+// Commit validates the transaction for its isolation level and installs its
+// writes. A failed commit aborts the transaction.
+func (t *StoreTransaction) Commit() error {
+	if t.done {
+		return ErrTransactionDone
+	}
+
+	s := t.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := t.validate(); err != nil {
+		t.abort()
+		return err
+	}
+
+	s.clock++
+	for key, value := range t.writes {
+		s.versions[key] = append(s.versions[key], valueVersion{value: value, commitTS: s.clock, transactionID: t.ID})
+	}
+	if t.Isolation == IsolationSerializable {
+		s.ssi.commit(t.ID, s.clock)
+	}
+	t.clearDirty()
+	t.done = true
+	return nil
+}
+
+// Abort discards the transaction's writes
+func (t *StoreTransaction) Abort() {
+	if t.done {
+		return
+	}
+
+	t.store.mu.Lock()
+	defer t.store.mu.Unlock()
+	t.abort()
+}
+
+// validate applies the commit-time checks of the isolation level. Caller
+// must hold the store's mutex.
+func (t *StoreTransaction) validate() error {
+	s := t.store
+
+	if t.Isolation.checksWrites() {
+		for key := range t.writes {
+			if version, ok := s.latest(key, -1); ok && version.commitTS > t.StartTS {
+				return fmt.Errorf("%w: %s was written by %s", ErrWriteConflict, key, version.transactionID)
+			}
+		}
+	}
+
+	if t.Isolation == IsolationSerializable {
+		return s.ssi.check(t.ID)
+	}
+
+	return nil
+}
+
+// abort marks the transaction finished and withdraws its dirty writes and
+// dependencies. Caller must hold the store's mutex.
+func (t *StoreTransaction) abort() {
+	t.clearDirty()
+	t.store.ssi.abort(t.ID)
+	t.done = true
+}
+
+// clearDirty removes the transaction's uncommitted values. Caller must hold
+// the store's mutex.
+func (t *StoreTransaction) clearDirty() {
+	for key, write := range t.store.dirty {
+		if write.transactionID == t.ID {
+			delete(t.store.dirty, key)
+		}
+	}
+}
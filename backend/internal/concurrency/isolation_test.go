@@ -0,0 +1,88 @@
+package concurrency
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestScenarioAnomaliesByIsolationLevel(t *testing.T) {
+	// Whether each scenario's anomaly gets through at each level
+	expected := map[string]map[IsolationLevel]bool{
+		"dirty-read": {
+			IsolationReadUncommitted: true,
+			IsolationReadCommitted:   false,
+			IsolationSnapshot:        false,
+			IsolationSerializable:    false,
+		},
+		"lost-update": {
+			IsolationReadUncommitted: true,
+			IsolationReadCommitted:   true,
+			IsolationSnapshot:        false,
+			IsolationSerializable:    false,
+		},
+		"read-skew": {
+			IsolationReadUncommitted: true,
+			IsolationReadCommitted:   true,
+			IsolationSnapshot:        false,
+			IsolationSerializable:    false,
+		},
+		"write-skew": {
+			IsolationReadUncommitted: true,
+			IsolationReadCommitted:   true,
+			IsolationSnapshot:        true,
+			IsolationSerializable:    false,
+		},
+		"read-only": {
+			IsolationReadUncommitted: true,
+			IsolationReadCommitted:   true,
+			IsolationSnapshot:        true,
+			IsolationSerializable:    false,
+		},
+	}
+
+	for _, scenario := range Scenarios {
+		levels, ok := expected[scenario.Name]
+		if !ok {
+			t.Errorf("No expectations for scenario %s", scenario.Name)
+			continue
+		}
+
+		for level, anomaly := range levels {
+			result := scenario.Run(level)
+			if got := result.Anomaly != nil; got != anomaly {
+				t.Errorf("%s under %s: expected anomaly=%v, got %+v", scenario.Name, level, anomaly, result)
+			}
+			if result.Anomaly != nil && result.Anomaly.Kind != scenario.Anomaly {
+				t.Errorf("%s: expected kind %s, got %s", scenario.Name, scenario.Anomaly, result.Anomaly.Kind)
+			}
+		}
+	}
+}
+
+func TestSnapshotFirstCommitterWins(t *testing.T) {
+	store := NewVersionedStore(map[string]int64{"x": 1})
+	t1 := store.Begin("T1", IsolationSnapshot)
+	t2 := store.Begin("T2", IsolationSnapshot)
+
+	t1.Write("x", 2)
+	t2.Write("x", 3)
+
+	if err := t1.Commit(); err != nil {
+		t.Fatalf("First committer should win: %v", err)
+	}
+	if err := t2.Commit(); !errors.Is(err, ErrWriteConflict) {
+		t.Fatalf("Expected ErrWriteConflict, got %v", err)
+	}
+
+	// A snapshot keeps reading the value from when it began
+	t3 := store.Begin("T3", IsolationSnapshot)
+	t4 := store.Begin("T4", IsolationReadCommitted)
+	t4.Write("x", 4)
+	t4.Commit()
+	if value, _ := t3.Read("x"); value != 2 {
+		t.Errorf("Expected the snapshot value 2, got %d", value)
+	}
+	if got := store.Snapshot()["x"]; got != 4 {
+		t.Errorf("Expected x=4 committed, got %d", got)
+	}
+}
@@ -0,0 +1,553 @@
+package concurrency
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/AnishMulay/Transaction-Conflict-Visualization/pkg/models"
+)
+
+var (
+	// ErrDeadlock is returned to a transaction aborted to break a wait-for cycle
+	ErrDeadlock = errors.New("deadlock: transaction aborted to break a wait-for cycle")
+	// ErrWounded is returned to a transaction aborted by an older one under wound-wait
+	ErrWounded = errors.New("wounded by an older transaction")
+	// ErrDied is returned to a transaction that requested a lock held by an
+	// older one under wait-die
+	ErrDied = errors.New("died waiting on an older transaction")
+)
+
+// lockHistorySize is how many broken deadlocks and prevention aborts are kept
+// for the wait-for graph
+const lockHistorySize = 16
+
+// LockMode is the mode a lock is requested or held in
+type LockMode int
+
+const (
+	LockShared LockMode = iota
+	LockExclusive
+)
+
+func (m LockMode) String() string {
+	if m == LockExclusive {
+		return "exclusive"
+	}
+	return "shared"
+}
+
+// compatible reports whether two transactions may hold a resource in these modes at once
+func compatible(a, b LockMode) bool {
+	return a == LockShared && b == LockShared
+}
+
+// DeadlockError reports a transaction chosen as the victim of a deadlock.
+// It wraps ErrDeadlock.
+type DeadlockError struct {
+	TransactionID string
+	// Cycle lists the transactions in the cycle, starting with the victim
+	Cycle []string
+}
+
+func (e *DeadlockError) Error() string {
+	loop := append(append([]string(nil), e.Cycle...), e.Cycle[0])
+	return fmt.Sprintf("%v (%s)", ErrDeadlock, strings.Join(loop, " waits for "))
+}
+
+func (e *DeadlockError) Unwrap() error {
+	return ErrDeadlock
+}
+
+// AbortError reports a transaction aborted by wound-wait or wait-die. It
+// wraps ErrWounded or ErrDied.
+type AbortError struct {
+	TransactionID string
+	// By is the older transaction that caused the abort
+	By    string
+	Cause error
+}
+
+// Reason describes the abort, such as "wounded by tx X" or "died waiting on tx Y"
+func (e *AbortError) Reason() string {
+	if e.Cause == ErrWounded {
+		return "wounded by tx " + e.By
+	}
+	return "died waiting on tx " + e.By
+}
+
+func (e *AbortError) Error() string {
+	return fmt.Sprintf("transaction %s aborted: %s", e.TransactionID, e.Reason())
+}
+
+func (e *AbortError) Unwrap() error {
+	return e.Cause
+}
+
+// lockRequest is a transaction blocked on a resource. done receives nil once
+// the lock is granted, or the reason the wait was given up.
+type lockRequest struct {
+	transaction *Transaction
+	resource    string
+	mode        LockMode
+	done        chan error
+}
+
+// resourceLock is the lock table entry of one resource. Waiters are granted
+// in FIFO order.
+type resourceLock struct {
+	holders map[string]LockMode
+	queue   []*lockRequest
+}
+
+// LockManager implements strict two-phase locking over named resources.
+// Transactions block in Acquire until their lock is granted and keep every
+// lock until ReleaseAll. The manager maintains the wait-for graph and, each
+// time it changes, aborts the youngest transaction of any cycle with a
+// DeadlockError.
+//
+// Transactions whose strategy is wound-wait or wait-die never get that far:
+// when a request conflicts, the manager compares StartTime with every
+// blocker and either wounds the younger blockers or lets the younger
+// requester die, so waits only ever point one way in age and no cycle forms.
+type LockManager struct {
+	mu           sync.Mutex
+	resources    map[string]*resourceLock
+	held         map[string]map[string]LockMode
+	waiting      map[string]*lockRequest
+	transactions map[string]*Transaction
+	// aborted holds the error of aborted transactions until ReleaseAll, so
+	// a wounded transaction notices on its next Acquire or Seal
+	aborted map[string]error
+	// sealed transactions are committing and can no longer be wounded
+	sealed    map[string]bool
+	deadlocks []models.DeadlockReport
+	aborts    []models.LockAbort
+	onChange  func()
+}
+
+// NewLockManager creates an empty lock table
+func NewLockManager() *LockManager {
+	return &LockManager{
+		resources:    make(map[string]*resourceLock),
+		held:         make(map[string]map[string]LockMode),
+		waiting:      make(map[string]*lockRequest),
+		transactions: make(map[string]*Transaction),
+		aborted:      make(map[string]error),
+		sealed:       make(map[string]bool),
+	}
+}
+
+// SetOnChange registers fn to run, outside the lock table's mutex, after every
+// change to the wait-for graph
+func (lm *LockManager) SetOnChange(fn func()) {
+	lm.mu.Lock()
+	defer lm.mu.Unlock()
+	lm.onChange = fn
+}
+
+// Acquire locks resource for transaction, blocking until the lock is granted.
+// A shared lock held by the transaction is upgraded when exclusive is
+// requested. It returns a *DeadlockError or *AbortError if the transaction
+// was aborted, before or while waiting; its locks have then already been
+// released.
+func (lm *LockManager) Acquire(transaction *Transaction, resource string, mode LockMode) error {
+	lm.mu.Lock()
+
+	if err, wounded := lm.aborted[transaction.ID]; wounded {
+		lm.mu.Unlock()
+		return err
+	}
+
+	if _, waiting := lm.waiting[transaction.ID]; waiting {
+		lm.mu.Unlock()
+		return fmt.Errorf("transaction %s is already waiting for a lock", transaction.ID)
+	}
+
+	lm.transactions[transaction.ID] = transaction
+	lock := lm.resource(resource)
+
+	current, holding := lock.holders[transaction.ID]
+	if holding && (current == LockExclusive || mode == LockShared) {
+		lm.mu.Unlock()
+		return nil
+	}
+
+	request := &lockRequest{
+		transaction: transaction,
+		resource:    resource,
+		mode:        mode,
+		done:        make(chan error, 1),
+	}
+
+	// Upgrades jump the queue: the upgrader already holds the resource, so
+	// anyone queued behind it is waiting for it anyway
+	if (holding || len(lock.queue) == 0) && lm.grantable(lock, request) {
+		lm.grant(lock, request)
+		onChange := lm.onChange
+		lm.mu.Unlock()
+		notify(onChange)
+		return nil
+	}
+
+	if holding {
+		lock.queue = append([]*lockRequest{request}, lock.queue...)
+	} else {
+		lock.queue = append(lock.queue, request)
+	}
+	lm.waiting[transaction.ID] = request
+
+	switch transaction.Strategy {
+	case StrategyWaitDie:
+		// The requester may only wait for younger transactions
+		for _, blocker := range lm.blockers(request) {
+			if !older(transaction, lm.transactions[blocker]) {
+				lm.abort(transaction.ID, &AbortError{TransactionID: transaction.ID, By: blocker, Cause: ErrDied})
+				break
+			}
+		}
+
+	case StrategyWoundWait:
+		// Younger blockers are wounded; the requester waits for older ones.
+		// A sealed blocker is already committing and releases shortly.
+		for _, blocker := range lm.blockers(request) {
+			if older(transaction, lm.transactions[blocker]) && !lm.sealed[blocker] {
+				lm.abort(blocker, &AbortError{TransactionID: blocker, By: transaction.ID, Cause: ErrWounded})
+			}
+		}
+		lm.promote(resource)
+	}
+	lm.detectDeadlocks()
+
+	onChange := lm.onChange
+	lm.mu.Unlock()
+	notify(onChange)
+
+	return <-request.done
+}
+
+// ReleaseAll releases every lock held by a transaction and cancels its
+// pending request, if any. Strict 2PL calls it only on commit or abort.
+func (lm *LockManager) ReleaseAll(transactionID string) {
+	lm.mu.Lock()
+	delete(lm.aborted, transactionID)
+	if _, known := lm.transactions[transactionID]; !known {
+		lm.mu.Unlock()
+		return
+	}
+
+	lm.release(transactionID, ErrNoTransaction)
+	lm.detectDeadlocks()
+
+	onChange := lm.onChange
+	lm.mu.Unlock()
+	notify(onChange)
+}
+
+// Seal marks a transaction as committing, so wound-wait can no longer abort
+// it. It returns the transaction's *AbortError if it was wounded first.
+func (lm *LockManager) Seal(transactionID string) error {
+	lm.mu.Lock()
+	defer lm.mu.Unlock()
+
+	if err, wounded := lm.aborted[transactionID]; wounded {
+		return err
+	}
+	if _, known := lm.transactions[transactionID]; known {
+		lm.sealed[transactionID] = true
+	}
+	return nil
+}
+
+// Graph returns a snapshot of the wait-for graph
+func (lm *LockManager) Graph() models.WaitForGraph {
+	lm.mu.Lock()
+	defer lm.mu.Unlock()
+
+	graph := models.WaitForGraph{
+		Transactions: []models.TransactionLocks{},
+		Edges:        lm.edges(),
+		Deadlocks:    append([]models.DeadlockReport(nil), lm.deadlocks...),
+		Aborts:       append([]models.LockAbort(nil), lm.aborts...),
+		Timestamp:    time.Now(),
+	}
+
+	for id, transaction := range lm.transactions {
+		entry := models.TransactionLocks{
+			TransactionID: id,
+			PlayerID:      transaction.PlayerID,
+			StartTime:     transaction.StartTime,
+			Held:          []models.LockGrant{},
+		}
+		for resource, mode := range lm.held[id] {
+			entry.Held = append(entry.Held, models.LockGrant{Resource: resource, Mode: mode.String()})
+		}
+		sort.Slice(entry.Held, func(i, j int) bool { return entry.Held[i].Resource < entry.Held[j].Resource })
+
+		if request, ok := lm.waiting[id]; ok {
+			entry.Waiting = &models.LockGrant{Resource: request.resource, Mode: request.mode.String()}
+		}
+		graph.Transactions = append(graph.Transactions, entry)
+	}
+	sort.Slice(graph.Transactions, func(i, j int) bool {
+		return older(lm.transactions[graph.Transactions[i].TransactionID], lm.transactions[graph.Transactions[j].TransactionID])
+	})
+
+	return graph
+}
+
+// resource returns the lock table entry of a resource, creating it if needed.
+// Caller must hold lm.mu.
+func (lm *LockManager) resource(name string) *resourceLock {
+	lock, ok := lm.resources[name]
+	if !ok {
+		lock = &resourceLock{holders: make(map[string]LockMode)}
+		lm.resources[name] = lock
+	}
+	return lock
+}
+
+// grantable reports whether request is compatible with every other holder.
+// Caller must hold lm.mu.
+func (lm *LockManager) grantable(lock *resourceLock, request *lockRequest) bool {
+	for holder, mode := range lock.holders {
+		if holder != request.transaction.ID && !compatible(mode, request.mode) {
+			return false
+		}
+	}
+	return true
+}
+
+// grant records request as held. Caller must hold lm.mu.
+func (lm *LockManager) grant(lock *resourceLock, request *lockRequest) {
+	id := request.transaction.ID
+	if current, ok := lock.holders[id]; !ok || request.mode > current {
+		lock.holders[id] = request.mode
+	}
+
+	if lm.held[id] == nil {
+		lm.held[id] = make(map[string]LockMode)
+	}
+	lm.held[id][request.resource] = lock.holders[id]
+}
+
+// promote grants queued requests on a resource, in order, until one has to
+// keep waiting. Caller must hold lm.mu.
+func (lm *LockManager) promote(name string) {
+	lock, ok := lm.resources[name]
+	if !ok {
+		return
+	}
+
+	for len(lock.queue) > 0 && lm.grantable(lock, lock.queue[0]) {
+		request := lock.queue[0]
+		lock.queue = lock.queue[1:]
+		delete(lm.waiting, request.transaction.ID)
+		lm.grant(lock, request)
+		request.done <- nil
+	}
+
+	if len(lock.holders) == 0 && len(lock.queue) == 0 {
+		delete(lm.resources, name)
+	}
+}
+
+// release drops a transaction from the lock table. Its pending request, if
+// any, fails with reason. Caller must hold lm.mu.
+func (lm *LockManager) release(transactionID string, reason error) {
+	var affected []string
+
+	if request, ok := lm.waiting[transactionID]; ok {
+		lock := lm.resources[request.resource]
+		for i, queued := range lock.queue {
+			if queued == request {
+				lock.queue = append(lock.queue[:i], lock.queue[i+1:]...)
+				break
+			}
+		}
+		delete(lm.waiting, transactionID)
+		request.done <- reason
+		affected = append(affected, request.resource)
+	}
+
+	for resource := range lm.held[transactionID] {
+		delete(lm.resources[resource].holders, transactionID)
+		affected = append(affected, resource)
+	}
+	delete(lm.held, transactionID)
+	delete(lm.transactions, transactionID)
+	delete(lm.sealed, transactionID)
+
+	sort.Strings(affected)
+	for _, resource := range affected {
+		lm.promote(resource)
+	}
+}
+
+// abort aborts a transaction on behalf of a prevention policy. A waiting
+// transaction gets err from Acquire; a running one when it next locks or
+// seals. Caller must hold lm.mu.
+func (lm *LockManager) abort(transactionID string, err *AbortError) {
+	lm.aborts = append(lm.aborts, models.LockAbort{
+		TransactionID: transactionID,
+		Reason:        err.Reason(),
+		By:            err.By,
+		AbortedAt:     time.Now(),
+	})
+	if len(lm.aborts) > lockHistorySize {
+		lm.aborts = lm.aborts[1:]
+	}
+
+	lm.aborted[transactionID] = err
+	lm.release(transactionID, err)
+}
+
+// blockers returns the transactions a waiting request waits for: the holders
+// and earlier waiters of its resource whose modes conflict with its own.
+// Caller must hold lm.mu.
+func (lm *LockManager) blockers(request *lockRequest) []string {
+	lock := lm.resources[request.resource]
+	id := request.transaction.ID
+	seen := make(map[string]bool)
+	var blockers []string
+
+	add := func(blocker string, mode LockMode) {
+		if blocker != id && !compatible(mode, request.mode) && !seen[blocker] {
+			seen[blocker] = true
+			blockers = append(blockers, blocker)
+		}
+	}
+
+	for holder, mode := range lock.holders {
+		add(holder, mode)
+	}
+	for _, queued := range lock.queue {
+		if queued == request {
+			break
+		}
+		add(queued.transaction.ID, queued.mode)
+	}
+
+	sort.Strings(blockers)
+	return blockers
+}
+
+// edges computes the wait-for graph. Caller must hold lm.mu.
+func (lm *LockManager) edges() []models.WaitForEdge {
+	edges := []models.WaitForEdge{}
+
+	for id, request := range lm.waiting {
+		for _, holder := range lm.blockers(request) {
+			edges = append(edges, models.WaitForEdge{Waiter: id, Holder: holder, Resource: request.resource})
+		}
+	}
+
+	sort.Slice(edges, func(i, j int) bool {
+		if edges[i].Waiter != edges[j].Waiter {
+			return edges[i].Waiter < edges[j].Waiter
+		}
+		return edges[i].Holder < edges[j].Holder
+	})
+	return edges
+}
+
+// detectDeadlocks aborts the youngest transaction of each cycle in the
+// wait-for graph until none is left. Caller must hold lm.mu.
+func (lm *LockManager) detectDeadlocks() {
+	for {
+		cycle := findCycle(lm.edges())
+		if cycle == nil {
+			return
+		}
+
+		victim := 0
+		for i, id := range cycle {
+			if older(lm.transactions[cycle[victim]], lm.transactions[id]) {
+				victim = i
+			}
+		}
+		cycle = append(cycle[victim:], cycle[:victim]...)
+
+		lm.deadlocks = append(lm.deadlocks, models.DeadlockReport{
+			Cycle:      cycle,
+			Victim:     cycle[0],
+			DetectedAt: time.Now(),
+		})
+		if len(lm.deadlocks) > lockHistorySize {
+			lm.deadlocks = lm.deadlocks[1:]
+		}
+
+		err := &DeadlockError{TransactionID: cycle[0], Cycle: cycle}
+		lm.aborted[cycle[0]] = err
+		lm.release(cycle[0], err)
+	}
+}
+
+// findCycle returns the transactions of one cycle in the graph, in wait
+// order, or nil if the graph is acyclic
+func findCycle(edges []models.WaitForEdge) []string {
+	adjacent := make(map[string][]string)
+	for _, edge := range edges {
+		adjacent[edge.Waiter] = append(adjacent[edge.Waiter], edge.Holder)
+	}
+
+	const (
+		unvisited = iota
+		onPath
+		done
+	)
+	state := make(map[string]int)
+	var path []string
+
+	var visit func(id string) []string
+	visit = func(id string) []string {
+		state[id] = onPath
+		path = append(path, id)
+
+		for _, next := range adjacent[id] {
+			switch state[next] {
+			case onPath:
+				for i, member := range path {
+					if member == next {
+						return append([]string(nil), path[i:]...)
+					}
+				}
+			case unvisited:
+				if cycle := visit(next); cycle != nil {
+					return cycle
+				}
+			}
+		}
+
+		path = path[:len(path)-1]
+		state[id] = done
+		return nil
+	}
+
+	for _, edge := range edges {
+		if state[edge.Waiter] == unvisited {
+			if cycle := visit(edge.Waiter); cycle != nil {
+				return cycle
+			}
+		}
+	}
+	return nil
+}
+
+// older reports whether a started before b. Ties are broken by ID so every
+// transaction has a distinct priority.
+func older(a, b *Transaction) bool {
+	if !a.StartTime.Equal(b.StartTime) {
+		return a.StartTime.Before(b.StartTime)
+	}
+	return a.ID < b.ID
+}
+
+func notify(fn func()) {
+	if fn != nil {
+		fn()
+	}
+}
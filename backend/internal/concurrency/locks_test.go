@@ -0,0 +1,240 @@
+package concurrency
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func newTestTransaction(id string, start time.Time) *Transaction {
+	return &Transaction{ID: id, PlayerID: id, StartTime: start}
+}
+
+// waitForEdges polls until the wait-for graph has n edges
+func waitForEdges(t *testing.T, lm *LockManager, n int) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for len(lm.Graph().Edges) != n {
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected %d wait-for edges, got %+v", n, lm.Graph().Edges)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestExclusiveLockBlocksUntilRelease(t *testing.T) {
+	lm := NewLockManager()
+	now := time.Now()
+	tx1 := newTestTransaction("tx1", now)
+	tx2 := newTestTransaction("tx2", now.Add(time.Millisecond))
+
+	if err := lm.Acquire(tx1, "r", LockExclusive); err != nil {
+		t.Fatalf("Failed to lock: %v", err)
+	}
+
+	granted := make(chan error, 1)
+	go func() { granted <- lm.Acquire(tx2, "r", LockShared) }()
+
+	waitForEdges(t, lm, 1)
+	edge := lm.Graph().Edges[0]
+	if edge.Waiter != "tx2" || edge.Holder != "tx1" || edge.Resource != "r" {
+		t.Errorf("Unexpected edge %+v", edge)
+	}
+
+	select {
+	case <-granted:
+		t.Fatal("Shared lock granted while an exclusive lock was held")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	lm.ReleaseAll("tx1")
+	if err := <-granted; err != nil {
+		t.Fatalf("Expected the lock after release, got %v", err)
+	}
+	waitForEdges(t, lm, 0)
+}
+
+func TestSharedLocksAreCompatible(t *testing.T) {
+	lm := NewLockManager()
+	now := time.Now()
+
+	for _, id := range []string{"tx1", "tx2"} {
+		if err := lm.Acquire(newTestTransaction(id, now), "r", LockShared); err != nil {
+			t.Fatalf("Failed to lock: %v", err)
+		}
+	}
+
+	if got := len(lm.Graph().Transactions); got != 2 {
+		t.Errorf("Expected 2 lock holders, got %d", got)
+	}
+}
+
+func TestDeadlockAbortsYoungestTransaction(t *testing.T) {
+	lm := NewLockManager()
+	now := time.Now()
+	older := newTestTransaction("older", now)
+	younger := newTestTransaction("younger", now.Add(time.Millisecond))
+
+	changes := make(chan struct{}, 16)
+	lm.SetOnChange(func() {
+		select {
+		case changes <- struct{}{}:
+		default:
+		}
+	})
+
+	if err := lm.Acquire(older, "a", LockExclusive); err != nil {
+		t.Fatal(err)
+	}
+	if err := lm.Acquire(younger, "b", LockExclusive); err != nil {
+		t.Fatal(err)
+	}
+
+	olderDone := make(chan error, 1)
+	go func() { olderDone <- lm.Acquire(older, "b", LockExclusive) }()
+	waitForEdges(t, lm, 1)
+
+	// Closing the cycle aborts the younger transaction and frees b for the older one
+	err := lm.Acquire(younger, "a", LockExclusive)
+	var deadlock *DeadlockError
+	if !errors.As(err, &deadlock) || !errors.Is(err, ErrDeadlock) {
+		t.Fatalf("Expected a DeadlockError, got %v", err)
+	}
+	if deadlock.TransactionID != "younger" || len(deadlock.Cycle) != 2 || deadlock.Cycle[0] != "younger" {
+		t.Errorf("Unexpected deadlock %+v", deadlock)
+	}
+
+	if err := <-olderDone; err != nil {
+		t.Fatalf("Expected the older transaction to get b, got %v", err)
+	}
+
+	graph := lm.Graph()
+	if len(graph.Deadlocks) != 1 || graph.Deadlocks[0].Victim != "younger" {
+		t.Errorf("Expected one recorded deadlock, got %+v", graph.Deadlocks)
+	}
+	if len(graph.Transactions) != 1 || len(graph.Transactions[0].Held) != 2 {
+		t.Errorf("Expected the older transaction to hold a and b, got %+v", graph.Transactions)
+	}
+	if len(changes) == 0 {
+		t.Error("Expected change notifications")
+	}
+}
+
+func TestLockUpgradeDeadlock(t *testing.T) {
+	lm := NewLockManager()
+	now := time.Now()
+	older := newTestTransaction("older", now)
+	younger := newTestTransaction("younger", now.Add(time.Millisecond))
+
+	lm.Acquire(older, "r", LockShared)
+	lm.Acquire(younger, "r", LockShared)
+
+	olderDone := make(chan error, 1)
+	go func() { olderDone <- lm.Acquire(older, "r", LockExclusive) }()
+	waitForEdges(t, lm, 1)
+
+	if err := lm.Acquire(younger, "r", LockExclusive); !errors.Is(err, ErrDeadlock) {
+		t.Fatalf("Expected ErrDeadlock for the younger upgrader, got %v", err)
+	}
+	if err := <-olderDone; err != nil {
+		t.Fatalf("Expected the older upgrade to succeed, got %v", err)
+	}
+}
+
+func TestWoundWaitAbortsYoungerHolder(t *testing.T) {
+	lm := NewLockManager()
+	now := time.Now()
+	older := &Transaction{ID: "older", StartTime: now, Strategy: StrategyWoundWait}
+	younger := &Transaction{ID: "younger", StartTime: now.Add(time.Millisecond), Strategy: StrategyWoundWait}
+
+	if err := lm.Acquire(younger, "r", LockExclusive); err != nil {
+		t.Fatal(err)
+	}
+
+	// The older transaction wounds the holder and gets the lock at once
+	if err := lm.Acquire(older, "r", LockExclusive); err != nil {
+		t.Fatalf("Expected the older transaction to wound and proceed, got %v", err)
+	}
+
+	var abort *AbortError
+	err := lm.Seal("younger")
+	if !errors.As(err, &abort) || !errors.Is(err, ErrWounded) {
+		t.Fatalf("Expected the younger transaction to be wounded, got %v", err)
+	}
+	if abort.Reason() != "wounded by tx older" {
+		t.Errorf("Unexpected reason %q", abort.Reason())
+	}
+	if err := lm.Acquire(younger, "other", LockShared); !errors.Is(err, ErrWounded) {
+		t.Errorf("Expected a wounded transaction to stay aborted, got %v", err)
+	}
+
+	graph := lm.Graph()
+	if len(graph.Aborts) != 1 || graph.Aborts[0].Reason != "wounded by tx older" || graph.Aborts[0].By != "older" {
+		t.Errorf("Expected the wound in the graph, got %+v", graph.Aborts)
+	}
+
+	// A younger requester waits instead
+	lm.ReleaseAll("younger")
+	waiter := &Transaction{ID: "waiter", StartTime: now.Add(time.Second), Strategy: StrategyWoundWait}
+	granted := make(chan error, 1)
+	go func() { granted <- lm.Acquire(waiter, "r", LockExclusive) }()
+	waitForEdges(t, lm, 1)
+
+	lm.ReleaseAll("older")
+	if err := <-granted; err != nil {
+		t.Fatalf("Expected the younger transaction to get the lock after waiting, got %v", err)
+	}
+}
+
+func TestWoundWaitSparesSealedHolder(t *testing.T) {
+	lm := NewLockManager()
+	now := time.Now()
+	older := &Transaction{ID: "older", StartTime: now, Strategy: StrategyWoundWait}
+	younger := &Transaction{ID: "younger", StartTime: now.Add(time.Millisecond), Strategy: StrategyWoundWait}
+
+	lm.Acquire(younger, "r", LockExclusive)
+	if err := lm.Seal("younger"); err != nil {
+		t.Fatal(err)
+	}
+
+	granted := make(chan error, 1)
+	go func() { granted <- lm.Acquire(older, "r", LockExclusive) }()
+	waitForEdges(t, lm, 1)
+
+	lm.ReleaseAll("younger")
+	if err := <-granted; err != nil {
+		t.Fatalf("Expected the lock once the committing holder released it, got %v", err)
+	}
+}
+
+func TestWaitDieAbortsYoungerRequester(t *testing.T) {
+	lm := NewLockManager()
+	now := time.Now()
+	older := &Transaction{ID: "older", StartTime: now, Strategy: StrategyWaitDie}
+	younger := &Transaction{ID: "younger", StartTime: now.Add(time.Millisecond), Strategy: StrategyWaitDie}
+
+	lm.Acquire(older, "a", LockExclusive)
+	lm.Acquire(younger, "b", LockExclusive)
+
+	// The older transaction may wait for the younger one
+	olderDone := make(chan error, 1)
+	go func() { olderDone <- lm.Acquire(older, "b", LockExclusive) }()
+	waitForEdges(t, lm, 1)
+
+	// The younger one dies instead of waiting, releasing b
+	err := lm.Acquire(younger, "a", LockExclusive)
+	var abort *AbortError
+	if !errors.As(err, &abort) || !errors.Is(err, ErrDied) {
+		t.Fatalf("Expected the younger transaction to die, got %v", err)
+	}
+	if abort.Reason() != "died waiting on tx older" {
+		t.Errorf("Unexpected reason %q", abort.Reason())
+	}
+
+	if err := <-olderDone; err != nil {
+		t.Fatalf("Expected the older transaction to get b, got %v", err)
+	}
+	if graph := lm.Graph(); len(graph.Deadlocks) != 0 || len(graph.Aborts) != 1 {
+		t.Errorf("Expected a prevented deadlock, got %+v", graph)
+	}
+}
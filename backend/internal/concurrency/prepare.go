@@ -0,0 +1,198 @@
+package concurrency
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/AnishMulay/Transaction-Conflict-Visualization/pkg/models"
+)
+
+var (
+	// ErrInDoubt is wrapped by InDoubtError
+	ErrInDoubt = errors.New("object is held by a prepared transaction")
+	// ErrNotPrepared is returned when deciding a transaction that is not the
+	// prepared one
+	ErrNotPrepared = errors.New("transaction is not prepared")
+	// ErrPrepareReplicated is returned by Prepare on a controller whose commits
+	// go through a replicator, which cannot hold a commit back
+	ErrPrepareReplicated = errors.New("prepare is not supported with a replicator")
+)
+
+// InDoubtError reports a commit refused because the object is promised to a
+// prepared transaction whose coordinator has not decided yet. It wraps
+// ErrInDoubt.
+type InDoubtError struct {
+	TransactionID string
+	PreparedAt    time.Time
+}
+
+func (e *InDoubtError) Error() string {
+	return fmt.Sprintf("%v %s since %s, waiting for its coordinator",
+		ErrInDoubt, e.TransactionID, e.PreparedAt.Format(time.TimeOnly))
+}
+
+func (e *InDoubtError) Unwrap() error {
+	return ErrInDoubt
+}
+
+// preparedTransaction is a transaction that voted to commit and now waits for
+// its coordinator's decision. Blocked counts the commits refused meanwhile.
+type preparedTransaction struct {
+	transaction *Transaction
+	commit      ReplicatedCommit
+	preparedAt  time.Time
+	blocked     int
+}
+
+func (p *preparedTransaction) inDoubt() *InDoubtError {
+	return &InDoubtError{TransactionID: p.transaction.ID, PreparedAt: p.preparedAt}
+}
+
+// Prepare is the first phase of two-phase commit: the transaction's move is
+// checked as CommitTransaction would, and if it would commit the controller
+// promises to commit it whatever happens meanwhile. Until CommitPrepared or
+// AbortPrepared every other commit fails with an InDoubtError, and under
+// two-phase locking the transaction keeps its locks. Only one transaction can
+// be prepared at a time. A transaction that fails to prepare is aborted; the
+// error is its vote against.
+func (cc *ConcurrencyController) Prepare(transactionID string) error {
+	cc.mu.Lock()
+	transaction, exists := cc.activeTransactions[transactionID]
+	if !exists {
+		cc.mu.Unlock()
+		return ErrNoTransaction
+	}
+	delete(cc.activeTransactions, transactionID)
+
+	err := cc.prepare(transaction)
+	if errors.Is(err, ErrVersionMismatch) {
+		cc.conflictStats.ConflictCount++
+	}
+	if err != nil {
+		cc.ssi.abort(transactionID)
+	}
+	cc.mu.Unlock()
+
+	if err != nil {
+		cc.locks.ReleaseAll(transactionID)
+	}
+	return err
+}
+
+// prepare votes on transaction. Caller must hold cc.mu.
+func (cc *ConcurrencyController) prepare(transaction *Transaction) error {
+	if cc.replicator != nil {
+		return ErrPrepareReplicated
+	}
+	if err := cc.locks.Seal(transaction.ID); err != nil {
+		cc.recordLockAbort(err)
+		return err
+	}
+	if transaction.ProposedChanges == nil {
+		return ErrNoProposal
+	}
+	if cc.prepared != nil {
+		cc.prepared.blocked++
+		return cc.prepared.inDoubt()
+	}
+
+	commit := ReplicatedCommit{
+		TransactionID: transaction.ID,
+		PlayerID:      transaction.PlayerID,
+		RequestID:     transaction.RequestID,
+		Position:      transaction.ProposedChanges.Position,
+		CommittedAt:   transaction.ProposedChanges.LastUpdated,
+		ReadVersion:   transaction.InitialVersion,
+		Checked:       transaction.Isolation.checksWrites() || transaction.pinned,
+	}
+
+	// The same check the commit will run, which nothing can change until then
+	currentVersion := cc.gameState.GetState().Object.Version
+	if commit.Checked && commit.ReadVersion != currentVersion {
+		conflict := &ConflictError{
+			TransactionID:    commit.TransactionID,
+			PlayerID:         commit.PlayerID,
+			RequestID:        commit.RequestID,
+			ReadVersion:      commit.ReadVersion,
+			CurrentVersion:   currentVersion,
+			ProposedPosition: commit.Position,
+		}
+		if winner, ok := cc.commits[commit.ReadVersion+1]; ok {
+			conflict.Winner = &winner
+		}
+		return conflict
+	}
+	if err := cc.checkSerializable(transaction); err != nil {
+		return err
+	}
+
+	cc.prepared = &preparedTransaction{transaction: transaction, commit: commit, preparedAt: time.Now()}
+	return nil
+}
+
+// CommitPrepared is the second phase for a transaction the coordinator
+// decided to commit. The commit cannot fail its version check, since nothing
+// else committed while the transaction was prepared.
+func (cc *ConcurrencyController) CommitPrepared(transactionID string) (CommitRecord, error) {
+	defer cc.locks.ReleaseAll(transactionID)
+
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+
+	if cc.prepared == nil || cc.prepared.transaction.ID != transactionID {
+		return CommitRecord{}, ErrNotPrepared
+	}
+	prepared := cc.prepared
+	cc.prepared = nil
+
+	record, err := cc.applyCommit(prepared.commit)
+	if err != nil {
+		cc.ssi.abort(transactionID)
+		return record, err
+	}
+	if prepared.transaction.ssi != nil {
+		cc.ssi.commit(transactionID, record.Version)
+	}
+
+	cc.conflictStats.SuccessfulMoves++
+	cc.conflictStats.AverageLatency = updateAverageLatency(
+		cc.conflictStats.AverageLatency,
+		time.Since(prepared.transaction.StartTime),
+		cc.conflictStats.SuccessfulMoves,
+	)
+	return record, nil
+}
+
+// AbortPrepared is the second phase for a transaction the coordinator decided
+// to abort. The object is released unchanged.
+func (cc *ConcurrencyController) AbortPrepared(transactionID string) error {
+	defer cc.locks.ReleaseAll(transactionID)
+
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+
+	if cc.prepared == nil || cc.prepared.transaction.ID != transactionID {
+		return ErrNotPrepared
+	}
+	cc.prepared = nil
+	cc.ssi.abort(transactionID)
+	return nil
+}
+
+// PreparedTransaction describes the transaction holding the object for its
+// coordinator, if there is one
+func (cc *ConcurrencyController) PreparedTransaction() (models.PreparedTransaction, bool) {
+	cc.mu.RLock()
+	defer cc.mu.RUnlock()
+
+	if cc.prepared == nil {
+		return models.PreparedTransaction{}, false
+	}
+	return models.PreparedTransaction{
+		TransactionID: cc.prepared.transaction.ID,
+		Position:      cc.prepared.commit.Position,
+		PreparedAt:    cc.prepared.preparedAt,
+		Blocked:       cc.prepared.blocked,
+	}, true
+}
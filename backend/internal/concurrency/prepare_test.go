@@ -0,0 +1,128 @@
+package concurrency
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/AnishMulay/Transaction-Conflict-Visualization/pkg/models"
+)
+
+// prepareMove begins, proposes and prepares a move
+func prepareMove(t *testing.T, controller *ConcurrencyController, player, direction string) (*Transaction, error) {
+	t.Helper()
+	transaction, _ := controller.BeginTransaction(player, direction)
+	if err := controller.ProposeMove(transaction.ID, direction); err != nil {
+		t.Fatalf("Propose failed: %v", err)
+	}
+	return transaction, controller.Prepare(transaction.ID)
+}
+
+func TestPreparedTransactionHoldsObject(t *testing.T) {
+	gameState := models.NewGameState(models.Position{X: 10, Y: 10})
+	controller := NewConcurrencyController(gameState)
+
+	// A move that read the same version is still in flight
+	other, _ := controller.BeginTransaction("player", "req")
+	controller.ProposeMove(other.ID, "down")
+
+	prepared, err := prepareMove(t, controller, "coordinator", "right")
+	if err != nil {
+		t.Fatalf("Prepare failed: %v", err)
+	}
+
+	// Until the decision, other commits and prepares are refused
+	_, err = controller.CommitTransaction(other.ID)
+	var inDoubt *InDoubtError
+	if !errors.As(err, &inDoubt) || inDoubt.TransactionID != prepared.ID {
+		t.Fatalf("Expected an in-doubt error naming %s, got %v", prepared.ID, err)
+	}
+	if _, err := prepareMove(t, controller, "coordinator", "up"); !errors.Is(err, ErrInDoubt) {
+		t.Errorf("Expected a second prepare to vote no, got %v", err)
+	}
+	if status, ok := controller.PreparedTransaction(); !ok || status.Blocked != 2 || status.Position != (models.Position{X: 6, Y: 5}) {
+		t.Errorf("Expected the prepared move with 2 blocked commits, got %+v", status)
+	}
+	if object := controller.Object(); object.Version != 1 {
+		t.Errorf("Expected the object untouched until commit, got %+v", object)
+	}
+
+	record, err := controller.CommitPrepared(prepared.ID)
+	if err != nil || record.Version != 2 || record.Position != (models.Position{X: 6, Y: 5}) {
+		t.Fatalf("Expected the prepared move committed at version 2, got %+v, %v", record, err)
+	}
+	if _, ok := controller.PreparedTransaction(); ok {
+		t.Error("Expected nothing prepared after the commit")
+	}
+	if _, err := controller.CommitPrepared(prepared.ID); !errors.Is(err, ErrNotPrepared) {
+		t.Errorf("Expected a second commit to fail, got %v", err)
+	}
+}
+
+func TestPrepareVotesAgainstStaleMove(t *testing.T) {
+	gameState := models.NewGameState(models.Position{X: 10, Y: 10})
+	controller := NewConcurrencyController(gameState)
+
+	stale, _ := controller.BeginTransaction("coordinator", "req")
+	controller.ProposeMove(stale.ID, "left")
+	prepared, err := prepareMove(t, controller, "player", "up")
+	if err != nil {
+		t.Fatalf("Prepare failed: %v", err)
+	}
+	if err := controller.AbortPrepared(prepared.ID); err != nil {
+		t.Fatalf("AbortPrepared failed: %v", err)
+	}
+
+	// Aborting released the object unchanged, and a fresh move goes through
+	if object := controller.Object(); object.Version != 1 {
+		t.Fatalf("Expected the aborted move discarded, got %+v", object)
+	}
+	move, _ := controller.BeginTransaction("player", "req2")
+	controller.ProposeMove(move.ID, "down")
+	if _, err := controller.CommitTransaction(move.ID); err != nil {
+		t.Fatalf("Commit after abort failed: %v", err)
+	}
+
+	// The move that read version 1 cannot promise to commit any more
+	var conflict *ConflictError
+	if err := controller.Prepare(stale.ID); !errors.As(err, &conflict) || conflict.CurrentVersion != 2 {
+		t.Errorf("Expected a conflict vote, got %v", err)
+	}
+	if err := controller.Prepare(stale.ID); !errors.Is(err, ErrNoTransaction) {
+		t.Errorf("Expected the transaction gone after voting no, got %v", err)
+	}
+}
+
+func TestPreparedTransactionKeepsLocks(t *testing.T) {
+	gameState := models.NewGameState(models.Position{X: 10, Y: 10})
+	controller := NewConcurrencyController(gameState)
+	controller.SetLockingStrategy(StrategyTwoPhaseLocking)
+
+	prepared, err := prepareMove(t, controller, "coordinator", "right")
+	if err != nil {
+		t.Fatalf("Prepare failed: %v", err)
+	}
+
+	// Under 2PL a player's move waits for the decision instead of failing
+	waiter, _ := controller.BeginTransaction("player", "req")
+	proposed := make(chan error, 1)
+	go func() { proposed <- controller.ProposeMove(waiter.ID, "down") }()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for len(controller.WaitForGraph().Edges) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("The move never waited for the prepared transaction's lock")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	if _, err := controller.CommitPrepared(prepared.ID); err != nil {
+		t.Fatalf("CommitPrepared failed: %v", err)
+	}
+	if err := <-proposed; err != nil {
+		t.Fatalf("Propose after the decision failed: %v", err)
+	}
+	if snapshot, err := controller.CommitTransaction(waiter.ID); err != nil || snapshot.Object.Position != (models.Position{X: 6, Y: 6}) {
+		t.Errorf("Expected the waiting move on top of the prepared one, got %+v, %v", snapshot, err)
+	}
+}
@@ -0,0 +1,59 @@
+package concurrency
+
+import (
+	"errors"
+	"time"
+
+	"github.com/AnishMulay/Transaction-Conflict-Visualization/pkg/models"
+)
+
+// ErrUnavailable is wrapped by a Replicator that could not get a commit
+// replicated, for example while no leader is elected. The commit may still
+// take effect later.
+var ErrUnavailable = errors.New("replicated log unavailable")
+
+// Replicator orders commits through a replicated log before they take
+// effect. Replicate returns once the commit has been applied on this node,
+// with the result ApplyCommit produced for it.
+type Replicator interface {
+	Replicate(commit ReplicatedCommit) (CommitRecord, error)
+}
+
+// ReplicatedCommit is a move commit as it travels through the replicated log:
+// the new position and the version check to run when it is applied. If
+// Checked is set the commit fails unless the object is still at ReadVersion.
+type ReplicatedCommit struct {
+	TransactionID string          `json:"transactionId"`
+	PlayerID      string          `json:"playerId"`
+	RequestID     string          `json:"requestId"`
+	Position      models.Position `json:"position"`
+	CommittedAt   time.Time       `json:"committedAt"`
+	ReadVersion   int64           `json:"readVersion"`
+	Checked       bool            `json:"checked"`
+}
+
+// SetReplicator routes commits through a replicated log. Without one they are
+// applied directly.
+func (cc *ConcurrencyController) SetReplicator(replicator Replicator) {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	cc.replicator = replicator
+}
+
+// InstallObject replaces the object with a copy transferred from a replica
+// further along the same commit order, for a replica that joined late
+func (cc *ConcurrencyController) InstallObject(object models.GameObject) {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+
+	cc.gameState.Mu.Lock()
+	defer cc.gameState.Mu.Unlock()
+
+	cc.gameState.Object = &object
+	cc.gameState.Version++
+}
+
+// Object returns a copy of the object as this replica last applied it
+func (cc *ConcurrencyController) Object() models.GameObject {
+	return *cc.gameState.GetState().Object
+}
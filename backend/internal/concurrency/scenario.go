@@ -0,0 +1,265 @@
+package concurrency
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/AnishMulay/Transaction-Conflict-Visualization/pkg/models"
+)
+
+// scenarioOp is an operation in a scripted scenario
+type scenarioOp string
+
+const (
+	opRead   scenarioOp = "read"
+	opWrite  scenarioOp = "write"
+	opAdd    scenarioOp = "add"
+	opCommit scenarioOp = "commit"
+	opAbort  scenarioOp = "abort"
+)
+
+// scenarioStep is one operation of one transaction. An add writes the value
+// the transaction last read for the key plus value.
+type scenarioStep struct {
+	transaction string
+	op          scenarioOp
+	key         string
+	value       int64
+}
+
+// scenarioRun records what the transactions of a scenario observed
+type scenarioRun struct {
+	reads     map[string]map[string][]int64
+	committed map[string]bool
+	final     map[string]int64
+}
+
+// lastRead returns the value a transaction most recently read for key
+func (r *scenarioRun) lastRead(transaction, key string) int64 {
+	values := r.reads[transaction][key]
+	if len(values) == 0 {
+		return 0
+	}
+	return values[len(values)-1]
+}
+
+// Scenario is a scripted interleaving of transactions that produces a
+// classic anomaly when the isolation level lets it through
+type Scenario struct {
+	Name        string
+	Anomaly     models.AnomalyKind
+	Description string
+	Initial     map[string]int64
+
+	steps []scenarioStep
+	// check describes the anomaly if it happened, or returns ""
+	check func(run *scenarioRun) string
+}
+
+// Scenarios lists the scripted anomaly scenarios
+var Scenarios = []Scenario{
+	{
+		Name:        "dirty-read",
+		Anomaly:     models.AnomalyDirtyRead,
+		Description: "T1 writes x and aborts after T2 has read it",
+		Initial:     map[string]int64{"x": 100},
+		steps: []scenarioStep{
+			{"T1", opWrite, "x", 200},
+			{"T2", opRead, "x", 0},
+			{"T1", opAbort, "", 0},
+			{"T2", opCommit, "", 0},
+		},
+		check: func(run *scenarioRun) string {
+			if seen := run.lastRead("T2", "x"); seen == 200 && !run.committed["T1"] {
+				return "T2 read x=200, a value written by T1 that was never committed"
+			}
+			return ""
+		},
+	},
+	{
+		Name:        "lost-update",
+		Anomaly:     models.AnomalyLostUpdate,
+		Description: "T1 adds 10 to x and T2 adds 20 to x, both read-modify-write from the same starting value",
+		Initial:     map[string]int64{"x": 100},
+		steps: []scenarioStep{
+			{"T1", opRead, "x", 0},
+			{"T2", opRead, "x", 0},
+			{"T1", opAdd, "x", 10},
+			{"T1", opCommit, "", 0},
+			{"T2", opAdd, "x", 20},
+			{"T2", opCommit, "", 0},
+		},
+		check: func(run *scenarioRun) string {
+			if run.committed["T1"] && run.committed["T2"] && run.final["x"] != 130 {
+				return fmt.Sprintf("T1 and T2 both committed, but x is %d instead of 130: T2 overwrote T1's update", run.final["x"])
+			}
+			return ""
+		},
+	},
+	{
+		Name:        "read-skew",
+		Anomaly:     models.AnomalyReadSkew,
+		Description: "T2 moves 25 from x to y while T1 reads x and then y; x+y is always 100",
+		Initial:     map[string]int64{"x": 50, "y": 50},
+		steps: []scenarioStep{
+			{"T1", opRead, "x", 0},
+			{"T2", opRead, "x", 0},
+			{"T2", opRead, "y", 0},
+			{"T2", opAdd, "x", -25},
+			{"T2", opAdd, "y", 25},
+			{"T2", opCommit, "", 0},
+			{"T1", opRead, "y", 0},
+			{"T1", opCommit, "", 0},
+		},
+		check: func(run *scenarioRun) string {
+			x, y := run.lastRead("T1", "x"), run.lastRead("T1", "y")
+			if x+y != 100 {
+				return fmt.Sprintf("T1 saw x=%d and y=%d, a total of %d that no committed state ever had", x, y, x+y)
+			}
+			return ""
+		},
+	},
+	{
+		Name:        "write-skew",
+		Anomaly:     models.AnomalyWriteSkew,
+		Description: "x and y are two doctors on call; each transaction checks that the other is on call (x+y >= 1) and takes itself off",
+		Initial:     map[string]int64{"x": 1, "y": 1},
+		steps: []scenarioStep{
+			{"T1", opRead, "x", 0},
+			{"T1", opRead, "y", 0},
+			{"T2", opRead, "x", 0},
+			{"T2", opRead, "y", 0},
+			{"T1", opWrite, "x", 0},
+			{"T2", opWrite, "y", 0},
+			{"T1", opCommit, "", 0},
+			{"T2", opCommit, "", 0},
+		},
+		check: func(run *scenarioRun) string {
+			if run.committed["T1"] && run.committed["T2"] && run.final["x"]+run.final["y"] < 1 {
+				return fmt.Sprintf("Both transactions saw x+y=2 and committed, leaving x+y=%d: nobody is on call", run.final["x"]+run.final["y"])
+			}
+			return ""
+		},
+	},
+	{
+		Name:        "read-only",
+		Anomaly:     models.AnomalyReadOnly,
+		Description: "T2 withdraws 10 from checking y and charges a fee of 1 if savings x plus y would go negative, T1 deposits 20 into x, and the read-only T3 reports x and y in between",
+		Initial:     map[string]int64{"x": 0, "y": 0},
+		steps: []scenarioStep{
+			{"T2", opRead, "x", 0},
+			{"T2", opRead, "y", 0},
+			{"T1", opRead, "x", 0},
+			{"T1", opAdd, "x", 20},
+			{"T1", opCommit, "", 0},
+			{"T3", opRead, "x", 0},
+			{"T3", opRead, "y", 0},
+			{"T3", opCommit, "", 0},
+			{"T2", opAdd, "y", -11},
+			{"T2", opCommit, "", 0},
+		},
+		check: func(run *scenarioRun) string {
+			if run.committed["T2"] && run.committed["T3"] && run.lastRead("T3", "x") == 20 && run.final["y"] == -11 {
+				return "T3 saw the deposit before the withdrawal, yet T2 charged the fee as if the deposit had not happened: no serial order explains both"
+			}
+			return ""
+		},
+	},
+}
+
+// FindScenario returns the scenario with the given name
+func FindScenario(name string) (Scenario, bool) {
+	for _, scenario := range Scenarios {
+		if scenario.Name == name {
+			return scenario, true
+		}
+	}
+	return Scenario{}, false
+}
+
+// Run plays the scenario against a fresh store under an isolation level. A
+// transaction whose operation fails is aborted and skips its later steps.
+func (s Scenario) Run(level IsolationLevel) models.ScenarioResult {
+	store := NewVersionedStore(s.Initial)
+	run := &scenarioRun{
+		reads:     make(map[string]map[string][]int64),
+		committed: make(map[string]bool),
+	}
+	transactions := make(map[string]*StoreTransaction)
+	var order []string
+	failed := make(map[string]bool)
+
+	result := models.ScenarioResult{
+		Scenario:       s.Name,
+		IsolationLevel: string(level),
+		Description:    s.Description,
+		Steps:          []models.ScenarioStep{},
+	}
+
+	for _, step := range s.steps {
+		if failed[step.transaction] {
+			continue
+		}
+
+		transaction, ok := transactions[step.transaction]
+		if !ok {
+			transaction = store.Begin(step.transaction, level)
+			transactions[step.transaction] = transaction
+			order = append(order, step.transaction)
+			run.reads[step.transaction] = make(map[string][]int64)
+		}
+
+		record := models.ScenarioStep{Transaction: step.transaction, Operation: string(step.op), Key: step.key}
+		var err error
+
+		switch step.op {
+		case opRead:
+			var value int64
+			value, err = transaction.Read(step.key)
+			run.reads[step.transaction][step.key] = append(run.reads[step.transaction][step.key], value)
+			record.Value = value
+		case opWrite:
+			record.Value = step.value
+			err = transaction.Write(step.key, step.value)
+		case opAdd:
+			record.Operation = string(opWrite)
+			record.Value = run.lastRead(step.transaction, step.key) + step.value
+			err = transaction.Write(step.key, record.Value)
+		case opCommit:
+			err = transaction.Commit()
+			run.committed[step.transaction] = err == nil
+		case opAbort:
+			transaction.Abort()
+		}
+
+		if err != nil {
+			switch {
+			case errors.Is(err, ErrWriteConflict):
+				result.WriteConflicts++
+			case errors.Is(err, ErrSerializationFailure):
+				result.SSIAborts++
+			}
+			record.Error = err.Error()
+			transaction.Abort()
+			failed[step.transaction] = true
+		}
+		result.Steps = append(result.Steps, record)
+	}
+
+	run.final = store.Snapshot()
+	result.Final = run.final
+
+	if description := s.check(run); description != "" {
+		result.Anomaly = &models.Anomaly{
+			Kind:           s.Anomaly,
+			IsolationLevel: string(level),
+			Description:    description,
+			Transactions:   order,
+			Scenario:       s.Name,
+			DetectedAt:     time.Now(),
+		}
+	}
+
+	return result
+}
@@ -0,0 +1,215 @@
+package concurrency
+
+import "fmt"
+
+// SSIError is returned by a serializable transaction whose commit would
+// complete a dangerous structure: In read a key Pivot wrote, Pivot read a
+// key Out wrote, and Out committed first. Every cycle in the serialization
+// graph of snapshot transactions contains such a structure.
+type SSIError struct {
+	TransactionID string
+	In            string
+	InKey         string
+	Pivot         string
+	OutKey        string
+	Out           string
+}
+
+func (e *SSIError) Error() string {
+	return fmt.Sprintf("%s: %s -rw(%s)-> %s -rw(%s)-> %s would complete a cycle, %s aborted",
+		ErrSerializationFailure, e.In, e.InKey, e.Pivot, e.OutKey, e.Out, e.TransactionID)
+}
+
+func (e *SSIError) Unwrap() error {
+	return ErrSerializationFailure
+}
+
+// ssiTransaction is what the tracker knows about a serializable transaction.
+// in and out map the other end of each rw-antidependency to the key behind it.
+type ssiTransaction struct {
+	id       string
+	startTS  int64
+	commitTS int64
+	reads    map[string]bool
+	writes   map[string]bool
+	// in holds readers of keys this transaction wrote (reader -rw-> this)
+	in map[string]string
+	// out holds writers of keys this transaction read (this -rw-> writer)
+	out map[string]string
+}
+
+func (t *ssiTransaction) committed() bool {
+	return t.commitTS > 0
+}
+
+// concurrent reports whether neither transaction committed before the other
+// began, so that neither saw the other's writes
+func (t *ssiTransaction) concurrent(other *ssiTransaction) bool {
+	return (!t.committed() || t.commitTS > other.startTS) &&
+		(!other.committed() || other.commitTS > t.startTS)
+}
+
+// ssiTracker implements serializable snapshot isolation. It records the read
+// and write set of each serializable transaction and the rw-antidependencies
+// between concurrent ones, and aborts a transaction only when committing it
+// could close a cycle. The mutex of the store or controller owning it guards
+// it.
+type ssiTracker struct {
+	transactions map[string]*ssiTransaction
+}
+
+func newSSITracker() *ssiTracker {
+	return &ssiTracker{transactions: make(map[string]*ssiTransaction)}
+}
+
+// begin starts tracking a transaction and returns its read and write sets
+func (s *ssiTracker) begin(id string, startTS int64) *ssiTransaction {
+	t := &ssiTransaction{
+		id:      id,
+		startTS: startTS,
+		reads:   make(map[string]bool),
+		writes:  make(map[string]bool),
+		in:      make(map[string]string),
+		out:     make(map[string]string),
+	}
+	s.transactions[id] = t
+	return t
+}
+
+// read records that id read key from its snapshot. Concurrent writers of the
+// key wrote a version the snapshot cannot see, so id precedes them.
+func (s *ssiTracker) read(id, key string) {
+	reader, ok := s.transactions[id]
+	if !ok {
+		return
+	}
+	reader.reads[key] = true
+
+	for _, writer := range s.transactions {
+		if writer != reader && writer.writes[key] && writer.concurrent(reader) {
+			addDependency(reader, writer, key)
+		}
+	}
+}
+
+// write records that id wrote key. Concurrent readers of the key read the
+// version before it, so they precede id.
+func (s *ssiTracker) write(id, key string) {
+	writer, ok := s.transactions[id]
+	if !ok {
+		return
+	}
+	writer.writes[key] = true
+
+	for _, reader := range s.transactions {
+		if reader != writer && reader.reads[key] && reader.concurrent(writer) {
+			addDependency(reader, writer, key)
+		}
+	}
+}
+
+// addDependency records reader -rw-> writer
+func addDependency(reader, writer *ssiTransaction, key string) {
+	reader.out[writer.id] = key
+	writer.in[reader.id] = key
+}
+
+// check returns an SSIError if committing id now would complete a dangerous
+// structure. Structures whose out transaction has not committed yet are left
+// for later commits, since the cycle needs it to commit first.
+func (s *ssiTracker) check(id string) error {
+	t, ok := s.transactions[id]
+	if !ok {
+		return nil
+	}
+
+	// t as the pivot: in -rw-> t -rw-> out with out already committed and in
+	// either still running or committed no earlier than out
+	for inID, inKey := range t.in {
+		in, ok := s.transactions[inID]
+		if !ok {
+			continue
+		}
+		for outID, outKey := range t.out {
+			out, ok := s.transactions[outID]
+			if !ok || !out.committed() {
+				continue
+			}
+			if !in.committed() || in.commitTS >= out.commitTS {
+				return &SSIError{TransactionID: id, In: inID, InKey: inKey, Pivot: id, OutKey: outKey, Out: outID}
+			}
+		}
+	}
+
+	// t as in: t -rw-> pivot -rw-> out where the pivot already committed
+	// after out, so t is the last chance to break the cycle
+	for pivotID, inKey := range t.out {
+		pivot, ok := s.transactions[pivotID]
+		if !ok || !pivot.committed() {
+			continue
+		}
+		for outID, outKey := range pivot.out {
+			out, ok := s.transactions[outID]
+			if ok && out.committed() && out.commitTS < pivot.commitTS {
+				return &SSIError{TransactionID: id, In: id, InKey: inKey, Pivot: pivotID, OutKey: outKey, Out: outID}
+			}
+		}
+	}
+
+	return nil
+}
+
+func (s *ssiTracker) commit(id string, commitTS int64) {
+	if t, ok := s.transactions[id]; ok {
+		t.commitTS = commitTS
+	}
+	s.prune()
+}
+
+// abort forgets an aborted transaction and its dependencies
+func (s *ssiTracker) abort(id string) {
+	t, ok := s.transactions[id]
+	if !ok {
+		return
+	}
+	for other := range t.in {
+		if reader, ok := s.transactions[other]; ok {
+			delete(reader.out, id)
+		}
+	}
+	for other := range t.out {
+		if writer, ok := s.transactions[other]; ok {
+			delete(writer.in, id)
+		}
+	}
+	delete(s.transactions, id)
+	s.prune()
+}
+
+// prune forgets committed transactions that committed before every running
+// one began, unless a committed pivot still needs them as its out
+// transaction. No new dependency can involve them.
+func (s *ssiTracker) prune() {
+	oldest := int64(-1)
+	for _, t := range s.transactions {
+		if !t.committed() && (oldest < 0 || t.startTS < oldest) {
+			oldest = t.startTS
+		}
+	}
+
+	keep := make(map[string]bool)
+	for id, t := range s.transactions {
+		if !t.committed() || (oldest >= 0 && t.commitTS > oldest) {
+			keep[id] = true
+			for out := range t.out {
+				keep[out] = true
+			}
+		}
+	}
+
+	for id := range s.transactions {
+		if !keep[id] {
+			delete(s.transactions, id)
+		}
+	}
+}
@@ -0,0 +1,152 @@
+package concurrency
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/AnishMulay/Transaction-Conflict-Visualization/pkg/models"
+)
+
+func TestSSICommitsWithoutCycle(t *testing.T) {
+	store := NewVersionedStore(map[string]int64{"x": 1, "y": 1})
+	t1 := store.Begin("T1", IsolationSerializable)
+	t2 := store.Begin("T2", IsolationSerializable)
+
+	// T1 -rw(x)-> T2 alone is serializable as T1, T2 even though T2
+	// overwrote what T1 read and committed first
+	t1.Read("x")
+	t2.Write("x", 2)
+	if err := t2.Commit(); err != nil {
+		t.Fatalf("T2 failed: %v", err)
+	}
+	t1.Write("y", 5)
+	if err := t1.Commit(); err != nil {
+		t.Fatalf("Expected T1 to commit without a cycle, got %v", err)
+	}
+
+	if got := store.Snapshot(); got["x"] != 2 || got["y"] != 5 {
+		t.Errorf("Unexpected final state %v", got)
+	}
+	if n := len(store.ssi.transactions); n != 0 {
+		t.Errorf("Expected finished transactions to be pruned, %d left", n)
+	}
+}
+
+func TestSSIAbortsPivot(t *testing.T) {
+	scenario, ok := FindScenario("write-skew")
+	if !ok {
+		t.Fatal("write-skew scenario missing")
+	}
+	run := scenario.Run(IsolationSerializable)
+
+	if run.SSIAborts != 1 || run.WriteConflicts != 0 {
+		t.Fatalf("Expected one SSI abort and no write conflict, got %+v", run)
+	}
+	last := run.Steps[len(run.Steps)-1]
+	if last.Transaction != "T2" || last.Operation != string(opCommit) || last.Error == "" {
+		t.Errorf("Expected T2's commit to fail, got %+v", last)
+	}
+
+	// Snapshot isolation has no dependency tracking to fall back on
+	if run := scenario.Run(IsolationSnapshot); run.SSIAborts != 0 || run.Anomaly == nil {
+		t.Errorf("Expected write skew under snapshot, got %+v", run)
+	}
+}
+
+func TestSSIReadOnlyAnomaly(t *testing.T) {
+	store := NewVersionedStore(map[string]int64{"x": 0, "y": 0})
+	t2 := store.Begin("T2", IsolationSerializable)
+	t2.Read("x")
+	t2.Read("y")
+
+	t1 := store.Begin("T1", IsolationSerializable)
+	t1.Read("x")
+	t1.Write("x", 20)
+	if err := t1.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	t3 := store.Begin("T3", IsolationSerializable)
+	t3.Read("x")
+	t3.Read("y")
+	if err := t3.Commit(); err != nil {
+		t.Fatalf("The read-only transaction should commit, got %v", err)
+	}
+
+	// T3 -rw(y)-> T2 -rw(x)-> T1 with T1 committed first
+	t2.Write("y", -11)
+	err := t2.Commit()
+	var ssi *SSIError
+	if !errors.As(err, &ssi) || !errors.Is(err, ErrSerializationFailure) {
+		t.Fatalf("Expected an SSIError, got %v", err)
+	}
+	want := SSIError{TransactionID: "T2", In: "T3", InKey: "y", Pivot: "T2", OutKey: "x", Out: "T1"}
+	if *ssi != want {
+		t.Errorf("Expected %+v, got %+v", want, *ssi)
+	}
+	if got := store.Snapshot()["y"]; got != 0 {
+		t.Errorf("Expected T2's write to be discarded, y=%d", got)
+	}
+}
+
+func TestScenarioAbortsAreCountedSeparately(t *testing.T) {
+	controller := NewConcurrencyController(models.NewGameState(models.Position{X: 10, Y: 10}))
+
+	controller.RunScenario("lost-update", IsolationSnapshot)
+	controller.RunScenario("write-skew", IsolationSerializable)
+	controller.RunScenario("read-only", IsolationSerializable)
+
+	if stats := controller.GetConflictStats(); stats.ConflictCount != 1 || stats.SSIAborts != 2 || stats.Anomalies != 0 {
+		t.Errorf("Unexpected stats %+v", stats)
+	}
+}
+
+func TestSSITracksMoves(t *testing.T) {
+	controller := NewConcurrencyController(models.NewGameState(models.Position{X: 10, Y: 10}))
+	object := ObjectResource(controller.gameState.GetState().Object.ID)
+
+	t1, _ := controller.BeginTransaction("player1", "req1")
+	t2, _ := controller.BeginTransaction("player2", "req2")
+	controller.ProposeMove(t1.ID, "right")
+	controller.ProposeMove(t2.ID, "left")
+	if !t1.ssi.reads[object] || !t1.ssi.writes[object] {
+		t.Fatalf("Expected the move to read and write %s, got %+v", object, t1.ssi)
+	}
+
+	// Two moves of the same object conflict on the write before any cycle
+	if _, err := controller.CommitTransaction(t2.ID); err != nil {
+		t.Fatalf("T2 failed: %v", err)
+	}
+	if _, err := controller.CommitTransaction(t1.ID); !errors.Is(err, ErrVersionMismatch) {
+		t.Fatalf("Expected a version mismatch, got %v", err)
+	}
+	if stats := controller.GetConflictStats(); stats.ConflictCount != 1 || stats.SSIAborts != 0 {
+		t.Errorf("Expected one write-write conflict, got %+v", stats)
+	}
+	if n := len(controller.ssi.transactions); n != 0 {
+		t.Errorf("Expected finished moves to be pruned, %d left", n)
+	}
+
+	// in -rw(object)-> T3 -rw(demo:x)-> out, with out committed first
+	t3, _ := controller.BeginTransaction("player1", "req3")
+	controller.ProposeMove(t3.ID, "down")
+	readVersion := t3.InitialVersion
+	controller.ssi.read(t3.ID, "demo:x")
+	controller.ssi.begin("out", readVersion)
+	controller.ssi.write("out", "demo:x")
+	controller.ssi.commit("out", readVersion+1)
+	controller.ssi.begin("in", readVersion)
+	controller.ssi.read("in", object)
+
+	_, err := controller.CommitTransaction(t3.ID)
+	var ssiErr *SSIError
+	if !errors.As(err, &ssiErr) || ssiErr.Pivot != t3.ID {
+		t.Fatalf("Expected T3 to abort as the pivot, got %v", err)
+	}
+	if stats := controller.GetConflictStats(); stats.SSIAborts != 1 || stats.ConflictCount != 1 {
+		t.Errorf("Expected the SSI abort counted apart from conflicts, got %+v", stats)
+	}
+	if snapshot := controller.gameState.GetState(); snapshot.Object.Version != readVersion {
+		t.Errorf("Expected the aborted move not to commit, object at version %d", snapshot.Object.Version)
+	}
+}
@@ -0,0 +1,57 @@
+package concurrency
+
+import (
+	"fmt"
+	"strings"
+)
+
+// LockingStrategy selects how a controller keeps concurrent moves apart. It is
+// set per controller, and so per room.
+type LockingStrategy string
+
+const (
+	// StrategyOptimistic lets transactions run unblocked and rejects stale
+	// commits with a ConflictError
+	StrategyOptimistic LockingStrategy = "optimistic"
+	// StrategyTwoPhaseLocking makes transactions wait for exclusive locks
+	// that are held until commit or abort, so moves never conflict.
+	// Deadlocks are detected in the wait-for graph and broken by aborting
+	// the youngest transaction of the cycle.
+	StrategyTwoPhaseLocking LockingStrategy = "2pl"
+	// StrategyWoundWait is two-phase locking where an older transaction
+	// wounds (aborts) younger lock holders and a younger one waits
+	StrategyWoundWait LockingStrategy = "2pl-wound-wait"
+	// StrategyWaitDie is two-phase locking where an older transaction waits
+	// for younger lock holders and a younger one dies (aborts) instead
+	StrategyWaitDie LockingStrategy = "2pl-wait-die"
+)
+
+// Locking reports whether transactions under the strategy take locks
+func (s LockingStrategy) Locking() bool {
+	switch s {
+	case StrategyTwoPhaseLocking, StrategyWoundWait, StrategyWaitDie:
+		return true
+	}
+	return false
+}
+
+// ParseLockingStrategy parses a strategy name: optimistic (or occ), 2pl,
+// wound-wait or wait-die
+func ParseLockingStrategy(name string) (LockingStrategy, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "", "optimistic", "occ":
+		return StrategyOptimistic, nil
+	case "2pl", "two-phase-locking":
+		return StrategyTwoPhaseLocking, nil
+	case "wound-wait", "2pl-wound-wait":
+		return StrategyWoundWait, nil
+	case "wait-die", "2pl-wait-die":
+		return StrategyWaitDie, nil
+	}
+	return "", fmt.Errorf("unknown locking strategy %q, use optimistic, 2pl, wound-wait or wait-die", name)
+}
+
+// ObjectResource is the lock resource name of a game object
+func ObjectResource(objectID string) string {
+	return "object:" + objectID
+}
@@ -0,0 +1,127 @@
+package httpapi
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/AnishMulay/Transaction-Conflict-Visualization/internal/concurrency"
+	"github.com/AnishMulay/Transaction-Conflict-Visualization/pkg/models"
+)
+
+// Demo resources locked in opposite orders by the deadlock demo
+const (
+	demoResourceA = "demo:a"
+	demoResourceB = "demo:b"
+
+	defaultDemoHold = 500 * time.Millisecond
+	maxDemoHold     = 10 * time.Second
+)
+
+// LocksAPI serves the wait-for graph and a scripted deadlock
+type LocksAPI struct {
+	controller *concurrency.ConcurrencyController
+}
+
+// DemoTransaction is the outcome of one transaction in the deadlock demo
+type DemoTransaction struct {
+	TransactionID string   `json:"transactionId"`
+	LockOrder     []string `json:"lockOrder"`
+	Outcome       string   `json:"outcome"`
+	Error         string   `json:"error,omitempty"`
+}
+
+// DeadlockDemoResult is the response of POST /locks/deadlock
+type DeadlockDemoResult struct {
+	Transactions []DemoTransaction   `json:"transactions"`
+	Graph        models.WaitForGraph `json:"graph"`
+}
+
+// NewLocksAPI creates the lock inspection API
+func NewLocksAPI(controller *concurrency.ConcurrencyController) *LocksAPI {
+	return &LocksAPI{controller: controller}
+}
+
+// Register adds the API routes to mux
+func (a *LocksAPI) Register(mux *http.ServeMux) {
+	mux.HandleFunc("GET /locks", a.getGraph)
+	mux.HandleFunc("POST /locks/deadlock", a.runDeadlock)
+}
+
+func (a *LocksAPI) getGraph(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, a.controller.WaitForGraph())
+}
+
+// runDeadlock starts two transactions that lock demo:a and demo:b in opposite
+// orders, pausing for ?hold= (default 500ms) after the first lock so the
+// cycle forms where WebSocket clients can watch it. The controller aborts one
+// of them, or under wound-wait and wait-die prevents the cycle by aborting
+// one early; the survivor holds both locks for another pause and then aborts.
+func (a *LocksAPI) runDeadlock(w http.ResponseWriter, r *http.Request) {
+	hold := defaultDemoHold
+	if value := r.URL.Query().Get("hold"); value != "" {
+		parsed, err := time.ParseDuration(value)
+		if err != nil || parsed < 0 || parsed > maxDemoHold {
+			writeError(w, http.StatusBadRequest, models.ErrorResponse{
+				Message: "hold must be a duration between 0 and " + maxDemoHold.String(),
+				Code:    models.ErrorCodeMalformed,
+				Field:   "hold",
+			})
+			return
+		}
+		hold = parsed
+	}
+
+	orders := [][]string{
+		{demoResourceA, demoResourceB},
+		{demoResourceB, demoResourceA},
+	}
+	results := make([]DemoTransaction, len(orders))
+
+	var wg sync.WaitGroup
+	for i, order := range orders {
+		transaction, err := a.controller.BeginTransaction("deadlock-demo", strconv.Itoa(i+1))
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, models.ErrorResponse{
+				Message: "Failed to begin transaction",
+				Code:    models.ErrorCodeTransaction,
+			})
+			return
+		}
+		results[i] = DemoTransaction{TransactionID: transaction.ID, LockOrder: order}
+
+		wg.Add(1)
+		go func(result *DemoTransaction) {
+			defer wg.Done()
+			defer a.controller.AbortTransaction(result.TransactionID)
+
+			for step, resource := range result.LockOrder {
+				if err := a.controller.Lock(result.TransactionID, resource, concurrency.LockExclusive); err != nil {
+					result.Outcome = "aborted"
+					var abort *concurrency.AbortError
+					if errors.As(err, &abort) {
+						result.Outcome = abort.Reason()
+					} else if errors.Is(err, concurrency.ErrDeadlock) {
+						result.Outcome = "deadlock victim"
+					}
+					result.Error = err.Error()
+					return
+				}
+				if step == 0 {
+					time.Sleep(hold)
+				}
+			}
+
+			result.Outcome = "acquired all locks"
+			time.Sleep(hold)
+		}(&results[i])
+	}
+	wg.Wait()
+
+	writeJSON(w, http.StatusOK, DeadlockDemoResult{
+		Transactions: results,
+		Graph:        a.controller.WaitForGraph(),
+	})
+}
@@ -0,0 +1,51 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/AnishMulay/Transaction-Conflict-Visualization/internal/concurrency"
+	"github.com/AnishMulay/Transaction-Conflict-Visualization/pkg/models"
+)
+
+func TestDeadlockDemo(t *testing.T) {
+	gameState := models.NewGameState(models.Position{X: 10, Y: 10})
+	controller := concurrency.NewConcurrencyController(gameState)
+
+	mux := http.NewServeMux()
+	NewLocksAPI(controller).Register(mux)
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	resp, err := http.Post(server.URL+"/locks/deadlock?hold=20ms", "application/json", nil)
+	if err != nil {
+		t.Fatalf("POST /locks/deadlock failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", resp.StatusCode)
+	}
+
+	var result DeadlockDemoResult
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		t.Fatalf("Failed to decode result: %v", err)
+	}
+
+	outcomes := make(map[string]int)
+	for _, transaction := range result.Transactions {
+		outcomes[transaction.Outcome]++
+	}
+	if outcomes["deadlock victim"] != 1 || outcomes["acquired all locks"] != 1 {
+		t.Errorf("Expected one victim and one survivor, got %+v", result.Transactions)
+	}
+
+	if len(result.Graph.Deadlocks) != 1 || len(result.Graph.Transactions) != 0 {
+		t.Errorf("Expected one broken deadlock and no lock holders, got %+v", result.Graph)
+	}
+	if stats := controller.GetConflictStats(); stats.Deadlocks != 1 {
+		t.Errorf("Expected 1 deadlock in stats, got %d", stats.Deadlocks)
+	}
+}
@@ -0,0 +1,301 @@
+// Package httpapi exposes the shared object over plain HTTP, using ETags and
+// If-Match for the same optimistic concurrency the WebSocket game uses
+package httpapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/AnishMulay/Transaction-Conflict-Visualization/internal/concurrency"
+	"github.com/AnishMulay/Transaction-Conflict-Visualization/pkg/models"
+)
+
+// defaultPlayerID identifies HTTP moves that do not send X-Player-ID
+const defaultPlayerID = "http"
+
+// An If-Match: * move that keeps losing to concurrent commits is attempted
+// at most unconditionalAttempts times, waiting a random delay of up to
+// unconditionalBackoff, doubled after each attempt, so contending clients
+// spread out
+const (
+	unconditionalAttempts = 5
+	unconditionalBackoff  = 5 * time.Millisecond
+)
+
+// ObjectAPI serves GET /object and POST /object/move
+type ObjectAPI struct {
+	gameState  *models.GameState
+	controller *concurrency.ConcurrencyController
+	onCommit   func()
+}
+
+// MoveBody is the request body of POST /object/move
+type MoveBody struct {
+	Direction string `json:"direction"`
+	RequestID string `json:"requestId,omitempty"`
+}
+
+// NewObjectAPI creates the HTTP object API. onCommit, if set, runs after every
+// successful move so other clients can be told about it.
+func NewObjectAPI(gameState *models.GameState, controller *concurrency.ConcurrencyController, onCommit func()) *ObjectAPI {
+	return &ObjectAPI{
+		gameState:  gameState,
+		controller: controller,
+		onCommit:   onCommit,
+	}
+}
+
+// Register adds the API routes to mux
+func (a *ObjectAPI) Register(mux *http.ServeMux) {
+	mux.HandleFunc("GET /object", a.getObject)
+	mux.HandleFunc("POST /object/move", a.moveObject)
+}
+
+func (a *ObjectAPI) getObject(w http.ResponseWriter, r *http.Request) {
+	object := a.gameState.GetState().Object
+	etag := versionETag(object.Version)
+
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", "no-cache")
+
+	if r.Header.Get("If-None-Match") == etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, object)
+}
+
+func (a *ObjectAPI) moveObject(w http.ResponseWriter, r *http.Request) {
+	ifMatch := strings.TrimSpace(r.Header.Get("If-Match"))
+	if ifMatch == "" {
+		writeError(w, http.StatusPreconditionRequired, models.ErrorResponse{
+			Message: "If-Match header with the object's ETag is required",
+			Code:    models.ErrorCodePreconditionNeeded,
+			Field:   "If-Match",
+		})
+		return
+	}
+
+	var body MoveBody
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&body); err != nil {
+		writeError(w, http.StatusBadRequest, models.ErrorResponse{
+			Message: "invalid body: " + err.Error(),
+			Code:    models.ErrorCodeInvalidMove,
+		})
+		return
+	}
+
+	switch body.Direction {
+	case "up", "down", "left", "right":
+	default:
+		writeError(w, http.StatusBadRequest, models.ErrorResponse{
+			Message:   fmt.Sprintf("direction must be up, down, left or right, got %q", body.Direction),
+			Code:      models.ErrorCodeInvalidMove,
+			RequestID: body.RequestID,
+			Field:     "direction",
+		})
+		return
+	}
+
+	// "*" matches any current version, making the move unconditional
+	unconditional := ifMatch == "*"
+	var readVersion int64
+	if !unconditional {
+		version, ok := parseVersionETag(ifMatch)
+		if !ok {
+			writeError(w, http.StatusBadRequest, models.ErrorResponse{
+				Message: fmt.Sprintf("If-Match must be a strong ETag like \"3\", got %s", ifMatch),
+				Code:    models.ErrorCodeBadPrecondition,
+				Field:   "If-Match",
+			})
+			return
+		}
+		readVersion = version
+	}
+
+	playerID := r.Header.Get("X-Player-ID")
+	if playerID == "" {
+		playerID = defaultPlayerID
+	}
+
+	var snapshot *models.GameStateSnapshot
+	var err error
+	if unconditional {
+		snapshot, err = a.moveUnconditionally(r.Context(), playerID, body)
+	} else {
+		snapshot, err = a.move(body.Direction, func() (*concurrency.Transaction, error) {
+			return a.controller.BeginTransactionAt(playerID, body.RequestID, readVersion)
+		})
+	}
+	var conflict *concurrency.ConflictError
+	if unconditional && errors.As(err, &conflict) {
+		// No version was pinned, so there is no stale precondition to report
+		w.Header().Set("ETag", versionETag(conflict.CurrentVersion))
+		response := conflict.Response()
+		response.Message = fmt.Sprintf("The move lost to a concurrent commit on all %d attempts, the object is at version %d",
+			unconditionalAttempts, conflict.CurrentVersion)
+		writeJSON(w, http.StatusConflict, response)
+		return
+	}
+	if err != nil {
+		writeMoveError(w, body.RequestID, err)
+		return
+	}
+
+	if a.onCommit != nil {
+		a.onCommit()
+	}
+
+	w.Header().Set("ETag", versionETag(snapshot.Object.Version))
+	writeJSON(w, http.StatusOK, snapshot.Object)
+}
+
+// moveUnconditionally runs an If-Match: * move. A move that loses to a
+// concurrent commit is run again on the version that won, as a retry the
+// conflict stats count apart from the client's own conflicts, until it
+// commits, runs out of attempts or the client gives up.
+func (a *ObjectAPI) moveUnconditionally(ctx context.Context, playerID string, body MoveBody) (*models.GameStateSnapshot, error) {
+	snapshot, err := a.move(body.Direction, func() (*concurrency.Transaction, error) {
+		return a.controller.BeginTransaction(playerID, body.RequestID)
+	})
+
+	backoff := unconditionalBackoff
+	for attempt := 1; attempt < unconditionalAttempts && errors.Is(err, concurrency.ErrVersionMismatch); attempt++ {
+		select {
+		case <-time.After(time.Duration(rand.Int63n(int64(backoff)) + 1)):
+		case <-ctx.Done():
+			return nil, err
+		}
+		backoff *= 2
+
+		snapshot, err = a.move(body.Direction, func() (*concurrency.Transaction, error) {
+			return a.controller.BeginRetry(playerID, body.RequestID)
+		})
+	}
+	return snapshot, err
+}
+
+// move runs one transaction moving the object, started by begin
+func (a *ObjectAPI) move(direction string, begin func() (*concurrency.Transaction, error)) (*models.GameStateSnapshot, error) {
+	transaction, err := begin()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := a.controller.ProposeMove(transaction.ID, direction); err != nil {
+		a.controller.AbortTransaction(transaction.ID)
+		return nil, err
+	}
+
+	// Simulated server-side processing between propose and commit
+	if delay := a.controller.ThinkTime(); delay > 0 {
+		time.Sleep(delay)
+	}
+
+	return a.controller.CommitTransaction(transaction.ID)
+}
+
+// writeMoveError answers a move that failed to propose or commit
+func writeMoveError(w http.ResponseWriter, requestID string, err error) {
+	var conflict *concurrency.ConflictError
+	if errors.As(err, &conflict) {
+		// The representation the client read is stale
+		w.Header().Set("ETag", versionETag(conflict.CurrentVersion))
+		response := conflict.Response()
+		response.Message = fmt.Sprintf("If-Match version %d is stale, the object is at version %d",
+			conflict.ReadVersion, conflict.CurrentVersion)
+		writeJSON(w, http.StatusPreconditionFailed, response)
+		return
+	}
+
+	if code, ok := lockAbortCode(err); ok {
+		writeError(w, http.StatusConflict, models.ErrorResponse{
+			Message:   err.Error(),
+			Code:      code,
+			RequestID: requestID,
+		})
+		return
+	}
+
+	switch {
+	case errors.Is(err, concurrency.ErrInvalidMove), errors.Is(err, concurrency.ErrWall):
+		code := models.ErrorCodeInvalidMove
+		if errors.Is(err, concurrency.ErrWall) {
+			code = models.ErrorCodeWall
+		}
+		writeError(w, http.StatusUnprocessableEntity, models.ErrorResponse{
+			Message:   err.Error(),
+			Code:      code,
+			RequestID: requestID,
+		})
+	case errors.Is(err, concurrency.ErrUnavailable):
+		writeError(w, http.StatusServiceUnavailable, models.ErrorResponse{
+			Message:   err.Error(),
+			Code:      models.ErrorCodeUnavailable,
+			RequestID: requestID,
+		})
+	case errors.Is(err, concurrency.ErrInDoubt):
+		writeError(w, http.StatusLocked, models.ErrorResponse{
+			Message:   err.Error(),
+			Code:      models.ErrorCodeInDoubt,
+			RequestID: requestID,
+		})
+	default:
+		writeError(w, http.StatusInternalServerError, models.ErrorResponse{
+			Message: err.Error(),
+			Code:    models.ErrorCodeTransaction,
+		})
+	}
+}
+
+// lockAbortCode returns the error code of a transaction aborted by the lock manager
+func lockAbortCode(err error) (models.ErrorCode, bool) {
+	switch {
+	case errors.Is(err, concurrency.ErrDeadlock):
+		return models.ErrorCodeDeadlock, true
+	case errors.Is(err, concurrency.ErrWounded), errors.Is(err, concurrency.ErrDied):
+		return models.ErrorCodeAborted, true
+	}
+	return "", false
+}
+
+// versionETag formats an object version as a strong ETag
+func versionETag(version int64) string {
+	return strconv.Quote(strconv.FormatInt(version, 10))
+}
+
+// parseVersionETag reads a version back from a strong ETag
+func parseVersionETag(etag string) (int64, bool) {
+	if len(etag) < 2 || etag[0] != '"' || etag[len(etag)-1] != '"' {
+		return 0, false
+	}
+
+	version, err := strconv.ParseInt(etag[1:len(etag)-1], 10, 64)
+	if err != nil || version < 1 {
+		return 0, false
+	}
+	return version, true
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		log.Printf("Failed to write response: %v", err)
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, response models.ErrorResponse) {
+	writeJSON(w, status, response)
+}
@@ -0,0 +1,241 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/AnishMulay/Transaction-Conflict-Visualization/internal/concurrency"
+	"github.com/AnishMulay/Transaction-Conflict-Visualization/pkg/models"
+)
+
+func newTestServer(t *testing.T, onCommit func()) *httptest.Server {
+	t.Helper()
+
+	gameState := models.NewGameState(models.Position{X: 10, Y: 10})
+	controller := concurrency.NewConcurrencyController(gameState)
+
+	mux := http.NewServeMux()
+	NewObjectAPI(gameState, controller, onCommit).Register(mux)
+
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func postMove(t *testing.T, server *httptest.Server, ifMatch, body string) *http.Response {
+	t.Helper()
+
+	req, err := http.NewRequest(http.MethodPost, server.URL+"/object/move", strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("Failed to build request: %v", err)
+	}
+	if ifMatch != "" {
+		req.Header.Set("If-Match", ifMatch)
+	}
+	req.Header.Set("X-Player-ID", "tester")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
+
+func TestConditionalMove(t *testing.T) {
+	commits := 0
+	server := newTestServer(t, func() { commits++ })
+
+	resp, err := http.Get(server.URL + "/object")
+	if err != nil {
+		t.Fatalf("GET /object failed: %v", err)
+	}
+	resp.Body.Close()
+	if etag := resp.Header.Get("ETag"); etag != `"1"` {
+		t.Fatalf(`Expected ETag "1", got %s`, etag)
+	}
+
+	// Revalidating an unchanged object is a 304
+	req, _ := http.NewRequest(http.MethodGet, server.URL+"/object", nil)
+	req.Header.Set("If-None-Match", `"1"`)
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("GET /object failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotModified {
+		t.Errorf("Expected 304, got %d", resp.StatusCode)
+	}
+
+	resp = postMove(t, server, `"1"`, `{"direction":"right","requestId":"r1"}`)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", resp.StatusCode)
+	}
+	if etag := resp.Header.Get("ETag"); etag != `"2"` {
+		t.Errorf(`Expected ETag "2", got %s`, etag)
+	}
+	var object models.GameObject
+	if err := json.NewDecoder(resp.Body).Decode(&object); err != nil {
+		t.Fatalf("Failed to decode object: %v", err)
+	}
+	if object.Position != (models.Position{X: 6, Y: 5}) || object.Version != 2 {
+		t.Errorf("Expected object at (6, 5) v2, got %+v", object)
+	}
+	if commits != 1 {
+		t.Errorf("Expected onCommit once, got %d", commits)
+	}
+
+	// The version read before the first move is now stale
+	resp = postMove(t, server, `"1"`, `{"direction":"left","requestId":"r2"}`)
+	if resp.StatusCode != http.StatusPreconditionFailed {
+		t.Fatalf("Expected 412, got %d", resp.StatusCode)
+	}
+	if etag := resp.Header.Get("ETag"); etag != `"2"` {
+		t.Errorf(`Expected current ETag "2", got %s`, etag)
+	}
+	var conflict models.ConflictResponse
+	if err := json.NewDecoder(resp.Body).Decode(&conflict); err != nil {
+		t.Fatalf("Failed to decode conflict: %v", err)
+	}
+	if conflict.ExpectedVersion != 1 || conflict.ActualVersion != 2 || conflict.RequestID != "r2" {
+		t.Errorf("Unexpected conflict body: %+v", conflict)
+	}
+	if conflict.Winner == nil || conflict.Winner.RequestID != "r1" || conflict.Winner.PlayerID != "tester" {
+		t.Errorf("Expected the winner to be r1, got %+v", conflict.Winner)
+	}
+	if commits != 1 {
+		t.Errorf("Expected no onCommit for a conflict, got %d", commits)
+	}
+
+	// A wildcard moves whatever the current version is
+	resp = postMove(t, server, "*", `{"direction":"up"}`)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected 200 for If-Match *, got %d", resp.StatusCode)
+	}
+	if etag := resp.Header.Get("ETag"); etag != `"3"` {
+		t.Errorf(`Expected ETag "3", got %s`, etag)
+	}
+}
+
+func TestMoveRequestErrors(t *testing.T) {
+	server := newTestServer(t, nil)
+
+	tests := []struct {
+		name    string
+		ifMatch string
+		body    string
+		status  int
+		code    models.ErrorCode
+	}{
+		{"missing If-Match", "", `{"direction":"up"}`, http.StatusPreconditionRequired, models.ErrorCodePreconditionNeeded},
+		{"weak ETag", `W/"1"`, `{"direction":"up"}`, http.StatusBadRequest, models.ErrorCodeBadPrecondition},
+		{"bad direction", `"1"`, `{"direction":"sideways"}`, http.StatusBadRequest, models.ErrorCodeInvalidMove},
+		{"unknown field", `"1"`, `{"direction":"up","speed":3}`, http.StatusBadRequest, models.ErrorCodeInvalidMove},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := postMove(t, server, tt.ifMatch, tt.body)
+			if resp.StatusCode != tt.status {
+				t.Fatalf("Expected %d, got %d", tt.status, resp.StatusCode)
+			}
+
+			var errResp models.ErrorResponse
+			if err := json.NewDecoder(resp.Body).Decode(&errResp); err != nil {
+				t.Fatalf("Failed to decode error: %v", err)
+			}
+			if errResp.Code != tt.code {
+				t.Errorf("Expected code %s, got %s", tt.code, errResp.Code)
+			}
+		})
+	}
+}
+
+// competingCommit is a think time that commits another move the first
+// rivals times a transaction thinks, as a player moving inside the window
+// would
+type competingCommit struct {
+	t          *testing.T
+	controller *concurrency.ConcurrencyController
+	rivals     int
+}
+
+func (c *competingCommit) Next() time.Duration {
+	if c.rivals > 0 {
+		c.rivals--
+		transaction, _ := c.controller.BeginTransaction("rival", "rival-move")
+		c.controller.ProposeMove(transaction.ID, "left")
+		if _, err := c.controller.CommitTransaction(transaction.ID); err != nil {
+			c.t.Errorf("Competing move failed: %v", err)
+		}
+	}
+	return 0
+}
+
+func (c *competingCommit) String() string { return "competing-commit" }
+
+func TestUnconditionalMoveSurvivesConcurrentCommit(t *testing.T) {
+	gameState := models.NewGameState(models.Position{X: 10, Y: 10})
+	controller := concurrency.NewConcurrencyController(gameState)
+	controller.SetThinkTime(&competingCommit{t: t, controller: controller, rivals: 1})
+
+	mux := http.NewServeMux()
+	NewObjectAPI(gameState, controller, nil).Register(mux)
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	resp := postMove(t, server, "*", `{"direction":"up"}`)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected If-Match * to move despite the concurrent commit, got %d", resp.StatusCode)
+	}
+	if etag := resp.Header.Get("ETag"); etag != `"3"` {
+		t.Errorf(`Expected ETag "3" after both moves, got %s`, etag)
+	}
+
+	// The move was computed from the rival's position, not the one first read
+	if object := gameState.GetState().Object; object.Position != (models.Position{X: 4, Y: 4}) {
+		t.Errorf("Expected both moves to apply, object at %v", object.Position)
+	}
+	if stats := controller.GetConflictStats(); stats.ConflictCount != 1 || stats.RetriedConflicts != 0 {
+		t.Errorf("Expected the first attempt to conflict and the retry to commit, got %+v", stats)
+	}
+}
+
+func TestUnconditionalMoveGivesUpAfterRepeatedConflicts(t *testing.T) {
+	gameState := models.NewGameState(models.Position{X: 10, Y: 10})
+	controller := concurrency.NewConcurrencyController(gameState)
+	controller.SetThinkTime(&competingCommit{t: t, controller: controller, rivals: unconditionalAttempts})
+
+	mux := http.NewServeMux()
+	NewObjectAPI(gameState, controller, nil).Register(mux)
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	resp := postMove(t, server, "*", `{"direction":"up"}`)
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusConflict {
+		t.Fatalf("Expected 409 once every attempt lost, got %d", resp.StatusCode)
+	}
+	want := versionETag(int64(1 + unconditionalAttempts))
+	if etag := resp.Header.Get("ETag"); etag != want {
+		t.Errorf("Expected the current ETag %s, got %s", want, etag)
+	}
+
+	var conflict models.ConflictResponse
+	if err := json.NewDecoder(resp.Body).Decode(&conflict); err != nil {
+		t.Fatalf("Failed to decode conflict: %v", err)
+	}
+	if conflict.Winner == nil || conflict.Winner.PlayerID != "rival" {
+		t.Errorf("Expected the rival as the winner, got %+v", conflict.Winner)
+	}
+
+	// Only the client's own attempt counts as a conflict
+	stats := controller.GetConflictStats()
+	if stats.ConflictCount != 1 || stats.RetriedConflicts != unconditionalAttempts-1 {
+		t.Errorf("Expected 1 conflict and %d retried conflicts, got %+v", unconditionalAttempts-1, stats)
+	}
+}
@@ -0,0 +1,137 @@
+package httpapi
+
+import (
+	"net/http"
+
+	"github.com/AnishMulay/Transaction-Conflict-Visualization/internal/concurrency"
+	"github.com/AnishMulay/Transaction-Conflict-Visualization/pkg/models"
+)
+
+// RoomsAPI reads and changes the concurrency settings of each room the
+// server hosts, so rooms can be tuned independently while they run
+type RoomsAPI struct {
+	names       []string
+	controllers map[string]*concurrency.ConcurrencyController
+}
+
+// RoomSettings are the concurrency settings of one room
+type RoomSettings struct {
+	Room      string `json:"room"`
+	ThinkTime string `json:"thinkTime"`
+	Locking   string `json:"locking"`
+	Isolation string `json:"isolation"`
+}
+
+// NewRoomsAPI creates the room settings API
+func NewRoomsAPI() *RoomsAPI {
+	return &RoomsAPI{controllers: make(map[string]*concurrency.ConcurrencyController)}
+}
+
+// AddRoom makes a room's controller configurable under name
+func (a *RoomsAPI) AddRoom(name string, controller *concurrency.ConcurrencyController) {
+	a.names = append(a.names, name)
+	a.controllers[name] = controller
+}
+
+// Register adds the API routes to mux
+func (a *RoomsAPI) Register(mux *http.ServeMux) {
+	mux.HandleFunc("GET /rooms", a.listRooms)
+	mux.HandleFunc("GET /rooms/{room}", a.getRoom)
+	mux.HandleFunc("POST /rooms/{room}", a.updateRoom)
+	mux.HandleFunc("GET /rooms/{room}/stats", a.getStats)
+}
+
+func (a *RoomsAPI) listRooms(w http.ResponseWriter, r *http.Request) {
+	rooms := make([]RoomSettings, 0, len(a.names))
+	for _, name := range a.names {
+		rooms = append(rooms, a.settings(name))
+	}
+	writeJSON(w, http.StatusOK, rooms)
+}
+
+func (a *RoomsAPI) getRoom(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("room")
+	if _, ok := a.controllers[name]; !ok {
+		writeError(w, http.StatusNotFound, models.ErrorResponse{Message: "unknown room " + name, Code: models.ErrorCodeUnknownRoom, Field: "room"})
+		return
+	}
+	writeJSON(w, http.StatusOK, a.settings(name))
+}
+
+// getStats returns the room's conflict stats, including the moves and
+// messages turned away by rate limits
+func (a *RoomsAPI) getStats(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("room")
+	controller, ok := a.controllers[name]
+	if !ok {
+		writeError(w, http.StatusNotFound, models.ErrorResponse{Message: "unknown room " + name, Code: models.ErrorCodeUnknownRoom, Field: "room"})
+		return
+	}
+	writeJSON(w, http.StatusOK, controller.GetConflictStats())
+}
+
+// updateRoom changes the room's think time to ?thinkTime=, in the -think-time
+// format, its locking strategy to ?locking= and its isolation level to
+// ?isolation=, in the formats of the matching flags. All are checked before
+// any changes. Transactions already running keep the delay they drew and
+// the strategy and level they began under.
+func (a *RoomsAPI) updateRoom(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("room")
+	controller, ok := a.controllers[name]
+	if !ok {
+		writeError(w, http.StatusNotFound, models.ErrorResponse{Message: "unknown room " + name, Code: models.ErrorCodeUnknownRoom, Field: "room"})
+		return
+	}
+
+	query := r.URL.Query()
+	var policy concurrency.DelayPolicy
+	if query.Has("thinkTime") {
+		parsed, err := concurrency.ParseDelayPolicy(query.Get("thinkTime"))
+		if err != nil {
+			writeError(w, http.StatusBadRequest, models.ErrorResponse{Message: err.Error(), Code: models.ErrorCodeMalformed, Field: "thinkTime"})
+			return
+		}
+		policy = parsed
+	}
+	var strategy concurrency.LockingStrategy
+	if query.Has("locking") {
+		parsed, err := concurrency.ParseLockingStrategy(query.Get("locking"))
+		if err != nil {
+			writeError(w, http.StatusBadRequest, models.ErrorResponse{Message: err.Error(), Code: models.ErrorCodeMalformed, Field: "locking"})
+			return
+		}
+		strategy = parsed
+	}
+	var level concurrency.IsolationLevel
+	if query.Has("isolation") {
+		parsed, err := concurrency.ParseIsolationLevel(query.Get("isolation"))
+		if err != nil {
+			writeError(w, http.StatusBadRequest, models.ErrorResponse{Message: err.Error(), Code: models.ErrorCodeMalformed, Field: "isolation"})
+			return
+		}
+		level = parsed
+	}
+
+	if policy != nil {
+		controller.SetThinkTime(policy)
+	}
+	if strategy != "" {
+		controller.SetLockingStrategy(strategy)
+	}
+	if level != "" {
+		controller.SetIsolationLevel(level)
+	}
+
+	writeJSON(w, http.StatusOK, a.settings(name))
+}
+
+// settings returns a room's current settings
+func (a *RoomsAPI) settings(name string) RoomSettings {
+	controller := a.controllers[name]
+	return RoomSettings{
+		Room:      name,
+		ThinkTime: controller.ThinkTimePolicy().String(),
+		Locking:   string(controller.LockingStrategy()),
+		Isolation: string(controller.IsolationLevel()),
+	}
+}
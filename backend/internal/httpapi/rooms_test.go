@@ -0,0 +1,131 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/AnishMulay/Transaction-Conflict-Visualization/internal/concurrency"
+	"github.com/AnishMulay/Transaction-Conflict-Visualization/pkg/models"
+)
+
+func TestRoomSettingsAreIndependent(t *testing.T) {
+	lobby := concurrency.NewConcurrencyController(models.NewGameState(models.Position{X: 10, Y: 10}))
+	arena := concurrency.NewConcurrencyController(models.NewGameState(models.Position{X: 10, Y: 10}))
+
+	api := NewRoomsAPI()
+	api.AddRoom("lobby", lobby)
+	api.AddRoom("arena", arena)
+	mux := http.NewServeMux()
+	api.Register(mux)
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	resp, err := http.Post(server.URL+"/rooms/arena?thinkTime=fixed:50ms&locking=wait-die", "application/json", nil)
+	if err != nil {
+		t.Fatalf("POST /rooms/arena failed: %v", err)
+	}
+	var settings RoomSettings
+	json.NewDecoder(resp.Body).Decode(&settings)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || settings.ThinkTime != "fixed:50ms" || settings.Locking != "2pl-wait-die" {
+		t.Fatalf("Expected the arena's new settings, got %d %+v", resp.StatusCode, settings)
+	}
+
+	if arena.ThinkTime() != 50*time.Millisecond || lobby.ThinkTime() != 0 {
+		t.Errorf("Expected only the arena to think, got %v and %v", arena.ThinkTime(), lobby.ThinkTime())
+	}
+	if arena.LockingStrategy() != concurrency.StrategyWaitDie || lobby.LockingStrategy() != concurrency.StrategyOptimistic {
+		t.Errorf("Expected only the arena to lock, got %s and %s", arena.LockingStrategy(), lobby.LockingStrategy())
+	}
+
+	// Each room runs under its own isolation level
+	for room, level := range map[string]concurrency.IsolationLevel{
+		"lobby": concurrency.IsolationReadCommitted,
+		"arena": concurrency.IsolationSnapshot,
+	} {
+		resp, err := http.Post(server.URL+"/rooms/"+room+"?isolation="+string(level), "application/json", nil)
+		if err != nil {
+			t.Fatalf("POST /rooms/%s failed: %v", room, err)
+		}
+		var settings RoomSettings
+		json.NewDecoder(resp.Body).Decode(&settings)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK || settings.Isolation != string(level) {
+			t.Fatalf("Expected %s at %s, got %d %+v", room, level, resp.StatusCode, settings)
+		}
+	}
+	if lobby.IsolationLevel() != concurrency.IsolationReadCommitted || arena.IsolationLevel() != concurrency.IsolationSnapshot {
+		t.Errorf("Expected each room at its own level, got %s and %s", lobby.IsolationLevel(), arena.IsolationLevel())
+	}
+
+	// A bad setting leaves the room as it was
+	for path, status := range map[string]int{
+		"/rooms/arena?thinkTime=soon":                  http.StatusBadRequest,
+		"/rooms/arena?thinkTime=none&locking=mutex":    http.StatusBadRequest,
+		"/rooms/arena?thinkTime=none&isolation=strict": http.StatusBadRequest,
+		"/rooms/attic?thinkTime=none":                  http.StatusNotFound,
+	} {
+		resp, err := http.Post(server.URL+path, "application/json", nil)
+		if err != nil {
+			t.Fatalf("POST %s failed: %v", path, err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != status {
+			t.Errorf("POST %s: expected %d, got %d", path, status, resp.StatusCode)
+		}
+	}
+	if arena.ThinkTime() != 50*time.Millisecond {
+		t.Errorf("Expected a rejected update to keep the arena's think time, got %v", arena.ThinkTime())
+	}
+
+	resp, err = http.Get(server.URL + "/rooms/attic")
+	if err != nil {
+		t.Fatalf("GET /rooms/attic failed: %v", err)
+	}
+	var refusal models.ErrorResponse
+	json.NewDecoder(resp.Body).Decode(&refusal)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound || refusal.Code != models.ErrorCodeUnknownRoom {
+		t.Errorf("Expected 404 UNKNOWN_ROOM for an unknown room, got %d %+v", resp.StatusCode, refusal)
+	}
+}
+
+func TestRoomStatsReportRateLimits(t *testing.T) {
+	lobby := concurrency.NewConcurrencyController(models.NewGameState(models.Position{X: 10, Y: 10}))
+	lobby.RecordRateLimitedMove()
+	lobby.RecordRateLimitedMessage()
+	lobby.RecordRateLimitedMessage()
+
+	api := NewRoomsAPI()
+	api.AddRoom("lobby", lobby)
+	mux := http.NewServeMux()
+	api.Register(mux)
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/rooms/lobby/stats")
+	if err != nil {
+		t.Fatalf("GET /rooms/lobby/stats failed: %v", err)
+	}
+	var stats struct {
+		RateLimitedMoves    int64 `json:"rateLimitedMoves"`
+		RateLimitedMessages int64 `json:"rateLimitedMessages"`
+	}
+	json.NewDecoder(resp.Body).Decode(&stats)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || stats.RateLimitedMoves != 1 || stats.RateLimitedMessages != 2 {
+		t.Errorf("Expected 1 limited move and 2 limited messages, got %d %+v", resp.StatusCode, stats)
+	}
+
+	resp, err = http.Get(server.URL + "/rooms/attic/stats")
+	if err != nil {
+		t.Fatalf("GET /rooms/attic/stats failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected 404 for an unknown room, got %d", resp.StatusCode)
+	}
+}
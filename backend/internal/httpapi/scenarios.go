@@ -0,0 +1,78 @@
+package httpapi
+
+import (
+	"net/http"
+
+	"github.com/AnishMulay/Transaction-Conflict-Visualization/internal/concurrency"
+	"github.com/AnishMulay/Transaction-Conflict-Visualization/pkg/models"
+)
+
+// ScenariosAPI lists and runs the scripted isolation anomaly scenarios
+type ScenariosAPI struct {
+	controller *concurrency.ConcurrencyController
+}
+
+// ScenarioInfo describes a scenario in GET /scenarios
+type ScenarioInfo struct {
+	Name        string             `json:"name"`
+	Anomaly     models.AnomalyKind `json:"anomaly"`
+	Description string             `json:"description"`
+	Initial     map[string]int64   `json:"initial"`
+}
+
+// NewScenariosAPI creates the scenario API
+func NewScenariosAPI(controller *concurrency.ConcurrencyController) *ScenariosAPI {
+	return &ScenariosAPI{controller: controller}
+}
+
+// Register adds the API routes to mux
+func (a *ScenariosAPI) Register(mux *http.ServeMux) {
+	mux.HandleFunc("GET /scenarios", a.listScenarios)
+	mux.HandleFunc("POST /scenarios/{name}", a.runScenario)
+}
+
+func (a *ScenariosAPI) listScenarios(w http.ResponseWriter, r *http.Request) {
+	scenarios := make([]ScenarioInfo, 0, len(concurrency.Scenarios))
+	for _, scenario := range concurrency.Scenarios {
+		scenarios = append(scenarios, ScenarioInfo{
+			Name:        scenario.Name,
+			Anomaly:     scenario.Anomaly,
+			Description: scenario.Description,
+			Initial:     scenario.Initial,
+		})
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"isolationLevel": a.controller.IsolationLevel(),
+		"scenarios":      scenarios,
+	})
+}
+
+// runScenario runs a scenario under the room's isolation level, or the one
+// given as ?isolation=. A detected anomaly is also broadcast to clients.
+func (a *ScenariosAPI) runScenario(w http.ResponseWriter, r *http.Request) {
+	level := a.controller.IsolationLevel()
+	if value := r.URL.Query().Get("isolation"); value != "" {
+		parsed, err := concurrency.ParseIsolationLevel(value)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, models.ErrorResponse{
+				Message: err.Error(),
+				Code:    models.ErrorCodeMalformed,
+				Field:   "isolation",
+			})
+			return
+		}
+		level = parsed
+	}
+
+	result, err := a.controller.RunScenario(r.PathValue("name"), level)
+	if err != nil {
+		writeError(w, http.StatusNotFound, models.ErrorResponse{
+			Message: err.Error(),
+			Code:    models.ErrorCodeUnknownType,
+		})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, result)
+}
@@ -0,0 +1,57 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/AnishMulay/Transaction-Conflict-Visualization/internal/concurrency"
+	"github.com/AnishMulay/Transaction-Conflict-Visualization/pkg/models"
+)
+
+func TestRunScenario(t *testing.T) {
+	gameState := models.NewGameState(models.Position{X: 10, Y: 10})
+	controller := concurrency.NewConcurrencyController(gameState)
+
+	reported := make(chan models.Anomaly, 1)
+	controller.OnAnomaly(func(anomaly models.Anomaly) { reported <- anomaly })
+
+	mux := http.NewServeMux()
+	NewScenariosAPI(controller).Register(mux)
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	run := func(path string) (int, models.ScenarioResult) {
+		resp, err := http.Post(server.URL+path, "application/json", nil)
+		if err != nil {
+			t.Fatalf("POST %s failed: %v", path, err)
+		}
+		defer resp.Body.Close()
+
+		var result models.ScenarioResult
+		json.NewDecoder(resp.Body).Decode(&result)
+		return resp.StatusCode, result
+	}
+
+	// The room defaults to serializable, which prevents write skew
+	status, result := run("/scenarios/write-skew")
+	if status != http.StatusOK || result.Anomaly != nil || result.IsolationLevel != "serializable" {
+		t.Fatalf("Expected write skew prevented, got %d %+v", status, result)
+	}
+
+	status, result = run("/scenarios/write-skew?isolation=snapshot")
+	if status != http.StatusOK || result.Anomaly == nil || result.Anomaly.Kind != models.AnomalyWriteSkew {
+		t.Fatalf("Expected write skew under snapshot, got %d %+v", status, result)
+	}
+	if anomaly := <-reported; anomaly.Scenario != "write-skew" {
+		t.Errorf("Expected the anomaly to be reported, got %+v", anomaly)
+	}
+
+	if status, _ := run("/scenarios/phantom"); status != http.StatusNotFound {
+		t.Errorf("Expected 404 for an unknown scenario, got %d", status)
+	}
+	if status, _ := run("/scenarios/lost-update?isolation=chaos"); status != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an unknown isolation level, got %d", status)
+	}
+}
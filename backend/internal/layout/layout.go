@@ -0,0 +1,144 @@
+// Package layout reads map files, which give a room walls the object
+// cannot enter and goal zones to move it into.
+//
+// A map file is a grid drawn in text, one line per row:
+//
+//	; the first room
+//	##########
+//	#...G....#
+//	#..S..#..#
+//	##########
+//
+// '#' is a wall, '.' an empty cell, 'G' a goal cell and 'S' the object's
+// starting cell, the middle of the grid when there is none. Goal cells next
+// to each other form one goal zone. Lines starting with ';' and blank lines
+// are ignored, and every row must be equally wide.
+package layout
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/AnishMulay/Transaction-Conflict-Visualization/pkg/models"
+)
+
+// MaxSize is the widest and tallest map accepted
+const MaxSize = 64
+
+// Map is a parsed map file
+type Map struct {
+	Layout *models.Layout
+	Size   models.Position
+	Start  models.Position
+}
+
+// Load reads the map file at path, named after the file
+func Load(path string) (Map, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return Map{}, err
+	}
+	defer file.Close()
+	return Parse(strings.TrimSuffix(filepath.Base(path), filepath.Ext(path)), file)
+}
+
+// Parse reads a map file
+func Parse(name string, r io.Reader) (Map, error) {
+	var rows []string
+	lines := make(map[int]int)
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimRight(scanner.Text(), " \t\r")
+		if text == "" || strings.HasPrefix(text, ";") {
+			continue
+		}
+		if len(rows) > 0 && len(text) != len(rows[0]) {
+			return Map{}, fmt.Errorf("line %d: row is %d cells wide, the first row %d", line, len(text), len(rows[0]))
+		}
+		lines[len(rows)] = line
+		rows = append(rows, text)
+	}
+	if err := scanner.Err(); err != nil {
+		return Map{}, err
+	}
+	if len(rows) == 0 {
+		return Map{}, fmt.Errorf("the map has no rows")
+	}
+	size := models.Position{X: len(rows[0]), Y: len(rows)}
+	if size.X > MaxSize || size.Y > MaxSize {
+		return Map{}, fmt.Errorf("the map is %dx%d, at most %dx%d is allowed", size.X, size.Y, MaxSize, MaxSize)
+	}
+
+	walls := []models.Position{}
+	goal := make(map[models.Position]bool)
+	var goalOrder []models.Position
+	start, hasStart := models.Position{X: size.X / 2, Y: size.Y / 2}, false
+	for y, row := range rows {
+		for x, cell := range row {
+			position := models.Position{X: x, Y: y}
+			switch cell {
+			case '.':
+			case '#':
+				walls = append(walls, position)
+			case 'G':
+				goal[position] = true
+				goalOrder = append(goalOrder, position)
+			case 'S':
+				if hasStart {
+					return Map{}, fmt.Errorf("line %d: a second start cell", lines[y])
+				}
+				start, hasStart = position, true
+			default:
+				return Map{}, fmt.Errorf("line %d: unknown cell %q at column %d", lines[y], cell, x+1)
+			}
+		}
+	}
+
+	layout := models.NewLayout(name, walls, goalZones(goal, goalOrder))
+	if layout.Blocked(start) {
+		return Map{}, fmt.Errorf("the middle of the map is a wall; mark the start with S")
+	}
+	return Map{Layout: layout, Size: size, Start: start}, nil
+}
+
+// goalZones groups goal cells that touch into zones, numbered in reading
+// order of their first cell
+func goalZones(goal map[models.Position]bool, order []models.Position) []models.GoalZone {
+	zones := []models.GoalZone{}
+	seen := make(map[models.Position]bool)
+	for _, first := range order {
+		if seen[first] {
+			continue
+		}
+		zone := models.GoalZone{ID: fmt.Sprintf("goal-%d", len(zones)+1)}
+		queue := []models.Position{first}
+		seen[first] = true
+		for len(queue) > 0 {
+			cell := queue[0]
+			queue = queue[1:]
+			zone.Cells = append(zone.Cells, cell)
+			for _, next := range []models.Position{
+				{X: cell.X, Y: cell.Y - 1}, {X: cell.X - 1, Y: cell.Y}, {X: cell.X + 1, Y: cell.Y}, {X: cell.X, Y: cell.Y + 1},
+			} {
+				if goal[next] && !seen[next] {
+					seen[next] = true
+					queue = append(queue, next)
+				}
+			}
+		}
+		zones = append(zones, zone)
+	}
+	return zones
+}
+
+// NewGameState creates a game state on the map, with the object at the start
+func (m Map) NewGameState() *models.GameState {
+	gameState := models.NewGameState(m.Size)
+	gameState.Object.Position = m.Start
+	gameState.Layout = m.Layout
+	return gameState
+}
@@ -0,0 +1,84 @@
+package layout
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/AnishMulay/Transaction-Conflict-Visualization/pkg/models"
+)
+
+func TestParseMap(t *testing.T) {
+	m, err := Parse("test", strings.NewReader(`
+; a comment
+#####.
+#GG.S.
+#..#G.
+`))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+
+	if m.Size != (models.Position{X: 6, Y: 3}) || m.Start != (models.Position{X: 4, Y: 1}) {
+		t.Errorf("Expected a 6x3 map starting at (4, 1), got %+v", m)
+	}
+	if !m.Layout.Blocked(models.Position{X: 3, Y: 2}) || m.Layout.Blocked(models.Position{X: 5, Y: 0}) {
+		t.Errorf("Expected (3, 2) to be a wall and (5, 0) not, got walls %v", m.Layout.Walls)
+	}
+	if len(m.Layout.Goals) != 2 || len(m.Layout.Goals[0].Cells) != 2 {
+		t.Fatalf("Expected two goal zones, the first of two cells, got %+v", m.Layout.Goals)
+	}
+	if id, ok := m.Layout.GoalAt(models.Position{X: 4, Y: 2}); !ok || id != "goal-2" {
+		t.Errorf("Expected (4, 2) in goal-2, got %q", id)
+	}
+}
+
+func TestParseRejectsBadMaps(t *testing.T) {
+	for name, text := range map[string]string{
+		"ragged rows":       "...\n..\n",
+		"unknown cell":      "..x\n...\n",
+		"two starts":        "S.S\n",
+		"walled middle":     "###\n###\n###\n",
+		"nothing":           "; only a comment\n",
+		"larger than limit": strings.Repeat(".", MaxSize+1) + "\n",
+	} {
+		if _, err := Parse(name, strings.NewReader(text)); err == nil {
+			t.Errorf("Expected %s to be rejected", name)
+		}
+	}
+}
+
+func TestShippedMapsReachEveryGoal(t *testing.T) {
+	paths, _ := filepath.Glob("../../maps/*.txt")
+	if len(paths) == 0 {
+		t.Fatal("Expected maps in backend/maps")
+	}
+
+	for _, path := range paths {
+		m, err := Load(path)
+		if err != nil {
+			t.Errorf("%s: %v", path, err)
+			continue
+		}
+
+		// Every goal zone can be reached from the start
+		reached := map[models.Position]bool{m.Start: true}
+		queue := []models.Position{m.Start}
+		for len(queue) > 0 {
+			cell := queue[0]
+			queue = queue[1:]
+			for _, next := range []models.Position{{X: cell.X, Y: cell.Y - 1}, {X: cell.X - 1, Y: cell.Y}, {X: cell.X + 1, Y: cell.Y}, {X: cell.X, Y: cell.Y + 1}} {
+				inside := next.X >= 0 && next.Y >= 0 && next.X < m.Size.X && next.Y < m.Size.Y
+				if inside && !reached[next] && !m.Layout.Blocked(next) {
+					reached[next] = true
+					queue = append(queue, next)
+				}
+			}
+		}
+		for _, goal := range m.Layout.Goals {
+			if !reached[goal.Cells[0]] {
+				t.Errorf("%s: %s cannot be reached from the start", path, goal.ID)
+			}
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package netsim
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// maxLag bounds the lag an admin can inject on a link
+const maxLag = time.Minute
+
+// Register adds the network admin routes to mux:
+//
+//	GET  /network            every link and each replica's progress
+//	POST /network/partition  cut the links between ?groups=r1|r2,r3
+//	POST /network/cut        cut the link between ?a= and ?b=
+//	POST /network/heal       heal the link between ?a= and ?b=, or every
+//	                         link and all lag without them
+//	POST /network/lag        delay the link between ?a= and ?b= by ?delay=
+func (n *Network) Register(mux *http.ServeMux) {
+	mux.HandleFunc("GET /network", n.status)
+	mux.HandleFunc("POST /network/partition", n.partition)
+	mux.HandleFunc("POST /network/cut", n.cut)
+	mux.HandleFunc("POST /network/heal", n.heal)
+	mux.HandleFunc("POST /network/lag", n.lag)
+}
+
+func (n *Network) status(w http.ResponseWriter, r *http.Request) {
+	n.writeStatus(w, nil)
+}
+
+func (n *Network) partition(w http.ResponseWriter, r *http.Request) {
+	var groups [][]string
+	for _, group := range strings.Split(r.URL.Query().Get("groups"), "|") {
+		var members []string
+		for _, member := range strings.Split(group, ",") {
+			if member = strings.TrimSpace(member); member != "" {
+				members = append(members, member)
+			}
+		}
+		if len(members) > 0 {
+			groups = append(groups, members)
+		}
+	}
+	if len(groups) < 2 {
+		http.Error(w, "groups must list at least two groups, e.g. r1|r2,r3", http.StatusBadRequest)
+		return
+	}
+
+	n.writeStatus(w, n.Partition(groups))
+}
+
+func (n *Network) cut(w http.ResponseWriter, r *http.Request) {
+	a, b, ok := endpoints(w, r)
+	if !ok {
+		return
+	}
+	n.writeStatus(w, n.Cut(a, b))
+}
+
+func (n *Network) heal(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Query().Get("a") == "" && r.URL.Query().Get("b") == "" {
+		n.HealAll()
+		n.writeStatus(w, nil)
+		return
+	}
+
+	a, b, ok := endpoints(w, r)
+	if !ok {
+		return
+	}
+	n.writeStatus(w, n.Heal(a, b))
+}
+
+func (n *Network) lag(w http.ResponseWriter, r *http.Request) {
+	a, b, ok := endpoints(w, r)
+	if !ok {
+		return
+	}
+
+	delay, err := time.ParseDuration(r.URL.Query().Get("delay"))
+	if err != nil || delay < 0 || delay > maxLag {
+		http.Error(w, "delay must be a duration between 0 and 1m", http.StatusBadRequest)
+		return
+	}
+	n.writeStatus(w, n.SetLag(a, b, delay))
+}
+
+// endpoints reads the link named by ?a= and ?b=
+func endpoints(w http.ResponseWriter, r *http.Request) (string, string, bool) {
+	a, b := r.URL.Query().Get("a"), r.URL.Query().Get("b")
+	if a == "" || b == "" {
+		http.Error(w, "a and b must name the ends of a link", http.StatusBadRequest)
+		return "", "", false
+	}
+	return a, b, true
+}
+
+// writeStatus answers with the network's status, or with err if the change
+// failed
+func (n *Network) writeStatus(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrUnknownLink):
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(n.Status())
+}
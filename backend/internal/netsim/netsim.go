@@ -0,0 +1,209 @@
+// Package netsim simulates an unreliable network between the copies of a
+// replicated room. A Network is a table of links, each of which an admin can
+// cut, heal or slow down; the replication code of each mode asks it whether
+// a message may pass and how long it takes. Clients is an endpoint like any
+// other, so cutting a replica from Clients isolates the players connected to
+// it.
+package netsim
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/AnishMulay/Transaction-Conflict-Visualization/pkg/models"
+)
+
+// Clients is the endpoint that stands for the players connected to a replica
+const Clients = "clients"
+
+// ErrUnknownLink is returned for links the network does not have
+var ErrUnknownLink = errors.New("unknown link")
+
+// link is the simulated state of the connection between two endpoints
+type link struct {
+	cut bool
+	lag time.Duration
+}
+
+// linkKey names the undirected link between a and b
+type linkKey struct {
+	a, b string
+}
+
+func keyOf(a, b string) linkKey {
+	if b < a {
+		a, b = b, a
+	}
+	return linkKey{a, b}
+}
+
+// Network is a table of simulated links. Links it does not have are always
+// connected and never lag, so a node only consults the links it was given.
+type Network struct {
+	mu       sync.Mutex
+	links    map[linkKey]*link
+	order    []linkKey
+	progress func() []models.ReplicaProgress
+	nodeID   string
+	onChange func()
+}
+
+// New creates a network in which every link is connected
+func New() *Network {
+	return &Network{links: make(map[linkKey]*link)}
+}
+
+// AddLink adds the link between a and b. Call it before the network is used.
+func (n *Network) AddLink(a, b string) {
+	key := keyOf(a, b)
+	if _, ok := n.links[key]; ok || a == b {
+		return
+	}
+	n.links[key] = &link{}
+	n.order = append(n.order, linkKey{a, b})
+}
+
+// SetProgress makes Status report each replica's progress from fn. nodeID
+// names the replica reporting, empty when every replica runs in-process.
+func (n *Network) SetProgress(nodeID string, fn func() []models.ReplicaProgress) {
+	n.nodeID = nodeID
+	n.progress = fn
+}
+
+// OnChange registers fn to run after every change to the link table. Call it
+// before the network is used.
+func (n *Network) OnChange(fn func()) {
+	n.onChange = fn
+}
+
+// Connected reports whether messages pass between a and b
+func (n *Network) Connected(a, b string) bool {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	l, ok := n.links[keyOf(a, b)]
+	return !ok || !l.cut
+}
+
+// Lag returns the extra delay of messages between a and b
+func (n *Network) Lag(a, b string) time.Duration {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if l, ok := n.links[keyOf(a, b)]; ok {
+		return l.lag
+	}
+	return 0
+}
+
+// Cut stops messages between a and b in both directions
+func (n *Network) Cut(a, b string) error {
+	return n.update(a, b, func(l *link) { l.cut = true })
+}
+
+// Heal restores the link between a and b
+func (n *Network) Heal(a, b string) error {
+	return n.update(a, b, func(l *link) { l.cut = false })
+}
+
+// SetLag delays messages between a and b by lag, on top of any delay the
+// replication mode has of its own
+func (n *Network) SetLag(a, b string, lag time.Duration) error {
+	return n.update(a, b, func(l *link) { l.lag = lag })
+}
+
+func (n *Network) update(a, b string, fn func(*link)) error {
+	n.mu.Lock()
+	l, ok := n.links[keyOf(a, b)]
+	if !ok {
+		n.mu.Unlock()
+		return fmt.Errorf("%w %s-%s", ErrUnknownLink, a, b)
+	}
+	fn(l)
+	n.mu.Unlock()
+
+	n.changed()
+	return nil
+}
+
+// Partition cuts every link between endpoints of different groups and heals
+// every link inside a group. Links to endpoints in no group are left alone.
+func (n *Network) Partition(groups [][]string) error {
+	group := make(map[string]int)
+	for i, members := range groups {
+		for _, member := range members {
+			if previous, ok := group[member]; ok && previous != i {
+				return fmt.Errorf("%s is in more than one group", member)
+			}
+			group[member] = i
+		}
+	}
+
+	n.mu.Lock()
+	for key, l := range n.links {
+		a, aOK := group[key.a]
+		b, bOK := group[key.b]
+		if aOK && bOK {
+			l.cut = a != b
+		}
+	}
+	n.mu.Unlock()
+
+	n.changed()
+	return nil
+}
+
+// HealAll restores every link and removes all lag
+func (n *Network) HealAll() {
+	n.mu.Lock()
+	for _, l := range n.links {
+		*l = link{}
+	}
+	n.mu.Unlock()
+
+	n.changed()
+}
+
+func (n *Network) changed() {
+	if n.onChange != nil {
+		n.onChange()
+	}
+}
+
+// Status describes every link and, when SetProgress was called, how far each
+// replica has got
+func (n *Network) Status() models.NetworkStatus {
+	status := models.NetworkStatus{
+		NodeID:    n.nodeID,
+		Nodes:     []string{},
+		Links:     []models.NetworkLink{},
+		Replicas:  []models.ReplicaProgress{},
+		Timestamp: time.Now(),
+	}
+
+	nodes := make(map[string]bool)
+	n.mu.Lock()
+	for _, key := range n.order {
+		l := n.links[keyOf(key.a, key.b)]
+		status.Links = append(status.Links, models.NetworkLink{
+			A:     key.a,
+			B:     key.b,
+			Cut:   l.cut,
+			LagMs: float64(l.lag.Microseconds()) / 1000,
+		})
+		nodes[key.a] = true
+		nodes[key.b] = true
+	}
+	n.mu.Unlock()
+
+	for node := range nodes {
+		status.Nodes = append(status.Nodes, node)
+	}
+	sort.Strings(status.Nodes)
+
+	if n.progress != nil {
+		status.Replicas = n.progress()
+	}
+	return status
+}
@@ -0,0 +1,151 @@
+package netsim
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/AnishMulay/Transaction-Conflict-Visualization/pkg/models"
+)
+
+// newTestNetwork links r1, r2 and r3 with each other and with their clients
+func newTestNetwork() *Network {
+	network := New()
+	for _, a := range []string{"r1", "r2", "r3"} {
+		for _, b := range []string{"r1", "r2", "r3", Clients} {
+			network.AddLink(a, b)
+		}
+	}
+	return network
+}
+
+func TestPartitionCutsLinksBetweenGroups(t *testing.T) {
+	network := newTestNetwork()
+	changes := 0
+	network.OnChange(func() { changes++ })
+
+	if err := network.Partition([][]string{{"r1", Clients}, {"r2", "r3"}}); err != nil {
+		t.Fatalf("Partition failed: %v", err)
+	}
+	tests := []struct {
+		a, b      string
+		connected bool
+	}{
+		{"r1", Clients, true},
+		{"r2", "r3", true},
+		{"r1", "r2", false},
+		{"r3", "r1", false},
+		{"r2", Clients, false},
+		// Links the network does not have are never cut
+		{"r1", "r9", true},
+	}
+	for _, test := range tests {
+		if got := network.Connected(test.a, test.b); got != test.connected {
+			t.Errorf("%s-%s: expected connected=%v, got %v", test.a, test.b, test.connected, got)
+		}
+	}
+
+	if err := network.Partition([][]string{{"r1"}, {"r1", "r2"}}); err == nil {
+		t.Error("Expected an endpoint in two groups to be refused")
+	}
+
+	network.HealAll()
+	if !network.Connected("r1", "r2") || !network.Connected("r2", Clients) {
+		t.Error("Expected every link connected after HealAll")
+	}
+	if changes != 2 {
+		t.Errorf("Expected 2 change notifications, got %d", changes)
+	}
+}
+
+func TestCutHealAndLag(t *testing.T) {
+	network := newTestNetwork()
+
+	if err := network.Cut("r2", "r1"); err != nil {
+		t.Fatalf("Cut failed: %v", err)
+	}
+	if network.Connected("r1", "r2") {
+		t.Error("Expected the link cut in both directions")
+	}
+	if err := network.SetLag("r1", "r3", 250*time.Millisecond); err != nil {
+		t.Fatalf("SetLag failed: %v", err)
+	}
+	if lag := network.Lag("r3", "r1"); lag != 250*time.Millisecond {
+		t.Errorf("Expected 250ms lag, got %v", lag)
+	}
+	if err := network.Cut("r1", "r9"); err == nil {
+		t.Error("Expected cutting an unknown link to fail")
+	}
+
+	network.Heal("r1", "r2")
+	if !network.Connected("r1", "r2") || network.Lag("r1", "r3") != 250*time.Millisecond {
+		t.Error("Expected Heal to restore one link and keep the lag of others")
+	}
+}
+
+func TestNetworkAPI(t *testing.T) {
+	network := newTestNetwork()
+	network.SetProgress("", func() []models.ReplicaProgress {
+		return []models.ReplicaProgress{{ID: "r1", Applied: 3, Version: 4, Reachable: true}}
+	})
+
+	mux := http.NewServeMux()
+	network.Register(mux)
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	post := func(path string, wantStatus int) models.NetworkStatus {
+		t.Helper()
+		resp, err := http.Post(server.URL+path, "application/json", nil)
+		if err != nil {
+			t.Fatalf("POST %s failed: %v", path, err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != wantStatus {
+			t.Fatalf("POST %s: expected %d, got %d", path, wantStatus, resp.StatusCode)
+		}
+
+		var status models.NetworkStatus
+		if wantStatus == http.StatusOK {
+			if err := json.NewDecoder(resp.Body).Decode(&status); err != nil {
+				t.Fatalf("Failed to decode status: %v", err)
+			}
+		}
+		return status
+	}
+
+	status := post("/network/partition?groups=r1|r2,r3,clients", http.StatusOK)
+	cut := 0
+	for _, link := range status.Links {
+		if link.Cut {
+			cut++
+		}
+	}
+	// r1 loses r2, r3 and its clients
+	if cut != 3 || len(status.Nodes) != 4 || len(status.Replicas) != 1 || status.Replicas[0].Applied != 3 {
+		t.Errorf("Unexpected status after partition %+v", status)
+	}
+
+	status = post("/network/lag?a=r2&b=r3&delay=150ms", http.StatusOK)
+	for _, link := range status.Links {
+		if link.A == "r2" && link.B == "r3" && link.LagMs != 150 {
+			t.Errorf("Expected 150ms lag on r2-r3, got %+v", link)
+		}
+	}
+
+	post("/network/heal?a=r1&b=r2", http.StatusOK)
+	if !network.Connected("r1", "r2") || network.Connected("r1", "r3") {
+		t.Error("Expected only r1-r2 healed")
+	}
+
+	post("/network/cut?a=r1&b=nowhere", http.StatusNotFound)
+	post("/network/lag?a=r1&b=r2&delay=-1s", http.StatusBadRequest)
+	post("/network/partition?groups=r1", http.StatusBadRequest)
+
+	post("/network/heal", http.StatusOK)
+	if !network.Connected("r1", "r3") || network.Lag("r2", "r3") != 0 {
+		t.Error("Expected every link healed and lag removed")
+	}
+}
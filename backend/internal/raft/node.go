@@ -0,0 +1,803 @@
+// Package raft implements the Raft consensus algorithm: leader election, log
+// replication and commitment by majority. State is kept in memory, so a
+// process that is killed rejoins as an empty node; Fail simulates a crash
+// that keeps the log, as if it had been on disk.
+package raft
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/AnishMulay/Transaction-Conflict-Visualization/pkg/models"
+)
+
+var (
+	// ErrNotLeader is wrapped by NotLeaderError
+	ErrNotLeader = errors.New("not the leader")
+	// ErrNodeDown is returned while a simulated failure is in progress
+	ErrNodeDown = errors.New("node is down")
+	// ErrEntryLost is returned by Wait when a new leader replaced the entry
+	// before it committed
+	ErrEntryLost = errors.New("entry was replaced by a new leader before it committed")
+	// ErrResultExpired is returned by Wait for an entry applied so long ago
+	// that its result is no longer kept
+	ErrResultExpired = errors.New("entry result no longer available")
+	// ErrLinkCut is returned for RPCs from a peer whose simulated link to this
+	// node is cut
+	ErrLinkCut = errors.New("link to peer is cut")
+)
+
+const (
+	// maxAppendEntries caps the entries sent in one AppendEntries request
+	maxAppendEntries = 64
+	// resultHistorySize is how many apply results are kept for Wait
+	resultHistorySize = 1024
+	// statusLogSize is how many recent entries Status reports
+	statusLogSize = 20
+)
+
+// Role is the part a node currently plays in the protocol
+type Role string
+
+const (
+	RoleFollower  Role = "follower"
+	RoleCandidate Role = "candidate"
+	RoleLeader    Role = "leader"
+	// RoleDown is reported while a simulated failure is in progress
+	RoleDown Role = "down"
+)
+
+// NotLeaderError is returned by Propose on a node that is not the leader.
+// LeaderID and LeaderAddress are empty while no leader is known.
+type NotLeaderError struct {
+	LeaderID      string
+	LeaderAddress string
+}
+
+func (e *NotLeaderError) Error() string {
+	if e.LeaderID == "" {
+		return fmt.Sprintf("%v, no leader elected", ErrNotLeader)
+	}
+	return fmt.Sprintf("%v, the leader is %s", ErrNotLeader, e.LeaderID)
+}
+
+func (e *NotLeaderError) Unwrap() error {
+	return ErrNotLeader
+}
+
+// Entry is one entry of the replicated log. Command is nil for the no-op a
+// new leader appends to commit entries from earlier terms.
+type Entry struct {
+	Index   uint64          `json:"index"`
+	Term    uint64          `json:"term"`
+	Command json.RawMessage `json:"command,omitempty"`
+}
+
+// ApplyFunc applies a committed entry to the state machine. Every node calls
+// it for every entry, in log order, from a single goroutine. The result is
+// handed to Wait on this node.
+type ApplyFunc func(entry Entry) interface{}
+
+// Config configures a node. Peers maps the ID of every other member of the
+// cluster to its address. The election timeout is randomized between
+// ElectionTimeout and twice that.
+type Config struct {
+	ID                string
+	Peers             map[string]string
+	ElectionTimeout   time.Duration
+	HeartbeatInterval time.Duration
+}
+
+// DefaultConfig returns timeouts suited to nodes on one machine
+func DefaultConfig(id string, peers map[string]string) Config {
+	return Config{
+		ID:                id,
+		Peers:             peers,
+		ElectionTimeout:   300 * time.Millisecond,
+		HeartbeatInterval: 50 * time.Millisecond,
+	}
+}
+
+// Links simulates the network between nodes: RPCs over a link that is not
+// connected are dropped in both directions, and those over a lagging link
+// are sent late
+type Links interface {
+	Connected(a, b string) bool
+	Lag(a, b string) time.Duration
+}
+
+// fingerprint summarizes what Status shows, so that heartbeats that change
+// nothing are not reported
+type fingerprint struct {
+	role        Role
+	down        bool
+	term        uint64
+	leaderID    string
+	lastIndex   uint64
+	commitIndex uint64
+	lastApplied uint64
+	matched     uint64
+	reachable   int
+}
+
+// appliedResult is the value ApplyFunc returned for an entry
+type appliedResult struct {
+	term  uint64
+	value interface{}
+}
+
+// Node is one member of a Raft cluster
+type Node struct {
+	mu        sync.Mutex
+	config    Config
+	transport Transport
+	apply     ApplyFunc
+	links     Links
+
+	role     Role
+	term     uint64
+	votedFor string
+	leaderID string
+	votes    int
+	// log[0] is a sentinel so that log[i] has index i
+	log         []Entry
+	commitIndex uint64
+	lastApplied uint64
+
+	// Leader state, reset on election
+	nextIndex     map[string]uint64
+	matchIndex    map[string]uint64
+	inFlight      map[string]bool
+	lastContact   map[string]time.Time
+	nextHeartbeat time.Time
+
+	electionDeadline time.Time
+	down             bool
+	downUntil        time.Time
+
+	results map[uint64]appliedResult
+	// changed is closed and replaced whenever an entry is applied or the node
+	// fails, waking Wait
+	changed   chan struct{}
+	committed chan struct{}
+	onChange  func()
+	notified  fingerprint
+	stop      chan struct{}
+	stopOnce  sync.Once
+}
+
+// NewNode creates a follower. Call Start to begin taking part in elections.
+func NewNode(config Config, transport Transport, apply ApplyFunc) *Node {
+	n := &Node{
+		config:    config,
+		transport: transport,
+		apply:     apply,
+		role:      RoleFollower,
+		log:       []Entry{{}},
+		results:   make(map[uint64]appliedResult),
+		changed:   make(chan struct{}),
+		committed: make(chan struct{}, 1),
+		stop:      make(chan struct{}),
+	}
+	n.resetElectionTimer()
+	return n
+}
+
+// ID returns the node's ID
+func (n *Node) ID() string {
+	return n.config.ID
+}
+
+// SetOnChange registers fn to run whenever the node's role, term, log or
+// commit index changes. fn is called without the node's mutex held.
+func (n *Node) SetOnChange(fn func()) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.onChange = fn
+}
+
+// SetLinks makes the node honour simulated network links to its peers. Call
+// it before Start.
+func (n *Node) SetLinks(links Links) {
+	n.links = links
+}
+
+// Start runs the node's timers and applier until Stop
+func (n *Node) Start() {
+	go n.run()
+	go n.applyCommitted()
+}
+
+// Stop shuts the node down for good
+func (n *Node) Stop() {
+	n.stopOnce.Do(func() { close(n.stop) })
+}
+
+// Fail simulates a crash for duration: the node stops sending and answering
+// RPCs and refuses proposals, then recovers as a follower with its log intact
+func (n *Node) Fail(duration time.Duration) {
+	n.mu.Lock()
+	n.down = true
+	n.downUntil = time.Now().Add(duration)
+	n.role = RoleFollower
+	n.leaderID = ""
+	n.wake()
+	n.unlockAndNotify()
+}
+
+// Propose appends command to the log if this node is the leader and starts
+// replicating it. It returns the entry's index and term without waiting for
+// the entry to commit; use Wait for that.
+func (n *Node) Propose(command []byte) (index, term uint64, err error) {
+	n.mu.Lock()
+
+	if n.down {
+		n.mu.Unlock()
+		return 0, 0, ErrNodeDown
+	}
+	if n.role != RoleLeader {
+		err := &NotLeaderError{LeaderID: n.leaderID, LeaderAddress: n.config.Peers[n.leaderID]}
+		n.mu.Unlock()
+		return 0, 0, err
+	}
+
+	entry := n.appendEntry(command)
+	n.advanceCommit()
+	n.replicate()
+	n.unlockAndNotify()
+	return entry.Index, entry.Term, nil
+}
+
+// Wait blocks until the entry at index has been applied on this node and
+// returns the value ApplyFunc produced for it. It returns ErrEntryLost if the
+// entry applied at index is not the one proposed in term.
+func (n *Node) Wait(index, term uint64, timeout time.Duration) (interface{}, error) {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	for {
+		n.mu.Lock()
+		if n.lastApplied >= index {
+			result, ok := n.results[index]
+			n.mu.Unlock()
+			if !ok {
+				return nil, ErrResultExpired
+			}
+			if result.term != term {
+				return nil, ErrEntryLost
+			}
+			return result.value, nil
+		}
+		if n.down {
+			n.mu.Unlock()
+			return nil, ErrNodeDown
+		}
+		changed := n.changed
+		n.mu.Unlock()
+
+		select {
+		case <-changed:
+		case <-timer.C:
+			return nil, fmt.Errorf("entry %d not committed after %v", index, timeout)
+		case <-n.stop:
+			return nil, ErrNodeDown
+		}
+	}
+}
+
+// Status describes the node for visualization
+func (n *Node) Status() models.RaftStatus {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	status := models.RaftStatus{
+		NodeID:      n.config.ID,
+		Role:        string(n.role),
+		Term:        n.term,
+		LeaderID:    n.leaderID,
+		VotedFor:    n.votedFor,
+		LastIndex:   n.lastIndex(),
+		CommitIndex: n.commitIndex,
+		LastApplied: n.lastApplied,
+		Peers:       []models.RaftPeer{},
+		Log:         []models.RaftEntry{},
+		Timestamp:   time.Now(),
+	}
+	if n.down {
+		status.Role = string(RoleDown)
+	}
+
+	for _, id := range n.peerIDs() {
+		peer := models.RaftPeer{ID: id, Address: n.config.Peers[id]}
+		if n.role == RoleLeader {
+			peer.MatchIndex = n.matchIndex[id]
+			peer.NextIndex = n.nextIndex[id]
+			peer.Reachable = n.reachable(id)
+		}
+		status.Peers = append(status.Peers, peer)
+	}
+
+	first := 1
+	if len(n.log) > statusLogSize {
+		first = len(n.log) - statusLogSize
+	}
+	for _, entry := range n.log[first:] {
+		status.Log = append(status.Log, models.RaftEntry{
+			Index:     entry.Index,
+			Term:      entry.Term,
+			Committed: entry.Index <= n.commitIndex,
+			Command:   entry.Command,
+		})
+	}
+
+	return status
+}
+
+// run drives elections and heartbeats
+func (n *Node) run() {
+	ticker := time.NewTicker(n.config.HeartbeatInterval / 5)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-n.stop:
+			return
+		case <-ticker.C:
+			n.tick()
+		}
+	}
+}
+
+func (n *Node) tick() {
+	n.mu.Lock()
+	now := time.Now()
+
+	if n.down {
+		if now.Before(n.downUntil) {
+			n.mu.Unlock()
+			return
+		}
+		n.down = false
+		n.resetElectionTimer()
+		n.unlockAndNotify()
+		return
+	}
+
+	switch n.role {
+	case RoleLeader:
+		if !now.Before(n.nextHeartbeat) {
+			n.replicate()
+		}
+		// Reports peers that stopped answering
+		n.unlockAndNotify()
+	default:
+		if now.Before(n.electionDeadline) {
+			n.mu.Unlock()
+			return
+		}
+		n.startElection()
+		n.unlockAndNotify()
+	}
+}
+
+// startElection makes the node a candidate for the next term. Caller must
+// hold n.mu.
+func (n *Node) startElection() {
+	n.term++
+	n.role = RoleCandidate
+	n.votedFor = n.config.ID
+	n.leaderID = ""
+	n.votes = 1
+	n.resetElectionTimer()
+
+	if n.votes > len(n.config.Peers)/2 {
+		n.becomeLeader()
+		return
+	}
+
+	args := RequestVoteArgs{
+		Term:         n.term,
+		CandidateID:  n.config.ID,
+		LastLogIndex: n.lastIndex(),
+		LastLogTerm:  n.log[n.lastIndex()].Term,
+	}
+	for _, peer := range n.peerIDs() {
+		go n.requestVote(peer, args)
+	}
+}
+
+func (n *Node) requestVote(peer string, args RequestVoteArgs) {
+	if !n.send(peer) {
+		return
+	}
+	reply, err := n.transport.RequestVote(n.config.Peers[peer], args)
+	if err != nil {
+		return
+	}
+
+	n.mu.Lock()
+	if n.down {
+		n.mu.Unlock()
+		return
+	}
+	if reply.Term > n.term {
+		n.stepDown(reply.Term)
+		n.unlockAndNotify()
+		return
+	}
+	if n.role != RoleCandidate || n.term != args.Term || !reply.VoteGranted {
+		n.mu.Unlock()
+		return
+	}
+
+	n.votes++
+	if n.votes > len(n.config.Peers)/2 {
+		n.becomeLeader()
+		n.unlockAndNotify()
+		return
+	}
+	n.mu.Unlock()
+}
+
+// becomeLeader takes over replication and appends a no-op, which commits the
+// entries of earlier terms once it is replicated. Caller must hold n.mu.
+func (n *Node) becomeLeader() {
+	n.role = RoleLeader
+	n.leaderID = n.config.ID
+	n.nextIndex = make(map[string]uint64)
+	n.matchIndex = make(map[string]uint64)
+	n.inFlight = make(map[string]bool)
+	n.lastContact = make(map[string]time.Time)
+
+	for peer := range n.config.Peers {
+		n.nextIndex[peer] = n.lastIndex() + 1
+		n.lastContact[peer] = time.Now()
+	}
+
+	n.appendEntry(nil)
+	n.advanceCommit()
+	n.replicate()
+}
+
+// stepDown makes the node a follower in term. Caller must hold n.mu.
+func (n *Node) stepDown(term uint64) {
+	if term > n.term {
+		n.term = term
+		n.votedFor = ""
+		n.leaderID = ""
+	}
+	n.role = RoleFollower
+	n.resetElectionTimer()
+}
+
+// replicate sends AppendEntries to every peer without a request in flight,
+// which doubles as the heartbeat. Caller must hold n.mu.
+func (n *Node) replicate() {
+	n.nextHeartbeat = time.Now().Add(n.config.HeartbeatInterval)
+
+	for _, peer := range n.peerIDs() {
+		if n.inFlight[peer] {
+			continue
+		}
+		n.inFlight[peer] = true
+		go n.sendAppendEntries(peer, n.appendArgs(peer))
+	}
+}
+
+// appendArgs builds the AppendEntries request for a peer from its nextIndex.
+// Caller must hold n.mu.
+func (n *Node) appendArgs(peer string) AppendEntriesArgs {
+	prev := n.nextIndex[peer] - 1
+	last := n.lastIndex()
+	if last > prev+maxAppendEntries {
+		last = prev + maxAppendEntries
+	}
+
+	return AppendEntriesArgs{
+		Term:         n.term,
+		LeaderID:     n.config.ID,
+		PrevLogIndex: prev,
+		PrevLogTerm:  n.log[prev].Term,
+		Entries:      append([]Entry(nil), n.log[prev+1:last+1]...),
+		LeaderCommit: n.commitIndex,
+	}
+}
+
+func (n *Node) sendAppendEntries(peer string, args AppendEntriesArgs) {
+	var reply AppendEntriesReply
+	err := ErrLinkCut
+	if n.send(peer) {
+		reply, err = n.transport.AppendEntries(n.config.Peers[peer], args)
+	}
+
+	n.mu.Lock()
+	n.inFlight[peer] = false
+	if err != nil || n.down {
+		n.mu.Unlock()
+		return
+	}
+	if reply.Term > n.term {
+		n.stepDown(reply.Term)
+		n.unlockAndNotify()
+		return
+	}
+	if n.role != RoleLeader || n.term != args.Term {
+		n.mu.Unlock()
+		return
+	}
+
+	n.lastContact[peer] = time.Now()
+
+	if reply.Success {
+		if match := args.PrevLogIndex + uint64(len(args.Entries)); match > n.matchIndex[peer] {
+			n.matchIndex[peer] = match
+			n.nextIndex[peer] = match + 1
+			n.advanceCommit()
+		}
+	} else {
+		n.nextIndex[peer] = max(1, min(reply.ConflictIndex, n.lastIndex()+1))
+	}
+
+	// Keep going while the peer is behind
+	if n.nextIndex[peer] <= n.lastIndex() {
+		n.inFlight[peer] = true
+		go n.sendAppendEntries(peer, n.appendArgs(peer))
+	}
+	n.unlockAndNotify()
+}
+
+// advanceCommit commits the newest entry of the current term stored on a
+// majority, and with it every entry before it. Caller must hold n.mu.
+func (n *Node) advanceCommit() {
+	for index := n.lastIndex(); index > n.commitIndex; index-- {
+		if n.log[index].Term != n.term {
+			break
+		}
+
+		replicas := 1
+		for _, match := range n.matchIndex {
+			if match >= index {
+				replicas++
+			}
+		}
+		if replicas > (len(n.config.Peers)+1)/2 {
+			n.setCommitIndex(index)
+			return
+		}
+	}
+}
+
+// setCommitIndex advances the commit index and wakes the applier. Caller must
+// hold n.mu.
+func (n *Node) setCommitIndex(index uint64) {
+	if index <= n.commitIndex {
+		return
+	}
+	n.commitIndex = index
+
+	select {
+	case n.committed <- struct{}{}:
+	default:
+	}
+}
+
+// applyCommitted applies committed entries in order, outside the mutex
+func (n *Node) applyCommitted() {
+	for {
+		select {
+		case <-n.stop:
+			return
+		case <-n.committed:
+		}
+
+		for {
+			n.mu.Lock()
+			if n.lastApplied >= n.commitIndex {
+				n.mu.Unlock()
+				break
+			}
+			entry := n.log[n.lastApplied+1]
+			n.mu.Unlock()
+
+			var value interface{}
+			if entry.Command != nil {
+				value = n.apply(entry)
+			}
+
+			n.mu.Lock()
+			n.lastApplied = entry.Index
+			n.results[entry.Index] = appliedResult{term: entry.Term, value: value}
+			delete(n.results, entry.Index-resultHistorySize)
+			n.wake()
+			n.unlockAndNotify()
+		}
+	}
+}
+
+// HandleRequestVote answers a candidate's vote request
+func (n *Node) HandleRequestVote(args RequestVoteArgs) (RequestVoteReply, error) {
+	if !n.connected(args.CandidateID) {
+		return RequestVoteReply{}, ErrLinkCut
+	}
+
+	n.mu.Lock()
+	if n.down {
+		n.mu.Unlock()
+		return RequestVoteReply{}, ErrNodeDown
+	}
+
+	if args.Term > n.term {
+		n.stepDown(args.Term)
+	}
+
+	reply := RequestVoteReply{Term: n.term}
+	lastIndex := n.lastIndex()
+	lastTerm := n.log[lastIndex].Term
+	upToDate := args.LastLogTerm > lastTerm || (args.LastLogTerm == lastTerm && args.LastLogIndex >= lastIndex)
+
+	if args.Term == n.term && (n.votedFor == "" || n.votedFor == args.CandidateID) && upToDate {
+		n.votedFor = args.CandidateID
+		n.resetElectionTimer()
+		reply.VoteGranted = true
+	}
+
+	n.unlockAndNotify()
+	return reply, nil
+}
+
+// HandleAppendEntries accepts entries and heartbeats from the leader
+func (n *Node) HandleAppendEntries(args AppendEntriesArgs) (AppendEntriesReply, error) {
+	if !n.connected(args.LeaderID) {
+		return AppendEntriesReply{}, ErrLinkCut
+	}
+
+	n.mu.Lock()
+	if n.down {
+		n.mu.Unlock()
+		return AppendEntriesReply{}, ErrNodeDown
+	}
+
+	if args.Term < n.term {
+		reply := AppendEntriesReply{Term: n.term}
+		n.mu.Unlock()
+		return reply, nil
+	}
+
+	n.stepDown(args.Term)
+	n.leaderID = args.LeaderID
+	reply := AppendEntriesReply{Term: n.term}
+
+	// The entry before the new ones must match, or the leader backs up
+	if args.PrevLogIndex > n.lastIndex() {
+		reply.ConflictIndex = n.lastIndex() + 1
+		n.unlockAndNotify()
+		return reply, nil
+	}
+	if term := n.log[args.PrevLogIndex].Term; term != args.PrevLogTerm {
+		// Skip back over the whole conflicting term at once
+		index := args.PrevLogIndex
+		for index > 1 && n.log[index-1].Term == term {
+			index--
+		}
+		reply.ConflictIndex = index
+		n.unlockAndNotify()
+		return reply, nil
+	}
+
+	for _, entry := range args.Entries {
+		if entry.Index <= n.lastIndex() {
+			if n.log[entry.Index].Term == entry.Term {
+				continue
+			}
+			// A conflicting suffix was never committed and is replaced
+			n.log = n.log[:entry.Index]
+		}
+		n.log = append(n.log, entry)
+	}
+
+	if args.LeaderCommit > n.commitIndex {
+		n.setCommitIndex(min(args.LeaderCommit, args.PrevLogIndex+uint64(len(args.Entries))))
+	}
+
+	reply.Success = true
+	n.unlockAndNotify()
+	return reply, nil
+}
+
+// appendEntry adds a command to the leader's log. Caller must hold n.mu.
+func (n *Node) appendEntry(command []byte) Entry {
+	entry := Entry{Index: n.lastIndex() + 1, Term: n.term, Command: command}
+	n.log = append(n.log, entry)
+	return entry
+}
+
+// lastIndex returns the index of the last log entry. Caller must hold n.mu.
+func (n *Node) lastIndex() uint64 {
+	return uint64(len(n.log) - 1)
+}
+
+// peerIDs returns the peers in a stable order
+func (n *Node) peerIDs() []string {
+	ids := make([]string, 0, len(n.config.Peers))
+	for id := range n.config.Peers {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// resetElectionTimer picks a new randomized election deadline. Caller must
+// hold n.mu.
+func (n *Node) resetElectionTimer() {
+	timeout := n.config.ElectionTimeout + time.Duration(rand.Int63n(int64(n.config.ElectionTimeout)))
+	n.electionDeadline = time.Now().Add(timeout)
+}
+
+// wake releases everything blocked in Wait. Caller must hold n.mu.
+func (n *Node) wake() {
+	close(n.changed)
+	n.changed = make(chan struct{})
+}
+
+// connected reports whether the simulated link to peer is up
+func (n *Node) connected(peer string) bool {
+	return n.links == nil || n.links.Connected(n.config.ID, peer)
+}
+
+// send waits out the simulated lag to peer and reports whether an RPC may be
+// sent to it. Must not hold n.mu.
+func (n *Node) send(peer string) bool {
+	if n.links == nil {
+		return true
+	}
+	if lag := n.links.Lag(n.config.ID, peer); lag > 0 {
+		select {
+		case <-time.After(lag):
+		case <-n.stop:
+			return false
+		}
+	}
+	return n.connected(peer)
+}
+
+// reachable reports whether the leader heard from a peer recently. Caller
+// must hold n.mu.
+func (n *Node) reachable(peer string) bool {
+	return time.Since(n.lastContact[peer]) < 3*n.config.HeartbeatInterval
+}
+
+// unlockAndNotify releases n.mu and then tells the observer if anything
+// Status shows has changed
+func (n *Node) unlockAndNotify() {
+	current := fingerprint{
+		role:        n.role,
+		down:        n.down,
+		term:        n.term,
+		leaderID:    n.leaderID,
+		lastIndex:   n.lastIndex(),
+		commitIndex: n.commitIndex,
+		lastApplied: n.lastApplied,
+	}
+	if n.role == RoleLeader {
+		for peer, match := range n.matchIndex {
+			current.matched += match
+			if n.reachable(peer) {
+				current.reachable++
+			}
+		}
+	}
+
+	onChange := n.onChange
+	changed := current != n.notified
+	n.notified = current
+	n.mu.Unlock()
+
+	if changed && onChange != nil {
+		onChange()
+	}
+}
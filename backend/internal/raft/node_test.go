@@ -0,0 +1,295 @@
+package raft
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+// memTransport delivers RPCs by calling the target node directly
+type memTransport struct {
+	mu    sync.Mutex
+	nodes map[string]*Node
+}
+
+func (t *memTransport) node(address string) (*Node, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	node, ok := t.nodes[address]
+	if !ok {
+		return nil, fmt.Errorf("no node at %s", address)
+	}
+	return node, nil
+}
+
+func (t *memTransport) RequestVote(address string, args RequestVoteArgs) (RequestVoteReply, error) {
+	node, err := t.node(address)
+	if err != nil {
+		return RequestVoteReply{}, err
+	}
+	return node.HandleRequestVote(args)
+}
+
+func (t *memTransport) AppendEntries(address string, args AppendEntriesArgs) (AppendEntriesReply, error) {
+	node, err := t.node(address)
+	if err != nil {
+		return AppendEntriesReply{}, err
+	}
+	return node.HandleAppendEntries(args)
+}
+
+// testLinks is a partition table shared by every node of a test cluster
+type testLinks struct {
+	mu       sync.Mutex
+	isolated map[string]bool
+}
+
+func (l *testLinks) Connected(a, b string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return !l.isolated[a] && !l.isolated[b]
+}
+
+func (l *testLinks) Lag(a, b string) time.Duration {
+	return 0
+}
+
+// isolate cuts every link of the node named id, or heals them
+func (l *testLinks) isolate(id string, isolated bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.isolated[id] = isolated
+}
+
+// testCluster is a set of nodes whose state machines record applied commands
+type testCluster struct {
+	nodes   []*Node
+	links   *testLinks
+	mu      sync.Mutex
+	applied map[string][]string
+}
+
+func newTestCluster(t *testing.T, size int) *testCluster {
+	t.Helper()
+	transport := &memTransport{nodes: make(map[string]*Node)}
+	cluster := &testCluster{
+		links:   &testLinks{isolated: make(map[string]bool)},
+		applied: make(map[string][]string),
+	}
+
+	for i := 1; i <= size; i++ {
+		id := fmt.Sprintf("n%d", i)
+		peers := make(map[string]string)
+		for j := 1; j <= size; j++ {
+			if j != i {
+				peers[fmt.Sprintf("n%d", j)] = fmt.Sprintf("n%d", j)
+			}
+		}
+
+		config := Config{ID: id, Peers: peers, ElectionTimeout: 50 * time.Millisecond, HeartbeatInterval: 10 * time.Millisecond}
+		node := NewNode(config, transport, func(entry Entry) interface{} {
+			cluster.mu.Lock()
+			defer cluster.mu.Unlock()
+			cluster.applied[id] = append(cluster.applied[id], string(entry.Command))
+			return len(cluster.applied[id])
+		})
+		node.SetLinks(cluster.links)
+		transport.nodes[id] = node
+		cluster.nodes = append(cluster.nodes, node)
+	}
+
+	for _, node := range cluster.nodes {
+		node.Start()
+		t.Cleanup(node.Stop)
+	}
+	return cluster
+}
+
+// waitForLeader polls until exactly one live node leads and the others follow it
+func (c *testCluster) waitForLeader(t *testing.T) *Node {
+	t.Helper()
+	deadline := time.Now().Add(3 * time.Second)
+	for time.Now().Before(deadline) {
+		var leader *Node
+		leaders, followers := 0, 0
+		for _, node := range c.nodes {
+			status := node.Status()
+			switch Role(status.Role) {
+			case RoleLeader:
+				leader = node
+				leaders++
+			case RoleFollower:
+				followers++
+			}
+		}
+		if leaders == 1 {
+			agreed := true
+			for _, node := range c.nodes {
+				if status := node.Status(); Role(status.Role) == RoleFollower && status.LeaderID != leader.ID() {
+					agreed = false
+				}
+			}
+			if agreed {
+				return leader
+			}
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatal("No leader elected")
+	return nil
+}
+
+func (c *testCluster) appliedOn(id string) []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]string(nil), c.applied[id]...)
+}
+
+// propose commits a command through the leader and waits for it
+func propose(t *testing.T, leader *Node, command string) interface{} {
+	t.Helper()
+	index, term, err := leader.Propose([]byte(`"` + command + `"`))
+	if err != nil {
+		t.Fatalf("Propose %s failed: %v", command, err)
+	}
+	result, err := leader.Wait(index, term, 2*time.Second)
+	if err != nil {
+		t.Fatalf("Wait for %s failed: %v", command, err)
+	}
+	return result
+}
+
+// waitForApplied polls until every node has applied want
+func (c *testCluster) waitForApplied(t *testing.T, want []string) {
+	t.Helper()
+	deadline := time.Now().Add(3 * time.Second)
+	for _, node := range c.nodes {
+		for fmt.Sprint(c.appliedOn(node.ID())) != fmt.Sprint(want) {
+			if time.Now().After(deadline) {
+				t.Fatalf("%s applied %v, expected %v", node.ID(), c.appliedOn(node.ID()), want)
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+	}
+}
+
+func TestLeaderReplicatesCommandsInOrder(t *testing.T) {
+	cluster := newTestCluster(t, 3)
+	leader := cluster.waitForLeader(t)
+
+	var want []string
+	for i := 1; i <= 5; i++ {
+		command := fmt.Sprintf("move-%d", i)
+		if result := propose(t, leader, command); result != i {
+			t.Errorf("Expected the state machine result %d, got %v", i, result)
+		}
+		want = append(want, `"`+command+`"`)
+	}
+
+	cluster.waitForApplied(t, want)
+
+	status := leader.Status()
+	// The leader's no-op plus five commands
+	if status.CommitIndex != 6 || len(status.Log) != 6 || status.Log[0].Command != nil {
+		t.Errorf("Unexpected leader status %+v", status)
+	}
+	for _, peer := range status.Peers {
+		if peer.MatchIndex != 6 || !peer.Reachable {
+			t.Errorf("Expected %s fully replicated, got %+v", peer.ID, peer)
+		}
+	}
+}
+
+func TestFollowerRejectsProposals(t *testing.T) {
+	cluster := newTestCluster(t, 3)
+	leader := cluster.waitForLeader(t)
+
+	for _, node := range cluster.nodes {
+		if node == leader {
+			continue
+		}
+		_, _, err := node.Propose([]byte(`"x"`))
+		var notLeader *NotLeaderError
+		if !errors.As(err, &notLeader) || notLeader.LeaderID != leader.ID() || notLeader.LeaderAddress != leader.ID() {
+			t.Errorf("Expected a NotLeaderError naming %s, got %v", leader.ID(), err)
+		}
+	}
+}
+
+func TestClusterSurvivesLeaderFailure(t *testing.T) {
+	cluster := newTestCluster(t, 3)
+	oldLeader := cluster.waitForLeader(t)
+	propose(t, oldLeader, "before")
+
+	oldLeader.Fail(500 * time.Millisecond)
+	if _, _, err := oldLeader.Propose([]byte(`"x"`)); !errors.Is(err, ErrNodeDown) {
+		t.Fatalf("Expected a failed node to refuse proposals, got %v", err)
+	}
+
+	// The remaining majority elects a new leader and keeps committing
+	var newLeader *Node
+	deadline := time.Now().Add(3 * time.Second)
+	for newLeader == nil {
+		for _, node := range cluster.nodes {
+			if node != oldLeader && Role(node.Status().Role) == RoleLeader {
+				newLeader = node
+			}
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("No new leader while the old one was down")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if Role(oldLeader.Status().Role) != RoleDown {
+		t.Errorf("Expected the failed node to report down, got %s", oldLeader.Status().Role)
+	}
+	propose(t, newLeader, "during")
+
+	// Once back, the old leader follows and catches up
+	if leader := cluster.waitForLeader(t); leader == oldLeader {
+		t.Errorf("Expected the recovered node to follow, it leads")
+	}
+	cluster.waitForApplied(t, []string{`"before"`, `"during"`})
+}
+
+func TestPartitionedLeaderCannotCommit(t *testing.T) {
+	cluster := newTestCluster(t, 3)
+	oldLeader := cluster.waitForLeader(t)
+	propose(t, oldLeader, "before")
+
+	// Cut off from both followers, the old leader still accepts a proposal
+	// but can never commit it
+	cluster.links.isolate(oldLeader.ID(), true)
+	index, term, err := oldLeader.Propose([]byte(`"lost"`))
+	if err != nil {
+		t.Fatalf("Propose on the isolated leader failed: %v", err)
+	}
+
+	// Meanwhile the majority elects a leader of its own and commits
+	var newLeader *Node
+	deadline := time.Now().Add(3 * time.Second)
+	for newLeader == nil {
+		for _, node := range cluster.nodes {
+			if node != oldLeader && Role(node.Status().Role) == RoleLeader {
+				newLeader = node
+			}
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("No new leader on the majority side")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	propose(t, newLeader, "during")
+	if status := oldLeader.Status(); status.CommitIndex >= index {
+		t.Errorf("Expected the isolated leader not to commit index %d, got %+v", index, status)
+	}
+
+	// Once healed the old leader steps down and its entry is replaced
+	cluster.links.isolate(oldLeader.ID(), false)
+	if _, err := oldLeader.Wait(index, term, 3*time.Second); !errors.Is(err, ErrEntryLost) {
+		t.Errorf("Expected the isolated leader's entry to be lost, got %v", err)
+	}
+	cluster.waitForApplied(t, []string{`"before"`, `"during"`})
+}
@@ -0,0 +1,130 @@
+package raft
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// RequestVoteArgs is a candidate's request for a vote
+type RequestVoteArgs struct {
+	Term         uint64 `json:"term"`
+	CandidateID  string `json:"candidateId"`
+	LastLogIndex uint64 `json:"lastLogIndex"`
+	LastLogTerm  uint64 `json:"lastLogTerm"`
+}
+
+// RequestVoteReply answers a RequestVoteArgs
+type RequestVoteReply struct {
+	Term        uint64 `json:"term"`
+	VoteGranted bool   `json:"voteGranted"`
+}
+
+// AppendEntriesArgs carries new entries from the leader, or none as a heartbeat
+type AppendEntriesArgs struct {
+	Term         uint64  `json:"term"`
+	LeaderID     string  `json:"leaderId"`
+	PrevLogIndex uint64  `json:"prevLogIndex"`
+	PrevLogTerm  uint64  `json:"prevLogTerm"`
+	Entries      []Entry `json:"entries"`
+	LeaderCommit uint64  `json:"leaderCommit"`
+}
+
+// AppendEntriesReply answers an AppendEntriesArgs. On failure ConflictIndex is
+// where the leader should resume sending from.
+type AppendEntriesReply struct {
+	Term          uint64 `json:"term"`
+	Success       bool   `json:"success"`
+	ConflictIndex uint64 `json:"conflictIndex,omitempty"`
+}
+
+// Transport delivers RPCs to the peer at an address. An error means the peer
+// could not be reached or is down.
+type Transport interface {
+	RequestVote(address string, args RequestVoteArgs) (RequestVoteReply, error)
+	AppendEntries(address string, args AppendEntriesArgs) (AppendEntriesReply, error)
+}
+
+// HTTPTransport sends RPCs as JSON over HTTP to the routes registered by
+// Register. Addresses are host:port.
+type HTTPTransport struct {
+	client *http.Client
+}
+
+// NewHTTPTransport creates a transport whose requests give up after timeout
+func NewHTTPTransport(timeout time.Duration) *HTTPTransport {
+	return &HTTPTransport{client: &http.Client{Timeout: timeout}}
+}
+
+// RequestVote implements Transport
+func (t *HTTPTransport) RequestVote(address string, args RequestVoteArgs) (RequestVoteReply, error) {
+	var reply RequestVoteReply
+	err := t.post(address, "/raft/vote", args, &reply)
+	return reply, err
+}
+
+// AppendEntries implements Transport
+func (t *HTTPTransport) AppendEntries(address string, args AppendEntriesArgs) (AppendEntriesReply, error) {
+	var reply AppendEntriesReply
+	err := t.post(address, "/raft/append", args, &reply)
+	return reply, err
+}
+
+func (t *HTTPTransport) post(address, path string, args, reply interface{}) error {
+	body, err := json.Marshal(args)
+	if err != nil {
+		return err
+	}
+
+	resp, err := t.client.Post("http://"+address+path, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s%s: %s", address, path, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(reply)
+}
+
+// Register adds the RPC routes of node to mux
+func Register(mux *http.ServeMux, node *Node) {
+	mux.HandleFunc("POST /raft/vote", func(w http.ResponseWriter, r *http.Request) {
+		var args RequestVoteArgs
+		if !decodeRPC(w, r, &args) {
+			return
+		}
+		reply, err := node.HandleRequestVote(args)
+		writeRPC(w, reply, err)
+	})
+
+	mux.HandleFunc("POST /raft/append", func(w http.ResponseWriter, r *http.Request) {
+		var args AppendEntriesArgs
+		if !decodeRPC(w, r, &args) {
+			return
+		}
+		reply, err := node.HandleAppendEntries(args)
+		writeRPC(w, reply, err)
+	})
+}
+
+func decodeRPC(w http.ResponseWriter, r *http.Request, args interface{}) bool {
+	if err := json.NewDecoder(r.Body).Decode(args); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return false
+	}
+	return true
+}
+
+func writeRPC(w http.ResponseWriter, reply interface{}, err error) {
+	if errors.Is(err, ErrNodeDown) || errors.Is(err, ErrLinkCut) {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(reply)
+}
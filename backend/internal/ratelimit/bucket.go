@@ -0,0 +1,138 @@
+// Package ratelimit implements token buckets for flood protection
+package ratelimit
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Limit is a sustained rate in events per second plus a burst allowance.
+// A zero Rate means unlimited.
+type Limit struct {
+	Rate  float64
+	Burst int
+}
+
+// Unlimited never rejects
+var Unlimited = Limit{}
+
+func (l Limit) String() string {
+	if l.Rate <= 0 {
+		return "unlimited"
+	}
+	return fmt.Sprintf("%g/s burst %d", l.Rate, l.Burst)
+}
+
+// ParseLimit parses "rate" or "rate/burst", e.g. "20" or "20/40". The burst
+// defaults to the rate rounded up. "0" or "" means unlimited.
+func ParseLimit(spec string) (Limit, error) {
+	spec = strings.TrimSpace(spec)
+	if spec == "" {
+		return Unlimited, nil
+	}
+
+	rateText, burstText, hasBurst := strings.Cut(spec, "/")
+	rate, err := strconv.ParseFloat(rateText, 64)
+	if err != nil || rate < 0 {
+		return Limit{}, fmt.Errorf("invalid rate %q", rateText)
+	}
+	if rate == 0 {
+		return Unlimited, nil
+	}
+
+	burst := int(math.Ceil(rate))
+	if hasBurst {
+		burst, err = strconv.Atoi(burstText)
+		if err != nil || burst < 1 {
+			return Limit{}, fmt.Errorf("invalid burst %q", burstText)
+		}
+	}
+
+	return Limit{Rate: rate, Burst: burst}, nil
+}
+
+// Bucket is a token bucket holding up to Burst tokens, refilled at Rate per second
+type Bucket struct {
+	mu     sync.Mutex
+	limit  Limit
+	tokens float64
+	last   time.Time
+	now    func() time.Time
+}
+
+// NewBucket creates a full bucket
+func NewBucket(limit Limit) *Bucket {
+	return newBucket(limit, time.Now)
+}
+
+func newBucket(limit Limit, now func() time.Time) *Bucket {
+	return &Bucket{
+		limit:  limit,
+		tokens: float64(limit.Burst),
+		last:   now(),
+		now:    now,
+	}
+}
+
+// Allow takes a token if one is available. Otherwise it reports how long
+// until the next token arrives.
+func (b *Bucket) Allow() (bool, time.Duration) {
+	if b.limit.Rate <= 0 {
+		return true, 0
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := b.now()
+	b.tokens = math.Min(float64(b.limit.Burst), b.tokens+now.Sub(b.last).Seconds()*b.limit.Rate)
+	b.last = now
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+
+	wait := (1 - b.tokens) / b.limit.Rate
+	return false, time.Duration(math.Ceil(wait * float64(time.Second)))
+}
+
+// Keyed holds one bucket per key, created on first use
+type Keyed struct {
+	mu      sync.Mutex
+	limit   Limit
+	buckets map[string]*Bucket
+}
+
+// NewKeyed creates an empty set of buckets sharing one limit
+func NewKeyed(limit Limit) *Keyed {
+	return &Keyed{limit: limit, buckets: make(map[string]*Bucket)}
+}
+
+// Allow takes a token from key's bucket
+func (k *Keyed) Allow(key string) (bool, time.Duration) {
+	if k.limit.Rate <= 0 {
+		return true, 0
+	}
+
+	k.mu.Lock()
+	bucket, ok := k.buckets[key]
+	if !ok {
+		bucket = NewBucket(k.limit)
+		k.buckets[key] = bucket
+	}
+	k.mu.Unlock()
+
+	return bucket.Allow()
+}
+
+// Forget drops key's bucket
+func (k *Keyed) Forget(key string) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	delete(k.buckets, key)
+}
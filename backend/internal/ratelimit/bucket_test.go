@@ -0,0 +1,89 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBucketBurstAndRefill(t *testing.T) {
+	now := time.Unix(0, 0)
+	bucket := newBucket(Limit{Rate: 10, Burst: 3}, func() time.Time { return now })
+
+	for i := 0; i < 3; i++ {
+		if ok, _ := bucket.Allow(); !ok {
+			t.Fatalf("Request %d within burst should pass", i)
+		}
+	}
+
+	ok, retryAfter := bucket.Allow()
+	if ok {
+		t.Fatal("Request beyond burst should be limited")
+	}
+	if retryAfter != 100*time.Millisecond {
+		t.Errorf("Expected retry after 100ms, got %s", retryAfter)
+	}
+
+	now = now.Add(100 * time.Millisecond)
+	if ok, _ := bucket.Allow(); !ok {
+		t.Error("Bucket should refill one token after 100ms")
+	}
+
+	// Refill never exceeds the burst
+	now = now.Add(time.Hour)
+	for i := 0; i < 3; i++ {
+		bucket.Allow()
+	}
+	if ok, _ := bucket.Allow(); ok {
+		t.Error("Tokens should be capped at the burst size")
+	}
+}
+
+func TestUnlimited(t *testing.T) {
+	bucket := NewBucket(Unlimited)
+	for i := 0; i < 1000; i++ {
+		if ok, _ := bucket.Allow(); !ok {
+			t.Fatal("Unlimited bucket should never limit")
+		}
+	}
+}
+
+func TestKeyedBucketsAreIndependent(t *testing.T) {
+	keyed := NewKeyed(Limit{Rate: 1, Burst: 1})
+
+	if ok, _ := keyed.Allow("a"); !ok {
+		t.Fatal("First request for a should pass")
+	}
+	if ok, _ := keyed.Allow("a"); ok {
+		t.Error("Second request for a should be limited")
+	}
+	if ok, _ := keyed.Allow("b"); !ok {
+		t.Error("b has its own bucket")
+	}
+
+	keyed.Forget("a")
+	if ok, _ := keyed.Allow("a"); !ok {
+		t.Error("Forgotten key should start with a full bucket")
+	}
+}
+
+func TestParseLimit(t *testing.T) {
+	cases := map[string]Limit{
+		"":      Unlimited,
+		"0":     Unlimited,
+		"20":    {Rate: 20, Burst: 20},
+		"2.5":   {Rate: 2.5, Burst: 3},
+		"10/40": {Rate: 10, Burst: 40},
+	}
+	for spec, want := range cases {
+		got, err := ParseLimit(spec)
+		if err != nil || got != want {
+			t.Errorf("ParseLimit(%q) = %v, %v; want %v", spec, got, err, want)
+		}
+	}
+
+	for _, spec := range []string{"fast", "-1", "10/0", "10/x"} {
+		if _, err := ParseLimit(spec); err == nil {
+			t.Errorf("ParseLimit(%q) should fail", spec)
+		}
+	}
+}
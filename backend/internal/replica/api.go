@@ -0,0 +1,28 @@
+package replica
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// Register adds the replica routes to mux:
+//
+//	GET /replicas?replica=  every replica compared with the named one
+//	                        (default the first)
+func (s *Set) Register(mux *http.ServeMux) {
+	mux.HandleFunc("GET /replicas", s.status)
+}
+
+func (s *Set) status(w http.ResponseWriter, r *http.Request) {
+	viewer := r.URL.Query().Get("replica")
+	if viewer == "" {
+		viewer = s.replicas[0].id
+	}
+	if _, ok := s.byID[viewer]; !ok {
+		http.Error(w, "unknown replica "+viewer, http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s.Status(viewer))
+}
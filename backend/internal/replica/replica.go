@@ -0,0 +1,476 @@
+// Package replica runs several in-process copies of a room that accept moves
+// independently and reconcile in the background. It is the available,
+// eventually consistent counterpart of the cluster package: a move commits
+// on the replica it was made on without waiting for any other, and replicas
+// disagree until the move has spread.
+package replica
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/AnishMulay/Transaction-Conflict-Visualization/internal/causality"
+	"github.com/AnishMulay/Transaction-Conflict-Visualization/internal/concurrency"
+	"github.com/AnishMulay/Transaction-Conflict-Visualization/pkg/models"
+)
+
+// defaultGossipInterval is how often replicas resend their state to each
+// other, which repairs anything earlier pushes missed
+const defaultGossipInterval = time.Second
+
+// MergeRule decides which write a replica keeps when writes meet
+type MergeRule string
+
+const (
+	// MergeLastWriterWins keeps the write with the latest timestamp,
+	// silently discarding concurrent writes that lose
+	MergeLastWriterWins MergeRule = "lww"
+	// MergeVectorClock keeps every write that no other write causally
+	// supersedes. Concurrent writes are kept side by side as siblings and
+	// surfaced as a conflict until a later move supersedes them all.
+	MergeVectorClock MergeRule = "vector"
+)
+
+// ParseMergeRule parses a merge rule name: lww or vector
+func ParseMergeRule(name string) (MergeRule, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "", "lww", "last-writer-wins":
+		return MergeLastWriterWins, nil
+	case "vector", "vector-clock":
+		return MergeVectorClock, nil
+	}
+	return "", fmt.Errorf("unknown merge rule %q, use lww or vector", name)
+}
+
+// Config configures a replica set. Delay is how long a replica's state takes
+// to reach another one.
+type Config struct {
+	Rule           MergeRule
+	Delay          time.Duration
+	GossipInterval time.Duration
+}
+
+// Links simulates the network between replicas: writes are not delivered
+// over a link that is not connected, and arrive late over a lagging one
+type Links interface {
+	Connected(a, b string) bool
+	Lag(a, b string) time.Duration
+}
+
+// Set is a group of replicas of one room
+type Set struct {
+	config   Config
+	links    Links
+	replicas []*Replica
+	byID     map[string]*Replica
+	onChange func()
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+// Write is a write of the object's position. Clock is the writing replica's
+// vector clock including the write itself, so (Origin, Clock[Origin])
+// identifies it. Every rule uses clocks to tell what a replica has seen;
+// only the vector clock rule also merges by them.
+type Write struct {
+	Origin    string
+	PlayerID  string
+	Position  models.Position
+	Timestamp time.Time
+	Clock     causality.VectorClock
+}
+
+// Replica is one copy of the room, with its own game state and controller.
+// It is the controller's concurrency.Replicator: moves commit locally and are
+// then pushed to the other replicas.
+type Replica struct {
+	id         string
+	set        *Set
+	gameState  *models.GameState
+	controller *concurrency.ConcurrencyController
+	onApplied  func()
+
+	mu          sync.Mutex
+	siblings    []Write
+	seen        causality.VectorClock
+	conflicts   int
+	overwritten int
+}
+
+// NewSet creates an empty replica set. Add replicas, then call Start.
+func NewSet(config Config) *Set {
+	if config.GossipInterval <= 0 {
+		config.GossipInterval = defaultGossipInterval
+	}
+	return &Set{
+		config: config,
+		byID:   make(map[string]*Replica),
+		stop:   make(chan struct{}),
+	}
+}
+
+// Add makes gameState and controller a replica named id and routes the
+// controller's commits through it
+func (s *Set) Add(id string, gameState *models.GameState, controller *concurrency.ConcurrencyController) *Replica {
+	snapshot := gameState.GetState()
+	r := &Replica{
+		id:         id,
+		set:        s,
+		gameState:  gameState,
+		controller: controller,
+		// The initial position is a write every real write supersedes
+		siblings: []Write{{Position: snapshot.Object.Position, Clock: causality.VectorClock{}}},
+		seen:     causality.VectorClock{},
+	}
+	controller.SetReplicator(r)
+
+	s.replicas = append(s.replicas, r)
+	s.byID[id] = r
+	return r
+}
+
+// Replica returns the replica named id
+func (s *Set) Replica(id string) (*Replica, bool) {
+	r, ok := s.byID[id]
+	return r, ok
+}
+
+// IDs returns the replica names in the order they were added
+func (s *Set) IDs() []string {
+	ids := make([]string, len(s.replicas))
+	for i, r := range s.replicas {
+		ids[i] = r.id
+	}
+	return ids
+}
+
+// Rule returns the set's merge rule
+func (s *Set) Rule() MergeRule {
+	return s.config.Rule
+}
+
+// OnChange registers fn to run whenever a replica's value or clock changes.
+// Call it before Start.
+func (s *Set) OnChange(fn func()) {
+	s.onChange = fn
+}
+
+// SetLinks makes replication honour simulated network links between
+// replicas. Call it before Start.
+func (s *Set) SetLinks(links Links) {
+	s.links = links
+}
+
+// Start begins background reconciliation
+func (s *Set) Start() {
+	go s.gossip()
+}
+
+// Stop ends background reconciliation
+func (s *Set) Stop() {
+	s.stopOnce.Do(func() { close(s.stop) })
+}
+
+func (s *Set) gossip() {
+	ticker := time.NewTicker(s.config.GossipInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+			for _, r := range s.replicas {
+				s.push(r)
+			}
+		}
+	}
+}
+
+// push sends from's current writes to every other replica
+func (s *Set) push(from *Replica) {
+	writes := from.writes()
+	for _, to := range s.replicas {
+		if to != from {
+			go s.deliver(from, to, writes)
+		}
+	}
+}
+
+// deliver hands writes to a replica once the replication delay and any lag
+// of the link have passed. Writes are lost if the link is cut when they are
+// sent or when they would arrive; gossip resends them after it heals.
+func (s *Set) deliver(from, to *Replica, writes []Write) {
+	if !s.connected(from, to) {
+		return
+	}
+
+	delay := s.config.Delay
+	if s.links != nil {
+		delay += s.links.Lag(from.id, to.id)
+	}
+	if delay > 0 {
+		select {
+		case <-time.After(delay):
+		case <-s.stop:
+			return
+		}
+	}
+
+	if s.connected(from, to) {
+		to.merge(writes)
+	}
+}
+
+// connected reports whether the simulated link between two replicas is up
+func (s *Set) connected(a, b *Replica) bool {
+	return s.links == nil || s.links.Connected(a.id, b.id)
+}
+
+func (s *Set) changed() {
+	if s.onChange != nil {
+		s.onChange()
+	}
+}
+
+// ID returns the replica's name
+func (r *Replica) ID() string {
+	return r.id
+}
+
+// OnApplied registers fn to run after writes from other replicas change this
+// replica's object. Call it before Start.
+func (r *Replica) OnApplied(fn func()) {
+	r.onApplied = fn
+}
+
+// Replicate implements concurrency.Replicator. The commit takes effect here
+// at once and reaches the other replicas later.
+func (r *Replica) Replicate(commit concurrency.ReplicatedCommit) (concurrency.CommitRecord, error) {
+	r.mu.Lock()
+	record, err := r.controller.ApplyCommit(commit)
+	if err != nil {
+		r.mu.Unlock()
+		return record, err
+	}
+
+	// The new write supersedes everything this replica has seen
+	r.seen[r.id]++
+	r.siblings = []Write{{
+		Origin:    r.id,
+		PlayerID:  commit.PlayerID,
+		Position:  commit.Position,
+		Timestamp: commit.CommittedAt,
+		Clock:     r.seen.Copy(),
+	}}
+	r.mu.Unlock()
+
+	r.set.changed()
+	r.set.push(r)
+	return record, nil
+}
+
+// writes returns a copy of the replica's current writes
+func (r *Replica) writes() []Write {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Write(nil), r.siblings...)
+}
+
+// merge reconciles writes from another replica with this one's
+func (r *Replica) merge(writes []Write) {
+	r.mu.Lock()
+	before := r.value()
+	changed := false
+
+	for _, write := range writes {
+		// A write this replica has not seen yet
+		if r.seen[write.Origin] >= write.Clock[write.Origin] {
+			if r.set.config.Rule == MergeLastWriterWins {
+				r.mergeLastWriterWins(write, false)
+			}
+			continue
+		}
+		changed = true
+
+		switch r.set.config.Rule {
+		case MergeVectorClock:
+			r.mergeVectorClock(write)
+		default:
+			r.mergeLastWriterWins(write, true)
+		}
+		r.seen.Merge(write.Clock)
+	}
+
+	after := r.value()
+	if after.Origin != before.Origin || after.Clock[after.Origin] != before.Clock[before.Origin] {
+		r.install(after)
+		changed = true
+	}
+	r.mu.Unlock()
+
+	if changed {
+		r.set.changed()
+	}
+}
+
+// mergeLastWriterWins keeps whichever of the current write and write is
+// later. A new write that is concurrent with the write it replaces or loses
+// to is a silently lost update. Must hold r.mu.
+func (r *Replica) mergeLastWriterWins(write Write, unseen bool) {
+	current := r.siblings[0]
+	if later(write, current) {
+		r.siblings = []Write{write}
+	}
+	if unseen && write.Clock.Compare(current.Clock) == causality.Concurrent {
+		r.overwritten++
+	}
+}
+
+// mergeVectorClock adds write to the siblings unless one of them supersedes
+// it, dropping the siblings it supersedes. Must hold r.mu.
+func (r *Replica) mergeVectorClock(write Write) {
+	var kept []Write
+	for _, sibling := range r.siblings {
+		switch write.Clock.Compare(sibling.Clock) {
+		case causality.Before, causality.Equal:
+			return
+		case causality.Concurrent:
+			kept = append(kept, sibling)
+		}
+	}
+
+	if len(kept) > 0 {
+		r.conflicts++
+	}
+	r.siblings = append(kept, write)
+}
+
+// value is the write the replica shows: the latest of its siblings. Every
+// replica holding the same siblings shows the same one. Must hold r.mu.
+func (r *Replica) value() Write {
+	value := r.siblings[0]
+	for _, sibling := range r.siblings[1:] {
+		if later(sibling, value) {
+			value = sibling
+		}
+	}
+	return value
+}
+
+// install makes write the object's value. Moves in flight on this replica
+// that read the old value conflict. Must hold r.mu.
+func (r *Replica) install(write Write) {
+	object := *r.gameState.GetState().Object
+	object.Position = write.Position
+	object.Version++
+	object.LastUpdated = write.Timestamp
+	r.controller.InstallObject(object)
+
+	if r.onApplied != nil {
+		r.onApplied()
+	}
+}
+
+// later orders writes by timestamp, breaking ties by origin
+func later(a, b Write) bool {
+	if !a.Timestamp.Equal(b.Timestamp) {
+		return a.Timestamp.After(b.Timestamp)
+	}
+	return a.Origin > b.Origin
+}
+
+// Status describes every replica compared with the one named viewer
+func (s *Set) Status(viewer string) models.ReplicaStatus {
+	type state struct {
+		siblings    []Write
+		seen        causality.VectorClock
+		conflicts   int
+		overwritten int
+	}
+
+	states := make([]state, len(s.replicas))
+	var viewed state
+	for i, r := range s.replicas {
+		r.mu.Lock()
+		states[i] = state{
+			siblings:    append([]Write(nil), r.siblings...),
+			seen:        r.seen.Copy(),
+			conflicts:   r.conflicts,
+			overwritten: r.overwritten,
+		}
+		r.mu.Unlock()
+		if r.id == viewer {
+			viewed = states[i]
+		}
+	}
+
+	status := models.ReplicaStatus{
+		ReplicaID: viewer,
+		MergeRule: string(s.config.Rule),
+		Timestamp: time.Now(),
+	}
+	for i, r := range s.replicas {
+		view := models.ReplicaView{
+			ID:          r.id,
+			Object:      *r.gameState.GetState().Object,
+			Clock:       states[i].seen,
+			Ahead:       viewed.seen.Missing(states[i].seen),
+			Behind:      states[i].seen.Missing(viewed.seen),
+			Converged:   sameWrites(states[i].siblings, viewed.siblings),
+			Conflicts:   states[i].conflicts,
+			Overwritten: states[i].overwritten,
+		}
+		for _, write := range states[i].siblings {
+			view.Siblings = append(view.Siblings, models.ReplicaWrite{
+				Origin:    write.Origin,
+				PlayerID:  write.PlayerID,
+				Position:  write.Position,
+				Timestamp: write.Timestamp,
+				Clock:     write.Clock,
+			})
+		}
+		status.Replicas = append(status.Replicas, view)
+	}
+	return status
+}
+
+// Progress reports how far each replica has got: the writes it has applied
+// from every replica, and its object's version
+func (s *Set) Progress() []models.ReplicaProgress {
+	progress := make([]models.ReplicaProgress, len(s.replicas))
+	for i, r := range s.replicas {
+		r.mu.Lock()
+		var applied uint64
+		for _, count := range r.seen {
+			applied += count
+		}
+		r.mu.Unlock()
+
+		object := r.controller.Object()
+		progress[i] = models.ReplicaProgress{
+			ID:        r.id,
+			Applied:   applied,
+			Version:   object.Version,
+			Position:  object.Position,
+			Reachable: true,
+		}
+	}
+	return progress
+}
+
+// sameWrites reports whether a and b hold the same writes
+func sameWrites(a, b []Write) bool {
+	key := func(writes []Write) string {
+		ids := make([]string, len(writes))
+		for i, write := range writes {
+			ids[i] = fmt.Sprintf("%s:%d", write.Origin, write.Clock[write.Origin])
+		}
+		sort.Strings(ids)
+		return strings.Join(ids, ",")
+	}
+	return key(a) == key(b)
+}
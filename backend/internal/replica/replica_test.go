@@ -0,0 +1,217 @@
+package replica
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/AnishMulay/Transaction-Conflict-Visualization/internal/concurrency"
+	"github.com/AnishMulay/Transaction-Conflict-Visualization/internal/netsim"
+	"github.com/AnishMulay/Transaction-Conflict-Visualization/pkg/models"
+)
+
+// newTestSet starts size replicas named r1, r2, ... that reconcile every
+// 20ms after a delay, over links if it is not nil
+func newTestSet(t *testing.T, rule MergeRule, size int, delay time.Duration, links Links) (*Set, []*concurrency.ConcurrencyController) {
+	t.Helper()
+	set := NewSet(Config{Rule: rule, Delay: delay, GossipInterval: 20 * time.Millisecond})
+	if links != nil {
+		set.SetLinks(links)
+	}
+
+	var controllers []*concurrency.ConcurrencyController
+	for i := 1; i <= size; i++ {
+		gameState := models.NewGameState(models.Position{X: 10, Y: 10})
+		gameState.Object.ID = "replicated-object"
+		controller := concurrency.NewConcurrencyController(gameState)
+		set.Add(fmt.Sprintf("r%d", i), gameState, controller)
+		controllers = append(controllers, controller)
+	}
+
+	set.Start()
+	t.Cleanup(set.Stop)
+	return set, controllers
+}
+
+// move commits a move on one replica
+func move(t *testing.T, controller *concurrency.ConcurrencyController, direction string) {
+	t.Helper()
+	transaction, _ := controller.BeginTransaction("player", direction)
+	if err := controller.ProposeMove(transaction.ID, direction); err != nil {
+		t.Fatalf("Propose failed: %v", err)
+	}
+	if _, err := controller.CommitTransaction(transaction.ID); err != nil {
+		t.Fatalf("Commit %s failed: %v", direction, err)
+	}
+}
+
+// concurrentMoves commits one move per replica at the same time, before any
+// of them can spread
+func concurrentMoves(t *testing.T, controllers []*concurrency.ConcurrencyController, directions ...string) {
+	t.Helper()
+	var wg sync.WaitGroup
+	for i, direction := range directions {
+		wg.Add(1)
+		go func(controller *concurrency.ConcurrencyController, direction string) {
+			defer wg.Done()
+			move(t, controller, direction)
+		}(controllers[i], direction)
+	}
+	wg.Wait()
+}
+
+// waitForConvergence polls until every replica holds the same writes and
+// returns the status seen from r1
+func waitForConvergence(t *testing.T, set *Set) models.ReplicaStatus {
+	t.Helper()
+	deadline := time.Now().Add(3 * time.Second)
+	for {
+		status := set.Status("r1")
+		converged := true
+		for _, view := range status.Replicas {
+			if !view.Converged || view.Ahead != 0 || view.Behind != 0 || view.Object.Position != status.Replicas[0].Object.Position {
+				converged = false
+			}
+		}
+		if converged {
+			return status
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Replicas never converged: %+v", status.Replicas)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestMovesSpreadAndReportDivergence(t *testing.T) {
+	set, controllers := newTestSet(t, MergeVectorClock, 3, 200*time.Millisecond, nil)
+
+	// The move commits on r2 at once; r1 does not know about it yet
+	move(t, controllers[1], "right")
+	status := set.Status("r1")
+	if r2 := status.Replicas[1]; r2.Ahead != 1 || r2.Converged || r2.Object.Position != (models.Position{X: 6, Y: 5}) {
+		t.Errorf("Expected r2 one write ahead of r1, got %+v", r2)
+	}
+	if r1 := status.Replicas[0]; r1.Object.Position != (models.Position{X: 5, Y: 5}) {
+		t.Errorf("Expected r1 still at the start, got %+v", r1.Object)
+	}
+
+	status = waitForConvergence(t, set)
+	for _, view := range status.Replicas {
+		if view.Object.Position != (models.Position{X: 6, Y: 5}) || len(view.Siblings) != 1 || view.Conflicts != 0 {
+			t.Errorf("Expected %s at (6, 5) without conflicts, got %+v", view.ID, view)
+		}
+	}
+
+	// A move made after seeing the first one supersedes it
+	move(t, controllers[0], "down")
+	status = waitForConvergence(t, set)
+	for _, view := range status.Replicas {
+		if view.Object.Position != (models.Position{X: 6, Y: 6}) || view.Conflicts != 0 {
+			t.Errorf("Expected %s at (6, 6) without conflicts, got %+v", view.ID, view)
+		}
+	}
+}
+
+func TestVectorClocksSurfaceConcurrentMoves(t *testing.T) {
+	set, controllers := newTestSet(t, MergeVectorClock, 3, 50*time.Millisecond, nil)
+
+	concurrentMoves(t, controllers, "up", "left")
+	status := waitForConvergence(t, set)
+	for _, view := range status.Replicas {
+		if len(view.Siblings) != 2 {
+			t.Errorf("Expected %s to keep both concurrent moves, got %+v", view.ID, view.Siblings)
+		}
+	}
+	if conflicts := status.Replicas[0].Conflicts + status.Replicas[1].Conflicts; conflicts == 0 {
+		t.Error("Expected the replicas that made the moves to report a conflict")
+	}
+
+	// A later move on any replica resolves the conflict everywhere
+	move(t, controllers[2], "down")
+	status = waitForConvergence(t, set)
+	for _, view := range status.Replicas {
+		if len(view.Siblings) != 1 || view.Siblings[0].Origin != "r3" {
+			t.Errorf("Expected %s resolved to r3's move, got %+v", view.ID, view.Siblings)
+		}
+	}
+}
+
+func TestLastWriterWinsDiscardsConcurrentMoves(t *testing.T) {
+	set, controllers := newTestSet(t, MergeLastWriterWins, 3, 50*time.Millisecond, nil)
+
+	concurrentMoves(t, controllers, "up", "left")
+	status := waitForConvergence(t, set)
+
+	overwritten := 0
+	for _, view := range status.Replicas {
+		if len(view.Siblings) != 1 {
+			t.Errorf("Expected %s to keep one write, got %+v", view.ID, view.Siblings)
+		}
+		overwritten += view.Overwritten
+	}
+	if overwritten == 0 {
+		t.Error("Expected the losing move to be counted as overwritten")
+	}
+
+	// The later of the two moves wins on every replica
+	winner := status.Replicas[0].Siblings[0]
+	want := map[string]models.Position{"r1": {X: 5, Y: 4}, "r2": {X: 4, Y: 5}}[winner.Origin]
+	if status.Replicas[0].Object.Position != want {
+		t.Errorf("Expected the object at %s's move %+v, got %+v", winner.Origin, want, status.Replicas[0].Object.Position)
+	}
+}
+
+func TestPartitionedReplicasDivergeUntilHealed(t *testing.T) {
+	network := netsim.New()
+	network.AddLink("r1", "r2")
+	network.AddLink("r1", "r3")
+	network.AddLink("r2", "r3")
+	network.Partition([][]string{{"r1"}, {"r2", "r3"}})
+	set, controllers := newTestSet(t, MergeVectorClock, 3, 10*time.Millisecond, network)
+
+	// Both sides accept a move, but only r2's crosses to r3
+	move(t, controllers[0], "up")
+	move(t, controllers[1], "left")
+	time.Sleep(150 * time.Millisecond)
+
+	applied := map[string]uint64{}
+	for _, progress := range set.Progress() {
+		applied[progress.ID] = progress.Applied
+	}
+	if applied["r1"] != 1 || applied["r2"] != 1 || applied["r3"] != 1 {
+		t.Errorf("Expected each side to apply only its own move, got %v", applied)
+	}
+	if status := set.Status("r1"); status.Replicas[2].Object.Position != (models.Position{X: 4, Y: 5}) || status.Replicas[2].Behind != 1 {
+		t.Errorf("Expected r3 to hold r2's move and miss r1's, got %+v", status.Replicas[2])
+	}
+
+	// Healed, gossip carries both moves everywhere and they meet as siblings
+	network.HealAll()
+	status := waitForConvergence(t, set)
+	for _, view := range status.Replicas {
+		if len(view.Siblings) != 2 {
+			t.Errorf("Expected %s to keep both partitioned moves, got %+v", view.ID, view.Siblings)
+		}
+	}
+	for _, progress := range set.Progress() {
+		if progress.Applied != 2 {
+			t.Errorf("Expected %s to have applied both moves, got %+v", progress.ID, progress)
+		}
+	}
+}
+
+func TestLaggingLinkDelaysReplication(t *testing.T) {
+	network := netsim.New()
+	network.AddLink("r1", "r2")
+	network.SetLag("r1", "r2", 300*time.Millisecond)
+	set, controllers := newTestSet(t, MergeLastWriterWins, 2, 0, network)
+
+	move(t, controllers[0], "down")
+	time.Sleep(100 * time.Millisecond)
+	if progress := set.Progress(); progress[1].Applied != 0 {
+		t.Errorf("Expected r2 not to have the move yet, got %+v", progress[1])
+	}
+	waitForConvergence(t, set)
+}
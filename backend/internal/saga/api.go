@@ -0,0 +1,82 @@
+package saga
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxStepDelay bounds ?stepDelay=, since a saga runs while the request waits
+const maxStepDelay = 5 * time.Second
+
+// Register adds the saga routes to mux:
+//
+//	GET  /sagas                  the most recent sagas
+//	POST /sagas                  run ?steps=lobby:up,lobby:right,arena:left
+//	                             in order, pausing ?stepDelay= before each
+//	                             commit and failing step ?fail=
+func (o *Orchestrator) Register(mux *http.ServeMux) {
+	mux.HandleFunc("GET /sagas", o.list)
+	mux.HandleFunc("POST /sagas", o.run)
+}
+
+func (o *Orchestrator) list(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, o.Sagas())
+}
+
+func (o *Orchestrator) run(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	var steps []Step
+	for _, spec := range strings.Split(query.Get("steps"), ",") {
+		room, direction, ok := strings.Cut(strings.TrimSpace(spec), ":")
+		if !ok || room == "" || direction == "" {
+			http.Error(w, "steps must list room:direction pairs, e.g. lobby:up,arena:left", http.StatusBadRequest)
+			return
+		}
+		steps = append(steps, Step{Room: room, Direction: direction})
+	}
+
+	var options Options
+	if value := query.Get("stepDelay"); value != "" {
+		delay, err := time.ParseDuration(value)
+		if err != nil || delay < 0 || delay > maxStepDelay {
+			http.Error(w, "stepDelay must be a duration between 0 and 5s", http.StatusBadRequest)
+			return
+		}
+		options.StepDelay = delay
+	}
+	if value := query.Get("fail"); value != "" {
+		step, err := strconv.Atoi(value)
+		if err != nil || step < 1 || step > len(steps) {
+			http.Error(w, "fail must be the number of a step, counted from 1", http.StatusBadRequest)
+			return
+		}
+		options.FailStep = step
+	}
+
+	saga, err := o.Run(steps, options)
+	switch {
+	case errors.Is(err, ErrUnknownRoom):
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	status := http.StatusOK
+	if saga.State != StateCompleted {
+		status = http.StatusConflict
+	}
+	writeJSON(w, status, saga)
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
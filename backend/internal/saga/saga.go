@@ -0,0 +1,317 @@
+// Package saga moves the objects of one or more rooms with a saga: a
+// sequence of moves that each commit on their own, as ordinary transactions
+// of their room. Nothing holds the objects between steps, so players see and
+// can interfere with the half-finished saga. When a step fails, the
+// orchestrator undoes the steps that already committed by running their
+// compensating moves, latest first. Unlike two-phase commit nothing ever
+// blocks, but the saga is not isolated and its undo is only semantic: a
+// compensation moves the object back by one square from wherever it is now.
+package saga
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/AnishMulay/Transaction-Conflict-Visualization/internal/concurrency"
+	"github.com/AnishMulay/Transaction-Conflict-Visualization/pkg/models"
+)
+
+const (
+	// sagaPlayer is the player ID saga moves are made as
+	sagaPlayer = "saga"
+	// keptSagas is how many recent sagas Sagas reports
+	keptSagas = 20
+	// compensationAttempts is how often a compensation is retried before the
+	// saga gives up on it
+	compensationAttempts = 5
+	// compensationBackoff is the wait before the first retry, doubled after
+	// every attempt
+	compensationBackoff = 20 * time.Millisecond
+)
+
+// Saga states
+const (
+	StateRunning      = "running"
+	StateCompensating = "compensating"
+	StateCompleted    = "completed"
+	StateCompensated  = "compensated"
+	StateFailed       = "failed"
+)
+
+// Step states
+const (
+	StepPending            = "pending"
+	StepCommitted          = "committed"
+	StepFailed             = "failed"
+	StepCompensated        = "compensated"
+	StepCompensationFailed = "compensation-failed"
+)
+
+var (
+	// ErrUnknownRoom is returned for steps in rooms the orchestrator does not
+	// have
+	ErrUnknownRoom = errors.New("unknown room")
+	// ErrInjectedFailure is the failure of the step chosen by
+	// Options.FailStep
+	ErrInjectedFailure = errors.New("injected failure")
+)
+
+// opposite maps each direction to the move that undoes it
+var opposite = map[string]string{
+	"up":    "down",
+	"down":  "up",
+	"left":  "right",
+	"right": "left",
+}
+
+// Step is one move of a saga
+type Step struct {
+	Room      string
+	Direction string
+}
+
+// Options tune a saga run. StepDelay is the pause between proposing and
+// committing each step, a window in which players' moves make the step
+// conflict. FailStep, counted from 1, makes that step fail without running.
+type Options struct {
+	StepDelay time.Duration
+	FailStep  int
+}
+
+// room is a room the orchestrator can move the object of
+type room struct {
+	controller *concurrency.ConcurrencyController
+	onChange   func()
+}
+
+// Orchestrator runs sagas over a set of rooms
+type Orchestrator struct {
+	rooms        map[string]room
+	onTransition func(models.Saga)
+
+	mu     sync.Mutex
+	nextID int
+	recent []*models.Saga
+}
+
+// New creates an orchestrator without rooms
+func New() *Orchestrator {
+	return &Orchestrator{rooms: make(map[string]room)}
+}
+
+// AddRoom lets sagas move the object of controller as the room named name.
+// onChange runs after every move the orchestrator commits there. Call it
+// before running sagas.
+func (o *Orchestrator) AddRoom(name string, controller *concurrency.ConcurrencyController, onChange func()) {
+	o.rooms[name] = room{controller: controller, onChange: onChange}
+}
+
+// OnTransition registers fn to receive a copy of a saga after each change of
+// its state or of one of its steps' states. Call it before running sagas.
+func (o *Orchestrator) OnTransition(fn func(models.Saga)) {
+	o.onTransition = fn
+}
+
+// Run runs steps in order and, if one fails, compensates the ones before
+// it. It returns the finished saga; the error is only set when steps are
+// invalid and the saga did not start.
+func (o *Orchestrator) Run(steps []Step, options Options) (models.Saga, error) {
+	if len(steps) == 0 {
+		return models.Saga{}, errors.New("a saga needs at least one step")
+	}
+	for _, step := range steps {
+		if _, ok := o.rooms[step.Room]; !ok {
+			return models.Saga{}, fmt.Errorf("%w %s", ErrUnknownRoom, step.Room)
+		}
+		if _, ok := opposite[step.Direction]; !ok {
+			return models.Saga{}, fmt.Errorf("invalid direction %q", step.Direction)
+		}
+	}
+
+	saga := &models.Saga{Steps: make([]models.SagaStep, len(steps)), Transitions: []models.SagaTransition{}, StartedAt: time.Now()}
+	for i, step := range steps {
+		saga.Steps[i] = models.SagaStep{
+			Room:         step.Room,
+			Direction:    step.Direction,
+			Compensation: opposite[step.Direction],
+			State:        StepPending,
+		}
+	}
+	o.mu.Lock()
+	o.nextID++
+	saga.ID = fmt.Sprintf("saga-%d", o.nextID)
+	o.recent = append(o.recent, saga)
+	if len(o.recent) > keptSagas {
+		o.recent = o.recent[1:]
+	}
+	o.mu.Unlock()
+	o.transition(saga, 0, StateRunning, "")
+
+	// Forward: each step commits on its own
+	failed := -1
+	for i, step := range steps {
+		if i+1 == options.FailStep {
+			o.transition(saga, i+1, StepFailed, ErrInjectedFailure.Error())
+			failed = i
+			break
+		}
+		snapshot, moved, err := o.move(step.Room, fmt.Sprintf("%s-%d", saga.ID, i+1), step.Direction, options.StepDelay)
+		if err != nil {
+			o.transition(saga, i+1, StepFailed, err.Error())
+			failed = i
+			break
+		}
+		o.stepMoved(saga, i, snapshot, moved)
+		o.transition(saga, i+1, StepCommitted, "")
+	}
+	if failed < 0 {
+		return o.finish(saga, StateCompleted), nil
+	}
+
+	// Backward: undo the committed steps, latest first
+	o.transition(saga, 0, StateCompensating, fmt.Sprintf("step %d failed", failed+1))
+	outcome := StateCompensated
+	for i := failed - 1; i >= 0; i-- {
+		if err := o.compensate(saga, i); err != nil {
+			o.transition(saga, i+1, StepCompensationFailed, err.Error())
+			outcome = StateFailed
+			continue
+		}
+		o.transition(saga, i+1, StepCompensated, "")
+	}
+	return o.finish(saga, outcome), nil
+}
+
+// move commits a move in a room as one transaction, waiting delay between
+// proposing and committing it. moved is false when the object was at the
+// edge of the grid and stayed where it was.
+func (o *Orchestrator) move(name, requestID, direction string, delay time.Duration) (snapshot *models.GameStateSnapshot, moved bool, err error) {
+	r := o.rooms[name]
+	transaction, err := r.controller.BeginTransaction(sagaPlayer, requestID)
+	if err != nil {
+		return nil, false, err
+	}
+	before := r.controller.Object()
+	if err := r.controller.ProposeMove(transaction.ID, direction); err != nil {
+		r.controller.AbortTransaction(transaction.ID)
+		return nil, false, err
+	}
+	if delay > 0 {
+		time.Sleep(delay)
+	}
+	snapshot, err = r.controller.CommitTransaction(transaction.ID)
+	if err != nil {
+		return nil, false, err
+	}
+	if r.onChange != nil {
+		r.onChange()
+	}
+	// Another move may have committed between the read and the proposal,
+	// in which case the move is assumed to have moved the object
+	moved = before.Version != snapshot.Object.Version-1 || before.Position != snapshot.Object.Position
+	return snapshot, moved, nil
+}
+
+// compensate runs the compensating move of step i, retrying it when it
+// conflicts with other moves. A step that did not move the object has
+// nothing to undo.
+func (o *Orchestrator) compensate(saga *models.Saga, i int) error {
+	o.mu.Lock()
+	step := saga.Steps[i]
+	o.mu.Unlock()
+	if step.Detail == notMovedDetail {
+		return nil
+	}
+
+	backoff := compensationBackoff
+	var err error
+	for attempt := 1; attempt <= compensationAttempts; attempt++ {
+		var snapshot *models.GameStateSnapshot
+		snapshot, _, err = o.move(step.Room, fmt.Sprintf("%s-%d-undo-%d", saga.ID, i+1, attempt), step.Compensation, 0)
+		if err == nil {
+			o.stepMoved(saga, i, snapshot, true)
+			return nil
+		}
+		if errors.Is(err, concurrency.ErrInvalidMove) || errors.Is(err, concurrency.ErrWall) {
+			break
+		}
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+	return err
+}
+
+// notMovedDetail marks a step whose move left the object where it was
+const notMovedDetail = "the object was at the edge and did not move"
+
+// stepMoved records where a step or its compensation left the object
+func (o *Orchestrator) stepMoved(saga *models.Saga, i int, snapshot *models.GameStateSnapshot, moved bool) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	step := &saga.Steps[i]
+	if !moved {
+		step.Detail = notMovedDetail
+	}
+	position := snapshot.Object.Position
+	step.Version = snapshot.Object.Version
+	step.Position = &position
+}
+
+// transition moves the saga, or its step when step is set, to state and
+// reports the change
+func (o *Orchestrator) transition(saga *models.Saga, step int, state, detail string) {
+	o.mu.Lock()
+	record := models.SagaTransition{Step: step, To: state, Detail: detail, Timestamp: time.Now()}
+	if step > 0 {
+		record.From = saga.Steps[step-1].State
+		saga.Steps[step-1].State = state
+		if detail != "" {
+			saga.Steps[step-1].Detail = detail
+		}
+	} else {
+		record.From = saga.State
+		saga.State = state
+	}
+	saga.Transitions = append(saga.Transitions, record)
+	copied := copySaga(saga)
+	o.mu.Unlock()
+
+	if o.onTransition != nil {
+		o.onTransition(copied)
+	}
+}
+
+// finish moves the saga to its final state and returns a copy of it
+func (o *Orchestrator) finish(saga *models.Saga, state string) models.Saga {
+	o.mu.Lock()
+	finishedAt := time.Now()
+	saga.FinishedAt = &finishedAt
+	o.mu.Unlock()
+
+	o.transition(saga, 0, state, "")
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return copySaga(saga)
+}
+
+// Sagas returns copies of the most recent sagas, oldest first
+func (o *Orchestrator) Sagas() []models.Saga {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	sagas := make([]models.Saga, 0, len(o.recent))
+	for _, saga := range o.recent {
+		sagas = append(sagas, copySaga(saga))
+	}
+	return sagas
+}
+
+// copySaga returns a copy of saga sharing nothing with it. Caller must hold
+// o.mu.
+func copySaga(saga *models.Saga) models.Saga {
+	copied := *saga
+	copied.Steps = append([]models.SagaStep(nil), saga.Steps...)
+	copied.Transitions = append([]models.SagaTransition(nil), saga.Transitions...)
+	return copied
+}
@@ -0,0 +1,140 @@
+package saga
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/AnishMulay/Transaction-Conflict-Visualization/internal/concurrency"
+	"github.com/AnishMulay/Transaction-Conflict-Visualization/pkg/models"
+)
+
+// newTestOrchestrator has the rooms lobby and arena, each with its object at
+// (5, 5)
+func newTestOrchestrator() (*Orchestrator, map[string]*concurrency.ConcurrencyController) {
+	orchestrator := New()
+	controllers := make(map[string]*concurrency.ConcurrencyController)
+	for _, room := range []string{"lobby", "arena"} {
+		controllers[room] = concurrency.NewConcurrencyController(models.NewGameState(models.Position{X: 10, Y: 10}))
+		orchestrator.AddRoom(room, controllers[room], nil)
+	}
+	return orchestrator, controllers
+}
+
+// history lists a saga's transitions as "state" or "step:state"
+func history(saga models.Saga) []string {
+	var entries []string
+	for _, transition := range saga.Transitions {
+		if transition.Step > 0 {
+			entries = append(entries, fmt.Sprintf("%d:%s", transition.Step, transition.To))
+		} else {
+			entries = append(entries, transition.To)
+		}
+	}
+	return entries
+}
+
+func expectHistory(t *testing.T, saga models.Saga, want ...string) {
+	t.Helper()
+	if got := history(saga); fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("Expected transitions %v, got %v", want, got)
+	}
+}
+
+func TestSagaCompletesEveryStep(t *testing.T) {
+	orchestrator, controllers := newTestOrchestrator()
+
+	var seen []string
+	orchestrator.OnTransition(func(saga models.Saga) { seen = append(seen, saga.State) })
+
+	saga, err := orchestrator.Run([]Step{{"lobby", "up"}, {"arena", "left"}, {"lobby", "right"}}, Options{})
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if saga.State != StateCompleted || saga.FinishedAt == nil {
+		t.Fatalf("Expected the saga completed, got %+v", saga)
+	}
+	expectHistory(t, saga, "running", "1:committed", "2:committed", "3:committed", "completed")
+	if len(seen) != 5 {
+		t.Errorf("Expected every transition reported, got %v", seen)
+	}
+	if object := controllers["lobby"].Object(); object.Position != (models.Position{X: 6, Y: 4}) {
+		t.Errorf("Expected the lobby object at (6, 4), got %+v", object)
+	}
+	if object := controllers["arena"].Object(); object.Position != (models.Position{X: 4, Y: 5}) {
+		t.Errorf("Expected the arena object at (4, 5), got %+v", object)
+	}
+}
+
+func TestFailedStepCompensatesEarlierSteps(t *testing.T) {
+	orchestrator, controllers := newTestOrchestrator()
+
+	saga, err := orchestrator.Run([]Step{{"lobby", "up"}, {"arena", "left"}, {"lobby", "right"}}, Options{FailStep: 3})
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if saga.State != StateCompensated {
+		t.Fatalf("Expected the saga compensated, got %+v", saga)
+	}
+	expectHistory(t, saga, "running", "1:committed", "2:committed", "3:failed", "compensating", "2:compensated", "1:compensated", "compensated")
+
+	// The objects are back where they started, with the moves in their
+	// version history
+	for room, controller := range controllers {
+		if object := controller.Object(); object.Position != (models.Position{X: 5, Y: 5}) || object.Version != 3 {
+			t.Errorf("Expected the %s object back at (5, 5) at version 3, got %+v", room, object)
+		}
+	}
+}
+
+func TestConflictingMoveFailsStep(t *testing.T) {
+	orchestrator, controllers := newTestOrchestrator()
+
+	// A player moves the lobby object while the saga's second step waits to
+	// commit
+	var wg sync.WaitGroup
+	orchestrator.OnTransition(func(saga models.Saga) {
+		if saga.Steps[0].State == StepCommitted && saga.Steps[1].State == StepPending {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				time.Sleep(20 * time.Millisecond)
+				transaction, _ := controllers["lobby"].BeginTransaction("player", "down")
+				controllers["lobby"].ProposeMove(transaction.ID, "right")
+				if _, err := controllers["lobby"].CommitTransaction(transaction.ID); err != nil {
+					t.Errorf("Player move failed: %v", err)
+				}
+			}()
+		}
+	})
+
+	saga, err := orchestrator.Run([]Step{{"lobby", "up"}, {"lobby", "up"}}, Options{StepDelay: 200 * time.Millisecond})
+	wg.Wait()
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	expectHistory(t, saga, "running", "1:committed", "2:failed", "compensating", "1:compensated", "compensated")
+
+	// The compensation undid the saga's move but kept the player's
+	if object := controllers["lobby"].Object(); object.Position != (models.Position{X: 6, Y: 5}) {
+		t.Errorf("Expected only the player's move to remain, got %+v", object)
+	}
+}
+
+func TestClampedStepHasNothingToUndo(t *testing.T) {
+	orchestrator, controllers := newTestOrchestrator()
+
+	// The sixth move up hits the edge and leaves the object where it is
+	steps := []Step{{"lobby", "up"}, {"lobby", "up"}, {"lobby", "up"}, {"lobby", "up"}, {"lobby", "up"}, {"lobby", "up"}, {"arena", "up"}}
+	saga, err := orchestrator.Run(steps, Options{FailStep: 7})
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if saga.State != StateCompensated || saga.Steps[5].Detail != notMovedDetail || saga.Steps[4].Detail != "" {
+		t.Fatalf("Expected only the sixth step marked as not moved, got %+v", saga.Steps)
+	}
+	if object := controllers["lobby"].Object(); object.Position != (models.Position{X: 5, Y: 5}) || object.Version != 12 {
+		t.Errorf("Expected five compensating moves back to (5, 5), got %+v", object)
+	}
+}
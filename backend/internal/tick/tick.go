@@ -0,0 +1,149 @@
+// Package tick resolves the moves of a fixed-rate authoritative simulation.
+// Instead of committing each move as it arrives, a room in tick mode
+// collects the moves received during a tick and combines them with a Rule
+// into a single move of the object, which commits once per tick. The same
+// moves in the same order always resolve the same way.
+package tick
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Rule decides how the moves of one tick combine
+type Rule string
+
+const (
+	// RuleVectorSum adds up every move, so opposite moves cancel out and
+	// moves in the same direction go further
+	RuleVectorSum Rule = "vector"
+	// RuleMajority moves the object one cell in the direction most players
+	// chose. A tie goes to the direction whose first vote arrived first.
+	RuleMajority Rule = "majority"
+	// RuleFirstArrival keeps the move that arrived first and overrules the
+	// others, like a lock that is only held for one tick
+	RuleFirstArrival Rule = "first"
+)
+
+// ParseRule parses a rule name: vector, majority or first
+func ParseRule(name string) (Rule, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "", "vector", "vector-sum", "sum":
+		return RuleVectorSum, nil
+	case "majority", "vote":
+		return RuleMajority, nil
+	case "first", "first-arrival":
+		return RuleFirstArrival, nil
+	}
+	return "", fmt.Errorf("unknown tick rule %q, use vector, majority or first", name)
+}
+
+// offsets is the displacement of a one cell move in each direction
+var offsets = map[string][2]int{
+	"up":    {0, -1},
+	"down":  {0, 1},
+	"left":  {-1, 0},
+	"right": {1, 0},
+}
+
+// Input is a move received during a tick
+type Input struct {
+	PlayerID   string
+	RequestID  string
+	Direction  string
+	ReceivedAt time.Time
+}
+
+// Resolution is the combined move of a tick. Accepted tells, for each input
+// in the order they were given to Resolve, whether it counted; Detail
+// explains the outcome.
+type Resolution struct {
+	DX, DY   int
+	Accepted []bool
+	Detail   string
+}
+
+// Resolve combines the moves of one tick. Inputs are taken in order of
+// arrival, ties broken by player and request ID, and only each player's
+// latest move counts: a player who sent several during the tick changed
+// their mind.
+func Resolve(rule Rule, inputs []Input) Resolution {
+	resolution := Resolution{Accepted: make([]bool, len(inputs))}
+	if len(inputs) == 0 {
+		resolution.Detail = "no moves"
+		return resolution
+	}
+
+	order := make([]int, len(inputs))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		x, y := inputs[order[a]], inputs[order[b]]
+		if !x.ReceivedAt.Equal(y.ReceivedAt) {
+			return x.ReceivedAt.Before(y.ReceivedAt)
+		}
+		if x.PlayerID != y.PlayerID {
+			return x.PlayerID < y.PlayerID
+		}
+		return x.RequestID < y.RequestID
+	})
+
+	latest := make(map[string]int)
+	for _, i := range order {
+		latest[inputs[i].PlayerID] = i
+	}
+	var counted []int
+	for _, i := range order {
+		if latest[inputs[i].PlayerID] == i {
+			counted = append(counted, i)
+		}
+	}
+	superseded := len(inputs) - len(counted)
+
+	switch rule {
+	case RuleMajority:
+		votes := make(map[string]int)
+		var directions []string
+		for _, i := range counted {
+			direction := inputs[i].Direction
+			if votes[direction] == 0 {
+				directions = append(directions, direction)
+			}
+			votes[direction]++
+		}
+		// directions is in order of first vote, so the earliest wins a tie
+		winner := directions[0]
+		for _, direction := range directions[1:] {
+			if votes[direction] > votes[winner] {
+				winner = direction
+			}
+		}
+		for _, i := range counted {
+			resolution.Accepted[i] = inputs[i].Direction == winner
+		}
+		resolution.DX, resolution.DY = offsets[winner][0], offsets[winner][1]
+		resolution.Detail = fmt.Sprintf("%s won %d of %d votes", winner, votes[winner], len(counted))
+
+	case RuleFirstArrival:
+		first := inputs[counted[0]]
+		resolution.Accepted[counted[0]] = true
+		resolution.DX, resolution.DY = offsets[first.Direction][0], offsets[first.Direction][1]
+		resolution.Detail = fmt.Sprintf("%s arrived first of %d", first.Direction, len(counted))
+
+	default:
+		for _, i := range counted {
+			resolution.Accepted[i] = true
+			resolution.DX += offsets[inputs[i].Direction][0]
+			resolution.DY += offsets[inputs[i].Direction][1]
+		}
+		resolution.Detail = fmt.Sprintf("%d moves sum to (%+d, %+d)", len(counted), resolution.DX, resolution.DY)
+	}
+
+	if superseded > 0 {
+		resolution.Detail += fmt.Sprintf(", %d superseded by a later move of the same player", superseded)
+	}
+	return resolution
+}
@@ -0,0 +1,74 @@
+package tick
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+// inputs builds one input per player and direction, arriving a millisecond
+// apart in the order given
+func inputs(moves ...string) []Input {
+	start := time.Now()
+	var result []Input
+	for i := 0; i+1 < len(moves); i += 2 {
+		result = append(result, Input{
+			PlayerID:   moves[i],
+			RequestID:  fmt.Sprintf("%s-%d", moves[i], i),
+			Direction:  moves[i+1],
+			ReceivedAt: start.Add(time.Duration(i) * time.Millisecond),
+		})
+	}
+	return result
+}
+
+func TestRulesResolveATick(t *testing.T) {
+	tests := []struct {
+		name     string
+		rule     Rule
+		inputs   []Input
+		dx, dy   int
+		accepted string
+	}{
+		{"vector sum adds up moves", RuleVectorSum, inputs("a", "right", "b", "right", "c", "up"), 2, -1, "[true true true]"},
+		{"vector sum cancels opposites", RuleVectorSum, inputs("a", "left", "b", "right"), 0, 0, "[true true]"},
+		{"majority moves one cell", RuleMajority, inputs("a", "down", "b", "left", "c", "left"), -1, 0, "[false true true]"},
+		{"majority tie goes to the first vote", RuleMajority, inputs("a", "up", "b", "down"), 0, -1, "[true false]"},
+		{"first arrival overrules the rest", RuleFirstArrival, inputs("b", "left", "a", "up", "c", "left"), -1, 0, "[true false false]"},
+		{"only a player's latest move counts", RuleVectorSum, inputs("a", "up", "b", "left", "a", "down"), -1, 1, "[false true true]"},
+	}
+
+	for _, test := range tests {
+		resolution := Resolve(test.rule, test.inputs)
+		if resolution.DX != test.dx || resolution.DY != test.dy || fmt.Sprint(resolution.Accepted) != test.accepted {
+			t.Errorf("%s: expected (%d, %d) accepting %s, got %+v", test.name, test.dx, test.dy, test.accepted, resolution)
+		}
+	}
+}
+
+func TestResolutionIsDeterministic(t *testing.T) {
+	// Simultaneous arrivals are ordered by player, whatever order they are
+	// collected in
+	at := time.Now()
+	forward := []Input{
+		{PlayerID: "a", RequestID: "1", Direction: "up", ReceivedAt: at},
+		{PlayerID: "b", RequestID: "1", Direction: "down", ReceivedAt: at},
+	}
+	backward := []Input{forward[1], forward[0]}
+
+	first, second := Resolve(RuleFirstArrival, forward), Resolve(RuleFirstArrival, backward)
+	if first.DY != -1 || second.DY != -1 {
+		t.Errorf("Expected player a's move to win both times, got %+v and %+v", first, second)
+	}
+}
+
+func TestParseRule(t *testing.T) {
+	for name, want := range map[string]Rule{"": RuleVectorSum, "vote": RuleMajority, "FIRST": RuleFirstArrival} {
+		if rule, err := ParseRule(name); err != nil || rule != want {
+			t.Errorf("ParseRule(%q) = %s, %v; expected %s", name, rule, err, want)
+		}
+	}
+	if _, err := ParseRule("random"); err == nil {
+		t.Error("Expected an unknown rule to be rejected")
+	}
+}
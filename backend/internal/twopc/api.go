@@ -0,0 +1,112 @@
+package twopc
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	defaultDowntime = 10 * time.Second
+	maxDowntime     = time.Minute
+)
+
+// Register adds the two-phase commit routes to mux:
+//
+//	GET  /twopc                  the coordinator's log and every room
+//	POST /twopc/move             move ?moves=lobby:up,arena:left atomically,
+//	                             crashing the coordinator at ?fault= for
+//	                             ?downtime= (default 10s)
+//	POST /twopc/crash            crash the coordinator for ?downtime=, or
+//	                             until recovered with downtime=0
+//	POST /twopc/recover          recover the coordinator now
+func (c *Coordinator) Register(mux *http.ServeMux) {
+	mux.HandleFunc("GET /twopc", c.status)
+	mux.HandleFunc("POST /twopc/move", c.move)
+	mux.HandleFunc("POST /twopc/crash", c.crash)
+	mux.HandleFunc("POST /twopc/recover", c.recover)
+}
+
+func (c *Coordinator) status(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, c.Status())
+}
+
+func (c *Coordinator) move(w http.ResponseWriter, r *http.Request) {
+	var moves []Move
+	for _, spec := range strings.Split(r.URL.Query().Get("moves"), ",") {
+		room, direction, ok := strings.Cut(strings.TrimSpace(spec), ":")
+		if !ok || room == "" || direction == "" {
+			http.Error(w, "moves must list room:direction pairs, e.g. lobby:up,arena:left", http.StatusBadRequest)
+			return
+		}
+		moves = append(moves, Move{Room: room, Direction: direction})
+	}
+
+	fault, err := ParseFault(r.URL.Query().Get("fault"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	downtime, ok := parseDowntime(w, r)
+	if !ok {
+		return
+	}
+
+	result, err := c.Run(moves, fault, downtime)
+	switch {
+	case errors.Is(err, ErrCoordinatorDown):
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	case errors.Is(err, ErrUnknownRoom):
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	status := http.StatusOK
+	switch result.Outcome {
+	case OutcomeAborted:
+		status = http.StatusConflict
+	case OutcomeInDoubt:
+		status = http.StatusAccepted
+	}
+	writeJSON(w, status, result)
+}
+
+func (c *Coordinator) crash(w http.ResponseWriter, r *http.Request) {
+	downtime, ok := parseDowntime(w, r)
+	if !ok {
+		return
+	}
+	c.Crash(downtime)
+	writeJSON(w, http.StatusOK, c.Status())
+}
+
+func (c *Coordinator) recover(w http.ResponseWriter, r *http.Request) {
+	c.Recover()
+	writeJSON(w, http.StatusOK, c.Status())
+}
+
+// parseDowntime reads ?downtime=, default 10s and at most a minute
+func parseDowntime(w http.ResponseWriter, r *http.Request) (time.Duration, bool) {
+	value := r.URL.Query().Get("downtime")
+	if value == "" {
+		return defaultDowntime, true
+	}
+	downtime, err := time.ParseDuration(value)
+	if err != nil || downtime < 0 || downtime > maxDowntime {
+		http.Error(w, "downtime must be a duration between 0 and 1m", http.StatusBadRequest)
+		return 0, false
+	}
+	return downtime, true
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
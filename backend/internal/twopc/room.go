@@ -0,0 +1,106 @@
+package twopc
+
+import (
+	"sync"
+
+	"github.com/AnishMulay/Transaction-Conflict-Visualization/internal/concurrency"
+	"github.com/AnishMulay/Transaction-Conflict-Visualization/pkg/models"
+)
+
+// coordinatorPlayer is the player ID cross-room moves are made as
+const coordinatorPlayer = "coordinator"
+
+// Room is a room's concurrency controller acting as a participant
+type Room struct {
+	name       string
+	controller *concurrency.ConcurrencyController
+	onChange   func()
+
+	mu sync.Mutex
+	// local maps the coordinator's transaction IDs to the controller's, for
+	// transactions prepared here and not yet decided
+	local map[string]string
+}
+
+// NewRoom makes controller a participant named name. onChange runs after a
+// decision changes the room's state.
+func NewRoom(name string, controller *concurrency.ConcurrencyController, onChange func()) *Room {
+	return &Room{
+		name:       name,
+		controller: controller,
+		onChange:   onChange,
+		local:      make(map[string]string),
+	}
+}
+
+// Prepare implements Participant by running the move as a transaction of
+// the room and preparing it
+func (r *Room) Prepare(transactionID, direction string) error {
+	transaction, err := r.controller.BeginTransaction(coordinatorPlayer, transactionID)
+	if err != nil {
+		return err
+	}
+	if err := r.controller.ProposeMove(transaction.ID, direction); err != nil {
+		r.controller.AbortTransaction(transaction.ID)
+		return err
+	}
+	if err := r.controller.Prepare(transaction.ID); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	r.local[transactionID] = transaction.ID
+	r.mu.Unlock()
+	return nil
+}
+
+// Commit implements Participant
+func (r *Room) Commit(transactionID string) error {
+	return r.decide(transactionID, func(local string) error {
+		_, err := r.controller.CommitPrepared(local)
+		return err
+	})
+}
+
+// Abort implements Participant
+func (r *Room) Abort(transactionID string) error {
+	return r.decide(transactionID, r.controller.AbortPrepared)
+}
+
+// decide applies a decision to a transaction prepared here. A transaction
+// that is not prepared here was decided before or never prepared, so there
+// is nothing left to do.
+func (r *Room) decide(transactionID string, apply func(local string) error) error {
+	r.mu.Lock()
+	local, ok := r.local[transactionID]
+	delete(r.local, transactionID)
+	r.mu.Unlock()
+	if !ok {
+		return nil
+	}
+
+	if err := apply(local); err != nil {
+		return err
+	}
+	if r.onChange != nil {
+		r.onChange()
+	}
+	return nil
+}
+
+// Status implements Participant
+func (r *Room) Status() models.ParticipantStatus {
+	status := models.ParticipantStatus{Room: r.name, Object: r.controller.Object()}
+	if prepared, ok := r.controller.PreparedTransaction(); ok {
+		// Named by the coordinator's ID, which its log uses
+		r.mu.Lock()
+		for id, local := range r.local {
+			if local == prepared.TransactionID {
+				prepared.TransactionID = id
+			}
+		}
+		r.mu.Unlock()
+		status.Prepared = &prepared
+	}
+	return status
+}
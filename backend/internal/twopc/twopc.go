@@ -0,0 +1,401 @@
+// Package twopc moves the objects of several rooms atomically with two-phase
+// commit. A coordinator asks every room to prepare its move, logs its
+// decision, then tells every room to commit or abort. Each room's
+// concurrency controller is a participant: once prepared it has promised its
+// object to the transaction and refuses other moves until it hears the
+// decision, so a coordinator that crashes between the phases leaves the rooms
+// blocked until it recovers and finishes what its log says.
+package twopc
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/AnishMulay/Transaction-Conflict-Visualization/pkg/models"
+)
+
+const (
+	// statusLogSize is how many recent log records Status reports
+	statusLogSize = 30
+	// logLimit is how many records the log holds before finished
+	// transactions are dropped
+	logLimit = 1024
+)
+
+// Kinds of coordinator log records
+const (
+	KindBegin   = "begin"
+	KindVoteYes = "vote-yes"
+	KindVoteNo  = "vote-no"
+	KindCommit  = "commit"
+	KindAbort   = "abort"
+	KindEnd     = "end"
+)
+
+// Outcomes of a cross-room transaction
+const (
+	OutcomeCommitted = "committed"
+	OutcomeAborted   = "aborted"
+	OutcomeInDoubt   = "in-doubt"
+)
+
+var (
+	// ErrCoordinatorDown is returned while a simulated crash is in progress
+	ErrCoordinatorDown = errors.New("coordinator is down")
+	// ErrUnknownRoom is returned for moves in rooms that are not participants
+	ErrUnknownRoom = errors.New("unknown room")
+)
+
+// Participant is a room's side of two-phase commit. Prepare runs the room's
+// part of a transaction and votes: nil to commit, the reason otherwise.
+// Commit and Abort deliver the decision; a recovering coordinator resends
+// decisions, so they must accept a transaction that was already decided or
+// never prepared.
+type Participant interface {
+	Prepare(transactionID, direction string) error
+	Commit(transactionID string) error
+	Abort(transactionID string) error
+	Status() models.ParticipantStatus
+}
+
+// Move is one room's part of a cross-room transaction
+type Move struct {
+	Room      string
+	Direction string
+}
+
+// Fault crashes the coordinator at a chosen point of a transaction
+type Fault string
+
+const (
+	FaultNone Fault = ""
+	// FaultAfterPrepare crashes once every room has voted, before deciding
+	FaultAfterPrepare Fault = "after-prepare"
+	// FaultAfterDecision crashes after logging the decision, before telling
+	// any room
+	FaultAfterDecision Fault = "after-decision"
+	// FaultMidCommit crashes after telling the first room the decision
+	FaultMidCommit Fault = "mid-commit"
+)
+
+// ParseFault parses a fault name: none, after-prepare, after-decision or
+// mid-commit
+func ParseFault(name string) (Fault, error) {
+	switch fault := Fault(strings.ToLower(strings.TrimSpace(name))); fault {
+	case "", "none":
+		return FaultNone, nil
+	case FaultAfterPrepare, FaultAfterDecision, FaultMidCommit:
+		return fault, nil
+	}
+	return "", fmt.Errorf("unknown fault %q, use none, after-prepare, after-decision or mid-commit", name)
+}
+
+// Coordinator runs cross-room transactions. Its log stands for stable
+// storage and survives crashes; everything else a transaction in flight
+// knows is lost when the coordinator crashes.
+type Coordinator struct {
+	participants map[string]Participant
+	rooms        []string
+	onChange     func()
+
+	mu        sync.Mutex
+	log       []models.CoordinatorRecord
+	nextIndex int
+	nextID    int
+	down      bool
+	downUntil time.Time
+	restart   *time.Timer
+	// epoch changes with every crash, stopping the transactions of the
+	// previous incarnation
+	epoch int
+}
+
+// NewCoordinator creates a coordinator without participants
+func NewCoordinator() *Coordinator {
+	return &Coordinator{participants: make(map[string]Participant)}
+}
+
+// AddParticipant makes participant the room named room. Call it before
+// running transactions.
+func (c *Coordinator) AddParticipant(room string, participant Participant) {
+	c.participants[room] = participant
+	c.rooms = append(c.rooms, room)
+}
+
+// OnChange registers fn to run after the coordinator's log or state changes.
+// Call it before running transactions.
+func (c *Coordinator) OnChange(fn func()) {
+	c.onChange = fn
+}
+
+func (c *Coordinator) changed() {
+	if c.onChange != nil {
+		c.onChange()
+	}
+}
+
+// Run moves the objects of the rooms in moves atomically. fault crashes the
+// coordinator at that point for downtime, after which it recovers on its
+// own; the transaction is then reported in doubt.
+func (c *Coordinator) Run(moves []Move, fault Fault, downtime time.Duration) (models.CrossRoomResult, error) {
+	if len(moves) == 0 {
+		return models.CrossRoomResult{}, errors.New("a transaction needs at least one move")
+	}
+	rooms := make([]string, len(moves))
+	for i, move := range moves {
+		if _, ok := c.participants[move.Room]; !ok {
+			return models.CrossRoomResult{}, fmt.Errorf("%w %s", ErrUnknownRoom, move.Room)
+		}
+		for _, room := range rooms[:i] {
+			if room == move.Room {
+				return models.CrossRoomResult{}, fmt.Errorf("room %s is moved twice", room)
+			}
+		}
+		rooms[i] = move.Room
+	}
+
+	c.mu.Lock()
+	if c.down {
+		c.mu.Unlock()
+		return models.CrossRoomResult{}, ErrCoordinatorDown
+	}
+	c.nextID++
+	id := fmt.Sprintf("2pc-%d", c.nextID)
+	epoch := c.epoch
+	c.mu.Unlock()
+
+	result := models.CrossRoomResult{TransactionID: id, Votes: make(map[string]string)}
+	inDoubt := func() (models.CrossRoomResult, error) {
+		result.Outcome = OutcomeInDoubt
+		result.Status = c.Status()
+		return result, nil
+	}
+	crash := func() (models.CrossRoomResult, error) {
+		c.Crash(downtime)
+		return inDoubt()
+	}
+
+	if !c.append(epoch, models.CoordinatorRecord{TransactionID: id, Kind: KindBegin, Rooms: rooms}) {
+		return inDoubt()
+	}
+
+	// Phase one: every room prepares and votes. One vote against decides.
+	decision := KindCommit
+	for _, move := range moves {
+		record := models.CoordinatorRecord{TransactionID: id, Kind: KindVoteYes, Room: move.Room}
+		result.Votes[move.Room] = "yes"
+		if err := c.participants[move.Room].Prepare(id, move.Direction); err != nil {
+			decision = KindAbort
+			record.Kind, record.Detail = KindVoteNo, err.Error()
+			result.Votes[move.Room] = "no: " + err.Error()
+		}
+		if !c.append(epoch, record) {
+			return inDoubt()
+		}
+		if decision == KindAbort {
+			break
+		}
+	}
+	if fault == FaultAfterPrepare {
+		return crash()
+	}
+
+	// The decision is final once it is in the log
+	if !c.append(epoch, models.CoordinatorRecord{TransactionID: id, Kind: decision}) {
+		return inDoubt()
+	}
+	if fault == FaultAfterDecision {
+		return crash()
+	}
+
+	// Phase two
+	for i, room := range rooms {
+		if !c.alive(epoch) {
+			return inDoubt()
+		}
+		c.deliver(id, room, decision)
+		if i == 0 && fault == FaultMidCommit {
+			return crash()
+		}
+	}
+	if !c.append(epoch, models.CoordinatorRecord{TransactionID: id, Kind: KindEnd}) {
+		return inDoubt()
+	}
+
+	result.Outcome = OutcomeCommitted
+	if decision == KindAbort {
+		result.Outcome = OutcomeAborted
+	}
+	result.Status = c.Status()
+	return result, nil
+}
+
+// deliver tells a room the decision on a transaction
+func (c *Coordinator) deliver(id, room, decision string) {
+	participant := c.participants[room]
+	deliver := participant.Abort
+	if decision == KindCommit {
+		deliver = participant.Commit
+	}
+	if err := deliver(id); err != nil {
+		log.Printf("Room %s failed to %s %s: %v", room, decision, id, err)
+	}
+}
+
+// append adds a record to the log unless the coordinator crashed since
+// epoch, reporting whether it did
+func (c *Coordinator) append(epoch int, record models.CoordinatorRecord) bool {
+	c.mu.Lock()
+	if c.down || c.epoch != epoch {
+		c.mu.Unlock()
+		return false
+	}
+	c.nextIndex++
+	record.Index = c.nextIndex
+	record.Timestamp = time.Now()
+	c.log = append(c.log, record)
+	if len(c.log) > logLimit {
+		c.compact()
+	}
+	c.mu.Unlock()
+
+	c.changed()
+	return true
+}
+
+// alive reports whether the incarnation of epoch is still running
+func (c *Coordinator) alive(epoch int) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return !c.down && c.epoch == epoch
+}
+
+// compact drops the records of transactions that ended. Caller must hold
+// c.mu.
+func (c *Coordinator) compact() {
+	ended := make(map[string]bool)
+	for _, record := range c.log {
+		if record.Kind == KindEnd {
+			ended[record.TransactionID] = true
+		}
+	}
+	kept := c.log[:0]
+	for _, record := range c.log {
+		if !ended[record.TransactionID] {
+			kept = append(kept, record)
+		}
+	}
+	c.log = kept
+}
+
+// Crash takes the coordinator down, losing the transactions in flight. It
+// recovers after downtime, or on Recover if downtime is not positive.
+func (c *Coordinator) Crash(downtime time.Duration) {
+	c.mu.Lock()
+	c.down = true
+	c.epoch++
+	c.downUntil = time.Time{}
+	if c.restart != nil {
+		c.restart.Stop()
+		c.restart = nil
+	}
+	if downtime > 0 {
+		c.downUntil = time.Now().Add(downtime)
+		c.restart = time.AfterFunc(downtime, c.Recover)
+	}
+	c.mu.Unlock()
+
+	c.changed()
+}
+
+// Recover restarts a crashed coordinator. It reads its log and finishes every
+// transaction that did not end: those with a logged decision get it resent,
+// and those without one are aborted, since no room can have committed them.
+func (c *Coordinator) Recover() {
+	c.mu.Lock()
+	if !c.down {
+		c.mu.Unlock()
+		return
+	}
+	c.down = false
+	c.downUntil = time.Time{}
+	if c.restart != nil {
+		c.restart.Stop()
+		c.restart = nil
+	}
+	epoch := c.epoch
+
+	type unfinished struct {
+		id       string
+		rooms    []string
+		decision string
+	}
+	var pending []*unfinished
+	byID := make(map[string]*unfinished)
+	for _, record := range c.log {
+		switch record.Kind {
+		case KindBegin:
+			byID[record.TransactionID] = &unfinished{id: record.TransactionID, rooms: record.Rooms}
+			pending = append(pending, byID[record.TransactionID])
+		case KindCommit, KindAbort:
+			if transaction, ok := byID[record.TransactionID]; ok {
+				transaction.decision = record.Kind
+			}
+		case KindEnd:
+			delete(byID, record.TransactionID)
+		}
+	}
+	c.mu.Unlock()
+	c.changed()
+
+	for _, transaction := range pending {
+		if _, ok := byID[transaction.id]; !ok {
+			continue
+		}
+		if transaction.decision == "" {
+			transaction.decision = KindAbort
+			if !c.append(epoch, models.CoordinatorRecord{TransactionID: transaction.id, Kind: KindAbort, Detail: "no decision logged before the crash"}) {
+				return
+			}
+		}
+		for _, room := range transaction.rooms {
+			if !c.alive(epoch) {
+				return
+			}
+			c.deliver(transaction.id, room, transaction.decision)
+		}
+		if !c.append(epoch, models.CoordinatorRecord{TransactionID: transaction.id, Kind: KindEnd, Detail: "finished by recovery"}) {
+			return
+		}
+	}
+}
+
+// Status describes the coordinator, its recent log and every room
+func (c *Coordinator) Status() models.CoordinatorStatus {
+	c.mu.Lock()
+	status := models.CoordinatorStatus{
+		Up:           !c.down,
+		Log:          []models.CoordinatorRecord{},
+		Participants: []models.ParticipantStatus{},
+		Timestamp:    time.Now(),
+	}
+	if !c.downUntil.IsZero() {
+		downUntil := c.downUntil
+		status.DownUntil = &downUntil
+	}
+	first := 0
+	if len(c.log) > statusLogSize {
+		first = len(c.log) - statusLogSize
+	}
+	status.Log = append(status.Log, c.log[first:]...)
+	c.mu.Unlock()
+
+	for _, room := range c.rooms {
+		status.Participants = append(status.Participants, c.participants[room].Status())
+	}
+	return status
+}
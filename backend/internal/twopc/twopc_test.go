@@ -0,0 +1,195 @@
+package twopc
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/AnishMulay/Transaction-Conflict-Visualization/internal/concurrency"
+	"github.com/AnishMulay/Transaction-Conflict-Visualization/pkg/models"
+)
+
+// refusingRoom is a participant that always votes no
+type refusingRoom struct{}
+
+func (refusingRoom) Prepare(transactionID, direction string) error {
+	return errors.New("room is closed")
+}
+func (refusingRoom) Commit(transactionID string) error { return nil }
+func (refusingRoom) Abort(transactionID string) error  { return nil }
+func (refusingRoom) Status() models.ParticipantStatus {
+	return models.ParticipantStatus{Room: "closed"}
+}
+
+// newTestCoordinator coordinates the rooms lobby and arena
+func newTestCoordinator() (*Coordinator, map[string]*concurrency.ConcurrencyController) {
+	coordinator := NewCoordinator()
+	controllers := make(map[string]*concurrency.ConcurrencyController)
+	for _, room := range []string{"lobby", "arena"} {
+		controllers[room] = concurrency.NewConcurrencyController(models.NewGameState(models.Position{X: 10, Y: 10}))
+		coordinator.AddParticipant(room, NewRoom(room, controllers[room], nil))
+	}
+	return coordinator, controllers
+}
+
+var bothRooms = []Move{{Room: "lobby", Direction: "up"}, {Room: "arena", Direction: "left"}}
+
+// logKinds lists the kinds of the log records of a transaction
+func logKinds(status models.CoordinatorStatus, id string) []string {
+	var kinds []string
+	for _, record := range status.Log {
+		if record.TransactionID == id {
+			kinds = append(kinds, record.Kind)
+		}
+	}
+	return kinds
+}
+
+// playerMove commits a player's move in a room
+func playerMove(controller *concurrency.ConcurrencyController, direction string) error {
+	transaction, _ := controller.BeginTransaction("player", direction)
+	if err := controller.ProposeMove(transaction.ID, direction); err != nil {
+		return err
+	}
+	_, err := controller.CommitTransaction(transaction.ID)
+	return err
+}
+
+func TestCrossRoomMoveCommitsEverywhere(t *testing.T) {
+	coordinator, controllers := newTestCoordinator()
+
+	result, err := coordinator.Run(bothRooms, FaultNone, 0)
+	if err != nil || result.Outcome != OutcomeCommitted {
+		t.Fatalf("Expected a commit, got %+v, %v", result, err)
+	}
+	if object := controllers["lobby"].Object(); object.Position != (models.Position{X: 5, Y: 4}) {
+		t.Errorf("Expected the lobby object moved up, got %+v", object)
+	}
+	if object := controllers["arena"].Object(); object.Position != (models.Position{X: 4, Y: 5}) {
+		t.Errorf("Expected the arena object moved left, got %+v", object)
+	}
+	if kinds := logKinds(result.Status, result.TransactionID); len(kinds) != 5 || kinds[3] != KindCommit || kinds[4] != KindEnd {
+		t.Errorf("Expected begin, two votes, commit and end logged, got %v", kinds)
+	}
+}
+
+func TestVoteAgainstAbortsEverywhere(t *testing.T) {
+	coordinator, controllers := newTestCoordinator()
+	coordinator.AddParticipant("closed", refusingRoom{})
+
+	result, err := coordinator.Run([]Move{{Room: "lobby", Direction: "up"}, {Room: "closed", Direction: "up"}}, FaultNone, 0)
+	if err != nil || result.Outcome != OutcomeAborted || result.Votes["closed"] != "no: room is closed" {
+		t.Fatalf("Expected an abort on the closed room's vote, got %+v, %v", result, err)
+	}
+
+	// The lobby had prepared, and was released unchanged
+	if object := controllers["lobby"].Object(); object.Version != 1 {
+		t.Errorf("Expected the lobby object untouched, got %+v", object)
+	}
+	if _, ok := controllers["lobby"].PreparedTransaction(); ok {
+		t.Error("Expected the lobby released after the abort")
+	}
+	if err := playerMove(controllers["lobby"], "down"); err != nil {
+		t.Errorf("Expected the lobby to take moves again, got %v", err)
+	}
+}
+
+func TestCrashAfterPrepareBlocksRoomsUntilRecovery(t *testing.T) {
+	coordinator, controllers := newTestCoordinator()
+
+	result, err := coordinator.Run(bothRooms, FaultAfterPrepare, 0)
+	if err != nil || result.Outcome != OutcomeInDoubt {
+		t.Fatalf("Expected the transaction in doubt, got %+v, %v", result, err)
+	}
+
+	// Both rooms promised their object and can only wait
+	if err := playerMove(controllers["lobby"], "down"); !errors.Is(err, concurrency.ErrInDoubt) {
+		t.Errorf("Expected the lobby blocked, got %v", err)
+	}
+	status := coordinator.Status()
+	if status.Up || len(status.Participants) != 2 {
+		t.Fatalf("Expected the coordinator down with two rooms, got %+v", status)
+	}
+	for _, participant := range status.Participants {
+		if participant.Prepared == nil || participant.Prepared.TransactionID != result.TransactionID {
+			t.Errorf("Expected %s held by %s, got %+v", participant.Room, result.TransactionID, participant.Prepared)
+		}
+	}
+	if status.Participants[0].Prepared.Blocked != 1 {
+		t.Errorf("Expected one blocked lobby move, got %+v", status.Participants[0].Prepared)
+	}
+	if _, err := coordinator.Run(bothRooms, FaultNone, 0); !errors.Is(err, ErrCoordinatorDown) {
+		t.Errorf("Expected a crashed coordinator to refuse transactions, got %v", err)
+	}
+
+	// Without a logged decision, recovery aborts and releases the rooms
+	coordinator.Recover()
+	status = coordinator.Status()
+	if kinds := logKinds(status, result.TransactionID); len(kinds) != 5 || kinds[3] != KindAbort || kinds[4] != KindEnd {
+		t.Errorf("Expected recovery to log an abort and the end, got %v", kinds)
+	}
+	for room, controller := range controllers {
+		if object := controller.Object(); object.Version != 1 {
+			t.Errorf("Expected %s untouched, got %+v", room, object)
+		}
+		if err := playerMove(controller, "down"); err != nil {
+			t.Errorf("Expected %s to take moves after recovery, got %v", room, err)
+		}
+	}
+}
+
+func TestRecoveryFinishesLoggedDecision(t *testing.T) {
+	for _, fault := range []Fault{FaultAfterDecision, FaultMidCommit} {
+		t.Run(string(fault), func(t *testing.T) {
+			coordinator, controllers := newTestCoordinator()
+
+			result, err := coordinator.Run(bothRooms, fault, 0)
+			if err != nil || result.Outcome != OutcomeInDoubt {
+				t.Fatalf("Expected the transaction in doubt, got %+v, %v", result, err)
+			}
+
+			// The arena never heard the decision and stays blocked
+			if err := playerMove(controllers["arena"], "down"); !errors.Is(err, concurrency.ErrInDoubt) {
+				t.Errorf("Expected the arena blocked, got %v", err)
+			}
+			if _, told := controllers["lobby"].PreparedTransaction(); told == (fault == FaultMidCommit) {
+				t.Errorf("Expected the lobby told the decision only mid-commit")
+			}
+
+			// The commit was logged, so recovery carries it out everywhere
+			coordinator.Recover()
+			if object := controllers["lobby"].Object(); object.Position != (models.Position{X: 5, Y: 4}) {
+				t.Errorf("Expected the lobby object moved up, got %+v", object)
+			}
+			if object := controllers["arena"].Object(); object.Position != (models.Position{X: 4, Y: 5}) {
+				t.Errorf("Expected the arena object moved left, got %+v", object)
+			}
+			if kinds := logKinds(coordinator.Status(), result.TransactionID); kinds[len(kinds)-1] != KindEnd {
+				t.Errorf("Expected the transaction ended by recovery, got %v", kinds)
+			}
+		})
+	}
+}
+
+func TestCoordinatorRestartsAfterDowntime(t *testing.T) {
+	coordinator, controllers := newTestCoordinator()
+
+	if _, err := coordinator.Run(bothRooms, FaultAfterPrepare, 50*time.Millisecond); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if status := coordinator.Status(); status.Up || status.DownUntil == nil {
+		t.Fatalf("Expected the coordinator down for a while, got %+v", status)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		_, held := controllers["arena"].PreparedTransaction()
+		if coordinator.Status().Up && !held {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("The coordinator never recovered and released the rooms")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
@@ -0,0 +1,325 @@
+package websocket
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/AnishMulay/Transaction-Conflict-Visualization/internal/backplane"
+	"github.com/AnishMulay/Transaction-Conflict-Visualization/internal/concurrency"
+	"github.com/AnishMulay/Transaction-Conflict-Visualization/pkg/models"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// roomCommitTimeout bounds how long a commit waits to come back over the
+	// backplane and be applied
+	roomCommitTimeout = 3 * time.Second
+
+	// roomSyncTimeout is how long a hub joining a room waits for another
+	// instance's state before assuming it is the first one
+	roomSyncTimeout = 500 * time.Millisecond
+)
+
+// roomEventKind says what a roomEvent carries
+type roomEventKind string
+
+const (
+	roomEventCommit        roomEventKind = "commit"
+	roomEventPlayer        roomEventKind = "player"
+	roomEventPlayerRemoved roomEventKind = "playerRemoved"
+	roomEventSync          roomEventKind = "sync"
+	roomEventState         roomEventKind = "state"
+)
+
+// roomEvent is what hubs sharing a room publish on the backplane. A sync
+// event asks the other instances for their state, which they answer with a
+// state event addressed To the asker.
+type roomEvent struct {
+	Kind     roomEventKind                 `json:"kind"`
+	Instance string                        `json:"instance"`
+	CommitID uint64                        `json:"commitId,omitempty"`
+	To       string                        `json:"to,omitempty"`
+	Commit   *concurrency.ReplicatedCommit `json:"commit,omitempty"`
+	Object   *models.GameObject            `json:"object,omitempty"`
+	Players  []models.Player               `json:"players,omitempty"`
+	PlayerID string                        `json:"playerId,omitempty"`
+}
+
+// commitResult is what applying one of this instance's commits produced
+type commitResult struct {
+	record concurrency.CommitRecord
+	err    error
+}
+
+// sharedRoom connects a hub to the other instances serving the same room.
+//
+// Commits are published on the room's channel and applied by every instance,
+// this one included, in the order the backplane delivers them. Every replica
+// runs the same version checks in the same order, so they agree on which
+// moves win. The committing instance answers its mover once its own copy
+// comes back. Player joins, changes and removals are published for the other
+// instances to mirror.
+//
+// A hub joining a room that is already in use asks for the others' state. It
+// buffers commits ordered after its request until the first answer, installs
+// the object from it and then applies the buffered commits.
+type sharedRoom struct {
+	hub      *Hub
+	bus      backplane.Backplane
+	channel  string
+	instance string
+	outbox   chan roomEvent
+
+	// publishMu makes a state answer and this instance's player events
+	// reach the backplane in the order their changes were made
+	publishMu sync.Mutex
+
+	mu       sync.Mutex
+	nextID   uint64
+	pending  map[uint64]chan commitResult
+	remote   map[string]bool
+	syncing  bool
+	syncSeen bool
+	buffered []roomEvent
+}
+
+// UseBackplane shares room with every hub subscribed to it on bus: moves are
+// committed through the backplane and players of every instance appear in
+// the room. Call it before serving clients. It replaces any replicator the
+// controller had.
+func (h *Hub) UseBackplane(bus backplane.Backplane, room string) error {
+	shared := &sharedRoom{
+		hub:      h,
+		bus:      bus,
+		channel:  "room:" + room,
+		instance: uuid.New().String(),
+		outbox:   make(chan roomEvent, 256),
+		pending:  make(map[uint64]chan commitResult),
+		remote:   make(map[string]bool),
+		syncing:  true,
+	}
+
+	subscription, err := bus.Subscribe(shared.channel, shared.receive)
+	if err != nil {
+		return err
+	}
+
+	if err := shared.publish(roomEvent{Kind: roomEventSync}); err != nil {
+		subscription.Close()
+		return err
+	}
+
+	h.room = shared
+	h.concurrencyController.SetReplicator(shared)
+	go shared.send()
+	time.AfterFunc(roomSyncTimeout, shared.endSync)
+	return nil
+}
+
+// Replicate implements concurrency.Replicator
+func (r *sharedRoom) Replicate(commit concurrency.ReplicatedCommit) (concurrency.CommitRecord, error) {
+	r.mu.Lock()
+	r.nextID++
+	id := r.nextID
+	done := make(chan commitResult, 1)
+	r.pending[id] = done
+	r.mu.Unlock()
+
+	defer func() {
+		r.mu.Lock()
+		delete(r.pending, id)
+		r.mu.Unlock()
+	}()
+
+	if err := r.publish(roomEvent{Kind: roomEventCommit, CommitID: id, Commit: &commit}); err != nil {
+		return concurrency.CommitRecord{}, fmt.Errorf("%w: %v", concurrency.ErrUnavailable, err)
+	}
+
+	select {
+	case result := <-done:
+		return result.record, result.err
+	case <-time.After(roomCommitTimeout):
+		return concurrency.CommitRecord{}, fmt.Errorf("%w: commit not delivered within %s", concurrency.ErrUnavailable, roomCommitTimeout)
+	}
+}
+
+// publish sends an event from this instance
+func (r *sharedRoom) publish(event roomEvent) error {
+	r.publishMu.Lock()
+	defer r.publishMu.Unlock()
+	return r.publishLocked(event)
+}
+
+func (r *sharedRoom) publishLocked(event roomEvent) error {
+	event.Instance = r.instance
+	message, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	return r.bus.Publish(r.channel, message)
+}
+
+// send publishes player events queued by the hub
+func (r *sharedRoom) send() {
+	for event := range r.outbox {
+		if err := r.publish(event); err != nil {
+			log.Printf("Failed to publish %s event to %s: %v", event.Kind, r.channel, err)
+		}
+	}
+}
+
+// receive handles the room's events in backplane order
+func (r *sharedRoom) receive(message []byte) {
+	var event roomEvent
+	if err := json.Unmarshal(message, &event); err != nil {
+		log.Printf("Skipping undecodable event on %s: %v", r.channel, err)
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	switch event.Kind {
+	case roomEventCommit:
+		if event.Commit == nil {
+			return
+		}
+		// The state we are waiting for already includes commits ordered
+		// before our request, but not these
+		if r.syncing && r.syncSeen {
+			r.buffered = append(r.buffered, event)
+			return
+		}
+		r.applyCommit(event)
+
+	case roomEventSync:
+		if event.Instance == r.instance {
+			r.syncSeen = true
+			return
+		}
+		r.answerSync(event.Instance)
+
+	case roomEventState:
+		if event.To != r.instance {
+			return
+		}
+		r.addPlayers(event.Players)
+		if event.Object != nil && r.syncing {
+			r.hub.concurrencyController.InstallObject(*event.Object)
+			r.endSyncLocked()
+			r.hub.broadcastGameState()
+		}
+
+	case roomEventPlayer:
+		if event.Instance != r.instance {
+			r.addPlayers(event.Players)
+		}
+
+	case roomEventPlayerRemoved:
+		if event.Instance != r.instance {
+			r.removePlayer(event.PlayerID)
+		}
+	}
+}
+
+// applyCommit runs a commit through the controller and answers the mover if
+// it is ours. Must hold r.mu.
+func (r *sharedRoom) applyCommit(event roomEvent) {
+	record, err := r.hub.concurrencyController.ApplyCommit(*event.Commit)
+	if err == nil {
+		r.hub.broadcastGameState()
+	}
+
+	if event.Instance == r.instance {
+		if done, ok := r.pending[event.CommitID]; ok {
+			done <- commitResult{record: record, err: err}
+		}
+	}
+}
+
+// answerSync sends a joining instance our object and local players. An
+// instance still syncing itself has no object worth installing. Must hold
+// r.mu.
+func (r *sharedRoom) answerSync(to string) {
+	r.publishMu.Lock()
+	defer r.publishMu.Unlock()
+
+	snapshot := r.hub.gameState.GetState()
+	event := roomEvent{Kind: roomEventState, To: to}
+	if !r.syncing {
+		event.Object = snapshot.Object
+	}
+	for id, player := range snapshot.Players {
+		if !r.remote[id] {
+			event.Players = append(event.Players, *player)
+		}
+	}
+
+	if err := r.publishLocked(event); err != nil {
+		log.Printf("Failed to send room state to %s: %v", to, err)
+	}
+}
+
+// endSync stops waiting for state once roomSyncTimeout has passed
+func (r *sharedRoom) endSync() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.syncing {
+		r.endSyncLocked()
+	}
+}
+
+// endSyncLocked applies the commits buffered while syncing. Must hold r.mu.
+func (r *sharedRoom) endSyncLocked() {
+	r.syncing = false
+	for _, event := range r.buffered {
+		r.applyCommit(event)
+	}
+	r.buffered = nil
+}
+
+// addPlayers mirrors players of other instances. Must hold r.mu.
+func (r *sharedRoom) addPlayers(players []models.Player) {
+	if len(players) == 0 {
+		return
+	}
+
+	r.hub.gameState.Mu.Lock()
+	for _, player := range players {
+		player := player
+		r.hub.gameState.Players[player.ID] = &player
+		r.remote[player.ID] = true
+	}
+	r.hub.gameState.Mu.Unlock()
+
+	r.hub.broadcastGameState()
+}
+
+// removePlayer drops a player another instance removed. Must hold r.mu.
+func (r *sharedRoom) removePlayer(playerID string) {
+	r.hub.gameState.Mu.Lock()
+	delete(r.hub.gameState.Players, playerID)
+	r.hub.gameState.Mu.Unlock()
+
+	delete(r.remote, playerID)
+	r.hub.broadcastGameState()
+}
+
+// announcePlayer tells the other instances sharing the room about a local
+// player's current state
+func (h *Hub) announcePlayer(player models.Player) {
+	if h.room != nil {
+		h.room.outbox <- roomEvent{Kind: roomEventPlayer, Players: []models.Player{player}}
+	}
+}
+
+// announcePlayerRemoved tells the other instances that a local player left
+func (h *Hub) announcePlayerRemoved(playerID string) {
+	if h.room != nil {
+		h.room.outbox <- roomEvent{Kind: roomEventPlayerRemoved, PlayerID: playerID}
+	}
+}
@@ -0,0 +1,181 @@
+package websocket
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/AnishMulay/Transaction-Conflict-Visualization/internal/backplane"
+	"github.com/AnishMulay/Transaction-Conflict-Visualization/internal/concurrency"
+	"github.com/AnishMulay/Transaction-Conflict-Visualization/pkg/models"
+
+	"github.com/gorilla/websocket"
+)
+
+// sharedHub is one server instance of a room shared over a backplane
+type sharedHub struct {
+	gameState  *models.GameState
+	controller *concurrency.ConcurrencyController
+	url        string
+}
+
+func newSharedHub(t *testing.T, bus backplane.Backplane) *sharedHub {
+	t.Helper()
+	gameState := models.NewGameState(models.Position{X: 10, Y: 10})
+	controller := concurrency.NewConcurrencyController(gameState)
+	hub := NewHub(gameState, controller)
+	if err := hub.UseBackplane(bus, "test"); err != nil {
+		t.Fatalf("UseBackplane failed: %v", err)
+	}
+	go hub.Run()
+
+	server := httptest.NewServer(http.HandlerFunc(hub.ServeWS))
+	t.Cleanup(server.Close)
+	return &sharedHub{gameState: gameState, controller: controller, url: "ws" + strings.TrimPrefix(server.URL, "http")}
+}
+
+// join connects a player and returns the connection after the join snapshot
+func (s *sharedHub) join(t *testing.T, name string) *websocket.Conn {
+	t.Helper()
+	conn, _, err := websocket.DefaultDialer.Dial(s.url, nil)
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	var message models.WebSocketMessage
+	conn.ReadJSON(&message) // initial snapshot
+	conn.WriteJSON(models.WebSocketMessage{
+		Type:      models.MessageTypeJoin,
+		Data:      models.JoinRequest{PlayerName: name},
+		Timestamp: time.Now(),
+	})
+	conn.ReadJSON(&message) // join snapshot
+	return conn
+}
+
+// move runs a move as a transaction read at version
+func (s *sharedHub) move(version int64, direction string) error {
+	transaction, _ := s.controller.BeginTransactionAt("player", direction, version)
+	if err := s.controller.ProposeMove(transaction.ID, direction); err != nil {
+		return err
+	}
+	_, err := s.controller.CommitTransaction(transaction.ID)
+	return err
+}
+
+// waitForRoom polls until every hub's object is at version and position and
+// it sees players players
+func waitForRoom(t *testing.T, hubs []*sharedHub, version int64, position models.Position, players int) {
+	t.Helper()
+	deadline := time.Now().Add(3 * time.Second)
+	for i, hub := range hubs {
+		for {
+			snapshot := hub.gameState.GetState()
+			if snapshot.Object.Version == version && snapshot.Object.Position == position && len(snapshot.Players) == players {
+				break
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("Hub %d has %+v with %d players, expected version %d at %+v with %d",
+					i, snapshot.Object, len(snapshot.Players), version, position, players)
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+	}
+}
+
+func TestHubsShareRoomOverBackplane(t *testing.T) {
+	bus := backplane.NewMemory()
+	defer bus.Close()
+	hubs := []*sharedHub{newSharedHub(t, bus), newSharedHub(t, bus)}
+
+	alice := hubs[0].join(t, "Alice")
+	bob := hubs[1].join(t, "Bob")
+	waitForRoom(t, hubs, 1, models.Position{X: 5, Y: 5}, 2)
+
+	// A move on one instance reaches the other instance's clients
+	alice.WriteJSON(models.WebSocketMessage{
+		Type:      models.MessageTypeMove,
+		Data:      models.MoveRequest{Direction: "right", RequestID: "shared-1"},
+		Timestamp: time.Now(),
+	})
+	bob.SetReadDeadline(time.Now().Add(3 * time.Second))
+	for {
+		var delta struct {
+			Type models.MessageType    `json:"type"`
+			Data models.GameStateDelta `json:"data"`
+		}
+		if err := bob.ReadJSON(&delta); err != nil {
+			t.Fatalf("Bob never saw Alice's move: %v", err)
+		}
+		if delta.Type == models.MessageTypeDelta && delta.Data.Object != nil {
+			if delta.Data.Object.Version != 2 || delta.Data.Object.Position != (models.Position{X: 6, Y: 5}) {
+				t.Errorf("Unexpected object in Bob's delta %+v", delta.Data.Object)
+			}
+			break
+		}
+	}
+	waitForRoom(t, hubs, 2, models.Position{X: 6, Y: 5}, 2)
+
+	// Racing moves on both instances: the backplane orders them and every
+	// replica lets the same one win
+	var wg sync.WaitGroup
+	errs := make([]error, len(hubs))
+	for i, hub := range hubs {
+		wg.Add(1)
+		go func(i int, hub *sharedHub) {
+			defer wg.Done()
+			errs[i] = hub.move(2, []string{"up", "down"}[i])
+		}(i, hub)
+	}
+	wg.Wait()
+
+	winners := 0
+	var winner models.Position
+	for i, err := range errs {
+		var conflict *concurrency.ConflictError
+		switch {
+		case err == nil:
+			winners++
+			winner = []models.Position{{X: 6, Y: 4}, {X: 6, Y: 6}}[i]
+		case errors.As(err, &conflict):
+			if conflict.Winner == nil {
+				t.Errorf("Expected the conflict to name the winner")
+			}
+		default:
+			t.Errorf("Unexpected error %v", err)
+		}
+	}
+	if winners != 1 {
+		t.Fatalf("Expected exactly one winner, got %v", errs)
+	}
+	waitForRoom(t, hubs, 3, winner, 2)
+}
+
+func TestLateHubCatchesUp(t *testing.T) {
+	bus := backplane.NewMemory()
+	defer bus.Close()
+	first := newSharedHub(t, bus)
+	first.join(t, "Alice")
+
+	for version, direction := range []string{"left", "left"} {
+		if err := first.move(int64(version+1), direction); err != nil {
+			t.Fatalf("Move failed: %v", err)
+		}
+	}
+
+	// A hub started later gets the object and players from the first
+	late := newSharedHub(t, bus)
+	hubs := []*sharedHub{first, late}
+	waitForRoom(t, hubs, 3, models.Position{X: 3, Y: 5}, 1)
+
+	late.join(t, "Bob")
+	if err := late.move(3, "up"); err != nil {
+		t.Fatalf("Move on the late hub failed: %v", err)
+	}
+	waitForRoom(t, hubs, 4, models.Position{X: 3, Y: 4}, 2)
+}
@@ -0,0 +1,118 @@
+package websocket
+
+import (
+	"sync"
+	"time"
+
+	"github.com/AnishMulay/Transaction-Conflict-Visualization/pkg/models"
+)
+
+// Dedupe window limits. A RequestID is remembered until either limit is hit.
+const (
+	dedupeWindowSize = 256
+	dedupeWindowTTL  = 2 * time.Minute
+)
+
+// moveOutcome is the response originally sent for a move
+type moveOutcome struct {
+	messageType models.MessageType
+	data        interface{}
+	recordedAt  time.Time
+}
+
+// replay returns the original response marked as a duplicate
+func (o moveOutcome) replay() (models.MessageType, interface{}) {
+	switch data := o.data.(type) {
+	case models.MoveAck:
+		data.Duplicate = true
+		return o.messageType, data
+	case models.ConflictResponse:
+		data.Duplicate = true
+		return o.messageType, data
+	}
+	return o.messageType, o.data
+}
+
+// playerOutcomes is one player's dedupe window, oldest request first
+type playerOutcomes struct {
+	order    []string
+	outcomes map[string]moveOutcome
+}
+
+// moveOutcomeCache remembers the outcome of each player's recent moves by
+// RequestID. A resent move is answered from the cache and never starts a
+// second transaction, which turns the at-least-once WebSocket transport into
+// exactly-once effects on the game state.
+type moveOutcomeCache struct {
+	mu      sync.Mutex
+	size    int
+	ttl     time.Duration
+	players map[string]*playerOutcomes
+}
+
+func newMoveOutcomeCache(size int, ttl time.Duration) *moveOutcomeCache {
+	return &moveOutcomeCache{
+		size:    size,
+		ttl:     ttl,
+		players: make(map[string]*playerOutcomes),
+	}
+}
+
+// lookup returns the recorded outcome of a request, if it is still in the window
+func (m *moveOutcomeCache) lookup(playerID, requestID string) (moveOutcome, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	window, ok := m.players[playerID]
+	if !ok {
+		return moveOutcome{}, false
+	}
+
+	m.expire(window, time.Now())
+	outcome, ok := window.outcomes[requestID]
+	return outcome, ok
+}
+
+// record stores the outcome of a request, evicting the oldest entries beyond the window
+func (m *moveOutcomeCache) record(playerID, requestID string, messageType models.MessageType, data interface{}) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	window, ok := m.players[playerID]
+	if !ok {
+		window = &playerOutcomes{outcomes: make(map[string]moveOutcome)}
+		m.players[playerID] = window
+	}
+
+	now := time.Now()
+	m.expire(window, now)
+
+	if _, exists := window.outcomes[requestID]; !exists {
+		window.order = append(window.order, requestID)
+	}
+	window.outcomes[requestID] = moveOutcome{messageType: messageType, data: data, recordedAt: now}
+
+	for len(window.order) > m.size {
+		delete(window.outcomes, window.order[0])
+		window.order = window.order[1:]
+	}
+}
+
+// forget drops a player's window once the player is gone
+func (m *moveOutcomeCache) forget(playerID string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.players, playerID)
+}
+
+// expire drops entries older than the TTL. Caller must hold m.mu.
+func (m *moveOutcomeCache) expire(window *playerOutcomes, now time.Time) {
+	for len(window.order) > 0 {
+		oldest := window.order[0]
+		if now.Sub(window.outcomes[oldest].recordedAt) < m.ttl {
+			return
+		}
+		delete(window.outcomes, oldest)
+		window.order = window.order[1:]
+	}
+}
@@ -0,0 +1,59 @@
+package websocket
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/AnishMulay/Transaction-Conflict-Visualization/pkg/models"
+)
+
+func TestMoveOutcomeCacheWindow(t *testing.T) {
+	cache := newMoveOutcomeCache(3, time.Minute)
+
+	for i := 0; i < 5; i++ {
+		requestID := fmt.Sprintf("req-%d", i)
+		cache.record("player", requestID, models.MessageTypeMoveAck, models.MoveAck{RequestID: requestID})
+	}
+
+	for i := 0; i < 2; i++ {
+		if _, ok := cache.lookup("player", fmt.Sprintf("req-%d", i)); ok {
+			t.Errorf("req-%d should have been evicted", i)
+		}
+	}
+
+	outcome, ok := cache.lookup("player", "req-4")
+	if !ok {
+		t.Fatal("req-4 should still be in the window")
+	}
+
+	messageType, data := outcome.replay()
+	ack, isAck := data.(models.MoveAck)
+	if messageType != models.MessageTypeMoveAck || !isAck || !ack.Duplicate {
+		t.Errorf("Expected replayed duplicate moveAck, got %s %+v", messageType, data)
+	}
+
+	if _, ok := cache.lookup("other", "req-4"); ok {
+		t.Error("Windows must be per player")
+	}
+
+	cache.forget("player")
+	if _, ok := cache.lookup("player", "req-4"); ok {
+		t.Error("Forgotten player should have no outcomes")
+	}
+}
+
+func TestMoveOutcomeCacheTTL(t *testing.T) {
+	cache := newMoveOutcomeCache(10, 20*time.Millisecond)
+	cache.record("player", "req", models.MessageTypeConflict, models.ConflictResponse{RequestID: "req"})
+
+	if _, ok := cache.lookup("player", "req"); !ok {
+		t.Fatal("Outcome should be cached")
+	}
+
+	time.Sleep(30 * time.Millisecond)
+
+	if _, ok := cache.lookup("player", "req"); ok {
+		t.Error("Outcome should have expired")
+	}
+}
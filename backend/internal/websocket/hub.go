@@ -1,14 +1,17 @@
 package websocket
 
 import (
-	"encoding/json"
+	"errors"
+	"fmt"
 	"log"
 	"net/http"
-	"sync"
 	"time"
 
+	"github.com/AnishMulay/Transaction-Conflict-Visualization/internal/causality"
 	"github.com/AnishMulay/Transaction-Conflict-Visualization/internal/concurrency"
+	"github.com/AnishMulay/Transaction-Conflict-Visualization/internal/ratelimit"
 	"github.com/AnishMulay/Transaction-Conflict-Visualization/pkg/models"
+	"github.com/AnishMulay/Transaction-Conflict-Visualization/pkg/protocol"
 
 	"github.com/google/uuid"
 	"github.com/gorilla/websocket"
@@ -20,45 +23,205 @@ var upgrader = websocket.Upgrader{
 	},
 	ReadBufferSize:  1024,
 	WriteBufferSize: 1024,
+	Subprotocols:    protocol.Subprotocols(),
 }
 
-// Hub maintains active WebSocket connections and coordinates message distribution
+// Hub maintains active WebSocket connections and coordinates message distribution.
+// The goroutine started by Run is the single owner of clients, playerClients and
+// every send channel; other goroutines reach it through the hub's channels. State
+// changes reach clients as sequence-numbered deltas against lastSnapshot.
 type Hub struct {
 	clients               map[*Client]bool
 	playerClients         map[string]*Client
-	broadcast             chan []byte
+	broadcast             chan models.WebSocketMessage
+	unicast               chan unicastMessage
 	register              chan *Client
 	unregister            chan *Client
+	commands              chan func()
+	stateChanged          chan struct{}
+	locksChanged          chan struct{}
+	clusterChanged        chan struct{}
+	clusterStatus         func() models.RaftStatus
+	replicaChanged        chan struct{}
+	replicaStatus         func() models.ReplicaStatus
+	networkChanged        chan struct{}
+	networkStatus         func() models.NetworkStatus
+	clientLink            func() bool
+	coordinatorChanged    chan struct{}
+	coordinatorStatus     func() models.CoordinatorStatus
+	ticks                 *tickLoop
+	match                 *matchLoop
+	causality             *causality.Tracker
+	process               string
+	room                  *sharedRoom
 	gameState             *models.GameState
 	concurrencyController *concurrency.ConcurrencyController
-	mu                    sync.RWMutex
+	moveOutcomes          *moveOutcomeCache
+	rateLimits            RateLimits
+	playerMoveLimits      *ratelimit.Keyed
+	seq                   int64
+	lastSnapshot          models.GameStateSnapshot
+}
+
+// RateLimits configures flood protection. PerConnection applies to every
+// message a connection sends; PerPlayer applies to moves.
+type RateLimits struct {
+	PerConnection ratelimit.Limit
+	PerPlayer     ratelimit.Limit
 }
 
 // Client represents a WebSocket client connection
 type Client struct {
 	hub      *Hub
 	conn     *websocket.Conn
+	codec    protocol.Codec
 	send     chan []byte
+	limiter  *ratelimit.Bucket
 	playerID string
 	player   *models.Player
-	mu       sync.RWMutex
+}
+
+// unicastMessage is a message addressed to a single client
+type unicastMessage struct {
+	client *Client
+	data   []byte
+}
+
+// newMessage wraps data in a versioned envelope
+func newMessage(messageType models.MessageType, data interface{}) models.WebSocketMessage {
+	return models.WebSocketMessage{
+		Type:            messageType,
+		ProtocolVersion: models.ProtocolVersion,
+		Data:            data,
+		Timestamp:       time.Now(),
+	}
+}
+
+// frameCache encodes a broadcast message at most once per codec in use
+type frameCache struct {
+	message models.WebSocketMessage
+	frames  map[string][]byte
+}
+
+func newFrameCache(message models.WebSocketMessage) *frameCache {
+	return &frameCache{message: message, frames: make(map[string][]byte)}
+}
+
+func (f *frameCache) encode(codec protocol.Codec) ([]byte, bool) {
+	if data, ok := f.frames[codec.Subprotocol()]; ok {
+		return data, data != nil
+	}
+
+	data, err := codec.Marshal(f.message)
+	if err != nil {
+		log.Printf("Failed to encode %s message as %s: %v", f.message.Type, codec.Subprotocol(), err)
+	}
+	f.frames[codec.Subprotocol()] = data
+	return data, data != nil
 }
 
 // NewHub creates a new WebSocket hub
 func NewHub(gameState *models.GameState, controller *concurrency.ConcurrencyController) *Hub {
-	return &Hub{
+	hub := &Hub{
 		clients:               make(map[*Client]bool),
 		playerClients:         make(map[string]*Client),
-		broadcast:             make(chan []byte, 256),
+		broadcast:             make(chan models.WebSocketMessage, 256),
+		unicast:               make(chan unicastMessage, 256),
 		register:              make(chan *Client),
 		unregister:            make(chan *Client),
+		commands:              make(chan func()),
+		stateChanged:          make(chan struct{}, 1),
 		gameState:             gameState,
 		concurrencyController: controller,
+		moveOutcomes:          newMoveOutcomeCache(dedupeWindowSize, dedupeWindowTTL),
+		playerMoveLimits:      ratelimit.NewKeyed(ratelimit.Unlimited),
+		lastSnapshot:          gameState.GetState(),
+		locksChanged:          make(chan struct{}, 1),
+		clusterChanged:        make(chan struct{}, 1),
+		replicaChanged:        make(chan struct{}, 1),
+		networkChanged:        make(chan struct{}, 1),
+		coordinatorChanged:    make(chan struct{}, 1),
+	}
+
+	controller.OnLocksChanged(hub.broadcastLocks)
+	controller.OnAnomaly(hub.broadcastAnomaly)
+	return hub
+}
+
+// SetRateLimits configures flood protection. Call it before serving clients.
+func (h *Hub) SetRateLimits(limits RateLimits) {
+	h.rateLimits = limits
+	h.playerMoveLimits = ratelimit.NewKeyed(limits.PerPlayer)
+}
+
+// SetClusterStatus enables raftStatus messages in replicated mode, built from
+// status. Call it before serving clients.
+func (h *Hub) SetClusterStatus(status func() models.RaftStatus) {
+	h.clusterStatus = status
+}
+
+// SetReplicaStatus enables replicaStatus messages in eventually consistent
+// mode, built from status. Call it before serving clients.
+func (h *Hub) SetReplicaStatus(status func() models.ReplicaStatus) {
+	h.replicaStatus = status
+}
+
+// SetNetworkStatus enables networkStatus messages, built from status, and
+// makes the hub's clients reachable only while clientLink reports true.
+// While the link is cut joins and moves are refused and state changes are
+// held back, to be published as one delta when it heals; status messages
+// still flow. Call it before serving clients.
+func (h *Hub) SetNetworkStatus(status func() models.NetworkStatus, clientLink func() bool) {
+	h.networkStatus = status
+	h.clientLink = clientLink
+}
+
+// SetCoordinatorStatus enables coordinatorStatus messages when the server
+// hosts several rooms, built from status. Call it before serving clients.
+func (h *Hub) SetCoordinatorStatus(status func() models.CoordinatorStatus) {
+	h.coordinatorStatus = status
+}
+
+// SetCausality records this hub's room as process on tracker: the hub
+// stamps its messages and records moves, and the hub's controller records
+// its transactions. Call it before serving clients.
+func (h *Hub) SetCausality(tracker *causality.Tracker, process string) {
+	h.causality = tracker
+	h.process = process
+	h.concurrencyController.SetCausality(tracker, process)
+}
+
+// stamp gives message this room's logical time. With an event the message
+// is recorded as a send to peer; otherwise it carries the current time.
+// Safe to call from any goroutine.
+func (h *Hub) stamp(message *models.WebSocketMessage, peer string, event *causality.Event) {
+	if h.causality == nil {
+		return
 	}
+	var stamp causality.Stamp
+	if event != nil {
+		stamp = h.causality.Send(h.process, peer, *event)
+	} else {
+		stamp = h.causality.Now(h.process)
+	}
+	message.Lamport, message.Clock = stamp.Lamport, stamp.Clock
+}
+
+// clientsReachable reports whether the simulated link to this hub's clients
+// is up
+func (h *Hub) clientsReachable() bool {
+	return h.clientLink == nil || h.clientLink()
 }
 
 // Run starts the hub's main event loop
 func (h *Hub) Run() {
+	if h.ticks != nil {
+		go h.runTicks()
+	}
+	if h.match != nil {
+		go h.runMatch()
+	}
+
 	for {
 		select {
 		case client := <-h.register:
@@ -68,11 +231,65 @@ func (h *Hub) Run() {
 			h.unregisterClient(client)
 
 		case message := <-h.broadcast:
-			h.broadcastMessage(message)
+			h.broadcastMessage(message, nil)
+
+		case message := <-h.unicast:
+			h.deliver(message.client, message.data)
+
+		case command := <-h.commands:
+			command()
+
+		case <-h.stateChanged:
+			// In tick mode changes wait for the next tick
+			if h.ticks == nil {
+				h.publishState(nil)
+			}
+
+		case <-h.locksChanged:
+			h.broadcastMessage(newMessage(models.MessageTypeWaitForGraph, h.concurrencyController.WaitForGraph()), nil)
+
+		case <-h.clusterChanged:
+			if h.clusterStatus != nil {
+				h.broadcastMessage(newMessage(models.MessageTypeRaftStatus, h.clusterStatus()), nil)
+			}
+
+		case <-h.replicaChanged:
+			if h.replicaStatus != nil {
+				h.broadcastMessage(newMessage(models.MessageTypeReplicaStatus, h.replicaStatus()), nil)
+			}
+
+		case <-h.networkChanged:
+			if h.networkStatus != nil {
+				h.broadcastMessage(newMessage(models.MessageTypeNetworkStatus, h.networkStatus()), nil)
+			}
+			// Changes held back while the client link was cut go out now
+			h.publishState(nil)
+
+		case <-h.coordinatorChanged:
+			if h.coordinatorStatus != nil {
+				h.broadcastMessage(newMessage(models.MessageTypeCoordinatorStatus, h.coordinatorStatus()), nil)
+			}
 		}
 	}
 }
 
+// do runs fn on the hub goroutine and waits for it to finish
+func (h *Hub) do(fn func()) {
+	done := make(chan struct{})
+	h.commands <- func() {
+		defer close(done)
+		fn()
+	}
+	<-done
+}
+
+// ClientCount returns the number of connected clients
+func (h *Hub) ClientCount() int {
+	var count int
+	h.do(func() { count = len(h.clients) })
+	return count
+}
+
 // ServeWS handles WebSocket upgrade requests
 func (h *Hub) ServeWS(w http.ResponseWriter, r *http.Request) {
 	conn, err := upgrader.Upgrade(w, r, nil)
@@ -82,9 +299,11 @@ func (h *Hub) ServeWS(w http.ResponseWriter, r *http.Request) {
 	}
 
 	client := &Client{
-		hub:  h,
-		conn: conn,
-		send: make(chan []byte, 256),
+		hub:     h,
+		conn:    conn,
+		codec:   protocol.ForSubprotocol(conn.Subprotocol()),
+		send:    make(chan []byte, 256),
+		limiter: ratelimit.NewBucket(h.rateLimits.PerConnection),
 	}
 
 	h.register <- client
@@ -95,101 +314,341 @@ func (h *Hub) ServeWS(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *Hub) registerClient(client *Client) {
-	h.mu.Lock()
-	defer h.mu.Unlock()
-
 	h.clients[client] = true
 	log.Printf("Client connected. Total clients: %d", len(h.clients))
 
 	// Send current game state to new client
-	snapshot := h.gameState.GetState()
-	h.sendToClient(client, models.WebSocketMessage{
-		Type:      models.MessageTypeGameState,
-		Data:      snapshot,
-		Timestamp: time.Now(),
-	})
-}
+	h.sendSnapshot(client)
 
-func (h *Hub) unregisterClient(client *Client) {
-	h.mu.Lock()
-	defer h.mu.Unlock()
+	if h.concurrencyController.LockingStrategy().Locking() {
+		h.queueFor(client, newMessage(models.MessageTypeWaitForGraph, h.concurrencyController.WaitForGraph()))
+	}
 
-	if _, ok := h.clients[client]; ok {
-		delete(h.clients, client)
-		close(client.send)
+	if h.clusterStatus != nil {
+		h.queueFor(client, newMessage(models.MessageTypeRaftStatus, h.clusterStatus()))
+	}
 
-		if client.playerID != "" {
-			delete(h.playerClients, client.playerID)
-			h.removePlayer(client.playerID)
-		}
+	if h.replicaStatus != nil {
+		h.queueFor(client, newMessage(models.MessageTypeReplicaStatus, h.replicaStatus()))
+	}
 
-		log.Printf("Client disconnected. Total clients: %d", len(h.clients))
+	if h.networkStatus != nil {
+		h.queueFor(client, newMessage(models.MessageTypeNetworkStatus, h.networkStatus()))
+	}
+
+	if h.coordinatorStatus != nil {
+		h.queueFor(client, newMessage(models.MessageTypeCoordinatorStatus, h.coordinatorStatus()))
 	}
 }
 
-func (h *Hub) broadcastMessage(message []byte) {
-	h.mu.RLock()
-	defer h.mu.RUnlock()
+func (h *Hub) unregisterClient(client *Client) {
+	if _, ok := h.clients[client]; !ok {
+		return
+	}
+
+	delete(h.clients, client)
+	close(client.send)
+
+	if client.playerID != "" {
+		delete(h.playerClients, client.playerID)
+		h.removePlayer(client.playerID)
+		// Deferred rather than published inline: unregisterClient can run in
+		// the middle of publishState when a slow client is evicted
+		h.broadcastGameState()
+	}
+
+	log.Printf("Client disconnected. Total clients: %d", len(h.clients))
+}
 
+// broadcastMessage encodes message for each client's codec and queues it for
+// every client except exclude. Must run on the hub goroutine.
+func (h *Hub) broadcastMessage(message models.WebSocketMessage, exclude *Client) {
+	if message.Lamport == 0 {
+		h.stamp(&message, "", nil)
+	}
+	frames := newFrameCache(message)
 	for client := range h.clients {
-		select {
-		case client.send <- message:
-		default:
-			close(client.send)
-			delete(h.clients, client)
+		if client == exclude {
+			continue
+		}
+		if data, ok := frames.encode(client.codec); ok {
+			h.deliver(client, data)
 		}
 	}
 }
 
-func (h *Hub) sendToClient(client *Client, message models.WebSocketMessage) {
-	data, err := json.Marshal(message)
-	if err != nil {
-		log.Printf("Failed to marshal message: %v", err)
+// deliver queues data on a client's send channel. A client whose buffer is
+// full is too slow to keep up and gets disconnected. Clients that are already
+// gone are skipped. Must run on the hub goroutine.
+func (h *Hub) deliver(client *Client, data []byte) {
+	if !h.clients[client] {
 		return
 	}
 
 	select {
 	case client.send <- data:
 	default:
-		close(client.send)
-		delete(h.clients, client)
+		log.Printf("Client send buffer full, disconnecting")
+		h.unregisterClient(client)
 	}
 }
 
-func (h *Hub) broadcastGameState() {
-	snapshot := h.gameState.GetState()
-	message := models.WebSocketMessage{
-		Type:      models.MessageTypeGameState,
-		Data:      snapshot,
-		Timestamp: time.Now(),
+// sendToClient queues a message for a single client. Safe to call from any
+// goroutine but the hub's, which uses queueFor.
+func (h *Hub) sendToClient(client *Client, message models.WebSocketMessage) {
+	if data, ok := h.encodeFor(client, message); ok {
+		h.unicast <- unicastMessage{client: client, data: data}
+	}
+}
+
+// queueFor encodes a message for a single client and queues it directly.
+// Must run on the hub goroutine, which must never wait on unicast, the
+// channel only it drains.
+func (h *Hub) queueFor(client *Client, message models.WebSocketMessage) {
+	if data, ok := h.encodeFor(client, message); ok {
+		h.deliver(client, data)
 	}
+}
 
-	data, err := json.Marshal(message)
+// encodeFor encodes a message addressed to one client, naming the client's
+// player and stamping it unless it already is
+func (h *Hub) encodeFor(client *Client, message models.WebSocketMessage) ([]byte, bool) {
+	if message.Lamport == 0 {
+		h.stamp(&message, "", nil)
+	}
+	message.PlayerID = client.playerID
+	data, err := client.codec.Marshal(message)
 	if err != nil {
-		log.Printf("Failed to marshal game state: %v", err)
+		log.Printf("Failed to marshal message: %v", err)
+		return nil, false
+	}
+	return data, true
+}
+
+// publishState broadcasts everything that changed since the last broadcast as
+// the next delta, skipping exclude. Nothing is published while the client
+// link is cut. Must run on the hub goroutine.
+func (h *Hub) publishState(exclude *Client) {
+	if !h.clientsReachable() {
+		return
+	}
+
+	snapshot := h.gameState.GetState()
+	delta, changed := models.DiffSnapshots(h.lastSnapshot, snapshot)
+	if !changed {
 		return
 	}
 
-	h.broadcast <- data
+	h.seq++
+	delta.Seq = h.seq
+	snapshot.Seq = h.seq
+	h.lastSnapshot = snapshot
+
+	message := newMessage(models.MessageTypeDelta, delta)
+	h.stamp(&message, clientsPeer, &causality.Event{Label: fmt.Sprintf("delta %d", delta.Seq)})
+	h.broadcastMessage(message, exclude)
 }
 
-func (h *Hub) removePlayer(playerID string) {
+// sendSnapshot brings the state up to date and sends it in full to one client.
+// Must run on the hub goroutine.
+func (h *Hub) sendSnapshot(client *Client) {
+	h.publishState(client)
+
+	snapshot := h.lastSnapshot
+	snapshot.Seq = h.seq
+	if data, ok := h.encodeFor(client, newMessage(models.MessageTypeGameState, snapshot)); ok {
+		h.deliver(client, data)
+	}
+}
+
+// StateChanged tells the hub that the game state was changed outside of it,
+// for example by the HTTP API, so WebSocket clients receive a delta
+func (h *Hub) StateChanged() {
+	h.broadcastGameState()
+}
+
+// broadcastGameState tells the hub that the game state changed. Bursts of
+// changes are coalesced into a single delta. Safe to call from any goroutine.
+func (h *Hub) broadcastGameState() {
+	select {
+	case h.stateChanged <- struct{}{}:
+	default:
+	}
+}
+
+// broadcastLocks tells the hub that the wait-for graph changed. Bursts of
+// changes are coalesced. Safe to call from any goroutine.
+func (h *Hub) broadcastLocks() {
+	select {
+	case h.locksChanged <- struct{}{}:
+	default:
+	}
+}
+
+// ClusterChanged tells the hub that this node's Raft state changed. Bursts of
+// changes are coalesced. Safe to call from any goroutine.
+func (h *Hub) ClusterChanged() {
+	select {
+	case h.clusterChanged <- struct{}{}:
+	default:
+	}
+}
+
+// ReplicaChanged tells the hub that a replica's state changed. Bursts of
+// changes are coalesced. Safe to call from any goroutine.
+func (h *Hub) ReplicaChanged() {
+	select {
+	case h.replicaChanged <- struct{}{}:
+	default:
+	}
+}
+
+// NetworkChanged tells the hub that the simulated network or a replica's
+// progress changed. Bursts of changes are coalesced. Safe to call from any
+// goroutine.
+func (h *Hub) NetworkChanged() {
+	select {
+	case h.networkChanged <- struct{}{}:
+	default:
+	}
+}
+
+// CoordinatorChanged tells the hub that the two-phase commit coordinator or
+// one of its rooms changed. Bursts of changes are coalesced. Safe to call
+// from any goroutine.
+func (h *Hub) CoordinatorChanged() {
+	select {
+	case h.coordinatorChanged <- struct{}{}:
+	default:
+	}
+}
+
+// BroadcastSaga sends the current state of a saga to every client. Safe to
+// call from any goroutine.
+func (h *Hub) BroadcastSaga(saga models.Saga) {
+	h.broadcast <- newMessage(models.MessageTypeSaga, saga)
+}
+
+// broadcastAnomaly sends an isolation anomaly to every client. Safe to call
+// from any goroutine.
+func (h *Hub) broadcastAnomaly(anomaly models.Anomaly) {
+	h.broadcast <- newMessage(models.MessageTypeAnomaly, anomaly)
+}
+
+// addPlayer creates a player for client if there is room. Must run on the hub goroutine.
+func (h *Hub) addPlayer(client *Client, name string) (*models.Player, bool) {
+	h.gameState.Mu.Lock()
+	defer h.gameState.Mu.Unlock()
+
+	// Check if game is full
+	if len(h.gameState.Players) >= h.gameState.MaxPlayers {
+		return nil, false
+	}
+
+	colors := []string{"#FF0000", "#00FF00", "#0000FF", "#FFFF00"}
+	player := &models.Player{
+		ID:        uuid.New().String(),
+		Name:      name,
+		Color:     colors[len(h.gameState.Players)%len(colors)],
+		Connected: true,
+		LastSeen:  time.Now(),
+	}
+	if h.match != nil {
+		player.Team = h.smallestTeam()
+	}
+
+	h.gameState.Players[player.ID] = player
+	h.playerClients[player.ID] = client
+	client.playerID = player.ID
+	client.player = player
+
+	return player, true
+}
+
+// resumePlayer hands a player whose connection dropped to client, keeping
+// its ID so moves resent after the reconnect are still answered from its
+// dedupe window. Must run on the hub goroutine.
+func (h *Hub) resumePlayer(client *Client, playerID string) (*models.Player, bool) {
 	h.gameState.Mu.Lock()
 	defer h.gameState.Mu.Unlock()
 
-	if player, exists := h.gameState.Players[playerID]; exists {
-		player.Connected = false
-		player.LastSeen = time.Now()
+	player, exists := h.gameState.Players[playerID]
+	if !exists || player.Connected || h.playerClients[playerID] != nil {
+		return nil, false
+	}
+
+	player.Connected = true
+	player.LastSeen = time.Now()
+	h.playerClients[player.ID] = client
+	client.playerID = player.ID
+	client.player = player
+
+	return player, true
+}
 
-		// Remove after grace period
-		go func() {
-			time.Sleep(30 * time.Second)
-			h.gameState.Mu.Lock()
-			delete(h.gameState.Players, playerID)
+func (h *Hub) removePlayer(playerID string) {
+	h.gameState.Mu.Lock()
+	player, exists := h.gameState.Players[playerID]
+	if !exists {
+		h.gameState.Mu.Unlock()
+		return
+	}
+	player.Connected = false
+	player.LastSeen = time.Now()
+	disconnected := *player
+	h.gameState.Mu.Unlock()
+
+	h.announcePlayer(disconnected)
+
+	// Remove after grace period, unless the player rejoined in the meantime
+	go func() {
+		time.Sleep(playerGracePeriod)
+		h.gameState.Mu.Lock()
+		if player, ok := h.gameState.Players[playerID]; !ok || player.Connected || !player.LastSeen.Equal(disconnected.LastSeen) {
 			h.gameState.Mu.Unlock()
-			h.broadcastGameState()
-		}()
+			return
+		}
+		delete(h.gameState.Players, playerID)
+		h.gameState.Mu.Unlock()
+		h.moveOutcomes.forget(playerID)
+		h.playerMoveLimits.Forget(playerID)
+		h.announcePlayerRemoved(playerID)
+		h.broadcastGameState()
+	}()
+}
+
+// playerName returns the name of a player, or "" if they are gone
+func (h *Hub) playerName(playerID string) string {
+	h.gameState.Mu.RLock()
+	defer h.gameState.Mu.RUnlock()
+
+	if player, ok := h.gameState.Players[playerID]; ok {
+		return player.Name
 	}
+	return ""
+}
+
+// lastProcessedSeq returns the input sequence number of the player's latest
+// processed move
+func (h *Hub) lastProcessedSeq(player *models.Player) uint64 {
+	h.gameState.Mu.RLock()
+	defer h.gameState.Mu.RUnlock()
+	return player.LastProcessedSeq
+}
+
+// processInput records that the player's move numbered seq ran. The next
+// delta carries it to every client.
+func (h *Hub) processInput(player *models.Player, seq uint64, rejected bool) {
+	h.gameState.Mu.Lock()
+	defer h.gameState.Mu.Unlock()
+	player.ProcessInput(seq, rejected)
+}
+
+// touchPlayer records activity for a player
+func (h *Hub) touchPlayer(player *models.Player) {
+	h.gameState.Mu.Lock()
+	defer h.gameState.Mu.Unlock()
+	player.LastSeen = time.Now()
 }
 
 // Constants for WebSocket configuration
@@ -197,7 +656,13 @@ const (
 	writeWait      = 10 * time.Second
 	pongWait       = 60 * time.Second
 	pingPeriod     = (pongWait * 9) / 10
-	maxMessageSize = 512
+	maxMessageSize = 4096
+
+	// playerGracePeriod is how long a disconnected player stays in the game state
+	playerGracePeriod = 30 * time.Second
+
+	// clientsPeer is the peer of messages broadcast to every client
+	clientsPeer = "clients"
 )
 
 // readPump handles incoming WebSocket messages
@@ -216,6 +681,7 @@ func (c *Client) readPump() {
 
 	for {
 		_, messageData, err := c.conn.ReadMessage()
+		receivedAt := time.Now()
 		if err != nil {
 			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
 				log.Printf("WebSocket error: %v", err)
@@ -223,12 +689,21 @@ func (c *Client) readPump() {
 			break
 		}
 
-		var message models.WebSocketMessage
-		if err := json.Unmarshal(messageData, &message); err != nil {
-			log.Printf("Failed to unmarshal message: %v", err)
+		message, err := protocol.Decode(c.codec, messageData)
+		if err != nil {
+			if protocolErr, ok := err.(*protocol.Error); ok {
+				c.sendResponse(models.MessageTypeError, protocolErr.Response())
+			}
+			continue
+		}
+
+		if ok, retryAfter := c.limiter.Allow(); !ok {
+			c.hub.concurrencyController.RecordRateLimitedMessage()
+			c.sendRateLimited(message, retryAfter)
 			continue
 		}
 
+		message.ReceivedAt = receivedAt
 		c.handleMessage(message)
 	}
 }
@@ -250,20 +725,9 @@ func (c *Client) writePump() {
 				return
 			}
 
-			w, err := c.conn.NextWriter(websocket.TextMessage)
-			if err != nil {
-				return
-			}
-			w.Write(message)
-
-			// Add queued messages to the current message
-			n := len(c.send)
-			for i := 0; i < n; i++ {
-				w.Write([]byte{'\n'})
-				w.Write(<-c.send)
-			}
-
-			if err := w.Close(); err != nil {
+			// Each message gets its own frame so clients can parse it on its
+			// own; a lost delta would otherwise force a resync
+			if err := c.conn.WriteMessage(c.frameType(), message); err != nil {
 				return
 			}
 
@@ -276,8 +740,16 @@ func (c *Client) writePump() {
 	}
 }
 
+// frameType returns the WebSocket frame type for the client's codec
+func (c *Client) frameType() int {
+	if c.codec.Binary() {
+		return websocket.BinaryMessage
+	}
+	return websocket.TextMessage
+}
+
 // handleMessage processes incoming WebSocket messages
-func (c *Client) handleMessage(message models.WebSocketMessage) {
+func (c *Client) handleMessage(message protocol.Envelope) {
 	switch message.Type {
 	case models.MessageTypeJoin:
 		c.handleJoin(message)
@@ -285,125 +757,323 @@ func (c *Client) handleMessage(message models.WebSocketMessage) {
 		c.handleMove(message)
 	case models.MessageTypeLeave:
 		c.handleLeave()
+	case models.MessageTypeResync:
+		c.hub.do(func() { c.hub.sendSnapshot(c) })
 	default:
-		log.Printf("Unknown message type: %s", message.Type)
+		c.sendError("Unhandled message type", models.ErrorCodeUnknownType)
+	}
+}
+
+func (c *Client) handleJoin(message protocol.Envelope) {
+	joinRequest := message.Payload.(*models.JoinRequest)
+
+	var errMessage string
+	var errCode models.ErrorCode
+	c.hub.do(func() {
+		if !c.hub.clients[c] {
+			errMessage, errCode = "Connection closed", models.ErrorCodeNotConnected
+			return
+		}
+
+		if c.playerID != "" {
+			errMessage, errCode = "Player already joined", models.ErrorCodeAlreadyJoined
+			return
+		}
+
+		if !c.hub.clientsReachable() {
+			errMessage, errCode = "The network link to this server is cut", models.ErrorCodeUnavailable
+			return
+		}
+
+		var player *models.Player
+		if joinRequest.PlayerID != "" {
+			resumed, ok := c.hub.resumePlayer(c, joinRequest.PlayerID)
+			if !ok {
+				errMessage, errCode = "No disconnected player "+joinRequest.PlayerID+" to resume", models.ErrorCodeInvalidJoin
+				return
+			}
+			player = resumed
+			log.Printf("Player %s (%s) rejoined the game", player.Name, player.ID)
+		} else {
+			added, ok := c.hub.addPlayer(c, joinRequest.PlayerName)
+			if !ok {
+				errMessage, errCode = "Game is full", models.ErrorCodeGameFull
+				return
+			}
+			player = added
+			log.Printf("Player %s (%s) joined the game", player.Name, player.ID)
+		}
+		c.hub.announcePlayer(*player)
+		c.hub.playerJoined()
+
+		// Other clients get the new player as a delta, the joiner a full snapshot
+		c.hub.sendSnapshot(c)
+	})
+
+	if errCode != "" {
+		c.sendError(errMessage, errCode)
 	}
 }
 
-func (c *Client) handleJoin(message models.WebSocketMessage) {
-	var joinRequest models.JoinRequest
-	data, _ := json.Marshal(message.Data)
-	if err := json.Unmarshal(data, &joinRequest); err != nil {
-		c.sendError("Invalid join request", "INVALID_JOIN")
+func (c *Client) handleMove(message protocol.Envelope) {
+	if c.playerID == "" {
+		c.sendError("Player not registered", models.ErrorCodeNotRegistered)
 		return
 	}
 
-	c.hub.mu.Lock()
-	defer c.hub.mu.Unlock()
+	moveRequest := message.Payload.(*models.MoveRequest)
 
-	// Check if game is full
-	if len(c.hub.gameState.Players) >= c.hub.gameState.MaxPlayers {
-		c.sendError("Game is full", "GAME_FULL")
+	// Not recorded in the dedupe window, so the move can be resent once the
+	// link heals
+	if !c.hub.clientsReachable() {
+		messageType, response := errorResponse("The network link to this server is cut", models.ErrorCodeUnavailable, moveRequest.RequestID)
+		c.sendResponse(messageType, withSeq(response, moveRequest.Seq))
 		return
 	}
 
-	// Create new player
-	playerID := uuid.New().String()
-	colors := []string{"#FF0000", "#00FF00", "#0000FF", "#FFFF00"}
-	playerColor := colors[len(c.hub.gameState.Players)%len(colors)]
-
-	player := &models.Player{
-		ID:        playerID,
-		Name:      joinRequest.PlayerName,
-		Color:     playerColor,
-		Connected: true,
-		LastSeen:  time.Now(),
+	if ok, retryAfter := c.hub.playerMoveLimits.Allow(c.playerID); !ok {
+		c.hub.concurrencyController.RecordRateLimitedMove()
+		c.sendRateLimited(message, retryAfter)
+		return
 	}
 
-	c.playerID = playerID
-	c.player = player
-	c.hub.gameState.Players[playerID] = player
-	c.hub.playerClients[playerID] = c
+	if c.hub.causality != nil {
+		sent := causality.Stamp{Lamport: message.Lamport, Clock: message.Clock}
+		c.hub.causality.Receive(c.hub.process, c.playerID, sent, causality.Event{
+			Label:     "move " + moveRequest.Direction,
+			RequestID: moveRequest.RequestID,
+		})
+	}
 
-	log.Printf("Player %s (%s) joined the game", player.Name, playerID)
+	// A resent move gets its original outcome and never runs again. Moves from
+	// one connection are handled one at a time, so a request cannot be in
+	// flight twice.
+	if outcome, ok := c.hub.moveOutcomes.lookup(c.playerID, moveRequest.RequestID); ok {
+		log.Printf("Duplicate move %s from player %s answered from dedupe window", moveRequest.RequestID, c.playerID)
+		outcomeType, data := outcome.replay()
+		c.replyToMove(moveRequest, outcomeType, data)
+		return
+	}
 
-	// Broadcast updated game state
-	c.hub.broadcastGameState()
-}
+	// Inputs run in the order the client numbered them. A move at or below
+	// the last one processed is stale and not recorded, since running it now
+	// would replay an input the client has already reconciled.
+	if last := c.hub.lastProcessedSeq(c.player); moveRequest.Seq != 0 && moveRequest.Seq <= last {
+		response := models.ErrorResponse{
+			Message:   fmt.Sprintf("Input %d is not after the last processed input %d", moveRequest.Seq, last),
+			Code:      models.ErrorCodeInvalidMove,
+			RequestID: moveRequest.RequestID,
+			Field:     "seq",
+			Seq:       moveRequest.Seq,
+		}
+		c.replyToMove(moveRequest, models.MessageTypeError, response)
+		return
+	}
 
-func (c *Client) handleMove(message models.WebSocketMessage) {
-	if c.playerID == "" {
-		c.sendError("Player not registered", "NOT_REGISTERED")
+	// Between rounds of a match moves are refused
+	if phase, ok := c.hub.matchPhase(); ok && phase != models.MatchPhasePlaying {
+		outcomeType, outcome := errorResponse(fmt.Sprintf("Moves only count during a round; the match is in its %s", phase),
+			models.ErrorCodeNotPlaying, moveRequest.RequestID)
+		outcome = withSeq(outcome, moveRequest.Seq)
+		c.hub.moveOutcomes.record(c.playerID, moveRequest.RequestID, outcomeType, outcome)
+		if moveRequest.Seq != 0 {
+			c.hub.processInput(c.player, moveRequest.Seq, true)
+		}
+		c.replyToMove(moveRequest, outcomeType, outcome)
 		return
 	}
 
-	var moveRequest models.MoveRequest
-	data, _ := json.Marshal(message.Data)
-	if err := json.Unmarshal(data, &moveRequest); err != nil {
-		c.sendError("Invalid move request", "INVALID_MOVE")
+	// In tick mode the move waits for the next tick, which answers it
+	if c.hub.ticks != nil {
+		c.hub.ticks.queue(c, *moveRequest, message.ReceivedAt)
 		return
 	}
 
+	outcomeType, outcome := c.executeMove(message, moveRequest)
+	c.hub.recordMatchOutcome(c.player, outcomeType)
+	outcome = withSeq(outcome, moveRequest.Seq)
+	c.hub.moveOutcomes.record(c.playerID, moveRequest.RequestID, outcomeType, outcome)
+	if moveRequest.Seq != 0 {
+		c.hub.processInput(c.player, moveRequest.Seq, outcomeType != models.MessageTypeMoveAck)
+	}
+	c.replyToMove(moveRequest, outcomeType, outcome)
+
+	// Send current state to all clients
+	c.hub.broadcastGameState()
+}
+
+// executeMove runs one move as an optimistic transaction and returns the
+// response for the sender: a moveAck, a conflict or an error
+func (c *Client) executeMove(message protocol.Envelope, moveRequest *models.MoveRequest) (models.MessageType, interface{}) {
 	// Begin optimistic transaction
 	transaction, err := c.hub.concurrencyController.BeginTransaction(c.playerID, moveRequest.RequestID)
 	if err != nil {
-		c.sendError("Failed to begin transaction", "TRANSACTION_ERROR")
-		return
+		return errorResponse("Failed to begin transaction", models.ErrorCodeTransaction, moveRequest.RequestID)
 	}
 
-	// Propose the move
+	// Propose the move. Under two-phase locking this waits for the object lock.
 	if err := c.hub.concurrencyController.ProposeMove(transaction.ID, moveRequest.Direction); err != nil {
 		c.hub.concurrencyController.AbortTransaction(transaction.ID)
-		c.sendError(err.Error(), "INVALID_MOVE")
-		return
+		if messageType, response, ok := lockAbortResponse(err, moveRequest.RequestID); ok {
+			return messageType, response
+		}
+		if errors.Is(err, concurrency.ErrWall) {
+			return errorResponse("Your move runs into a wall", models.ErrorCodeWall, moveRequest.RequestID)
+		}
+		return errorResponse(err.Error(), models.ErrorCodeInvalidMove, moveRequest.RequestID)
+	}
+
+	// Simulated server-side processing between propose and commit
+	if delay := c.hub.concurrencyController.ThinkTime(); delay > 0 {
+		time.Sleep(delay)
 	}
 
 	// Attempt to commit
 	snapshot, err := c.hub.concurrencyController.CommitTransaction(transaction.ID)
 	if err != nil {
-		// Handle concurrency conflict
-		c.sendConflict(moveRequest.RequestID, err.Error())
-		c.hub.broadcastGameState() // Send current state to all clients
-		return
+		return c.hub.commitErrorResponse(err, moveRequest.RequestID)
 	}
 
-	log.Printf("Snapshot after commit: %+v", snapshot)
-
 	// Update last seen
-	c.player.LastSeen = time.Now()
+	c.hub.touchPlayer(c.player)
 
-	// Broadcast successful move
-	c.hub.broadcastGameState()
+	// Tell the sender which request won and what it produced
+	return models.MessageTypeMoveAck, models.MoveAck{
+		RequestID:       moveRequest.RequestID,
+		TransactionID:   transaction.ID,
+		Version:         snapshot.Object.Version,
+		Position:        snapshot.Object.Position,
+		ServerLatencyMs: float64(time.Since(message.ReceivedAt).Microseconds()) / 1000,
+		Timestamp:       time.Now(),
+	}
 }
 
 func (c *Client) handleLeave() {
 	c.hub.unregister <- c
 }
 
-func (c *Client) sendError(message, code string) {
-	errorMsg := models.WebSocketMessage{
-		Type: models.MessageTypeError,
-		Data: models.ErrorResponse{
-			Message: message,
-			Code:    code,
-		},
-		Timestamp: time.Now(),
-	}
-	c.hub.sendToClient(c, errorMsg)
-}
-
-func (c *Client) sendConflict(requestID, message string) {
-	snapshot := c.hub.gameState.GetState()
-	conflictMsg := models.WebSocketMessage{
-		Type: models.MessageTypeConflict,
-		Data: models.ConflictResponse{
-			Message:         message,
-			ExpectedVersion: snapshot.Object.Version,
-			ActualVersion:   snapshot.Object.Version,
-			RequestID:       requestID,
-			Timestamp:       time.Now(),
-		},
-		Timestamp: time.Now(),
-	}
-	c.hub.sendToClient(c, conflictMsg)
+// sendResponse queues a message for this client only
+func (c *Client) sendResponse(messageType models.MessageType, data interface{}) {
+	c.hub.sendToClient(c, newMessage(messageType, data))
+}
+
+// replyToMove sends the outcome of a move to this client, recorded as a
+// send when causality is tracked
+func (c *Client) replyToMove(moveRequest *models.MoveRequest, messageType models.MessageType, data interface{}) {
+	message := newMessage(messageType, data)
+	c.hub.stamp(&message, c.playerID, &causality.Event{Label: string(messageType), RequestID: moveRequest.RequestID})
+	c.hub.sendToClient(c, message)
+}
+
+// commitErrorResponse builds the response to a move whose commit failed, or
+// to a tick whose combined move did: a conflict or an error
+func (h *Hub) commitErrorResponse(err error, requestID string) (models.MessageType, interface{}) {
+	var conflict *concurrency.ConflictError
+	if errors.As(err, &conflict) {
+		return models.MessageTypeConflict, h.conflictResponse(conflict)
+	}
+	if messageType, response, ok := lockAbortResponse(err, requestID); ok {
+		return messageType, response
+	}
+	if errors.Is(err, concurrency.ErrWall) {
+		return errorResponse("The combined move runs into a wall", models.ErrorCodeWall, requestID)
+	}
+	if errors.Is(err, concurrency.ErrUnavailable) {
+		return errorResponse("Your move could not be replicated: "+err.Error(), models.ErrorCodeUnavailable, requestID)
+	}
+	if errors.Is(err, concurrency.ErrInDoubt) {
+		// The blocked count changed
+		h.CoordinatorChanged()
+		return errorResponse("Your move has to wait: "+err.Error(), models.ErrorCodeInDoubt, requestID)
+	}
+	return errorResponse(err.Error(), models.ErrorCodeTransaction, requestID)
+}
+
+// withSeq tags the response to a move with the move's input sequence number
+func withSeq(response interface{}, seq uint64) interface{} {
+	switch r := response.(type) {
+	case models.MoveAck:
+		r.Seq = seq
+		return r
+	case models.ConflictResponse:
+		r.Seq = seq
+		return r
+	case models.ErrorResponse:
+		r.Seq = seq
+		return r
+	}
+	return response
+}
+
+// errorResponse builds the payload of an error message about a request
+func errorResponse(message string, code models.ErrorCode, requestID string) (models.MessageType, interface{}) {
+	return models.MessageTypeError, models.ErrorResponse{
+		Message:   message,
+		Code:      code,
+		RequestID: requestID,
+	}
+}
+
+// lockAbortResponse builds the error for a move aborted by the lock manager,
+// reporting wound-wait and wait-die aborts with their reason
+func lockAbortResponse(err error, requestID string) (models.MessageType, interface{}, bool) {
+	var abort *concurrency.AbortError
+	if errors.As(err, &abort) {
+		messageType, response := errorResponse("Your move was aborted: "+abort.Reason(), models.ErrorCodeAborted, requestID)
+		return messageType, response, true
+	}
+	if errors.Is(err, concurrency.ErrDeadlock) {
+		messageType, response := errorResponse(err.Error(), models.ErrorCodeDeadlock, requestID)
+		return messageType, response, true
+	}
+	return "", nil, false
+}
+
+// sendRateLimited rejects a message that exceeded a rate limit. The move is
+// not recorded for deduplication, so it can be resent after retryAfter.
+func (c *Client) sendRateLimited(message protocol.Envelope, retryAfter time.Duration) {
+	response := models.ErrorResponse{
+		Message:      "Too many messages, slow down",
+		Code:         models.ErrorCodeRateLimited,
+		MessageType:  message.Type,
+		RetryAfterMs: retryAfter.Milliseconds(),
+	}
+	if response.RetryAfterMs == 0 {
+		response.RetryAfterMs = 1
+	}
+
+	if move, ok := message.Payload.(*models.MoveRequest); ok {
+		response.RequestID = move.RequestID
+		response.Seq = move.Seq
+	}
+
+	c.sendResponse(models.MessageTypeError, response)
+}
+
+func (c *Client) sendError(message string, code models.ErrorCode) {
+	c.sendResponse(models.MessageTypeError, models.ErrorResponse{
+		Message: message,
+		Code:    code,
+	})
+}
+
+// conflictResponse explains a lost optimistic check to the losing player
+func (h *Hub) conflictResponse(conflict *concurrency.ConflictError) models.ConflictResponse {
+	response := conflict.Response()
+	response.Message = fmt.Sprintf("Your move read version %d but the object was already at version %d",
+		conflict.ReadVersion, conflict.CurrentVersion)
+
+	if winner := response.Winner; winner != nil {
+		winner.PlayerName = h.playerName(winner.PlayerID)
+
+		name := winner.PlayerName
+		if name == "" {
+			name = "Another player"
+		}
+		response.Message = fmt.Sprintf("%s moved the object to (%d, %d) first (v%d → v%d); your move to (%d, %d) was rejected",
+			name, winner.Position.X, winner.Position.Y, conflict.ReadVersion, winner.Version,
+			conflict.ProposedPosition.X, conflict.ProposedPosition.Y)
+	}
+
+	return response
 }
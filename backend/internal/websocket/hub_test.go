@@ -2,14 +2,20 @@ package websocket
 
 import (
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
+	"github.com/AnishMulay/Transaction-Conflict-Visualization/internal/causality"
 	"github.com/AnishMulay/Transaction-Conflict-Visualization/internal/concurrency"
+	"github.com/AnishMulay/Transaction-Conflict-Visualization/internal/netsim"
+	"github.com/AnishMulay/Transaction-Conflict-Visualization/internal/ratelimit"
 	"github.com/AnishMulay/Transaction-Conflict-Visualization/pkg/models"
+	"github.com/AnishMulay/Transaction-Conflict-Visualization/pkg/protocol"
 
 	"github.com/gorilla/websocket"
 )
@@ -212,3 +218,989 @@ func TestMultiplePlayersConflict(t *testing.T) {
 		t.Log("No explicit conflict message, but this is acceptable if moves were serialized")
 	}
 }
+
+func TestRegisteringClientWithFullUnicastQueue(t *testing.T) {
+	gameState := models.NewGameState(models.Position{X: 10, Y: 10})
+	controller := concurrency.NewConcurrencyController(gameState)
+	controller.SetLockingStrategy(concurrency.StrategyTwoPhaseLocking)
+	hub := NewHub(gameState, controller)
+	hub.SetClusterStatus(func() models.RaftStatus { return models.RaftStatus{} })
+	hub.SetReplicaStatus(func() models.ReplicaStatus { return models.ReplicaStatus{} })
+	hub.SetNetworkStatus(func() models.NetworkStatus { return models.NetworkStatus{} }, nil)
+	hub.SetCoordinatorStatus(func() models.CoordinatorStatus { return models.CoordinatorStatus{} })
+
+	// Replies from other goroutines fill the queue only the hub drains
+	other := &Client{hub: hub, codec: protocol.JSON, send: make(chan []byte, 1)}
+	for i := 0; i < cap(hub.unicast); i++ {
+		hub.unicast <- unicastMessage{client: other, data: []byte("{}")}
+	}
+
+	// Registering runs on the hub goroutine and must not wait on that queue
+	client := &Client{hub: hub, codec: protocol.JSON, send: make(chan []byte, 8)}
+	done := make(chan struct{})
+	go func() {
+		hub.registerClient(client)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Registering a client blocked on the full unicast queue")
+	}
+
+	if queued := len(client.send); queued != 6 {
+		t.Errorf("Expected the snapshot, the wait-for graph and four statuses queued, got %d messages", queued)
+	}
+}
+
+func TestSlowClientIsDisconnectedOnce(t *testing.T) {
+	gridSize := models.Position{X: 10, Y: 10}
+	gameState := models.NewGameState(gridSize)
+	controller := concurrency.NewConcurrencyController(gameState)
+	hub := NewHub(gameState, controller)
+
+	go hub.Run()
+
+	// A client that never drains its buffer; the initial game state fills it
+	client := &Client{hub: hub, codec: protocol.JSON, send: make(chan []byte, 1)}
+	hub.register <- client
+
+	if count := hub.ClientCount(); count != 1 {
+		t.Fatalf("Expected 1 client, got %d", count)
+	}
+
+	// Overflowing the buffer from several goroutines must close send exactly once
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			hub.broadcastGameState()
+			hub.sendToClient(client, models.WebSocketMessage{Type: models.MessageTypeError})
+		}()
+	}
+	wg.Wait()
+
+	// A late unregister, as sent by readPump on exit, must not double close
+	hub.unregister <- client
+
+	if count := hub.ClientCount(); count != 0 {
+		t.Fatalf("Expected slow client to be removed, got %d clients", count)
+	}
+
+	<-client.send
+	if _, ok := <-client.send; ok {
+		t.Error("Expected send channel to be closed")
+	}
+}
+
+func TestConcurrentJoinMoveLeave(t *testing.T) {
+	gridSize := models.Position{X: 10, Y: 10}
+	gameState := models.NewGameState(gridSize)
+	controller := concurrency.NewConcurrencyController(gameState)
+	hub := NewHub(gameState, controller)
+
+	go hub.Run()
+
+	server := httptest.NewServer(http.HandlerFunc(hub.ServeWS))
+	defer server.Close()
+
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + "/ws"
+
+	numClients := 8
+	var wg sync.WaitGroup
+
+	for i := 0; i < numClients; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+			if err != nil {
+				t.Errorf("Failed to connect: %v", err)
+				return
+			}
+			defer conn.Close()
+
+			// Drain everything the server sends until the connection closes
+			go func() {
+				for {
+					if _, _, err := conn.ReadMessage(); err != nil {
+						return
+					}
+				}
+			}()
+
+			conn.WriteJSON(models.WebSocketMessage{
+				Type:      models.MessageTypeJoin,
+				Data:      models.JoinRequest{PlayerName: fmt.Sprintf("Player%d", i)},
+				Timestamp: time.Now(),
+			})
+
+			for j := 0; j < 20; j++ {
+				conn.WriteJSON(models.WebSocketMessage{
+					Type: models.MessageTypeMove,
+					Data: models.MoveRequest{
+						Direction: []string{"up", "down", "left", "right"}[j%4],
+						RequestID: fmt.Sprintf("move-%d-%d", i, j),
+					},
+					Timestamp: time.Now(),
+				})
+			}
+
+			if i%2 == 0 {
+				conn.WriteJSON(models.WebSocketMessage{Type: models.MessageTypeLeave, Timestamp: time.Now()})
+			}
+		}(i)
+	}
+
+	wg.Wait()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for hub.ClientCount() != 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if count := hub.ClientCount(); count != 0 {
+		t.Errorf("Expected all clients to disconnect, got %d", count)
+	}
+
+	snapshot := gameState.GetState()
+	if len(snapshot.Players) > snapshot.MaxPlayers {
+		t.Errorf("Expected at most %d players, got %d", snapshot.MaxPlayers, len(snapshot.Players))
+	}
+
+	for _, player := range snapshot.Players {
+		if player.Connected {
+			t.Errorf("Player %s should be marked disconnected", player.Name)
+		}
+	}
+}
+
+func TestDeltaSequenceAndResync(t *testing.T) {
+	gridSize := models.Position{X: 10, Y: 10}
+	gameState := models.NewGameState(gridSize)
+	controller := concurrency.NewConcurrencyController(gameState)
+	hub := NewHub(gameState, controller)
+
+	go hub.Run()
+
+	server := httptest.NewServer(http.HandlerFunc(hub.ServeWS))
+	defer server.Close()
+
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + "/ws"
+
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer conn.Close()
+
+	var message models.WebSocketMessage
+	conn.ReadJSON(&message) // initial snapshot
+
+	conn.WriteJSON(models.WebSocketMessage{
+		Type:      models.MessageTypeJoin,
+		Data:      models.JoinRequest{PlayerName: "Seq"},
+		Timestamp: time.Now(),
+	})
+
+	// The joiner gets a full snapshot
+	var joined struct {
+		Type models.MessageType       `json:"type"`
+		Data models.GameStateSnapshot `json:"data"`
+	}
+	if err := conn.ReadJSON(&joined); err != nil {
+		t.Fatalf("Failed to read join snapshot: %v", err)
+	}
+	if joined.Type != models.MessageTypeGameState || len(joined.Data.Players) != 1 {
+		t.Fatalf("Expected snapshot with 1 player, got %s with %d", joined.Type, len(joined.Data.Players))
+	}
+
+	lastSeq := joined.Data.Seq
+	for i := 0; i < 3; i++ {
+		conn.WriteJSON(models.WebSocketMessage{
+			Type:      models.MessageTypeMove,
+			Data:      models.MoveRequest{Direction: "right", RequestID: fmt.Sprintf("seq-%d", i)},
+			Timestamp: time.Now(),
+		})
+
+		// The sender also gets a moveAck, in either order relative to the delta
+		var delta struct {
+			Type models.MessageType    `json:"type"`
+			Data models.GameStateDelta `json:"data"`
+		}
+		for delta.Type != models.MessageTypeDelta {
+			if err := conn.ReadJSON(&delta); err != nil {
+				t.Fatalf("Failed to read delta: %v", err)
+			}
+			if delta.Type != models.MessageTypeDelta && delta.Type != models.MessageTypeMoveAck {
+				t.Fatalf("Expected delta or moveAck, got %s", delta.Type)
+			}
+		}
+		if delta.Data.Seq != lastSeq+1 {
+			t.Errorf("Expected seq %d, got %d", lastSeq+1, delta.Data.Seq)
+		}
+		if delta.Data.Object == nil {
+			t.Error("Expected object change in delta")
+		}
+		lastSeq = delta.Data.Seq
+	}
+
+	// A client that detects a gap asks for a full snapshot
+	conn.WriteJSON(models.WebSocketMessage{Type: models.MessageTypeResync, Timestamp: time.Now()})
+
+	var resync struct {
+		Type models.MessageType       `json:"type"`
+		Data models.GameStateSnapshot `json:"data"`
+	}
+	if err := conn.ReadJSON(&resync); err != nil {
+		t.Fatalf("Failed to read resync snapshot: %v", err)
+	}
+	if resync.Type != models.MessageTypeGameState || resync.Data.Seq != lastSeq {
+		t.Errorf("Expected snapshot at seq %d, got %s at %d", lastSeq, resync.Type, resync.Data.Seq)
+	}
+	if resync.Data.Object.Position.X != 8 {
+		t.Errorf("Expected object at x=8, got %d", resync.Data.Object.Position.X)
+	}
+}
+
+func TestMessagePackSubprotocol(t *testing.T) {
+	gridSize := models.Position{X: 10, Y: 10}
+	gameState := models.NewGameState(gridSize)
+	controller := concurrency.NewConcurrencyController(gameState)
+	hub := NewHub(gameState, controller)
+
+	go hub.Run()
+
+	server := httptest.NewServer(http.HandlerFunc(hub.ServeWS))
+	defer server.Close()
+
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + "/ws"
+
+	dialer := websocket.Dialer{Subprotocols: []string{protocol.SubprotocolMessagePack}}
+	conn, _, err := dialer.Dial(url, nil)
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer conn.Close()
+
+	if conn.Subprotocol() != protocol.SubprotocolMessagePack {
+		t.Fatalf("Expected msgpack subprotocol, got %q", conn.Subprotocol())
+	}
+
+	readSnapshot := func() models.GameStateSnapshot {
+		frameType, data, err := conn.ReadMessage()
+		if err != nil {
+			t.Fatalf("Failed to read message: %v", err)
+		}
+		if frameType != websocket.BinaryMessage {
+			t.Fatalf("Expected binary frame, got %d", frameType)
+		}
+
+		envelope, err := protocol.MessagePack.DecodeEnvelope(data)
+		if err != nil {
+			t.Fatalf("Failed to decode envelope: %v", err)
+		}
+		if envelope.Type != models.MessageTypeGameState {
+			t.Fatalf("Expected game state, got %s", envelope.Type)
+		}
+
+		var snapshot models.GameStateSnapshot
+		if err := protocol.MessagePack.Unmarshal(envelope.Data, &snapshot); err != nil {
+			t.Fatalf("Failed to decode snapshot: %v", err)
+		}
+		return snapshot
+	}
+
+	readSnapshot()
+
+	join, _ := protocol.MessagePack.Marshal(models.WebSocketMessage{
+		Type:      models.MessageTypeJoin,
+		Data:      models.JoinRequest{PlayerName: "Bot"},
+		Timestamp: time.Now(),
+	})
+	conn.WriteMessage(websocket.BinaryMessage, join)
+
+	snapshot := readSnapshot()
+	if len(snapshot.Players) != 1 {
+		t.Fatalf("Expected 1 player, got %d", len(snapshot.Players))
+	}
+	for _, player := range snapshot.Players {
+		if player.Name != "Bot" {
+			t.Errorf("Expected player Bot, got %q", player.Name)
+		}
+	}
+}
+
+func TestProtocolErrorsAreReported(t *testing.T) {
+	gridSize := models.Position{X: 10, Y: 10}
+	gameState := models.NewGameState(gridSize)
+	controller := concurrency.NewConcurrencyController(gameState)
+	hub := NewHub(gameState, controller)
+
+	go hub.Run()
+
+	server := httptest.NewServer(http.HandlerFunc(hub.ServeWS))
+	defer server.Close()
+
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + "/ws"
+
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer conn.Close()
+
+	var message models.WebSocketMessage
+	conn.ReadJSON(&message) // initial snapshot
+
+	cases := []struct {
+		input string
+		code  models.ErrorCode
+	}{
+		{`not json`, models.ErrorCodeMalformed},
+		{`{"type":"teleport","protocolVersion":1}`, models.ErrorCodeUnknownType},
+		{`{"type":"join","protocolVersion":9,"data":{"playerName":"Future"}}`, models.ErrorCodeUnsupportedVersion},
+		{`{"type":"join","data":{"playerName":"X","role":"admin"}}`, models.ErrorCodeInvalidJoin},
+	}
+
+	for _, tc := range cases {
+		conn.WriteMessage(websocket.TextMessage, []byte(tc.input))
+
+		var reply struct {
+			Type            models.MessageType   `json:"type"`
+			ProtocolVersion int                  `json:"protocolVersion"`
+			Data            models.ErrorResponse `json:"data"`
+		}
+		if err := conn.ReadJSON(&reply); err != nil {
+			t.Fatalf("Failed to read reply to %s: %v", tc.input, err)
+		}
+
+		if reply.Type != models.MessageTypeError || reply.Data.Code != tc.code {
+			t.Errorf("Expected %s error for %s, got %s %+v", tc.code, tc.input, reply.Type, reply.Data)
+		}
+		if reply.ProtocolVersion != models.ProtocolVersion {
+			t.Errorf("Expected protocolVersion %d on reply, got %d", models.ProtocolVersion, reply.ProtocolVersion)
+		}
+	}
+}
+
+func TestMoveAckCorrelatesRequest(t *testing.T) {
+	gridSize := models.Position{X: 10, Y: 10}
+	gameState := models.NewGameState(gridSize)
+	controller := concurrency.NewConcurrencyController(gameState)
+	hub := NewHub(gameState, controller)
+
+	go hub.Run()
+
+	server := httptest.NewServer(http.HandlerFunc(hub.ServeWS))
+	defer server.Close()
+
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + "/ws"
+
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer conn.Close()
+
+	var message models.WebSocketMessage
+	conn.ReadJSON(&message) // initial snapshot
+
+	conn.WriteJSON(models.WebSocketMessage{
+		Type:      models.MessageTypeJoin,
+		Data:      models.JoinRequest{PlayerName: "Acker"},
+		Timestamp: time.Now(),
+	})
+	conn.ReadJSON(&message) // join snapshot
+
+	conn.WriteJSON(models.WebSocketMessage{
+		Type:      models.MessageTypeMove,
+		Data:      models.MoveRequest{Direction: "down", RequestID: "ack-me"},
+		Timestamp: time.Now(),
+	})
+
+	var ack struct {
+		Type models.MessageType `json:"type"`
+		Data models.MoveAck     `json:"data"`
+	}
+	for ack.Type != models.MessageTypeMoveAck {
+		if err := conn.ReadJSON(&ack); err != nil {
+			t.Fatalf("Failed to read moveAck: %v", err)
+		}
+	}
+
+	if ack.Data.RequestID != "ack-me" {
+		t.Errorf("Expected requestId ack-me, got %q", ack.Data.RequestID)
+	}
+	if ack.Data.TransactionID == "" {
+		t.Error("Expected a transaction ID")
+	}
+	if ack.Data.Version != 2 {
+		t.Errorf("Expected committed version 2, got %d", ack.Data.Version)
+	}
+	if ack.Data.Position != (models.Position{X: 5, Y: 6}) {
+		t.Errorf("Expected position (5,6), got %v", ack.Data.Position)
+	}
+	if ack.Data.ServerLatencyMs < 0 {
+		t.Errorf("Expected non-negative latency, got %f", ack.Data.ServerLatencyMs)
+	}
+}
+
+func TestResentMoveAppliesOnce(t *testing.T) {
+	gridSize := models.Position{X: 10, Y: 10}
+	gameState := models.NewGameState(gridSize)
+	controller := concurrency.NewConcurrencyController(gameState)
+	hub := NewHub(gameState, controller)
+
+	go hub.Run()
+
+	server := httptest.NewServer(http.HandlerFunc(hub.ServeWS))
+	defer server.Close()
+
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + "/ws"
+
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer conn.Close()
+
+	var message models.WebSocketMessage
+	conn.ReadJSON(&message) // initial snapshot
+
+	conn.WriteJSON(models.WebSocketMessage{
+		Type:      models.MessageTypeJoin,
+		Data:      models.JoinRequest{PlayerName: "Retrier"},
+		Timestamp: time.Now(),
+	})
+	conn.ReadJSON(&message) // join snapshot
+
+	readAck := func() models.MoveAck {
+		var ack struct {
+			Type models.MessageType `json:"type"`
+			Data models.MoveAck     `json:"data"`
+		}
+		for ack.Type != models.MessageTypeMoveAck {
+			if err := conn.ReadJSON(&ack); err != nil {
+				t.Fatalf("Failed to read moveAck: %v", err)
+			}
+		}
+		return ack.Data
+	}
+
+	move := models.WebSocketMessage{
+		Type:      models.MessageTypeMove,
+		Data:      models.MoveRequest{Direction: "left", RequestID: "retry-me"},
+		Timestamp: time.Now(),
+	}
+
+	conn.WriteJSON(move)
+	first := readAck()
+
+	conn.WriteJSON(move)
+	second := readAck()
+
+	if first.Duplicate || !second.Duplicate {
+		t.Errorf("Expected only the resend to be marked duplicate, got %v and %v", first.Duplicate, second.Duplicate)
+	}
+
+	if first.TransactionID != second.TransactionID || first.Version != second.Version {
+		t.Errorf("Resend should return the original outcome: %+v vs %+v", first, second)
+	}
+
+	if stats := controller.GetConflictStats(); stats.TotalTransactions != 1 {
+		t.Errorf("Expected 1 transaction, got %d", stats.TotalTransactions)
+	}
+
+	if snapshot := gameState.GetState(); snapshot.Object.Position.X != 4 {
+		t.Errorf("Expected object moved once to x=4, got %d", snapshot.Object.Position.X)
+	}
+}
+
+func TestMoveResentAfterReconnectAppliesOnce(t *testing.T) {
+	gameState := models.NewGameState(models.Position{X: 10, Y: 10})
+	controller := concurrency.NewConcurrencyController(gameState)
+	hub := NewHub(gameState, controller)
+
+	go hub.Run()
+
+	server := httptest.NewServer(http.HandlerFunc(hub.ServeWS))
+	defer server.Close()
+
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + "/ws"
+
+	// join connects and joins, resuming playerID if set, and returns the
+	// connection and the ID it plays as
+	join := func(playerID string) (*websocket.Conn, string) {
+		conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+		if err != nil {
+			t.Fatalf("Failed to connect: %v", err)
+		}
+		var message models.WebSocketMessage
+		conn.ReadJSON(&message) // initial snapshot
+
+		conn.WriteJSON(models.WebSocketMessage{
+			Type:      models.MessageTypeJoin,
+			Data:      models.JoinRequest{PlayerName: "Roamer", PlayerID: playerID},
+			Timestamp: time.Now(),
+		})
+		for message.PlayerID == "" {
+			if err := conn.ReadJSON(&message); err != nil {
+				t.Fatalf("Failed to read join snapshot: %v", err)
+			}
+			if message.Type == models.MessageTypeError {
+				t.Fatalf("Join failed: %+v", message.Data)
+			}
+		}
+		return conn, message.PlayerID
+	}
+
+	move := models.WebSocketMessage{
+		Type:      models.MessageTypeMove,
+		Data:      models.MoveRequest{Direction: "left", RequestID: "lost-ack", Seq: 1},
+		Timestamp: time.Now(),
+	}
+	readAck := func(conn *websocket.Conn) models.MoveAck {
+		var ack struct {
+			Type models.MessageType `json:"type"`
+			Data models.MoveAck     `json:"data"`
+		}
+		for ack.Type != models.MessageTypeMoveAck {
+			if err := conn.ReadJSON(&ack); err != nil {
+				t.Fatalf("Failed to read moveAck: %v", err)
+			}
+		}
+		return ack.Data
+	}
+
+	conn, playerID := join("")
+	conn.WriteJSON(move)
+	first := readAck(conn)
+	conn.Close()
+
+	// The hub notices the dropped connection and keeps the player for the grace period
+	deadline := time.Now().Add(time.Second)
+	for {
+		gameState.Mu.RLock()
+		connected := gameState.Players[playerID].Connected
+		gameState.Mu.RUnlock()
+		if !connected {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Timed out waiting for the player to disconnect")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	conn, resumedID := join(playerID)
+	defer conn.Close()
+	if resumedID != playerID {
+		t.Fatalf("Expected to rejoin as %s, got %s", playerID, resumedID)
+	}
+
+	conn.WriteJSON(move)
+	second := readAck(conn)
+	if !second.Duplicate || second.TransactionID != first.TransactionID {
+		t.Errorf("Expected the resend to replay the original ack, got %+v after %+v", second, first)
+	}
+	if stats := controller.GetConflictStats(); stats.TotalTransactions != 1 {
+		t.Errorf("Expected 1 transaction, got %d", stats.TotalTransactions)
+	}
+	if snapshot := gameState.GetState(); snapshot.Object.Position.X != 4 || len(snapshot.Players) != 1 {
+		t.Errorf("Expected one player and the object moved once to x=4, got %d players at x=%d", len(snapshot.Players), snapshot.Object.Position.X)
+	}
+
+	// A player that is connected, or never existed, cannot be taken over
+	other, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer other.Close()
+	var message models.WebSocketMessage
+	other.ReadJSON(&message) // initial snapshot
+	for _, id := range []string{playerID, "nobody"} {
+		other.WriteJSON(models.WebSocketMessage{
+			Type:      models.MessageTypeJoin,
+			Data:      models.JoinRequest{PlayerName: "Thief", PlayerID: id},
+			Timestamp: time.Now(),
+		})
+		var refusal struct {
+			Type models.MessageType   `json:"type"`
+			Data models.ErrorResponse `json:"data"`
+		}
+		for refusal.Type != models.MessageTypeError {
+			if err := other.ReadJSON(&refusal); err != nil {
+				t.Fatalf("Failed to read join refusal: %v", err)
+			}
+		}
+		if refusal.Data.Code != models.ErrorCodeInvalidJoin {
+			t.Errorf("Expected INVALID_JOIN resuming %s, got %+v", id, refusal.Data)
+		}
+	}
+}
+
+func TestMovesAreRateLimited(t *testing.T) {
+	gridSize := models.Position{X: 10, Y: 10}
+	gameState := models.NewGameState(gridSize)
+	controller := concurrency.NewConcurrencyController(gameState)
+	hub := NewHub(gameState, controller)
+	hub.SetRateLimits(RateLimits{PerPlayer: ratelimit.Limit{Rate: 1, Burst: 2}})
+
+	go hub.Run()
+
+	server := httptest.NewServer(http.HandlerFunc(hub.ServeWS))
+	defer server.Close()
+
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + "/ws"
+
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer conn.Close()
+
+	var message models.WebSocketMessage
+	conn.ReadJSON(&message) // initial snapshot
+
+	conn.WriteJSON(models.WebSocketMessage{
+		Type:      models.MessageTypeJoin,
+		Data:      models.JoinRequest{PlayerName: "Flooder"},
+		Timestamp: time.Now(),
+	})
+	conn.ReadJSON(&message) // join snapshot
+
+	for i := 0; i < 3; i++ {
+		conn.WriteJSON(models.WebSocketMessage{
+			Type:      models.MessageTypeMove,
+			Data:      models.MoveRequest{Direction: "up", RequestID: fmt.Sprintf("flood-%d", i)},
+			Timestamp: time.Now(),
+		})
+	}
+
+	var limited struct {
+		Type models.MessageType   `json:"type"`
+		Data models.ErrorResponse `json:"data"`
+	}
+	for limited.Type != models.MessageTypeError {
+		if err := conn.ReadJSON(&limited); err != nil {
+			t.Fatalf("Failed to read rate limit error: %v", err)
+		}
+	}
+
+	if limited.Data.Code != models.ErrorCodeRateLimited {
+		t.Fatalf("Expected RATE_LIMITED, got %s", limited.Data.Code)
+	}
+	if limited.Data.RequestID != "flood-2" {
+		t.Errorf("Expected the third move to be limited, got %q", limited.Data.RequestID)
+	}
+	if limited.Data.RetryAfterMs <= 0 || limited.Data.RetryAfterMs > 1000 {
+		t.Errorf("Expected retry-after within 1s, got %dms", limited.Data.RetryAfterMs)
+	}
+
+	stats := controller.GetConflictStats()
+	if stats.RateLimitedMoves != 1 || stats.RateLimitedMessages != 0 {
+		t.Errorf("Expected 1 rate-limited move and no messages in stats, got %d and %d", stats.RateLimitedMoves, stats.RateLimitedMessages)
+	}
+	if stats.TotalTransactions != 2 {
+		t.Errorf("Limited move must not start a transaction, got %d transactions", stats.TotalTransactions)
+	}
+}
+
+func TestConnectionFloodIsRateLimited(t *testing.T) {
+	gridSize := models.Position{X: 10, Y: 10}
+	gameState := models.NewGameState(gridSize)
+	controller := concurrency.NewConcurrencyController(gameState)
+	hub := NewHub(gameState, controller)
+	hub.SetRateLimits(RateLimits{PerConnection: ratelimit.Limit{Rate: 1, Burst: 1}})
+
+	go hub.Run()
+
+	server := httptest.NewServer(http.HandlerFunc(hub.ServeWS))
+	defer server.Close()
+
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + "/ws"
+
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer conn.Close()
+
+	var message models.WebSocketMessage
+	conn.ReadJSON(&message) // initial snapshot
+
+	// Two resyncs back to back; the second exceeds the connection budget
+	resync := models.WebSocketMessage{Type: models.MessageTypeResync, Timestamp: time.Now()}
+	conn.WriteJSON(resync)
+	conn.WriteJSON(resync)
+
+	var reply struct {
+		Type models.MessageType   `json:"type"`
+		Data models.ErrorResponse `json:"data"`
+	}
+	for reply.Type != models.MessageTypeError {
+		if err := conn.ReadJSON(&reply); err != nil {
+			t.Fatalf("Failed to read rate limit error: %v", err)
+		}
+	}
+
+	if reply.Data.Code != models.ErrorCodeRateLimited || reply.Data.MessageType != models.MessageTypeResync {
+		t.Errorf("Expected RATE_LIMITED for resync, got %+v", reply.Data)
+	}
+
+	stats := controller.GetConflictStats()
+	if stats.RateLimitedMessages != 1 || stats.RateLimitedMoves != 0 {
+		t.Errorf("Expected 1 rate-limited message and no moves in stats, got %d and %d", stats.RateLimitedMessages, stats.RateLimitedMoves)
+	}
+}
+
+func TestCutClientLinkHoldsBackState(t *testing.T) {
+	gameState := models.NewGameState(models.Position{X: 10, Y: 10})
+	controller := concurrency.NewConcurrencyController(gameState)
+	hub := NewHub(gameState, controller)
+
+	network := netsim.New()
+	network.AddLink("r1", netsim.Clients)
+	network.OnChange(hub.NetworkChanged)
+	hub.SetNetworkStatus(network.Status, func() bool { return network.Connected("r1", netsim.Clients) })
+	go hub.Run()
+
+	server := httptest.NewServer(http.HandlerFunc(hub.ServeWS))
+	defer server.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(3 * time.Second))
+
+	type message struct {
+		Type models.MessageType `json:"type"`
+		Data json.RawMessage    `json:"data"`
+	}
+	read := func() message {
+		t.Helper()
+		var m message
+		if err := conn.ReadJSON(&m); err != nil {
+			t.Fatalf("Failed to read: %v", err)
+		}
+		return m
+	}
+
+	read() // initial snapshot
+	if m := read(); m.Type != models.MessageTypeNetworkStatus {
+		t.Fatalf("Expected the network status on connect, got %s", m.Type)
+	}
+	conn.WriteJSON(models.WebSocketMessage{
+		Type:      models.MessageTypeJoin,
+		Data:      models.JoinRequest{PlayerName: "Alice"},
+		Timestamp: time.Now(),
+	})
+	read() // join snapshot
+
+	network.Cut("r1", netsim.Clients)
+	var status models.NetworkStatus
+	m := read()
+	json.Unmarshal(m.Data, &status)
+	if m.Type != models.MessageTypeNetworkStatus || !status.Links[0].Cut {
+		t.Fatalf("Expected a network status with the link cut, got %s %s", m.Type, m.Data)
+	}
+
+	// Moves from the cut-off clients are refused
+	conn.WriteJSON(models.WebSocketMessage{
+		Type:      models.MessageTypeMove,
+		Data:      models.MoveRequest{Direction: "up", RequestID: "cut-1"},
+		Timestamp: time.Now(),
+	})
+	var refused models.ErrorResponse
+	m = read()
+	json.Unmarshal(m.Data, &refused)
+	if m.Type != models.MessageTypeError || refused.Code != models.ErrorCodeUnavailable || refused.RequestID != "cut-1" {
+		t.Fatalf("Expected the move refused as unavailable, got %s %s", m.Type, m.Data)
+	}
+
+	// A change made meanwhile reaches the clients only after the link heals
+	transaction, _ := controller.BeginTransaction("other", "other-1")
+	controller.ProposeMove(transaction.ID, "right")
+	if _, err := controller.CommitTransaction(transaction.ID); err != nil {
+		t.Fatalf("Commit failed: %v", err)
+	}
+	hub.StateChanged()
+	time.Sleep(50 * time.Millisecond)
+
+	network.HealAll()
+	if m := read(); m.Type != models.MessageTypeNetworkStatus {
+		t.Fatalf("Expected nothing but the healed network status first, got %s %s", m.Type, m.Data)
+	}
+	var delta models.GameStateDelta
+	m = read()
+	json.Unmarshal(m.Data, &delta)
+	if m.Type != models.MessageTypeDelta || delta.Object == nil || delta.Object.Position != (models.Position{X: 6, Y: 5}) {
+		t.Errorf("Expected the held back move as a delta, got %s %s", m.Type, m.Data)
+	}
+}
+
+func TestMovesCarryLogicalTime(t *testing.T) {
+	gameState := models.NewGameState(models.Position{X: 10, Y: 10})
+	controller := concurrency.NewConcurrencyController(gameState)
+	hub := NewHub(gameState, controller)
+	tracker := causality.NewTracker()
+	hub.SetCausality(tracker, "lobby")
+	go hub.Run()
+
+	server := httptest.NewServer(http.HandlerFunc(hub.ServeWS))
+	defer server.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(3 * time.Second))
+
+	var message models.WebSocketMessage
+	conn.ReadJSON(&message) // initial snapshot
+	conn.WriteJSON(models.WebSocketMessage{
+		Type:      models.MessageTypeJoin,
+		Data:      models.JoinRequest{PlayerName: "Lamport"},
+		Timestamp: time.Now(),
+	})
+	conn.ReadJSON(&message) // join snapshot
+	playerID := message.PlayerID
+	if playerID == "" {
+		t.Fatal("Expected the join snapshot to name the player")
+	}
+
+	// The client's clock is far ahead of the server's
+	conn.WriteJSON(models.WebSocketMessage{
+		Type:      models.MessageTypeMove,
+		Data:      models.MoveRequest{Direction: "up", RequestID: "tick"},
+		Timestamp: time.Now(),
+		Lamport:   40,
+		Clock:     map[string]uint64{playerID: 3},
+	})
+	for message.Type != models.MessageTypeMoveAck {
+		if err := conn.ReadJSON(&message); err != nil {
+			t.Fatalf("Failed to read moveAck: %v", err)
+		}
+	}
+	if message.Lamport <= 40 || message.Clock[playerID] != 3 || message.Clock["lobby"] == 0 {
+		t.Errorf("Expected the ack to be stamped after the move, got %d %v", message.Lamport, message.Clock)
+	}
+
+	var kinds []string
+	for _, event := range tracker.History(0).Events {
+		if event.RequestID == "tick" {
+			kinds = append(kinds, event.Process+":"+event.Kind)
+		}
+	}
+	if want := "[" + playerID + ":send lobby:receive lobby:commit lobby:send]"; fmt.Sprint(kinds) != want {
+		t.Errorf("Expected the move's events %s, got %v", want, kinds)
+	}
+}
+
+func TestInputSequencesAreReconciled(t *testing.T) {
+	gameState := models.NewGameState(models.Position{X: 10, Y: 10})
+	controller := concurrency.NewConcurrencyController(gameState)
+	hub := NewHub(gameState, controller)
+	go hub.Run()
+
+	server := httptest.NewServer(http.HandlerFunc(hub.ServeWS))
+	defer server.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(3 * time.Second))
+
+	var message models.WebSocketMessage
+	conn.ReadJSON(&message) // initial snapshot
+	conn.WriteJSON(models.WebSocketMessage{
+		Type:      models.MessageTypeJoin,
+		Data:      models.JoinRequest{PlayerName: "Predictor"},
+		Timestamp: time.Now(),
+	})
+	conn.ReadJSON(&message) // join snapshot
+	playerID := message.PlayerID
+
+	// reply sends a move and returns its reply, checking that it echoes seq.
+	// Deltas read on the way update player.
+	var player models.Player
+	reply := func(direction, requestID string, seq uint64) (models.MessageType, models.ErrorResponse) {
+		t.Helper()
+		conn.WriteJSON(models.WebSocketMessage{
+			Type:      models.MessageTypeMove,
+			Data:      models.MoveRequest{Direction: direction, RequestID: requestID, Seq: seq},
+			Timestamp: time.Now(),
+		})
+		for {
+			var received struct {
+				Type models.MessageType `json:"type"`
+				Data json.RawMessage    `json:"data"`
+			}
+			if err := conn.ReadJSON(&received); err != nil {
+				t.Fatalf("Failed to read the reply to %s: %v", requestID, err)
+			}
+			if received.Type == models.MessageTypeDelta {
+				var delta models.GameStateDelta
+				json.Unmarshal(received.Data, &delta)
+				if p, ok := delta.Players[playerID]; ok {
+					player = *p
+				}
+				continue
+			}
+			var response models.ErrorResponse
+			json.Unmarshal(received.Data, &response)
+			if response.Seq != seq {
+				t.Errorf("Expected the reply to %s to echo seq %d, got %s", requestID, seq, received.Data)
+			}
+			return received.Type, response
+		}
+	}
+
+	if messageType, _ := reply("left", "first", 1); messageType != models.MessageTypeMoveAck {
+		t.Errorf("Expected input 1 to commit, got %s", messageType)
+	}
+	// A prepared cross-room transaction holds the object, so input 2 is
+	// processed but cannot commit
+	held, _ := controller.BeginTransaction("coordinator", "hold")
+	controller.ProposeMove(held.ID, "down")
+	if err := controller.Prepare(held.ID); err != nil {
+		t.Fatalf("Prepare failed: %v", err)
+	}
+	if messageType, response := reply("up", "second", 2); messageType != models.MessageTypeError || response.Code != models.ErrorCodeInDoubt {
+		t.Errorf("Expected input 2 to be rejected, got %s %+v", messageType, response)
+	}
+	controller.AbortPrepared(held.ID)
+	if _, response := reply("left", "stale", 2); response.Field != "seq" {
+		t.Errorf("Expected a reused sequence number to be refused, got %+v", response)
+	}
+	if messageType, _ := reply("left", "third", 5); messageType != models.MessageTypeMoveAck {
+		t.Errorf("Expected input 5 to commit after a gap, got %s", messageType)
+	}
+
+	// The state tells every client how far the player's inputs got
+	deadline := time.Now().Add(2 * time.Second)
+	for player.LastProcessedSeq != 5 && time.Now().Before(deadline) {
+		conn.WriteJSON(models.WebSocketMessage{Type: models.MessageTypeResync, Timestamp: time.Now()})
+		var snapshot struct {
+			Data models.GameStateSnapshot `json:"data"`
+		}
+		conn.ReadJSON(&snapshot)
+		if p, ok := snapshot.Data.Players[playerID]; ok {
+			player = *p
+		}
+	}
+	if player.LastProcessedSeq != 5 || fmt.Sprint(player.RejectedSeqs) != "[2]" {
+		t.Errorf("Expected inputs up to 5 processed and 2 rejected, got %+v", player)
+	}
+	if position := gameState.GetState().Object.Position; position != (models.Position{X: 3, Y: 5}) {
+		t.Errorf("Expected only inputs 1 and 5 to move the object, got %+v", position)
+	}
+}
@@ -0,0 +1,484 @@
+package websocket
+
+import (
+	"fmt"
+	"log"
+	"maps"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/AnishMulay/Transaction-Conflict-Visualization/internal/concurrency"
+	"github.com/AnishMulay/Transaction-Conflict-Visualization/pkg/models"
+)
+
+// matchPlayer is the player ID the match puts the object back at its start as
+const matchPlayer = "match"
+
+// resetAttempts is how often putting the object back is tried when moves
+// keep committing first
+const resetAttempts = 3
+
+// MatchConfig configures the matches a hub runs. A match is Rounds rounds;
+// each starts once MinPlayers connected players are in the room, after a
+// Countdown, lasts Round, and shows its results for Results. Before every
+// round and after every goal the object goes back to Start.
+type MatchConfig struct {
+	Rounds     int
+	MinPlayers int
+	Countdown  time.Duration
+	Round      time.Duration
+	Results    time.Duration
+	Start      models.Position
+}
+
+// matchLoop holds what a hub running matches tracks besides the match in
+// the game state
+type matchLoop struct {
+	config  MatchConfig
+	goals   chan concurrency.CommitRecord
+	joined  chan struct{}
+	timings chan struct{}
+
+	mu           sync.Mutex
+	results      map[string]*models.RoundResult
+	resetVersion int64
+}
+
+// SetMatch makes the hub run matches in its room: players join teams, one
+// per goal zone of the room's layout, and score by moving the object into
+// their team's zone during timed rounds. Moves outside a round are refused
+// with NOT_PLAYING. Call it before serving clients.
+func (h *Hub) SetMatch(config MatchConfig) {
+	h.match = &matchLoop{
+		config:  config,
+		goals:   make(chan concurrency.CommitRecord, 64),
+		joined:  make(chan struct{}, 1),
+		timings: make(chan struct{}, 1),
+		results: make(map[string]*models.RoundResult),
+	}
+
+	h.gameState.Mu.Lock()
+	h.gameState.Match = &models.Match{
+		Phase:  models.MatchPhaseLobby,
+		Rounds: config.Rounds,
+		Scores: h.teamScores(),
+		Totals: h.teamScores(),
+	}
+	h.gameState.Mu.Unlock()
+	h.lastSnapshot = h.gameState.GetState()
+
+	h.concurrencyController.OnCommit(h.goalCommitted)
+}
+
+// teamScores returns a score of zero for every team
+func (h *Hub) teamScores() map[string]int {
+	scores := make(map[string]int)
+	for _, goal := range h.gameState.Layout.Goals {
+		scores[goal.ID] = 0
+	}
+	return scores
+}
+
+// smallestTeam returns the team with the fewest players, the first of them
+// on a tie. Caller must hold the game state's lock.
+func (h *Hub) smallestTeam() string {
+	sizes := make(map[string]int)
+	for _, player := range h.gameState.Players {
+		sizes[player.Team]++
+	}
+	team := ""
+	for _, goal := range h.gameState.Layout.Goals {
+		if team == "" || sizes[goal.ID] < sizes[team] {
+			team = goal.ID
+		}
+	}
+	return team
+}
+
+// matchPhase returns the phase of the room's match; ok is false when the
+// room does not play matches
+func (h *Hub) matchPhase() (phase models.MatchPhase, ok bool) {
+	if h.match == nil {
+		return "", false
+	}
+	h.gameState.Mu.RLock()
+	defer h.gameState.Mu.RUnlock()
+	return h.gameState.Match.Phase, true
+}
+
+// playerJoined tells the match loop a player joined, which may fill the
+// lobby. Safe to call from any goroutine.
+func (h *Hub) playerJoined() {
+	if h.match == nil {
+		return
+	}
+	select {
+	case h.match.joined <- struct{}{}:
+	default:
+	}
+}
+
+// goalCommitted passes a commit that moved the object into a goal zone to
+// the match loop. It runs for every commit, so it never waits. Only commits
+// made during a round score: HTTP moves, sagas and cross-room transactions
+// commit without the hub's NOT_PLAYING check.
+func (h *Hub) goalCommitted(record concurrency.CommitRecord) {
+	if record.PlayerID == matchPlayer {
+		return
+	}
+	if phase, _ := h.matchPhase(); phase != models.MatchPhasePlaying {
+		return
+	}
+	if _, ok := h.gameState.Layout.GoalAt(record.Position); !ok {
+		return
+	}
+	select {
+	case h.match.goals <- record:
+	default:
+		log.Printf("Dropped goal by transaction %s: too many goals waiting", record.TransactionID)
+	}
+}
+
+// recordMatchOutcome counts the outcome of a player's move towards the
+// round's results. Moves outside a round are not counted.
+func (h *Hub) recordMatchOutcome(player *models.Player, outcomeType models.MessageType) {
+	if phase, ok := h.matchPhase(); !ok || phase != models.MatchPhasePlaying {
+		return
+	}
+
+	h.match.mu.Lock()
+	defer h.match.mu.Unlock()
+	result := h.roundResult(player)
+	switch outcomeType {
+	case models.MessageTypeMoveAck:
+		result.SuccessfulMoves++
+	case models.MessageTypeConflict:
+		result.ConflictsLost++
+	}
+}
+
+// roundResult returns the player's result in the current round. Caller must
+// hold the match loop's lock.
+func (h *Hub) roundResult(player *models.Player) *models.RoundResult {
+	result, ok := h.match.results[player.ID]
+	if !ok {
+		h.gameState.Mu.RLock()
+		result = &models.RoundResult{PlayerID: player.ID, PlayerName: player.Name, Team: player.Team}
+		h.gameState.Mu.RUnlock()
+		h.match.results[player.ID] = result
+	}
+	return result
+}
+
+// runMatch moves the match from phase to phase, as players join and phases
+// run out, and scores goals. It runs on its own goroutine because putting
+// the object back commits a transaction, which must not hold up the hub.
+func (h *Hub) runMatch() {
+	h.fillLobby()
+
+	for {
+		h.gameState.Mu.RLock()
+		endsAt := h.gameState.Match.PhaseEndsAt
+		h.gameState.Mu.RUnlock()
+
+		var timer *time.Timer
+		var deadline <-chan time.Time
+		if endsAt != nil {
+			timer = time.NewTimer(time.Until(*endsAt))
+			deadline = timer.C
+		}
+
+		select {
+		case <-h.match.joined:
+			h.fillLobby()
+		case record := <-h.match.goals:
+			h.scoreGoal(record)
+		case <-h.match.timings:
+			// The phase changed outside the loop; wait for its new end
+		case <-deadline:
+			h.advanceMatch()
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+	}
+}
+
+// updateMatch changes the match in the game state with fn, which reports
+// whether it changed anything, and tells the hub. The next delta carries the
+// change.
+func (h *Hub) updateMatch(fn func(match *models.Match) bool) bool {
+	h.gameState.Mu.Lock()
+	changed := fn(h.gameState.Match)
+	h.gameState.Mu.Unlock()
+	if !changed {
+		return false
+	}
+
+	select {
+	case h.match.timings <- struct{}{}:
+	default:
+	}
+	h.broadcastGameState()
+	return true
+}
+
+// announceMatch broadcasts a match message, after the delta with the change
+// it announces unless that waits for the next tick
+func (h *Hub) announceMatch(message models.WebSocketMessage) {
+	h.do(func() {
+		if h.ticks == nil {
+			h.publishState(nil)
+		}
+		h.broadcastMessage(message, nil)
+	})
+}
+
+// advanceMatch ends the current phase and starts the next
+func (h *Hub) advanceMatch() {
+	phase, _ := h.matchPhase()
+	switch phase {
+	case models.MatchPhaseLobby:
+		h.fillLobby()
+	case models.MatchPhaseCountdown:
+		h.startRound()
+	case models.MatchPhasePlaying:
+		h.endRound()
+	case models.MatchPhaseResults:
+		h.updateMatch(func(match *models.Match) bool {
+			if match.Phase != models.MatchPhaseResults {
+				return false
+			}
+			// After the last round a new match starts from nothing
+			if match.Round >= match.Rounds {
+				match.Round = 0
+				match.Totals = h.teamScores()
+			}
+			match.Phase, match.PhaseEndsAt = models.MatchPhaseLobby, nil
+			return true
+		})
+		h.fillLobby()
+	}
+}
+
+// fillLobby starts the countdown to the next round once the lobby has
+// enough connected players
+func (h *Hub) fillLobby() {
+	var round int
+	counting := h.updateMatch(func(match *models.Match) bool {
+		connected := 0
+		for _, player := range h.gameState.Players {
+			if player.Connected {
+				connected++
+			}
+		}
+		if match.Phase != models.MatchPhaseLobby || connected < h.match.config.MinPlayers {
+			return false
+		}
+
+		match.Round++
+		match.Phase, match.PhaseEndsAt = models.MatchPhaseCountdown, phaseEnd(h.match.config.Countdown)
+		match.Scores = h.teamScores()
+		round = match.Round
+		return true
+	})
+	if !counting {
+		return
+	}
+
+	h.match.mu.Lock()
+	h.match.results = make(map[string]*models.RoundResult)
+	h.match.mu.Unlock()
+
+	log.Printf("Round %d of %d starts in %v", round, h.match.config.Rounds, h.match.config.Countdown)
+	h.resetObject()
+}
+
+// startRound ends the countdown: moves count from now on
+func (h *Hub) startRound() {
+	var start models.RoundStart
+	started := h.updateMatch(func(match *models.Match) bool {
+		if match.Phase != models.MatchPhaseCountdown {
+			return false
+		}
+		match.Phase, match.PhaseEndsAt = models.MatchPhasePlaying, phaseEnd(h.match.config.Round)
+		start = models.RoundStart{
+			Round:      match.Round,
+			Rounds:     match.Rounds,
+			DurationMs: h.match.config.Round.Milliseconds(),
+			EndsAt:     *match.PhaseEndsAt,
+			Timestamp:  time.Now(),
+		}
+		return true
+	})
+	if started {
+		h.announceMatch(newMessage(models.MessageTypeRoundStart, start))
+	}
+}
+
+// endRound ends the round and reports its results
+func (h *Hub) endRound() {
+	var end models.RoundEnd
+	ended := h.updateMatch(func(match *models.Match) bool {
+		if match.Phase != models.MatchPhasePlaying {
+			return false
+		}
+		match.Phase, match.PhaseEndsAt = models.MatchPhaseResults, phaseEnd(h.match.config.Results)
+		end = models.RoundEnd{
+			Round:  match.Round,
+			Rounds: match.Rounds,
+			Final:  match.Round >= match.Rounds,
+			Scores: maps.Clone(match.Scores),
+			Totals: maps.Clone(match.Totals),
+			Winner: leadingTeam(match.Scores),
+		}
+		return true
+	})
+	if !ended {
+		return
+	}
+
+	end.Results = h.roundResults()
+	end.Timestamp = time.Now()
+
+	log.Printf("Round %d of %d over: %v", end.Round, end.Rounds, end.Scores)
+	h.announceMatch(newMessage(models.MessageTypeRoundEnd, end))
+}
+
+// roundResults returns every player's result in the round, including
+// players who left during it, best first
+func (h *Hub) roundResults() []models.RoundResult {
+	h.gameState.Mu.RLock()
+	players := make([]*models.Player, 0, len(h.gameState.Players))
+	for _, player := range h.gameState.Players {
+		players = append(players, player)
+	}
+	h.gameState.Mu.RUnlock()
+
+	h.match.mu.Lock()
+	for _, player := range players {
+		h.roundResult(player)
+	}
+	results := make([]models.RoundResult, 0, len(h.match.results))
+	for _, result := range h.match.results {
+		results = append(results, *result)
+	}
+	h.match.mu.Unlock()
+
+	sort.Slice(results, func(a, b int) bool {
+		if results[a].Goals != results[b].Goals {
+			return results[a].Goals > results[b].Goals
+		}
+		if results[a].SuccessfulMoves != results[b].SuccessfulMoves {
+			return results[a].SuccessfulMoves > results[b].SuccessfulMoves
+		}
+		return results[a].PlayerID < results[b].PlayerID
+	})
+	return results
+}
+
+// scoreGoal scores a commit that moved the object into a goal zone during a
+// round for the zone's team, and for the player who made it if that is
+// their team, then puts the object back. Commits older than the last time
+// the object was put back no longer count.
+func (h *Hub) scoreGoal(record concurrency.CommitRecord) {
+	h.match.mu.Lock()
+	stale := record.Version <= h.match.resetVersion
+	h.match.mu.Unlock()
+	if stale {
+		return
+	}
+
+	team, _ := h.gameState.Layout.GoalAt(record.Position)
+	var update models.ScoreUpdate
+	scored := h.updateMatch(func(match *models.Match) bool {
+		if match.Phase != models.MatchPhasePlaying {
+			return false
+		}
+		match.Scores[team]++
+		match.Totals[team]++
+		update = models.ScoreUpdate{
+			Round:         match.Round,
+			Team:          team,
+			TransactionID: record.TransactionID,
+			Version:       record.Version,
+			Position:      record.Position,
+			Scores:        maps.Clone(match.Scores),
+			Totals:        maps.Clone(match.Totals),
+		}
+		return true
+	})
+	if !scored {
+		return
+	}
+
+	h.gameState.Mu.RLock()
+	scorer, ok := h.gameState.Players[record.PlayerID]
+	h.gameState.Mu.RUnlock()
+	if ok {
+		update.PlayerID, update.PlayerName = scorer.ID, h.playerName(scorer.ID)
+		h.match.mu.Lock()
+		if result := h.roundResult(scorer); result.Team == team {
+			result.Goals++
+		}
+		h.match.mu.Unlock()
+	}
+	update.Timestamp = time.Now()
+
+	log.Printf("Goal for %s in round %d by transaction %s", team, update.Round, record.TransactionID)
+	h.announceMatch(newMessage(models.MessageTypeScoreUpdate, update))
+	h.resetObject()
+}
+
+// resetObject puts the object back at the start in a transaction of its own
+func (h *Hub) resetObject() {
+	var err error
+	for attempt := 1; attempt <= resetAttempts; attempt++ {
+		var snapshot *models.GameStateSnapshot
+		if snapshot, err = h.placeObject(h.match.config.Start); err == nil {
+			h.match.mu.Lock()
+			h.match.resetVersion = snapshot.Object.Version
+			h.match.mu.Unlock()
+			h.broadcastGameState()
+			return
+		}
+	}
+	log.Printf("Failed to put the object back at the start: %v", err)
+}
+
+// placeObject commits moving the object straight to position
+func (h *Hub) placeObject(position models.Position) (*models.GameStateSnapshot, error) {
+	transaction, err := h.concurrencyController.BeginTransaction(matchPlayer, fmt.Sprintf("reset-%d", time.Now().UnixNano()))
+	if err != nil {
+		return nil, err
+	}
+	if err := h.concurrencyController.ProposePosition(transaction.ID, position); err != nil {
+		h.concurrencyController.AbortTransaction(transaction.ID)
+		return nil, err
+	}
+	return h.concurrencyController.CommitTransaction(transaction.ID)
+}
+
+// phaseEnd returns when a phase lasting duration from now ends
+func phaseEnd(duration time.Duration) *time.Time {
+	end := time.Now().Add(duration)
+	return &end
+}
+
+// leadingTeam returns the team with the highest score, or "" on a tie
+func leadingTeam(scores map[string]int) string {
+	leader, best, tied := "", -1, false
+	for team, score := range scores {
+		switch {
+		case score > best:
+			leader, best, tied = team, score, false
+		case score == best:
+			tied = true
+		}
+	}
+	if tied {
+		return ""
+	}
+	return leader
+}
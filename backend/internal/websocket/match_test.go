@@ -0,0 +1,197 @@
+package websocket
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/AnishMulay/Transaction-Conflict-Visualization/internal/concurrency"
+	"github.com/AnishMulay/Transaction-Conflict-Visualization/internal/httpapi"
+	"github.com/AnishMulay/Transaction-Conflict-Visualization/pkg/models"
+
+	"github.com/gorilla/websocket"
+)
+
+// readMatchMessage returns the data of the next message of messageType,
+// skipping the others
+func readMatchMessage(t *testing.T, conn *websocket.Conn, messageType models.MessageType, data interface{}) {
+	t.Helper()
+	for {
+		var message struct {
+			Type models.MessageType `json:"type"`
+			Data json.RawMessage    `json:"data"`
+		}
+		if err := conn.ReadJSON(&message); err != nil {
+			t.Fatalf("Failed to read a %s message: %v", messageType, err)
+		}
+		if message.Type == messageType {
+			json.Unmarshal(message.Data, data)
+			return
+		}
+	}
+}
+
+// waitForMatch polls until the match satisfies done
+func waitForMatch(t *testing.T, gameState *models.GameState, done func(snapshot models.GameStateSnapshot) bool) models.GameStateSnapshot {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		snapshot := gameState.GetState()
+		if done(snapshot) {
+			return snapshot
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Timed out waiting for the match, at %+v with the object at %+v", snapshot.Match, snapshot.Object.Position)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestMatchPlaysTimedRounds(t *testing.T) {
+	// Two one-cell goal zones either side of the start; phases only end
+	// when the test advances them
+	gameState := models.NewGameState(models.Position{X: 10, Y: 10})
+	gameState.Layout = models.NewLayout("test", nil, []models.GoalZone{
+		{ID: "goal-1", Cells: []models.Position{{X: 6, Y: 5}}},
+		{ID: "goal-2", Cells: []models.Position{{X: 4, Y: 5}}},
+	})
+	hub := NewHub(gameState, concurrency.NewConcurrencyController(gameState))
+	hub.SetMatch(MatchConfig{Rounds: 1, MinPlayers: 2, Countdown: time.Hour, Round: time.Hour, Results: time.Hour, Start: models.Position{X: 5, Y: 5}})
+	go hub.Run()
+
+	conns := joinPlayers(t, hub, "Ada", "Bo")
+	snapshot := waitForMatch(t, gameState, func(snapshot models.GameStateSnapshot) bool {
+		return snapshot.Match.Phase == models.MatchPhaseCountdown
+	})
+	teams := map[string]string{}
+	for _, player := range snapshot.Players {
+		teams[player.Name] = player.Team
+	}
+	if teams["Ada"] != "goal-1" || teams["Bo"] != "goal-2" || snapshot.Match.Round != 1 {
+		t.Fatalf("Expected round 1 counting down with one player per team, got %+v and teams %v", snapshot.Match, teams)
+	}
+
+	// Moves wait for the round
+	conns[0].WriteJSON(models.WebSocketMessage{Type: models.MessageTypeMove, Data: models.MoveRequest{Direction: "right", RequestID: "early"}, Timestamp: time.Now()})
+	if _, _, refusal := readReply(t, conns[0]); refusal.Code != models.ErrorCodeNotPlaying {
+		t.Fatalf("Expected a move during the countdown refused, got %+v", refusal)
+	}
+
+	hub.advanceMatch()
+	var start models.RoundStart
+	readMatchMessage(t, conns[1], models.MessageTypeRoundStart, &start)
+	if start.Round != 1 || start.Rounds != 1 {
+		t.Errorf("Expected round 1 of 1 to start, got %+v", start)
+	}
+
+	// Each player scores in their own zone; the object goes back in between
+	var update models.ScoreUpdate
+	conns[0].WriteJSON(models.WebSocketMessage{Type: models.MessageTypeMove, Data: models.MoveRequest{Direction: "right", RequestID: "a"}, Timestamp: time.Now()})
+	readMatchMessage(t, conns[1], models.MessageTypeScoreUpdate, &update)
+	if update.Team != "goal-1" || update.PlayerName != "Ada" || update.Scores["goal-1"] != 1 {
+		t.Errorf("Expected Ada to score for goal-1, got %+v", update)
+	}
+	waitForMatch(t, gameState, func(snapshot models.GameStateSnapshot) bool {
+		return snapshot.Object.Position == models.Position{X: 5, Y: 5}
+	})
+
+	conns[1].WriteJSON(models.WebSocketMessage{Type: models.MessageTypeMove, Data: models.MoveRequest{Direction: "left", RequestID: "b"}, Timestamp: time.Now()})
+	readMatchMessage(t, conns[1], models.MessageTypeScoreUpdate, &update)
+	if update.Team != "goal-2" || update.Totals["goal-1"] != 1 || update.Totals["goal-2"] != 1 {
+		t.Errorf("Expected Bo to score for goal-2, got %+v", update)
+	}
+
+	hub.advanceMatch()
+	var end models.RoundEnd
+	readMatchMessage(t, conns[0], models.MessageTypeRoundEnd, &end)
+	if !end.Final || end.Winner != "" || len(end.Results) != 2 {
+		t.Fatalf("Expected the final round tied with two results, got %+v", end)
+	}
+	for _, result := range end.Results {
+		if result.SuccessfulMoves != 1 || result.Goals != 1 || result.ConflictsLost != 0 {
+			t.Errorf("Expected one move and one goal each, got %+v", result)
+		}
+	}
+
+	// After the last round a new match starts, the lobby being full
+	hub.advanceMatch()
+	snapshot = waitForMatch(t, gameState, func(snapshot models.GameStateSnapshot) bool {
+		return snapshot.Match.Phase == models.MatchPhaseCountdown
+	})
+	if snapshot.Match.Round != 1 || snapshot.Match.Totals["goal-1"] != 0 {
+		t.Errorf("Expected a new match from round 1, got %+v", snapshot.Match)
+	}
+}
+
+func TestOnlyCommitsDuringARoundScore(t *testing.T) {
+	gameState := models.NewGameState(models.Position{X: 10, Y: 10})
+	gameState.Layout = models.NewLayout("test", nil, []models.GoalZone{
+		{ID: "goal-1", Cells: []models.Position{{X: 6, Y: 5}}},
+	})
+	controller := concurrency.NewConcurrencyController(gameState)
+	hub := NewHub(gameState, controller)
+	hub.SetMatch(MatchConfig{Rounds: 1, MinPlayers: 1, Countdown: time.Hour, Round: time.Hour, Results: time.Hour, Start: models.Position{X: 5, Y: 5}})
+
+	// An HTTP move, a saga step or a cross-room transaction into the goal,
+	// none of which the hub refuses outside a round
+	commitGoal := func(phase models.MatchPhase) {
+		t.Helper()
+		gameState.Mu.Lock()
+		gameState.Match.Phase = phase
+		gameState.Mu.Unlock()
+
+		for _, position := range []models.Position{{X: 5, Y: 5}, {X: 6, Y: 5}} {
+			transaction, _ := controller.BeginTransaction("http", string(phase))
+			controller.ProposePosition(transaction.ID, position)
+			if _, err := controller.CommitTransaction(transaction.ID); err != nil {
+				t.Fatalf("Commit in the %s failed: %v", phase, err)
+			}
+		}
+	}
+
+	for _, phase := range []models.MatchPhase{models.MatchPhaseLobby, models.MatchPhaseCountdown, models.MatchPhaseResults} {
+		commitGoal(phase)
+		if queued := len(hub.match.goals); queued != 0 {
+			t.Errorf("Expected a goal in the %s not to score, %d queued", phase, queued)
+		}
+	}
+
+	commitGoal(models.MatchPhasePlaying)
+	if queued := len(hub.match.goals); queued != 1 {
+		t.Errorf("Expected a goal during the round to score, %d queued", queued)
+	}
+}
+
+func TestHTTPMoveOutsideARoundDoesNotScore(t *testing.T) {
+	gameState := models.NewGameState(models.Position{X: 10, Y: 10})
+	gameState.Layout = models.NewLayout("test", nil, []models.GoalZone{
+		{ID: "goal-1", Cells: []models.Position{{X: 6, Y: 5}}},
+	})
+	controller := concurrency.NewConcurrencyController(gameState)
+	hub := NewHub(gameState, controller)
+	hub.SetMatch(MatchConfig{Rounds: 1, MinPlayers: 1, Countdown: time.Hour, Round: time.Hour, Results: time.Hour, Start: models.Position{X: 5, Y: 5}})
+
+	mux := http.NewServeMux()
+	httpapi.NewObjectAPI(gameState, controller, hub.StateChanged).Register(mux)
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	// The lobby refuses WebSocket moves but not plain HTTP ones
+	req, _ := http.NewRequest(http.MethodPost, server.URL+"/object/move", strings.NewReader(`{"direction":"right"}`))
+	req.Header.Set("If-Match", "*")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("POST /object/move failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || gameState.GetState().Object.Position != (models.Position{X: 6, Y: 5}) {
+		t.Fatalf("Expected the HTTP move into the goal to commit, got %d", resp.StatusCode)
+	}
+
+	if queued := len(hub.match.goals); queued != 0 {
+		t.Errorf("Expected the HTTP move in the lobby not to score, %d queued", queued)
+	}
+}
@@ -0,0 +1,189 @@
+package websocket
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/AnishMulay/Transaction-Conflict-Visualization/internal/tick"
+	"github.com/AnishMulay/Transaction-Conflict-Visualization/pkg/models"
+)
+
+// tickPlayer is the player ID a tick's combined move is made as
+const tickPlayer = "tick"
+
+// tickLoop holds the moves a hub in tick mode received since the last tick
+type tickLoop struct {
+	interval time.Duration
+	rule     tick.Rule
+
+	mu     sync.Mutex
+	queued []queuedMove
+	count  int64
+}
+
+// queuedMove is a move waiting for the next tick
+type queuedMove struct {
+	client     *Client
+	request    models.MoveRequest
+	receivedAt time.Time
+}
+
+// SetTick switches the hub to tick mode: the moves received during each
+// interval are combined by rule and committed together at the end of it.
+// Call it before serving clients.
+func (h *Hub) SetTick(interval time.Duration, rule tick.Rule) {
+	h.ticks = &tickLoop{interval: interval, rule: rule}
+}
+
+// queue holds a move until the next tick. A resend of a move that is
+// already waiting is dropped; the tick answers the original.
+func (t *tickLoop) queue(client *Client, request models.MoveRequest, receivedAt time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, move := range t.queued {
+		if move.client.playerID == client.playerID && move.request.RequestID == request.RequestID {
+			return
+		}
+	}
+	t.queued = append(t.queued, queuedMove{client: client, request: request, receivedAt: receivedAt})
+}
+
+// take starts the next tick and returns its number with the moves received
+// during it, in order of arrival
+func (t *tickLoop) take() (int64, []queuedMove) {
+	t.mu.Lock()
+	t.count++
+	count, moves := t.count, t.queued
+	t.queued = nil
+	t.mu.Unlock()
+
+	sort.SliceStable(moves, func(a, b int) bool { return moves[a].receivedAt.Before(moves[b].receivedAt) })
+	return count, moves
+}
+
+// runTicks resolves the queued moves every interval and publishes the
+// result. It runs on its own goroutine because a commit can wait for locks,
+// which must not hold up the hub.
+func (h *Hub) runTicks() {
+	ticker := time.NewTicker(h.ticks.interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		summary := h.resolveTick()
+		h.do(func() {
+			h.publishState(nil)
+			if summary != nil {
+				h.broadcastMessage(newMessage(models.MessageTypeTick, *summary), nil)
+			}
+		})
+	}
+}
+
+// resolveTick combines the moves of one tick, commits the result and
+// answers every move. It returns nil when no move arrived.
+func (h *Hub) resolveTick() *models.Tick {
+	count, moves := h.ticks.take()
+	if len(moves) == 0 {
+		return nil
+	}
+
+	inputs := make([]tick.Input, len(moves))
+	for i, move := range moves {
+		inputs[i] = tick.Input{
+			PlayerID:   move.client.playerID,
+			RequestID:  move.request.RequestID,
+			Direction:  move.request.Direction,
+			ReceivedAt: move.receivedAt,
+		}
+	}
+	resolution := tick.Resolve(h.ticks.rule, inputs)
+
+	summary := &models.Tick{
+		Tick:       count,
+		Rule:       string(h.ticks.rule),
+		IntervalMs: float64(h.ticks.interval.Microseconds()) / 1000,
+		Inputs:     make([]models.TickInput, 0, len(moves)),
+		DX:         resolution.DX,
+		DY:         resolution.DY,
+		Detail:     resolution.Detail,
+	}
+	transactionID, snapshot, err := h.commitTick(count, resolution)
+	if err != nil {
+		object := h.concurrencyController.Object()
+		summary.Version, summary.Position, summary.Error = object.Version, object.Position, err.Error()
+	} else {
+		summary.TransactionID, summary.Version, summary.Position = transactionID, snapshot.Object.Version, snapshot.Object.Position
+	}
+
+	for i, move := range moves {
+		var outcomeType models.MessageType
+		var outcome interface{}
+		switch {
+		case !resolution.Accepted[i]:
+			outcomeType, outcome = errorResponse(fmt.Sprintf("Overruled in tick %d: %s", count, resolution.Detail),
+				models.ErrorCodeOverruled, move.request.RequestID)
+		case err != nil:
+			outcomeType, outcome = h.commitErrorResponse(err, move.request.RequestID)
+			if conflict, ok := outcome.(models.ConflictResponse); ok {
+				conflict.RequestID = move.request.RequestID
+				outcome = conflict
+			}
+		default:
+			h.touchPlayer(move.client.player)
+			outcomeType, outcome = models.MessageTypeMoveAck, models.MoveAck{
+				RequestID:       move.request.RequestID,
+				TransactionID:   transactionID,
+				Version:         snapshot.Object.Version,
+				Position:        snapshot.Object.Position,
+				ServerLatencyMs: float64(time.Since(move.receivedAt).Microseconds()) / 1000,
+				Timestamp:       time.Now(),
+			}
+		}
+
+		h.recordMatchOutcome(move.client.player, outcomeType)
+		outcome = withSeq(outcome, move.request.Seq)
+		h.moveOutcomes.record(move.client.playerID, move.request.RequestID, outcomeType, outcome)
+		if move.request.Seq != 0 {
+			h.processInput(move.client.player, move.request.Seq, outcomeType != models.MessageTypeMoveAck)
+		}
+		move.client.replyToMove(&move.request, outcomeType, outcome)
+
+		summary.Inputs = append(summary.Inputs, models.TickInput{
+			PlayerID:   move.client.playerID,
+			PlayerName: h.playerName(move.client.playerID),
+			RequestID:  move.request.RequestID,
+			Seq:        move.request.Seq,
+			Direction:  move.request.Direction,
+			Accepted:   resolution.Accepted[i],
+		})
+	}
+
+	summary.Timestamp = time.Now()
+	return summary
+}
+
+// commitTick commits the combined move of a tick as one transaction. Moves
+// that cancel out leave the object where it is, so nothing is committed and
+// the transaction ID is empty.
+func (h *Hub) commitTick(count int64, resolution tick.Resolution) (string, *models.GameStateSnapshot, error) {
+	if resolution.DX == 0 && resolution.DY == 0 {
+		object := h.concurrencyController.Object()
+		return "", &models.GameStateSnapshot{Object: &object}, nil
+	}
+
+	transaction, err := h.concurrencyController.BeginTransaction(tickPlayer, fmt.Sprintf("tick-%d", count))
+	if err != nil {
+		return "", nil, err
+	}
+	if err := h.concurrencyController.ProposeOffset(transaction.ID, resolution.DX, resolution.DY); err != nil {
+		h.concurrencyController.AbortTransaction(transaction.ID)
+		return "", nil, err
+	}
+	snapshot, err := h.concurrencyController.CommitTransaction(transaction.ID)
+	if err != nil {
+		return "", nil, err
+	}
+	return transaction.ID, snapshot, nil
+}
@@ -0,0 +1,184 @@
+package websocket
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/AnishMulay/Transaction-Conflict-Visualization/internal/concurrency"
+	"github.com/AnishMulay/Transaction-Conflict-Visualization/internal/tick"
+	"github.com/AnishMulay/Transaction-Conflict-Visualization/pkg/models"
+
+	"github.com/gorilla/websocket"
+)
+
+// tickPlayers connects and joins one client per name to a hub in tick mode
+// whose ticks only run when the test resolves them
+func tickPlayers(t *testing.T, rule tick.Rule, names ...string) (*Hub, *models.GameState, []*websocket.Conn) {
+	t.Helper()
+	gameState := models.NewGameState(models.Position{X: 10, Y: 10})
+	hub := NewHub(gameState, concurrency.NewConcurrencyController(gameState))
+	hub.SetTick(time.Hour, rule)
+	go hub.Run()
+	return hub, gameState, joinPlayers(t, hub, names...)
+}
+
+// joinPlayers serves a running hub and connects and joins one client per name
+func joinPlayers(t *testing.T, hub *Hub, names ...string) []*websocket.Conn {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(hub.ServeWS))
+	t.Cleanup(server.Close)
+
+	var conns []*websocket.Conn
+	for _, name := range names {
+		conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+		if err != nil {
+			t.Fatalf("Failed to connect: %v", err)
+		}
+		t.Cleanup(func() { conn.Close() })
+		conn.SetReadDeadline(time.Now().Add(3 * time.Second))
+
+		var message models.WebSocketMessage
+		conn.ReadJSON(&message) // initial snapshot
+		conn.WriteJSON(models.WebSocketMessage{
+			Type:      models.MessageTypeJoin,
+			Data:      models.JoinRequest{PlayerName: name},
+			Timestamp: time.Now(),
+		})
+		for message.Type != models.MessageTypeGameState || message.PlayerID == "" {
+			if err := conn.ReadJSON(&message); err != nil {
+				t.Fatalf("Failed to join %s: %v", name, err)
+			}
+		}
+		conns = append(conns, conn)
+	}
+	return conns
+}
+
+// waitForQueued polls until the hub holds count moves for the next tick
+func waitForQueued(t *testing.T, hub *Hub, count int) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		hub.ticks.mu.Lock()
+		queued := len(hub.ticks.queued)
+		hub.ticks.mu.Unlock()
+		if queued == count {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected %d queued moves, got %d", count, queued)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// readReply returns the reply to a move, skipping broadcasts
+func readReply(t *testing.T, conn *websocket.Conn) (models.MessageType, models.MoveAck, models.ErrorResponse) {
+	t.Helper()
+	for {
+		var message struct {
+			Type models.MessageType `json:"type"`
+			Data json.RawMessage    `json:"data"`
+		}
+		if err := conn.ReadJSON(&message); err != nil {
+			t.Fatalf("Failed to read the reply: %v", err)
+		}
+		var ack models.MoveAck
+		var refusal models.ErrorResponse
+		switch message.Type {
+		case models.MessageTypeMoveAck:
+			json.Unmarshal(message.Data, &ack)
+			return message.Type, ack, refusal
+		case models.MessageTypeError, models.MessageTypeConflict:
+			json.Unmarshal(message.Data, &refusal)
+			return message.Type, ack, refusal
+		}
+	}
+}
+
+func TestTickCombinesMovesIntoOneCommit(t *testing.T) {
+	hub, gameState, conns := tickPlayers(t, tick.RuleVectorSum, "Ada", "Bo")
+
+	conns[0].WriteJSON(models.WebSocketMessage{Type: models.MessageTypeMove, Data: models.MoveRequest{Direction: "right", RequestID: "a", Seq: 1}, Timestamp: time.Now()})
+	conns[1].WriteJSON(models.WebSocketMessage{Type: models.MessageTypeMove, Data: models.MoveRequest{Direction: "down", RequestID: "b"}, Timestamp: time.Now()})
+	waitForQueued(t, hub, 2)
+
+	// Nothing commits before the tick
+	if version := gameState.GetState().Object.Version; version != 1 {
+		t.Fatalf("Expected no commit before the tick, got version %d", version)
+	}
+
+	summary := hub.resolveTick()
+	if summary == nil || summary.Position != (models.Position{X: 6, Y: 6}) || summary.Version != 2 || len(summary.Inputs) != 2 {
+		t.Fatalf("Expected one commit moving the object to (6, 6), got %+v", summary)
+	}
+
+	_, first, _ := readReply(t, conns[0])
+	_, second, _ := readReply(t, conns[1])
+	if first.TransactionID != summary.TransactionID || second.TransactionID != summary.TransactionID || first.Seq != 1 {
+		t.Errorf("Expected both moves acknowledged by the tick's transaction, got %+v and %+v", first, second)
+	}
+
+	if hub.resolveTick() != nil {
+		t.Error("Expected a tick without moves to report nothing")
+	}
+}
+
+func TestTickOverrulesLosingMoves(t *testing.T) {
+	hub, gameState, conns := tickPlayers(t, tick.RuleFirstArrival, "Ada", "Bo")
+
+	conns[0].WriteJSON(models.WebSocketMessage{Type: models.MessageTypeMove, Data: models.MoveRequest{Direction: "up", RequestID: "a"}, Timestamp: time.Now()})
+	waitForQueued(t, hub, 1)
+	conns[1].WriteJSON(models.WebSocketMessage{Type: models.MessageTypeMove, Data: models.MoveRequest{Direction: "left", RequestID: "b", Seq: 7}, Timestamp: time.Now()})
+	waitForQueued(t, hub, 2)
+	hub.resolveTick()
+
+	if messageType, _, _ := readReply(t, conns[0]); messageType != models.MessageTypeMoveAck {
+		t.Errorf("Expected the first move to win, got %s", messageType)
+	}
+	if _, _, refusal := readReply(t, conns[1]); refusal.Code != models.ErrorCodeOverruled || refusal.Seq != 7 {
+		t.Errorf("Expected the second move overruled, got %+v", refusal)
+	}
+
+	snapshot := gameState.GetState()
+	if snapshot.Object.Position != (models.Position{X: 5, Y: 4}) {
+		t.Errorf("Expected only the first move applied, got %+v", snapshot.Object.Position)
+	}
+	for _, player := range snapshot.Players {
+		if player.Name == "Bo" && (player.LastProcessedSeq != 7 || len(player.RejectedSeqs) != 1) {
+			t.Errorf("Expected Bo's overruled input reported, got %+v", player)
+		}
+	}
+}
+
+func TestTickThatCancelsOutCommitsNothing(t *testing.T) {
+	hub, gameState, conns := tickPlayers(t, tick.RuleVectorSum, "Ada", "Bo")
+
+	conns[0].WriteJSON(models.WebSocketMessage{Type: models.MessageTypeMove, Data: models.MoveRequest{Direction: "left", RequestID: "a"}, Timestamp: time.Now()})
+	conns[1].WriteJSON(models.WebSocketMessage{Type: models.MessageTypeMove, Data: models.MoveRequest{Direction: "right", RequestID: "b"}, Timestamp: time.Now()})
+	waitForQueued(t, hub, 2)
+
+	summary := hub.resolveTick()
+	if summary == nil || summary.DX != 0 || summary.DY != 0 || summary.TransactionID != "" || summary.Error != "" {
+		t.Fatalf("Expected the moves to cancel out without a transaction, got %+v", summary)
+	}
+	if summary.Version != 1 || summary.Position != (models.Position{X: 5, Y: 5}) {
+		t.Errorf("Expected the tick to report the unmoved object, got version %d at %v", summary.Version, summary.Position)
+	}
+
+	for _, conn := range conns {
+		if messageType, ack, _ := readReply(t, conn); messageType != models.MessageTypeMoveAck || ack.Version != 1 {
+			t.Errorf("Expected the move acknowledged at the current version, got %s %+v", messageType, ack)
+		}
+	}
+	if version := gameState.GetState().Object.Version; version != 1 {
+		t.Errorf("Expected no commit, object at version %d", version)
+	}
+	if stats := hub.concurrencyController.GetConflictStats(); stats.TotalTransactions != 0 {
+		t.Errorf("Expected no transaction, got %d", stats.TotalTransactions)
+	}
+}
@@ -0,0 +1,50 @@
+package models
+
+import "time"
+
+// CausalEvent is something that happened in one process, a player's client
+// or a server room, with its logical time. Lamport orders events consistently
+// with happens-before; Clock, a vector clock, tells exactly which events
+// happened before this one. A send and the receive of the same message share
+// Message, and Peer names the other end. Inferred is set on a client's send
+// that the client did not stamp, whose logical time the server made up from
+// the client's earlier sends. Timestamp is the server's
+// wall clock when it learned of the event, for reference only.
+type CausalEvent struct {
+	ID            uint64            `json:"id"`
+	Process       string            `json:"process"`
+	Kind          string            `json:"kind"`
+	Lamport       uint64            `json:"lamport"`
+	Clock         map[string]uint64 `json:"clock"`
+	Peer          string            `json:"peer,omitempty"`
+	Message       uint64            `json:"message,omitempty"`
+	Label         string            `json:"label,omitempty"`
+	TransactionID string            `json:"transactionId,omitempty"`
+	RequestID     string            `json:"requestId,omitempty"`
+	Inferred      bool              `json:"inferred,omitempty"`
+	Timestamp     time.Time         `json:"timestamp"`
+}
+
+// CausalHistory is the recent events of every process in an order
+// consistent with happens-before, for drawing space-time diagrams. Processes
+// are the diagram's lines, in order of their first event. Messages pairs
+// sends with receives whose events are both in Events.
+type CausalHistory struct {
+	Processes []string        `json:"processes"`
+	Events    []CausalEvent   `json:"events"`
+	Messages  []CausalMessage `json:"messages"`
+	Timestamp time.Time       `json:"timestamp"`
+}
+
+// CausalMessage is a message from the event Send to the event Receive
+type CausalMessage struct {
+	Send    uint64 `json:"send"`
+	Receive uint64 `json:"receive"`
+}
+
+// CausalOrder is how two events relate: before, after, concurrent or equal
+type CausalOrder struct {
+	A        CausalEvent `json:"a"`
+	B        CausalEvent `json:"b"`
+	Relation string      `json:"relation"`
+}
@@ -1,6 +1,7 @@
 package models
 
 import (
+	"slices"
 	"sync"
 	"time"
 
@@ -21,16 +22,48 @@ type GameObject struct {
 	LastUpdated time.Time `json:"lastUpdated"`
 }
 
-// Player represents a connected player
+// Player represents a connected player. LastProcessedSeq is the input
+// sequence number of the latest move the server ran for the player, and
+// RejectedSeqs are the most recent of those moves that did not commit, so a
+// client predicting its moves can drop every input up to LastProcessedSeq and
+// replay the rest on top of the state it received. Team is the goal zone the
+// player scores in when the room plays matches.
 type Player struct {
-	ID        string    `json:"id"`
-	Name      string    `json:"name"`
-	Color     string    `json:"color"`
-	Connected bool      `json:"connected"`
-	LastSeen  time.Time `json:"lastSeen"`
+	ID               string    `json:"id"`
+	Name             string    `json:"name"`
+	Color            string    `json:"color"`
+	Connected        bool      `json:"connected"`
+	LastSeen         time.Time `json:"lastSeen"`
+	LastProcessedSeq uint64    `json:"lastProcessedSeq,omitempty"`
+	RejectedSeqs     []uint64  `json:"rejectedSeqs,omitempty"`
+	Team             string    `json:"team,omitempty"`
 }
 
-// GameState represents the complete state of the game
+// MaxRejectedSeqs is how many rejected input sequence numbers a player keeps
+const MaxRejectedSeqs = 16
+
+// ProcessInput records that the move numbered seq ran, and whether it was
+// rejected. Caller must hold the game state's lock.
+func (p *Player) ProcessInput(seq uint64, rejected bool) {
+	p.LastProcessedSeq = seq
+	if rejected {
+		p.RejectedSeqs = append(p.RejectedSeqs, seq)
+		if len(p.RejectedSeqs) > MaxRejectedSeqs {
+			p.RejectedSeqs = append([]uint64(nil), p.RejectedSeqs[len(p.RejectedSeqs)-MaxRejectedSeqs:]...)
+		}
+	}
+}
+
+// equal reports whether two copies of a player are the same
+func (p *Player) equal(other *Player) bool {
+	return p.ID == other.ID && p.Name == other.Name && p.Color == other.Color &&
+		p.Connected == other.Connected && p.LastSeen.Equal(other.LastSeen) &&
+		p.LastProcessedSeq == other.LastProcessedSeq && slices.Equal(p.RejectedSeqs, other.RejectedSeqs) &&
+		p.Team == other.Team
+}
+
+// GameState represents the complete state of the game. Layout is nil on an
+// empty grid, and Match when the room does not play matches.
 type GameState struct {
 	Mu         sync.RWMutex
 	Object     *GameObject        `json:"object"`
@@ -38,6 +71,8 @@ type GameState struct {
 	Version    int64              `json:"version"`
 	MaxPlayers int                `json:"maxPlayers"`
 	GridSize   Position           `json:"gridSize"`
+	Layout     *Layout            `json:"layout,omitempty"`
+	Match      *Match             `json:"match,omitempty"`
 }
 
 // NewGameState creates a new game state with initial values
@@ -64,14 +99,22 @@ func (gs *GameState) GetState() GameStateSnapshot {
 	playersSnapshot := make(map[string]*Player)
 	for id, player := range gs.Players {
 		playersSnapshot[id] = &Player{
-			ID:        player.ID,
-			Name:      player.Name,
-			Color:     player.Color,
-			Connected: player.Connected,
-			LastSeen:  player.LastSeen,
+			ID:               player.ID,
+			Name:             player.Name,
+			Color:            player.Color,
+			Connected:        player.Connected,
+			LastSeen:         player.LastSeen,
+			LastProcessedSeq: player.LastProcessedSeq,
+			RejectedSeqs:     slices.Clone(player.RejectedSeqs),
+			Team:             player.Team,
 		}
 	}
 
+	var match *Match
+	if gs.Match != nil {
+		match = gs.Match.clone()
+	}
+
 	return GameStateSnapshot{
 		Object: &GameObject{
 			ID:          gs.Object.ID,
@@ -83,6 +126,8 @@ func (gs *GameState) GetState() GameStateSnapshot {
 		Version:    gs.Version,
 		MaxPlayers: gs.MaxPlayers,
 		GridSize:   gs.GridSize,
+		Layout:     gs.Layout,
+		Match:      match,
 	}
 }
 
@@ -93,4 +138,56 @@ type GameStateSnapshot struct {
 	Version    int64              `json:"version"`
 	MaxPlayers int                `json:"maxPlayers"`
 	GridSize   Position           `json:"gridSize"`
+	Layout     *Layout            `json:"layout,omitempty"`
+	Match      *Match             `json:"match,omitempty"`
+	Seq        int64              `json:"seq"`
+}
+
+// GameStateDelta carries only what changed between two consecutive broadcasts.
+// A client holding the snapshot or delta numbered Seq-1 applies it by replacing
+// the object and the match (if present), upserting Players and deleting
+// RemovedPlayers.
+type GameStateDelta struct {
+	Seq            int64              `json:"seq"`
+	Version        int64              `json:"version"`
+	Object         *GameObject        `json:"object,omitempty"`
+	Players        map[string]*Player `json:"players,omitempty"`
+	RemovedPlayers []string           `json:"removedPlayers,omitempty"`
+	Match          *Match             `json:"match,omitempty"`
+}
+
+// DiffSnapshots returns the changes from prev to next. The returned delta has
+// no sequence number; ok is false when nothing changed.
+func DiffSnapshots(prev, next GameStateSnapshot) (delta GameStateDelta, ok bool) {
+	delta.Version = next.Version
+
+	if next.Object != nil && (prev.Object == nil || *prev.Object != *next.Object) {
+		object := *next.Object
+		delta.Object = &object
+	}
+
+	if next.Match != nil && (prev.Match == nil || !prev.Match.equal(next.Match)) {
+		delta.Match = next.Match.clone()
+	}
+
+	for id, player := range next.Players {
+		if old, exists := prev.Players[id]; exists && old.equal(player) {
+			continue
+		}
+		if delta.Players == nil {
+			delta.Players = make(map[string]*Player)
+		}
+		copied := *player
+		delta.Players[id] = &copied
+	}
+
+	for id := range prev.Players {
+		if _, exists := next.Players[id]; !exists {
+			delta.RemovedPlayers = append(delta.RemovedPlayers, id)
+		}
+	}
+
+	ok = delta.Object != nil || delta.Match != nil || len(delta.Players) > 0 || len(delta.RemovedPlayers) > 0 ||
+		prev.Version != next.Version
+	return delta, ok
 }
@@ -57,3 +57,63 @@ func TestGameStateSnapshot(t *testing.T) {
 		t.Error("Snapshot should not affect original game state")
 	}
 }
+
+func TestDiffSnapshots(t *testing.T) {
+	gameState := NewGameState(Position{X: 20, Y: 20})
+	gameState.Players["stay"] = &Player{ID: "stay", Name: "Stay", Connected: true}
+	gameState.Players["leave"] = &Player{ID: "leave", Name: "Leave", Connected: true}
+	prev := gameState.GetState()
+
+	if _, changed := DiffSnapshots(prev, gameState.GetState()); changed {
+		t.Error("Identical snapshots should produce no delta")
+	}
+
+	gameState.Object.Position = Position{X: 11, Y: 10}
+	gameState.Object.Version++
+	gameState.Version++
+	delete(gameState.Players, "leave")
+	gameState.Players["new"] = &Player{ID: "new", Name: "New", Connected: true}
+
+	delta, changed := DiffSnapshots(prev, gameState.GetState())
+	if !changed {
+		t.Fatal("Expected a delta")
+	}
+
+	if delta.Object == nil || delta.Object.Position.X != 11 {
+		t.Errorf("Expected object at x=11 in delta, got %+v", delta.Object)
+	}
+
+	if len(delta.Players) != 1 || delta.Players["new"] == nil {
+		t.Errorf("Expected only the new player in delta, got %v", delta.Players)
+	}
+
+	if len(delta.RemovedPlayers) != 1 || delta.RemovedPlayers[0] != "leave" {
+		t.Errorf("Expected removed player 'leave', got %v", delta.RemovedPlayers)
+	}
+
+	if delta.Version != gameState.Version {
+		t.Errorf("Expected version %d, got %d", gameState.Version, delta.Version)
+	}
+}
+
+func TestDiffSnapshotsCarriesMatch(t *testing.T) {
+	gameState := NewGameState(Position{X: 20, Y: 20})
+	gameState.Match = &Match{Phase: MatchPhaseLobby, Rounds: 3, Scores: map[string]int{}, Totals: map[string]int{}}
+	prev := gameState.GetState()
+
+	// The snapshot is a copy the match can change under
+	gameState.Match.Phase = MatchPhasePlaying
+	gameState.Match.Scores["goal-1"] = 1
+	if prev.Match.Phase != MatchPhaseLobby || len(prev.Match.Scores) != 0 {
+		t.Fatalf("Expected the snapshot's match unchanged, got %+v", prev.Match)
+	}
+
+	delta, changed := DiffSnapshots(prev, gameState.GetState())
+	if !changed || delta.Match == nil || delta.Match.Phase != MatchPhasePlaying || delta.Match.Scores["goal-1"] != 1 {
+		t.Errorf("Expected the new phase and score in the delta, got %+v", delta.Match)
+	}
+
+	if delta, _ := DiffSnapshots(gameState.GetState(), gameState.GetState()); delta.Match != nil {
+		t.Errorf("Expected an unchanged match left out, got %+v", delta.Match)
+	}
+}
@@ -0,0 +1,50 @@
+package models
+
+import "time"
+
+// AnomalyKind names a classic isolation anomaly
+type AnomalyKind string
+
+const (
+	AnomalyDirtyRead  AnomalyKind = "dirty-read"
+	AnomalyLostUpdate AnomalyKind = "lost-update"
+	AnomalyReadSkew   AnomalyKind = "read-skew"
+	AnomalyWriteSkew  AnomalyKind = "write-skew"
+	AnomalyReadOnly   AnomalyKind = "read-only"
+)
+
+// Anomaly is an isolation anomaly that actually happened, either in a
+// scripted scenario or between players' moves. Scenario is empty for moves.
+type Anomaly struct {
+	Kind           AnomalyKind `json:"kind"`
+	IsolationLevel string      `json:"isolationLevel"`
+	Description    string      `json:"description"`
+	Transactions   []string    `json:"transactions"`
+	Scenario       string      `json:"scenario,omitempty"`
+	DetectedAt     time.Time   `json:"detectedAt"`
+}
+
+// ScenarioStep is one operation of a scripted scenario and what it returned
+type ScenarioStep struct {
+	Transaction string `json:"transaction"`
+	Operation   string `json:"operation"`
+	Key         string `json:"key,omitempty"`
+	Value       int64  `json:"value,omitempty"`
+	Error       string `json:"error,omitempty"`
+}
+
+// ScenarioResult is the outcome of running a scenario under one isolation
+// level. Anomaly is nil when the isolation level prevented it.
+// WriteConflicts counts transactions aborted because a concurrent one wrote
+// the same key first, SSIAborts those aborted by serializable snapshot
+// isolation to break a dependency cycle.
+type ScenarioResult struct {
+	Scenario       string           `json:"scenario"`
+	IsolationLevel string           `json:"isolationLevel"`
+	Description    string           `json:"description"`
+	Steps          []ScenarioStep   `json:"steps"`
+	Final          map[string]int64 `json:"final"`
+	WriteConflicts int              `json:"writeConflicts"`
+	SSIAborts      int              `json:"ssiAborts"`
+	Anomaly        *Anomaly         `json:"anomaly,omitempty"`
+}
@@ -0,0 +1,53 @@
+package models
+
+// Layout is the map of a room: cells the object cannot enter and goal zones
+// it can be moved into. It is fixed for the life of the room, so snapshots
+// share it and deltas never carry it.
+type Layout struct {
+	Name  string     `json:"name"`
+	Walls []Position `json:"walls"`
+	Goals []GoalZone `json:"goals"`
+
+	walls map[Position]bool
+	goals map[Position]string
+}
+
+// GoalZone is a connected group of goal cells
+type GoalZone struct {
+	ID    string     `json:"id"`
+	Cells []Position `json:"cells"`
+}
+
+// NewLayout creates a layout with the given walls and goal zones
+func NewLayout(name string, walls []Position, goals []GoalZone) *Layout {
+	layout := &Layout{
+		Name:  name,
+		Walls: walls,
+		Goals: goals,
+		walls: make(map[Position]bool),
+		goals: make(map[Position]string),
+	}
+	for _, wall := range walls {
+		layout.walls[wall] = true
+	}
+	for _, goal := range goals {
+		for _, cell := range goal.Cells {
+			layout.goals[cell] = goal.ID
+		}
+	}
+	return layout
+}
+
+// Blocked reports whether position is a wall. A nil layout has no walls.
+func (l *Layout) Blocked(position Position) bool {
+	return l != nil && l.walls[position]
+}
+
+// GoalAt returns the goal zone position is in, if any
+func (l *Layout) GoalAt(position Position) (string, bool) {
+	if l == nil {
+		return "", false
+	}
+	id, ok := l.goals[position]
+	return id, ok
+}
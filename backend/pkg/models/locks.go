@@ -0,0 +1,54 @@
+package models
+
+import "time"
+
+// WaitForGraph is a snapshot of the lock table under two-phase locking. An
+// edge from Waiter to Holder means Waiter is blocked on a lock Holder owns
+// (or has queued ahead of it). Deadlocks lists recently broken cycles and
+// Aborts the transactions recently aborted by wound-wait or wait-die.
+type WaitForGraph struct {
+	Strategy     string             `json:"strategy"`
+	Transactions []TransactionLocks `json:"transactions"`
+	Edges        []WaitForEdge      `json:"edges"`
+	Deadlocks    []DeadlockReport   `json:"deadlocks,omitempty"`
+	Aborts       []LockAbort        `json:"aborts,omitempty"`
+	Timestamp    time.Time          `json:"timestamp"`
+}
+
+// TransactionLocks is one transaction in the wait-for graph
+type TransactionLocks struct {
+	TransactionID string      `json:"transactionId"`
+	PlayerID      string      `json:"playerId"`
+	StartTime     time.Time   `json:"startTime"`
+	Held          []LockGrant `json:"held"`
+	Waiting       *LockGrant  `json:"waiting,omitempty"`
+}
+
+// LockGrant is a lock on a resource, either held or requested
+type LockGrant struct {
+	Resource string `json:"resource"`
+	Mode     string `json:"mode"`
+}
+
+// WaitForEdge is one waits-for relationship
+type WaitForEdge struct {
+	Waiter   string `json:"waiter"`
+	Holder   string `json:"holder"`
+	Resource string `json:"resource"`
+}
+
+// DeadlockReport describes a deadlock and the transaction aborted to break it
+type DeadlockReport struct {
+	Cycle      []string  `json:"cycle"`
+	Victim     string    `json:"victim"`
+	DetectedAt time.Time `json:"detectedAt"`
+}
+
+// LockAbort describes a transaction aborted by a deadlock prevention policy,
+// with a reason such as "wounded by tx X" or "died waiting on tx Y"
+type LockAbort struct {
+	TransactionID string    `json:"transactionId"`
+	Reason        string    `json:"reason"`
+	By            string    `json:"by"`
+	AbortedAt     time.Time `json:"abortedAt"`
+}
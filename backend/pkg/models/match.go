@@ -0,0 +1,93 @@
+package models
+
+import (
+	"maps"
+	"time"
+)
+
+// MatchPhase is the stage a room's match is in
+type MatchPhase string
+
+const (
+	MatchPhaseLobby     MatchPhase = "lobby"
+	MatchPhaseCountdown MatchPhase = "countdown"
+	MatchPhasePlaying   MatchPhase = "playing"
+	MatchPhaseResults   MatchPhase = "results"
+)
+
+// Match is where a room's match stands. Teams are the goal zones of the
+// room's layout; Scores are each team's goals in the current round and
+// Totals its goals over the match. PhaseEndsAt is nil in the lobby, which
+// waits for players.
+type Match struct {
+	Phase       MatchPhase     `json:"phase"`
+	Round       int            `json:"round"`
+	Rounds      int            `json:"rounds"`
+	PhaseEndsAt *time.Time     `json:"phaseEndsAt,omitempty"`
+	Scores      map[string]int `json:"scores"`
+	Totals      map[string]int `json:"totals"`
+}
+
+// clone returns a deep copy of the match
+func (m *Match) clone() *Match {
+	copied := *m
+	copied.Scores = maps.Clone(m.Scores)
+	copied.Totals = maps.Clone(m.Totals)
+	return &copied
+}
+
+// equal reports whether two copies of a match are the same
+func (m *Match) equal(other *Match) bool {
+	return m.Phase == other.Phase && m.Round == other.Round && m.Rounds == other.Rounds &&
+		(m.PhaseEndsAt == nil) == (other.PhaseEndsAt == nil) && (m.PhaseEndsAt == nil || m.PhaseEndsAt.Equal(*other.PhaseEndsAt)) &&
+		maps.Equal(m.Scores, other.Scores) && maps.Equal(m.Totals, other.Totals)
+}
+
+// RoundStart announces that a round's countdown is over and moves count
+type RoundStart struct {
+	Round      int       `json:"round"`
+	Rounds     int       `json:"rounds"`
+	DurationMs int64     `json:"durationMs"`
+	EndsAt     time.Time `json:"endsAt"`
+	Timestamp  time.Time `json:"timestamp"`
+}
+
+// ScoreUpdate reports a goal: the commit that moved the object into Team's
+// goal zone, made by PlayerID, which is empty when the goal was not one
+// player's move. Scores and Totals are the match's after the goal.
+type ScoreUpdate struct {
+	Round         int            `json:"round"`
+	Team          string         `json:"team"`
+	PlayerID      string         `json:"playerId,omitempty"`
+	PlayerName    string         `json:"playerName,omitempty"`
+	TransactionID string         `json:"transactionId"`
+	Version       int64          `json:"version"`
+	Position      Position       `json:"position"`
+	Scores        map[string]int `json:"scores"`
+	Totals        map[string]int `json:"totals"`
+	Timestamp     time.Time      `json:"timestamp"`
+}
+
+// RoundEnd reports the results of a round. Winner is the team that scored
+// most, empty on a tie; Final is set on the last round of the match.
+type RoundEnd struct {
+	Round     int            `json:"round"`
+	Rounds    int            `json:"rounds"`
+	Final     bool           `json:"final"`
+	Scores    map[string]int `json:"scores"`
+	Totals    map[string]int `json:"totals"`
+	Winner    string         `json:"winner,omitempty"`
+	Results   []RoundResult  `json:"results"`
+	Timestamp time.Time      `json:"timestamp"`
+}
+
+// RoundResult is how one player did in a round: the moves they committed,
+// the conflicts they lost and the goals they scored for their team
+type RoundResult struct {
+	PlayerID        string `json:"playerId"`
+	PlayerName      string `json:"playerName,omitempty"`
+	Team            string `json:"team,omitempty"`
+	SuccessfulMoves int    `json:"successfulMoves"`
+	ConflictsLost   int    `json:"conflictsLost"`
+	Goals           int    `json:"goals"`
+}
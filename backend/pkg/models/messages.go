@@ -2,50 +2,144 @@ package models
 
 import "time"
 
+// ProtocolVersion is the envelope version spoken by this server. Clients may
+// omit protocolVersion, which is read as the current version.
+const ProtocolVersion = 1
+
 // MessageType defines the types of WebSocket messages
 type MessageType string
 
 const (
-	MessageTypeJoin      MessageType = "join"
-	MessageTypeLeave     MessageType = "leave"
-	MessageTypeMove      MessageType = "move"
-	MessageTypeGameState MessageType = "gameState"
-	MessageTypeError     MessageType = "error"
-	MessageTypeConflict  MessageType = "conflict"
+	MessageTypeJoin              MessageType = "join"
+	MessageTypeLeave             MessageType = "leave"
+	MessageTypeMove              MessageType = "move"
+	MessageTypeGameState         MessageType = "gameState"
+	MessageTypeError             MessageType = "error"
+	MessageTypeConflict          MessageType = "conflict"
+	MessageTypeDelta             MessageType = "delta"
+	MessageTypeResync            MessageType = "resync"
+	MessageTypeMoveAck           MessageType = "moveAck"
+	MessageTypeWaitForGraph      MessageType = "waitForGraph"
+	MessageTypeAnomaly           MessageType = "anomaly"
+	MessageTypeRaftStatus        MessageType = "raftStatus"
+	MessageTypeReplicaStatus     MessageType = "replicaStatus"
+	MessageTypeNetworkStatus     MessageType = "networkStatus"
+	MessageTypeCoordinatorStatus MessageType = "coordinatorStatus"
+	MessageTypeSaga              MessageType = "saga"
+	MessageTypeTick              MessageType = "tick"
+	MessageTypeRoundStart        MessageType = "roundStart"
+	MessageTypeScoreUpdate       MessageType = "scoreUpdate"
+	MessageTypeRoundEnd          MessageType = "roundEnd"
 )
 
-// WebSocketMessage represents a message sent over WebSocket
+// ErrorCode identifies why a request was rejected
+type ErrorCode string
+
+const (
+	ErrorCodeMalformed          ErrorCode = "MALFORMED"
+	ErrorCodeUnknownType        ErrorCode = "UNKNOWN_TYPE"
+	ErrorCodeUnsupportedVersion ErrorCode = "UNSUPPORTED_VERSION"
+	ErrorCodeInvalidJoin        ErrorCode = "INVALID_JOIN"
+	ErrorCodeInvalidMove        ErrorCode = "INVALID_MOVE"
+	ErrorCodeNotRegistered      ErrorCode = "NOT_REGISTERED"
+	ErrorCodeNotConnected       ErrorCode = "NOT_CONNECTED"
+	ErrorCodeAlreadyJoined      ErrorCode = "ALREADY_JOINED"
+	ErrorCodeGameFull           ErrorCode = "GAME_FULL"
+	ErrorCodeTransaction        ErrorCode = "TRANSACTION_ERROR"
+	ErrorCodeRateLimited        ErrorCode = "RATE_LIMITED"
+	ErrorCodePreconditionNeeded ErrorCode = "PRECONDITION_REQUIRED"
+	ErrorCodeBadPrecondition    ErrorCode = "INVALID_PRECONDITION"
+	ErrorCodeDeadlock           ErrorCode = "DEADLOCK"
+	ErrorCodeAborted            ErrorCode = "ABORTED"
+	ErrorCodeUnavailable        ErrorCode = "UNAVAILABLE"
+	ErrorCodeInDoubt            ErrorCode = "IN_DOUBT"
+	ErrorCodeOverruled          ErrorCode = "OVERRULED"
+	ErrorCodeWall               ErrorCode = "WALL"
+	ErrorCodeNotPlaying         ErrorCode = "NOT_PLAYING"
+	ErrorCodeUnknownRoom        ErrorCode = "UNKNOWN_ROOM"
+)
+
+// WebSocketMessage represents a message sent over WebSocket. Lamport and
+// Clock are the sender's logical time; unlike Timestamp they order messages
+// across clients. On a message the server sends to one player, PlayerID is
+// that player's ID, which is its entry in vector clocks.
 type WebSocketMessage struct {
-	Type      MessageType `json:"type"`
-	Data      interface{} `json:"data"`
-	PlayerID  string      `json:"playerId,omitempty"`
-	Timestamp time.Time   `json:"timestamp"`
+	Type            MessageType       `json:"type"`
+	ProtocolVersion int               `json:"protocolVersion,omitempty"`
+	Data            interface{}       `json:"data"`
+	PlayerID        string            `json:"playerId,omitempty"`
+	Timestamp       time.Time         `json:"timestamp"`
+	Lamport         uint64            `json:"lamport,omitempty"`
+	Clock           map[string]uint64 `json:"clock,omitempty"`
 }
 
-// JoinRequest represents a player joining the game
+// JoinRequest represents a player joining the game. PlayerID, when set,
+// resumes a player whose connection dropped within the grace period, with
+// its dedupe window and input sequence numbers, instead of creating a new one.
 type JoinRequest struct {
 	PlayerName string `json:"playerName"`
+	PlayerID   string `json:"playerId,omitempty"`
 }
 
-// MoveRequest represents a move command with optimistic concurrency
+// MoveRequest represents a move command with optimistic concurrency. Seq is
+// the client's input sequence number, increasing with every move it sends;
+// zero when the client does not number its inputs.
 type MoveRequest struct {
 	Direction     string `json:"direction"`
 	ObjectVersion int64  `json:"objectVersion"`
 	RequestID     string `json:"requestId"`
+	Seq           uint64 `json:"seq,omitempty"`
 }
 
-// ErrorResponse represents error information
+// MoveAck confirms a committed move to the player that sent it. Duplicate is
+// set when the move was a resend answered from the dedupe window. Seq echoes
+// the move's input sequence number.
+type MoveAck struct {
+	RequestID       string    `json:"requestId"`
+	Seq             uint64    `json:"seq,omitempty"`
+	TransactionID   string    `json:"transactionId"`
+	Version         int64     `json:"version"`
+	Position        Position  `json:"position"`
+	ServerLatencyMs float64   `json:"serverLatencyMs"`
+	Duplicate       bool      `json:"duplicate,omitempty"`
+	Timestamp       time.Time `json:"timestamp"`
+}
+
+// ErrorResponse represents error information. RetryAfterMs is set on
+// RATE_LIMITED errors. Seq is the input sequence number of a rejected move.
 type ErrorResponse struct {
-	Message   string `json:"message"`
-	Code      string `json:"code"`
-	RequestID string `json:"requestId,omitempty"`
+	Message      string      `json:"message"`
+	Code         ErrorCode   `json:"code"`
+	RequestID    string      `json:"requestId,omitempty"`
+	Seq          uint64      `json:"seq,omitempty"`
+	MessageType  MessageType `json:"messageType,omitempty"`
+	Field        string      `json:"field,omitempty"`
+	RetryAfterMs int64       `json:"retryAfterMs,omitempty"`
 }
 
-// ConflictResponse represents a concurrency conflict
+// ConflictResponse represents a concurrency conflict. ExpectedVersion is the
+// object version the losing transaction read; ActualVersion is the version it
+// found at commit time. Duplicate is set when the move was a resend answered
+// from the dedupe window. Seq echoes the move's input sequence number.
 type ConflictResponse struct {
-	Message         string    `json:"message"`
-	ExpectedVersion int64     `json:"expectedVersion"`
-	ActualVersion   int64     `json:"actualVersion"`
-	RequestID       string    `json:"requestId"`
-	Timestamp       time.Time `json:"timestamp"`
+	Message          string          `json:"message"`
+	ExpectedVersion  int64           `json:"expectedVersion"`
+	ActualVersion    int64           `json:"actualVersion"`
+	RequestID        string          `json:"requestId"`
+	Seq              uint64          `json:"seq,omitempty"`
+	TransactionID    string          `json:"transactionId"`
+	ProposedPosition Position        `json:"proposedPosition"`
+	Winner           *ConflictWinner `json:"winner,omitempty"`
+	Duplicate        bool            `json:"duplicate,omitempty"`
+	Timestamp        time.Time       `json:"timestamp"`
+}
+
+// ConflictWinner is the committed transaction that beat a conflicting one
+type ConflictWinner struct {
+	TransactionID string   `json:"transactionId"`
+	PlayerID      string   `json:"playerId"`
+	PlayerName    string   `json:"playerName,omitempty"`
+	RequestID     string   `json:"requestId"`
+	Version       int64    `json:"version"`
+	Position      Position `json:"position"`
 }
@@ -0,0 +1,37 @@
+package models
+
+import "time"
+
+// NetworkStatus is the simulated network between the copies of a replicated
+// room and how far each copy has got. NodeID is the node reporting in
+// replicated mode, where each node only enforces the links it is an end of;
+// it is empty when every replica runs in one process.
+type NetworkStatus struct {
+	NodeID    string            `json:"nodeId,omitempty"`
+	Nodes     []string          `json:"nodes"`
+	Links     []NetworkLink     `json:"links"`
+	Replicas  []ReplicaProgress `json:"replicas"`
+	Timestamp time.Time         `json:"timestamp"`
+}
+
+// NetworkLink is the link between endpoints A and B. The endpoint "clients"
+// stands for the players connected to the replica at the other end.
+type NetworkLink struct {
+	A     string  `json:"a"`
+	B     string  `json:"b"`
+	Cut   bool    `json:"cut"`
+	LagMs float64 `json:"lagMs"`
+}
+
+// ReplicaProgress is how far one copy of the room has got. Applied counts the
+// commits or writes it has applied and Version is its object's version. Role
+// is its Raft role in replicated mode. Reachable is false when the reporting
+// node could not reach it, in which case the values are the last ones known.
+type ReplicaProgress struct {
+	ID        string   `json:"id"`
+	Role      string   `json:"role,omitempty"`
+	Applied   uint64   `json:"applied"`
+	Version   int64    `json:"version"`
+	Position  Position `json:"position"`
+	Reachable bool     `json:"reachable"`
+}
@@ -0,0 +1,42 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// RaftStatus is one node's view of the Raft cluster it belongs to. Peers
+// carries replication progress only on the leader.
+type RaftStatus struct {
+	NodeID      string      `json:"nodeId"`
+	Role        string      `json:"role"`
+	Term        uint64      `json:"term"`
+	LeaderID    string      `json:"leaderId,omitempty"`
+	VotedFor    string      `json:"votedFor,omitempty"`
+	LastIndex   uint64      `json:"lastIndex"`
+	CommitIndex uint64      `json:"commitIndex"`
+	LastApplied uint64      `json:"lastApplied"`
+	Peers       []RaftPeer  `json:"peers"`
+	Log         []RaftEntry `json:"log"`
+	Timestamp   time.Time   `json:"timestamp"`
+}
+
+// RaftPeer is another member of the cluster. MatchIndex and NextIndex are the
+// leader's replication progress for it; Reachable is whether it answered the
+// leader recently.
+type RaftPeer struct {
+	ID         string `json:"id"`
+	Address    string `json:"address"`
+	MatchIndex uint64 `json:"matchIndex"`
+	NextIndex  uint64 `json:"nextIndex"`
+	Reachable  bool   `json:"reachable"`
+}
+
+// RaftEntry is a recent log entry. Command is the replicated command as JSON,
+// absent for the no-op a new leader appends.
+type RaftEntry struct {
+	Index     uint64          `json:"index"`
+	Term      uint64          `json:"term"`
+	Committed bool            `json:"committed"`
+	Command   json.RawMessage `json:"command,omitempty"`
+}
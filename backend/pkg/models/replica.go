@@ -0,0 +1,41 @@
+package models
+
+import "time"
+
+// ReplicaStatus is the state of every replica of an eventually consistent
+// room, compared with the replica the client is connected to
+type ReplicaStatus struct {
+	ReplicaID string        `json:"replicaId"`
+	MergeRule string        `json:"mergeRule"`
+	Replicas  []ReplicaView `json:"replicas"`
+	Timestamp time.Time     `json:"timestamp"`
+}
+
+// ReplicaView is one replica's copy of the object. Siblings are the writes
+// its current value is made of; there is more than one only when the vector
+// clock rule kept concurrent writes. Ahead counts writes this replica has
+// seen that the client's replica has not, Behind the other way round, and
+// Converged is whether both hold the same writes. Conflicts counts
+// concurrent writes this replica kept as siblings; Overwritten counts those
+// it discarded under last-writer-wins.
+type ReplicaView struct {
+	ID          string            `json:"id"`
+	Object      GameObject        `json:"object"`
+	Clock       map[string]uint64 `json:"clock"`
+	Siblings    []ReplicaWrite    `json:"siblings"`
+	Ahead       int               `json:"ahead"`
+	Behind      int               `json:"behind"`
+	Converged   bool              `json:"converged"`
+	Conflicts   int               `json:"conflicts"`
+	Overwritten int               `json:"overwritten"`
+}
+
+// ReplicaWrite is a write of the object's position made on one replica.
+// Clock is that replica's vector clock when it wrote.
+type ReplicaWrite struct {
+	Origin    string            `json:"origin"`
+	PlayerID  string            `json:"playerId,omitempty"`
+	Position  Position          `json:"position"`
+	Timestamp time.Time         `json:"timestamp"`
+	Clock     map[string]uint64 `json:"clock"`
+}
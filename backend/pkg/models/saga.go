@@ -0,0 +1,39 @@
+package models
+
+import "time"
+
+// Saga is a sequence of moves across rooms, each committed on its own. State
+// is running, compensating, completed, compensated or failed; failed means a
+// compensation could not be applied and the rooms are left half moved.
+// Transitions is the saga's history, oldest first.
+type Saga struct {
+	ID          string           `json:"id"`
+	State       string           `json:"state"`
+	Steps       []SagaStep       `json:"steps"`
+	Transitions []SagaTransition `json:"transitions"`
+	StartedAt   time.Time        `json:"startedAt"`
+	FinishedAt  *time.Time       `json:"finishedAt,omitempty"`
+}
+
+// SagaStep is one move of a saga and the move that undoes it. State is
+// pending, committed, failed, compensated or compensation-failed. Position is
+// where the step, or its compensation, left the room's object.
+type SagaStep struct {
+	Room         string    `json:"room"`
+	Direction    string    `json:"direction"`
+	Compensation string    `json:"compensation"`
+	State        string    `json:"state"`
+	Version      int64     `json:"version,omitempty"`
+	Position     *Position `json:"position,omitempty"`
+	Detail       string    `json:"detail,omitempty"`
+}
+
+// SagaTransition is a change of state of a saga, or of one of its steps when
+// Step is set. Steps are numbered from 1.
+type SagaTransition struct {
+	Step      int       `json:"step,omitempty"`
+	From      string    `json:"from"`
+	To        string    `json:"to"`
+	Detail    string    `json:"detail,omitempty"`
+	Timestamp time.Time `json:"timestamp"`
+}
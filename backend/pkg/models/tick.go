@@ -0,0 +1,35 @@
+package models
+
+import "time"
+
+// Tick is how one tick of a room's authoritative simulation resolved the
+// moves received during it. DX and DY are the combined move under Rule;
+// TransactionID, Version and Position describe its commit, and Error why it
+// failed to commit. When the moves cancel out nothing commits: TransactionID
+// is empty and Version and Position are the object's. Inputs are in order of
+// arrival.
+type Tick struct {
+	Tick          int64       `json:"tick"`
+	Rule          string      `json:"rule"`
+	IntervalMs    float64     `json:"intervalMs"`
+	Inputs        []TickInput `json:"inputs"`
+	DX            int         `json:"dx"`
+	DY            int         `json:"dy"`
+	Detail        string      `json:"detail"`
+	TransactionID string      `json:"transactionId,omitempty"`
+	Version       int64       `json:"version"`
+	Position      Position    `json:"position"`
+	Error         string      `json:"error,omitempty"`
+	Timestamp     time.Time   `json:"timestamp"`
+}
+
+// TickInput is one move received during a tick. Accepted is whether the
+// tick's rule let it count.
+type TickInput struct {
+	PlayerID   string `json:"playerId"`
+	PlayerName string `json:"playerName,omitempty"`
+	RequestID  string `json:"requestId"`
+	Seq        uint64 `json:"seq,omitempty"`
+	Direction  string `json:"direction"`
+	Accepted   bool   `json:"accepted"`
+}
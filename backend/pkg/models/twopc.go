@@ -0,0 +1,55 @@
+package models
+
+import "time"
+
+// PreparedTransaction is a transaction that voted to commit in a room and
+// holds the room's object until its coordinator decides. Blocked counts the
+// commits the room refused meanwhile.
+type PreparedTransaction struct {
+	TransactionID string    `json:"transactionId"`
+	Position      Position  `json:"position"`
+	PreparedAt    time.Time `json:"preparedAt"`
+	Blocked       int       `json:"blocked"`
+}
+
+// CoordinatorStatus is the two-phase commit coordinator and the rooms taking
+// part in its transactions. DownUntil is set while a simulated crash is in
+// progress. Log is the coordinator's decision log, most recent last.
+type CoordinatorStatus struct {
+	Up           bool                `json:"up"`
+	DownUntil    *time.Time          `json:"downUntil,omitempty"`
+	Log          []CoordinatorRecord `json:"log"`
+	Participants []ParticipantStatus `json:"participants"`
+	Timestamp    time.Time           `json:"timestamp"`
+}
+
+// CoordinatorRecord is one entry of the coordinator's log. Kind is begin,
+// vote-yes, vote-no, commit, abort or end. Rooms lists a transaction's rooms
+// on its begin record; Room names the room a vote came from.
+type CoordinatorRecord struct {
+	Index         int       `json:"index"`
+	TransactionID string    `json:"transactionId"`
+	Kind          string    `json:"kind"`
+	Rooms         []string  `json:"rooms,omitempty"`
+	Room          string    `json:"room,omitempty"`
+	Detail        string    `json:"detail,omitempty"`
+	Timestamp     time.Time `json:"timestamp"`
+}
+
+// ParticipantStatus is one room's side of two-phase commit. Prepared is set
+// while a transaction holds the room's object, blocking its moves.
+type ParticipantStatus struct {
+	Room     string               `json:"room"`
+	Object   GameObject           `json:"object"`
+	Prepared *PreparedTransaction `json:"prepared,omitempty"`
+}
+
+// CrossRoomResult is the outcome of a cross-room transaction: committed,
+// aborted, or in-doubt when the coordinator crashed before telling every room.
+// Votes maps each room to its vote, with the reason for a vote against.
+type CrossRoomResult struct {
+	TransactionID string            `json:"transactionId"`
+	Outcome       string            `json:"outcome"`
+	Votes         map[string]string `json:"votes"`
+	Status        CoordinatorStatus `json:"status"`
+}
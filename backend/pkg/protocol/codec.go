@@ -0,0 +1,191 @@
+// Package protocol defines how game messages are encoded on the wire.
+//
+// Clients pick an encoding with the WebSocket subprotocol header. JSON is the
+// default when no subprotocol is requested; MessagePack is available for
+// high-rate bot and load generator traffic.
+package protocol
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/AnishMulay/Transaction-Conflict-Visualization/pkg/models"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// Subprotocol names offered during the WebSocket handshake
+const (
+	SubprotocolJSON        = "json"
+	SubprotocolMessagePack = "msgpack"
+)
+
+// Envelope is an incoming message. Data holds the payload as it arrived;
+// Decode fills Payload with the strictly decoded and validated request
+// (for example *models.MoveRequest), or leaves it nil for types without one.
+// ReceivedAt is stamped by the server when the frame was read. Lamport and
+// Clock are the client's logical time, zero when it does not keep one.
+type Envelope struct {
+	Type            models.MessageType
+	ProtocolVersion int
+	PlayerID        string
+	Timestamp       time.Time
+	Lamport         uint64
+	Clock           map[string]uint64
+	ReceivedAt      time.Time
+	Data            []byte
+	Payload         interface{}
+}
+
+// Codec encodes and decodes messages for one wire format
+type Codec interface {
+	// Subprotocol is the WebSocket subprotocol that selects this codec
+	Subprotocol() string
+	// Binary reports whether messages travel in binary frames
+	Binary() bool
+	Marshal(v interface{}) ([]byte, error)
+	// Unmarshal strictly decodes a payload, rejecting unknown fields. An
+	// empty payload leaves v untouched.
+	Unmarshal(data []byte, v interface{}) error
+	// DecodeEnvelope decodes the envelope without looking at the payload
+	DecodeEnvelope(data []byte) (Envelope, error)
+}
+
+var (
+	// JSON is the default text encoding
+	JSON Codec = jsonCodec{}
+	// MessagePack is the binary encoding. Field names follow the json tags.
+	MessagePack Codec = msgpackCodec{}
+)
+
+// Subprotocols lists the supported subprotocols in order of server preference
+func Subprotocols() []string {
+	return []string{SubprotocolMessagePack, SubprotocolJSON}
+}
+
+// ForSubprotocol returns the codec negotiated for a connection. An empty or
+// unknown subprotocol falls back to JSON.
+func ForSubprotocol(name string) Codec {
+	if name == SubprotocolMessagePack {
+		return MessagePack
+	}
+	return JSON
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) Subprotocol() string { return SubprotocolJSON }
+func (jsonCodec) Binary() bool        { return false }
+
+func (jsonCodec) Marshal(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (jsonCodec) Unmarshal(data []byte, v interface{}) error {
+	if len(data) == 0 || bytes.Equal(bytes.TrimSpace(data), []byte("null")) {
+		return nil
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		return err
+	}
+	if dec.More() {
+		return errors.New("unexpected data after payload")
+	}
+	return nil
+}
+
+func (jsonCodec) DecodeEnvelope(data []byte) (Envelope, error) {
+	var raw struct {
+		Type            models.MessageType `json:"type"`
+		ProtocolVersion int                `json:"protocolVersion"`
+		Data            json.RawMessage    `json:"data"`
+		PlayerID        string             `json:"playerId"`
+		Timestamp       time.Time          `json:"timestamp"`
+		Lamport         uint64             `json:"lamport"`
+		Clock           map[string]uint64  `json:"clock"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return Envelope{}, err
+	}
+
+	return Envelope{
+		Type:            raw.Type,
+		ProtocolVersion: raw.ProtocolVersion,
+		PlayerID:        raw.PlayerID,
+		Timestamp:       raw.Timestamp,
+		Lamport:         raw.Lamport,
+		Clock:           raw.Clock,
+		Data:            raw.Data,
+	}, nil
+}
+
+type msgpackCodec struct{}
+
+func (msgpackCodec) Subprotocol() string { return SubprotocolMessagePack }
+func (msgpackCodec) Binary() bool        { return true }
+
+func (msgpackCodec) Marshal(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := msgpack.NewEncoder(&buf)
+	enc.SetCustomStructTag("json")
+	enc.UseCompactInts(true)
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (msgpackCodec) Unmarshal(data []byte, v interface{}) error {
+	return msgpackDecode(data, v, true)
+}
+
+func (msgpackCodec) DecodeEnvelope(data []byte) (Envelope, error) {
+	var raw struct {
+		Type            models.MessageType `json:"type"`
+		ProtocolVersion int                `json:"protocolVersion"`
+		Data            msgpack.RawMessage `json:"data"`
+		PlayerID        string             `json:"playerId"`
+		Timestamp       time.Time          `json:"timestamp"`
+		Lamport         uint64             `json:"lamport"`
+		Clock           map[string]uint64  `json:"clock"`
+	}
+	if err := msgpackDecode(data, &raw, false); err != nil {
+		return Envelope{}, err
+	}
+
+	return Envelope{
+		Type:            raw.Type,
+		ProtocolVersion: raw.ProtocolVersion,
+		PlayerID:        raw.PlayerID,
+		Timestamp:       raw.Timestamp,
+		Lamport:         raw.Lamport,
+		Clock:           raw.Clock,
+		Data:            raw.Data,
+	}, nil
+}
+
+// msgpackNil is the MessagePack encoding of nil
+const msgpackNil = 0xc0
+
+func msgpackDecode(data []byte, v interface{}, strict bool) error {
+	if len(data) == 0 || (len(data) == 1 && data[0] == msgpackNil) {
+		return nil
+	}
+
+	r := bytes.NewReader(data)
+	dec := msgpack.NewDecoder(r)
+	dec.SetCustomStructTag("json")
+	dec.DisallowUnknownFields(strict)
+	if err := dec.Decode(v); err != nil {
+		return err
+	}
+	if r.Len() > 0 {
+		return errors.New("unexpected data after payload")
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+package protocol
+
+import (
+	"testing"
+	"time"
+
+	"github.com/AnishMulay/Transaction-Conflict-Visualization/pkg/models"
+)
+
+func TestCodecRoundTrip(t *testing.T) {
+	for _, codec := range []Codec{JSON, MessagePack} {
+		message := models.WebSocketMessage{
+			Type: models.MessageTypeMove,
+			Data: models.MoveRequest{
+				Direction:     "left",
+				ObjectVersion: 7,
+				RequestID:     "req-1",
+			},
+			PlayerID:  "player-1",
+			Timestamp: time.Now().UTC().Truncate(time.Millisecond),
+			Lamport:   12,
+			Clock:     map[string]uint64{"player-1": 4, "server": 7},
+		}
+
+		data, err := codec.Marshal(message)
+		if err != nil {
+			t.Fatalf("%s: marshal failed: %v", codec.Subprotocol(), err)
+		}
+
+		envelope, err := codec.DecodeEnvelope(data)
+		if err != nil {
+			t.Fatalf("%s: decode envelope failed: %v", codec.Subprotocol(), err)
+		}
+
+		if envelope.Type != message.Type || envelope.PlayerID != message.PlayerID {
+			t.Errorf("%s: envelope mismatch: %+v", codec.Subprotocol(), envelope)
+		}
+
+		if envelope.Lamport != 12 || envelope.Clock["player-1"] != 4 || envelope.Clock["server"] != 7 {
+			t.Errorf("%s: expected the logical time to survive, got %d %v", codec.Subprotocol(), envelope.Lamport, envelope.Clock)
+		}
+
+		if !envelope.Timestamp.Equal(message.Timestamp) {
+			t.Errorf("%s: expected timestamp %v, got %v", codec.Subprotocol(), message.Timestamp, envelope.Timestamp)
+		}
+
+		var move models.MoveRequest
+		if err := codec.Unmarshal(envelope.Data, &move); err != nil {
+			t.Fatalf("%s: payload decode failed: %v", codec.Subprotocol(), err)
+		}
+
+		if move != message.Data {
+			t.Errorf("%s: expected %+v, got %+v", codec.Subprotocol(), message.Data, move)
+		}
+	}
+}
+
+func TestForSubprotocol(t *testing.T) {
+	if ForSubprotocol("") != JSON {
+		t.Error("Expected JSON when no subprotocol is negotiated")
+	}
+	if ForSubprotocol("xml") != JSON {
+		t.Error("Expected JSON for unknown subprotocols")
+	}
+	if ForSubprotocol(SubprotocolMessagePack) != MessagePack {
+		t.Error("Expected MessagePack for the msgpack subprotocol")
+	}
+}
+
+func TestMessagePackIsSmaller(t *testing.T) {
+	delta := models.WebSocketMessage{
+		Type: models.MessageTypeDelta,
+		Data: models.GameStateDelta{
+			Seq:     42,
+			Version: 17,
+			Object:  &models.GameObject{ID: "object", Position: models.Position{X: 3, Y: 4}, Version: 17},
+		},
+		Timestamp: time.Now(),
+	}
+
+	jsonData, _ := JSON.Marshal(delta)
+	msgpackData, _ := MessagePack.Marshal(delta)
+
+	if len(msgpackData) >= len(jsonData) {
+		t.Errorf("Expected MessagePack (%d bytes) to be smaller than JSON (%d bytes)", len(msgpackData), len(jsonData))
+	}
+}
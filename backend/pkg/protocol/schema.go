@@ -0,0 +1,30 @@
+package protocol
+
+import (
+	"embed"
+	"io/fs"
+	"net/http"
+
+	"github.com/AnishMulay/Transaction-Conflict-Visualization/pkg/models"
+)
+
+// schemaFiles holds one JSON Schema per message type, named
+// <type>.schema.json, plus the shared envelope and common definitions
+//
+//go:embed schema/*.schema.json
+var schemaFiles embed.FS
+
+// Schema returns the JSON Schema document for a message type
+func Schema(messageType models.MessageType) ([]byte, error) {
+	return schemaFiles.ReadFile("schema/" + string(messageType) + ".schema.json")
+}
+
+// SchemaHandler serves the schema documents. Mount it with http.StripPrefix
+// so that relative $ref values between documents resolve.
+func SchemaHandler() http.Handler {
+	sub, err := fs.Sub(schemaFiles, "schema")
+	if err != nil {
+		panic(err)
+	}
+	return http.FileServer(http.FS(sub))
+}
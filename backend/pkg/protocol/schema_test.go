@@ -0,0 +1,136 @@
+package protocol
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/AnishMulay/Transaction-Conflict-Visualization/pkg/models"
+)
+
+// messagePayloads maps every message type to its payload type, or nil when
+// the message carries no payload
+var messagePayloads = map[models.MessageType]reflect.Type{
+	models.MessageTypeJoin:              reflect.TypeOf(models.JoinRequest{}),
+	models.MessageTypeMove:              reflect.TypeOf(models.MoveRequest{}),
+	models.MessageTypeLeave:             nil,
+	models.MessageTypeResync:            nil,
+	models.MessageTypeGameState:         reflect.TypeOf(models.GameStateSnapshot{}),
+	models.MessageTypeDelta:             reflect.TypeOf(models.GameStateDelta{}),
+	models.MessageTypeError:             reflect.TypeOf(models.ErrorResponse{}),
+	models.MessageTypeConflict:          reflect.TypeOf(models.ConflictResponse{}),
+	models.MessageTypeMoveAck:           reflect.TypeOf(models.MoveAck{}),
+	models.MessageTypeWaitForGraph:      reflect.TypeOf(models.WaitForGraph{}),
+	models.MessageTypeAnomaly:           reflect.TypeOf(models.Anomaly{}),
+	models.MessageTypeRaftStatus:        reflect.TypeOf(models.RaftStatus{}),
+	models.MessageTypeReplicaStatus:     reflect.TypeOf(models.ReplicaStatus{}),
+	models.MessageTypeNetworkStatus:     reflect.TypeOf(models.NetworkStatus{}),
+	models.MessageTypeCoordinatorStatus: reflect.TypeOf(models.CoordinatorStatus{}),
+	models.MessageTypeSaga:              reflect.TypeOf(models.Saga{}),
+	models.MessageTypeTick:              reflect.TypeOf(models.Tick{}),
+	models.MessageTypeRoundStart:        reflect.TypeOf(models.RoundStart{}),
+	models.MessageTypeScoreUpdate:       reflect.TypeOf(models.ScoreUpdate{}),
+	models.MessageTypeRoundEnd:          reflect.TypeOf(models.RoundEnd{}),
+}
+
+func jsonFieldNames(t reflect.Type) []string {
+	var names []string
+	for i := 0; i < t.NumField(); i++ {
+		tag := t.Field(i).Tag.Get("json")
+		name, _, _ := strings.Cut(tag, ",")
+		if name != "" && name != "-" {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+func TestSchemaForEveryMessageType(t *testing.T) {
+	for messageType, payloadType := range messagePayloads {
+		data, err := Schema(messageType)
+		if err != nil {
+			t.Errorf("No schema for %s: %v", messageType, err)
+			continue
+		}
+
+		var schema struct {
+			Properties struct {
+				Type struct {
+					Const string `json:"const"`
+				} `json:"type"`
+				Data struct {
+					Properties map[string]json.RawMessage `json:"properties"`
+				} `json:"data"`
+			} `json:"properties"`
+		}
+		if err := json.Unmarshal(data, &schema); err != nil {
+			t.Errorf("Schema for %s is not valid JSON: %v", messageType, err)
+			continue
+		}
+
+		if schema.Properties.Type.Const != string(messageType) {
+			t.Errorf("Schema for %s pins type to %q", messageType, schema.Properties.Type.Const)
+		}
+
+		if payloadType == nil {
+			continue
+		}
+
+		var schemaFields []string
+		for name := range schema.Properties.Data.Properties {
+			schemaFields = append(schemaFields, name)
+		}
+		sort.Strings(schemaFields)
+
+		if want := jsonFieldNames(payloadType); !reflect.DeepEqual(schemaFields, want) {
+			t.Errorf("Schema for %s has fields %v, payload has %v", messageType, schemaFields, want)
+		}
+	}
+}
+
+func TestEnvelopeSchemaListsEveryType(t *testing.T) {
+	data, err := schemaFiles.ReadFile("schema/envelope.schema.json")
+	if err != nil {
+		t.Fatalf("Missing envelope schema: %v", err)
+	}
+
+	var envelope struct {
+		Properties struct {
+			Type struct {
+				Enum []string `json:"enum"`
+			} `json:"type"`
+		} `json:"properties"`
+	}
+	if err := json.Unmarshal(data, &envelope); err != nil {
+		t.Fatalf("Envelope schema is not valid JSON: %v", err)
+	}
+
+	if len(envelope.Properties.Type.Enum) != len(messagePayloads) {
+		t.Errorf("Envelope lists %d types, expected %d", len(envelope.Properties.Type.Enum), len(messagePayloads))
+	}
+	for _, name := range envelope.Properties.Type.Enum {
+		if _, ok := messagePayloads[models.MessageType(name)]; !ok {
+			t.Errorf("Envelope lists unknown type %q", name)
+		}
+	}
+}
+
+func TestSchemaHandler(t *testing.T) {
+	server := httptest.NewServer(http.StripPrefix("/schema/", SchemaHandler()))
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/schema/move.schema.json")
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected 200, got %d", resp.StatusCode)
+	}
+}
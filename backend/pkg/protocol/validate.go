@@ -0,0 +1,150 @@
+package protocol
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/AnishMulay/Transaction-Conflict-Visualization/pkg/models"
+)
+
+// Limits enforced on incoming payloads
+const (
+	MaxPlayerNameLength = 32
+	MaxRequestIDLength  = 128
+)
+
+// Error is a structured protocol error that is reported back to the client
+type Error struct {
+	Code        models.ErrorCode
+	Message     string
+	MessageType models.MessageType
+	Field       string
+	RequestID   string
+}
+
+func (e *Error) Error() string {
+	if e.Field != "" {
+		return fmt.Sprintf("%s: %s (%s)", e.Code, e.Message, e.Field)
+	}
+	return fmt.Sprintf("%s: %s", e.Code, e.Message)
+}
+
+// Response converts the error into the payload of an error message
+func (e *Error) Response() models.ErrorResponse {
+	return models.ErrorResponse{
+		Message:     e.Message,
+		Code:        e.Code,
+		RequestID:   e.RequestID,
+		MessageType: e.MessageType,
+		Field:       e.Field,
+	}
+}
+
+// payloadSpec describes the payload of a message type clients may send
+type payloadSpec struct {
+	// newPayload returns a pointer to decode into, or is nil for payload-less types
+	newPayload func() interface{}
+	// code is reported when the payload fails to decode or validate
+	code models.ErrorCode
+}
+
+// inbound lists every message type a client may send
+var inbound = map[models.MessageType]payloadSpec{
+	models.MessageTypeJoin: {
+		newPayload: func() interface{} { return &models.JoinRequest{} },
+		code:       models.ErrorCodeInvalidJoin,
+	},
+	models.MessageTypeMove: {
+		newPayload: func() interface{} { return &models.MoveRequest{} },
+		code:       models.ErrorCodeInvalidMove,
+	},
+	models.MessageTypeLeave:  {},
+	models.MessageTypeResync: {},
+}
+
+var directions = map[string]bool{"up": true, "down": true, "left": true, "right": true}
+
+// Decode decodes an incoming message, checks its protocol version and type,
+// and strictly decodes its payload into Envelope.Payload. Failures are
+// returned as *Error.
+func Decode(codec Codec, data []byte) (Envelope, error) {
+	envelope, err := codec.DecodeEnvelope(data)
+	if err != nil {
+		return Envelope{}, &Error{Code: models.ErrorCodeMalformed, Message: "message could not be decoded: " + err.Error()}
+	}
+
+	if envelope.ProtocolVersion == 0 {
+		envelope.ProtocolVersion = models.ProtocolVersion
+	}
+	if envelope.ProtocolVersion != models.ProtocolVersion {
+		return envelope, &Error{
+			Code:        models.ErrorCodeUnsupportedVersion,
+			Message:     fmt.Sprintf("protocol version %d is not supported, use %d", envelope.ProtocolVersion, models.ProtocolVersion),
+			MessageType: envelope.Type,
+			Field:       "protocolVersion",
+		}
+	}
+
+	spec, ok := inbound[envelope.Type]
+	if !ok {
+		return envelope, &Error{
+			Code:        models.ErrorCodeUnknownType,
+			Message:     fmt.Sprintf("unknown message type %q", envelope.Type),
+			MessageType: envelope.Type,
+			Field:       "type",
+		}
+	}
+
+	if spec.newPayload == nil {
+		return envelope, nil
+	}
+
+	payload := spec.newPayload()
+	if err := codec.Unmarshal(envelope.Data, payload); err != nil {
+		return envelope, &Error{
+			Code:        spec.code,
+			Message:     "invalid payload: " + err.Error(),
+			MessageType: envelope.Type,
+			Field:       "data",
+		}
+	}
+
+	if field, problem := validatePayload(payload); problem != "" {
+		protocolErr := &Error{Code: spec.code, Message: problem, MessageType: envelope.Type, Field: field}
+		if move, ok := payload.(*models.MoveRequest); ok {
+			protocolErr.RequestID = move.RequestID
+		}
+		return envelope, protocolErr
+	}
+
+	envelope.Payload = payload
+	return envelope, nil
+}
+
+// validatePayload returns the offending field and a description, or an empty
+// description when the payload is valid
+func validatePayload(payload interface{}) (field, problem string) {
+	switch p := payload.(type) {
+	case *models.JoinRequest:
+		if strings.TrimSpace(p.PlayerName) == "" {
+			return "playerName", "playerName is required"
+		}
+		if utf8.RuneCountInString(p.PlayerName) > MaxPlayerNameLength {
+			return "playerName", fmt.Sprintf("playerName must be at most %d characters", MaxPlayerNameLength)
+		}
+
+	case *models.MoveRequest:
+		if !directions[p.Direction] {
+			return "direction", fmt.Sprintf("direction must be up, down, left or right, got %q", p.Direction)
+		}
+		if p.RequestID == "" {
+			return "requestId", "requestId is required"
+		}
+		if len(p.RequestID) > MaxRequestIDLength {
+			return "requestId", fmt.Sprintf("requestId must be at most %d bytes", MaxRequestIDLength)
+		}
+	}
+
+	return "", ""
+}
@@ -0,0 +1,77 @@
+package protocol
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/AnishMulay/Transaction-Conflict-Visualization/pkg/models"
+)
+
+func TestDecodeValidMessages(t *testing.T) {
+	envelope, err := Decode(JSON, []byte(`{"type":"move","data":{"direction":"up","requestId":"r1","objectVersion":3}}`))
+	if err != nil {
+		t.Fatalf("Expected valid move, got %v", err)
+	}
+
+	move, ok := envelope.Payload.(*models.MoveRequest)
+	if !ok {
+		t.Fatalf("Expected *models.MoveRequest payload, got %T", envelope.Payload)
+	}
+	if move.Direction != "up" || move.RequestID != "r1" || move.ObjectVersion != 3 {
+		t.Errorf("Unexpected payload %+v", move)
+	}
+	if envelope.ProtocolVersion != models.ProtocolVersion {
+		t.Errorf("Expected omitted version to read as %d, got %d", models.ProtocolVersion, envelope.ProtocolVersion)
+	}
+
+	if _, err := Decode(JSON, []byte(`{"type":"resync","protocolVersion":1}`)); err != nil {
+		t.Errorf("Expected valid resync, got %v", err)
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	cases := []struct {
+		name  string
+		input string
+		code  models.ErrorCode
+		field string
+	}{
+		{"malformed", `{"type":`, models.ErrorCodeMalformed, ""},
+		{"unknown type", `{"type":"teleport"}`, models.ErrorCodeUnknownType, "type"},
+		{"server-only type", `{"type":"gameState"}`, models.ErrorCodeUnknownType, "type"},
+		{"future version", `{"type":"resync","protocolVersion":2}`, models.ErrorCodeUnsupportedVersion, "protocolVersion"},
+		{"unknown field", `{"type":"move","data":{"direction":"up","requestId":"r","speed":9}}`, models.ErrorCodeInvalidMove, "data"},
+		{"wrong field type", `{"type":"move","data":{"direction":1,"requestId":"r"}}`, models.ErrorCodeInvalidMove, "data"},
+		{"bad direction", `{"type":"move","data":{"direction":"north","requestId":"r"}}`, models.ErrorCodeInvalidMove, "direction"},
+		{"missing request id", `{"type":"move","data":{"direction":"up"}}`, models.ErrorCodeInvalidMove, "requestId"},
+		{"missing name", `{"type":"join","data":{}}`, models.ErrorCodeInvalidJoin, "playerName"},
+		{"long name", `{"type":"join","data":{"playerName":"abcdefghijklmnopqrstuvwxyzabcdefg"}}`, models.ErrorCodeInvalidJoin, "playerName"},
+	}
+
+	for _, tc := range cases {
+		_, err := Decode(JSON, []byte(tc.input))
+
+		var protocolErr *Error
+		if !errors.As(err, &protocolErr) {
+			t.Errorf("%s: expected *Error, got %v", tc.name, err)
+			continue
+		}
+		if protocolErr.Code != tc.code || protocolErr.Field != tc.field {
+			t.Errorf("%s: expected %s on %q, got %s on %q", tc.name, tc.code, tc.field, protocolErr.Code, protocolErr.Field)
+		}
+	}
+}
+
+func TestDecodeRejectsUnknownMessagePackFields(t *testing.T) {
+	data, _ := MessagePack.Marshal(map[string]interface{}{
+		"type": "join",
+		"data": map[string]interface{}{"playerName": "Bot", "admin": true},
+	})
+
+	_, err := Decode(MessagePack, data)
+
+	var protocolErr *Error
+	if !errors.As(err, &protocolErr) || protocolErr.Code != models.ErrorCodeInvalidJoin {
+		t.Errorf("Expected INVALID_JOIN, got %v", err)
+	}
+}
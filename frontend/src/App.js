@@ -1,17 +1,122 @@
-import React, { useState, useEffect, useCallback } from 'react';
+import React, { useState, useEffect, useCallback, useRef } from 'react';
 import './App.css';
 import GameBoard from './components/GameBoard';
 import PlayerList from './components/PlayerList';
 import ConnectionStatus from './components/ConnectionStatus';
 import JoinForm from './components/JoinForm';
 import ConflictNotification from './components/ConflictNotification';
+import LockGraph from './components/LockGraph';
+import ClusterStatus from './components/ClusterStatus';
+import ReplicaStatus from './components/ReplicaStatus';
+import NetworkStatus from './components/NetworkStatus';
+import CoordinatorStatus from './components/CoordinatorStatus';
+import SagaLog from './components/SagaLog';
+import TickLog from './components/TickLog';
+import MatchStatus from './components/MatchStatus';
 import useWebSocket from './hooks/useWebSocket';
 
+// PROTOCOL_VERSION is the message envelope version this client speaks.
+// Schemas for every message are served at /schema/.
+const PROTOCOL_VERSION = 1;
+
+// WS_URL is the server that served this page, so each node of a multi-node
+// cluster serves its own clients. The development server on port 3000 talks
+// to the default node. In eventually consistent mode ?replica=r2 on the page
+// picks the replica to play on, and with several rooms ?room=arena the room.
+const REPLICA = new URLSearchParams(window.location.search).get('replica');
+const ROOM = new URLSearchParams(window.location.search).get('room');
+const WS_URL = (process.env.REACT_APP_WS_URL
+  || (window.location.port === '3000'
+    ? 'ws://localhost:8080/ws'
+    : `${window.location.protocol === 'https:' ? 'wss' : 'ws'}://${window.location.host}/ws`))
+  + (REPLICA ? `?replica=${encodeURIComponent(REPLICA)}` : '')
+  + (!REPLICA && ROOM ? `?room=${encodeURIComponent(ROOM)}` : '');
+
+// boardMarkers lists squares to highlight on the grid: Raft log entries'
+// targets, solid once committed, and other replicas' copies of the object,
+// solid once in sync with ours
+const boardMarkers = (raftStatus, replicaStatus) => {
+  const markers = [];
+  ((raftStatus && raftStatus.log) || []).forEach(entry => {
+    if (entry.command) {
+      markers.push({
+        position: entry.command.position,
+        label: `#${entry.index}`,
+        solid: entry.committed
+      });
+    }
+  });
+  ((replicaStatus && replicaStatus.replicas) || []).forEach(replica => {
+    if (replica.id !== replicaStatus.replicaId) {
+      markers.push({
+        position: replica.object.position,
+        label: replica.id,
+        solid: replica.converged
+      });
+    }
+  });
+  return markers;
+};
+
+// applyDelta returns the game state after applying a sequence-numbered delta
+const applyDelta = (state, delta) => {
+  const players = { ...state.players, ...(delta.players || {}) };
+  (delta.removedPlayers || []).forEach(id => delete players[id]);
+
+  return {
+    ...state,
+    object: delta.object || state.object,
+    match: delta.match || state.match,
+    players,
+    version: delta.version,
+    seq: delta.seq
+  };
+};
+
+// predictObject returns the object as it will be once our pending inputs
+// commit, moving it as the server does: stopping at the edges and leaving
+// out moves into a wall, which the server refuses
+const predictObject = (object, inputs, gridSize, layout) => {
+  const walls = new Set(((layout && layout.walls) || []).map(wall => `${wall.x}-${wall.y}`));
+  let position = { ...object.position };
+  inputs.forEach(({ direction }) => {
+    const next = { ...position };
+    if (direction === 'up') next.y = Math.max(0, next.y - 1);
+    if (direction === 'down') next.y = Math.min(gridSize.y - 1, next.y + 1);
+    if (direction === 'left') next.x = Math.max(0, next.x - 1);
+    if (direction === 'right') next.x = Math.min(gridSize.x - 1, next.x + 1);
+    if (!walls.has(`${next.x}-${next.y}`)) position = next;
+  });
+  return { ...object, position };
+};
+
 function App() {
   const [gameState, setGameState] = useState(null);
   const [playerName, setPlayerName] = useState('');
   const [isJoined, setIsJoined] = useState(false);
   const [conflicts, setConflicts] = useState([]);
+  const [lastAck, setLastAck] = useState(null);
+  const [lockGraph, setLockGraph] = useState(null);
+  const [raftStatus, setRaftStatus] = useState(null);
+  const [replicaStatus, setReplicaStatus] = useState(null);
+  const [networkStatus, setNetworkStatus] = useState(null);
+  const [coordinatorStatus, setCoordinatorStatus] = useState(null);
+  const [sagas, setSagas] = useState([]);
+  const [ticks, setTicks] = useState([]);
+  const [lastRound, setLastRound] = useState(null);
+  // Send times of moves still waiting for a moveAck or conflict, by requestId
+  const pendingMoves = useRef(new Map());
+  // How many concurrent moves our replica held at the last replicaStatus
+  const replicaSiblings = useRef(0);
+  // Our moves, numbered by input sequence, applied locally until the server
+  // reports them processed
+  const [pendingInputs, setPendingInputs] = useState([]);
+  const nextSeq = useRef(1);
+  const playerId = useRef(null);
+  // dropInput forgets a move the server has answered
+  const dropInput = useCallback((seq) => {
+    if (seq) setPendingInputs(prev => prev.filter(input => input.seq !== seq));
+  }, []);
   
   const {
     isConnected,
@@ -19,7 +124,7 @@ function App() {
     sendMessage,
     lastMessage,
     conflictStats
-  } = useWebSocket('ws://localhost:8080/ws');
+  } = useWebSocket(WS_URL);
 
   // Handle incoming WebSocket messages
   useEffect(() => {
@@ -27,18 +132,136 @@ function App() {
 
     switch (lastMessage.type) {
       case 'gameState':
+        if (lastMessage.playerId) playerId.current = lastMessage.playerId;
         setGameState(lastMessage.data);
         break;
+
+      case 'delta': {
+        const delta = lastMessage.data;
+        setGameState(prev => {
+          if (!prev || delta.seq <= prev.seq) return prev;
+          if (delta.seq !== prev.seq + 1) {
+            // Missed an update; ask for a full snapshot
+            sendMessage({ type: 'resync', protocolVersion: PROTOCOL_VERSION, timestamp: new Date().toISOString() });
+            return prev;
+          }
+          return applyDelta(prev, delta);
+        });
+        break;
+      }
       
       case 'error':
         console.error('Game error:', lastMessage.data);
-        if (lastMessage.data.code === 'GAME_FULL') {
+        if (lastMessage.data.requestId) {
+          pendingMoves.current.delete(lastMessage.data.requestId);
+        }
+        dropInput(lastMessage.data.seq);
+        if (lastMessage.data.code === 'UNSUPPORTED_VERSION') {
+          alert('This page is out of date. Please reload.');
+        } else if (['DEADLOCK', 'ABORTED', 'UNAVAILABLE', 'IN_DOUBT', 'OVERRULED', 'WALL', 'NOT_PLAYING'].includes(lastMessage.data.code)) {
+          setConflicts(prev => [...prev, {
+            id: Date.now(),
+            message: lastMessage.data.code === 'DEADLOCK'
+              ? `Your move was aborted to break a deadlock: ${lastMessage.data.message}`
+              : lastMessage.data.message,
+            timestamp: new Date(lastMessage.timestamp)
+          }]);
+        } else if (lastMessage.data.code === 'GAME_FULL') {
           alert('Game is full! Please try again later.');
         }
         break;
       
+      case 'moveAck': {
+        const ack = lastMessage.data;
+        const sentAt = pendingMoves.current.get(ack.requestId);
+        pendingMoves.current.delete(ack.requestId);
+        dropInput(ack.seq);
+        setLastAck({
+          ...ack,
+          roundTripMs: sentAt ? Math.round(performance.now() - sentAt) : null
+        });
+        break;
+      }
+
+      case 'waitForGraph':
+        setLockGraph(lastMessage.data);
+        break;
+
+      case 'raftStatus':
+        setRaftStatus(lastMessage.data);
+        break;
+
+      case 'replicaStatus': {
+        const status = lastMessage.data;
+        const own = status.replicas.find(replica => replica.id === status.replicaId);
+        const siblings = own ? own.siblings.length : 0;
+        // Surface a conflict when our replica starts holding concurrent moves
+        if (siblings > 1 && replicaSiblings.current <= 1) {
+          setConflicts(prev => [...prev, {
+            id: Date.now(),
+            message: `Concurrent moves on ${status.replicaId}: ${own.siblings
+              .map(write => `${write.origin} → (${write.position.x}, ${write.position.y})`)
+              .join(' vs ')}. The next move resolves them.`,
+            timestamp: new Date(lastMessage.timestamp)
+          }]);
+        }
+        replicaSiblings.current = siblings;
+        setReplicaStatus(status);
+        break;
+      }
+
+      case 'networkStatus':
+        setNetworkStatus(lastMessage.data);
+        break;
+
+      case 'coordinatorStatus':
+        setCoordinatorStatus(lastMessage.data);
+        break;
+
+      case 'saga': {
+        // Each message carries the whole saga; keep the latest five
+        const saga = lastMessage.data;
+        setSagas(prev => [...prev.filter(other => other.id !== saga.id), saga].slice(-5));
+        break;
+      }
+
+      case 'tick':
+        // Only ticks that received moves are sent; keep the latest eight
+        setTicks(prev => [...prev, lastMessage.data].slice(-8));
+        break;
+
+      case 'roundStart':
+        setLastRound(null);
+        break;
+
+      case 'scoreUpdate': {
+        const update = lastMessage.data;
+        setConflicts(prev => [...prev, {
+          id: Date.now(),
+          message: `Goal for ${update.team}${update.playerName ? ` by ${update.playerName}` : ''}!`,
+          timestamp: new Date(lastMessage.timestamp)
+        }]);
+        break;
+      }
+
+      case 'roundEnd':
+        setLastRound(lastMessage.data);
+        break;
+
+      case 'anomaly': {
+        const anomaly = lastMessage.data;
+        setConflicts(prev => [...prev, {
+          id: Date.now(),
+          message: `${anomaly.kind} under ${anomaly.isolationLevel}: ${anomaly.description}`,
+          timestamp: new Date(lastMessage.timestamp)
+        }]);
+        break;
+      }
+
       case 'conflict':
         console.warn('Move conflict:', lastMessage.data);
+        pendingMoves.current.delete(lastMessage.data.requestId);
+        dropInput(lastMessage.data.seq);
         setConflicts(prev => [...prev, {
           id: Date.now(),
           message: lastMessage.data.message,
@@ -49,7 +272,16 @@ function App() {
       default:
         console.log('Unknown message type:', lastMessage.type);
     }
-  }, [lastMessage]);
+  }, [lastMessage, sendMessage, dropInput]);
+
+  // Reconcile with the server: every input up to the last one it processed
+  // is already in the state, committed or rejected
+  useEffect(() => {
+    const own = gameState && playerId.current && gameState.players[playerId.current];
+    if (own && own.lastProcessedSeq) {
+      setPendingInputs(prev => prev.filter(input => input.seq > own.lastProcessedSeq));
+    }
+  }, [gameState]);
 
   const handleJoinGame = useCallback((name) => {
     if (!isConnected) {
@@ -60,6 +292,7 @@ function App() {
     setPlayerName(name);
     sendMessage({
       type: 'join',
+      protocolVersion: PROTOCOL_VERSION,
       data: { playerName: name },
       timestamp: new Date().toISOString()
     });
@@ -70,12 +303,17 @@ function App() {
     if (!isJoined || !gameState) return;
 
     const requestId = `${Date.now()}-${Math.random()}`;
+    const seq = nextSeq.current++;
+    pendingMoves.current.set(requestId, performance.now());
+    setPendingInputs(prev => [...prev, { seq, direction }]);
     sendMessage({
       type: 'move',
+      protocolVersion: PROTOCOL_VERSION,
       data: {
         direction,
         objectVersion: gameState.object.version,
-        requestId
+        requestId,
+        seq
       },
       timestamp: new Date().toISOString()
     });
@@ -117,6 +355,13 @@ function App() {
           <div className="conflict-stats">
             <span>Conflicts: {conflictStats.conflicts}</span>
             <span>Success Rate: {conflictStats.successRate}%</span>
+            {lastAck && (
+              <span>
+                Last move: v{lastAck.version}
+                {lastAck.roundTripMs !== null && ` in ${lastAck.roundTripMs}ms`}
+                {` (server ${lastAck.serverLatencyMs.toFixed(1)}ms)`}
+              </span>
+            )}
           </div>
         )}
       </header>
@@ -126,14 +371,41 @@ function App() {
           <>
             <div className="game-area">
               <GameBoard 
-                gameState={gameState}
+                gameState={pendingInputs.length > 0
+                  ? { ...gameState, object: predictObject(gameState.object, pendingInputs, gameState.gridSize, gameState.layout) }
+                  : gameState}
                 onMove={handleMove}
                 playerName={playerName}
+                markers={boardMarkers(raftStatus, replicaStatus)}
               />
             </div>
             
             <div className="sidebar">
               <PlayerList players={gameState.players} />
+              {lockGraph && <LockGraph graph={lockGraph} />}
+              {raftStatus && <ClusterStatus status={raftStatus} />}
+              {replicaStatus && <ReplicaStatus status={replicaStatus} />}
+              {networkStatus && (
+                <NetworkStatus
+                  status={networkStatus}
+                  own={networkStatus.nodeId || (replicaStatus && replicaStatus.replicaId)}
+                />
+              )}
+              {coordinatorStatus && (
+                <CoordinatorStatus
+                  status={coordinatorStatus}
+                  own={ROOM || (coordinatorStatus.participants[0] && coordinatorStatus.participants[0].room)}
+                />
+              )}
+              {sagas.length > 0 && <SagaLog sagas={sagas} />}
+              {ticks.length > 0 && <TickLog ticks={ticks} />}
+              {gameState.match && (
+                <MatchStatus
+                  match={gameState.match}
+                  team={playerId.current && gameState.players[playerId.current] && gameState.players[playerId.current].team}
+                  lastRound={lastRound}
+                />
+              )}
             </div>
           </>
         )}
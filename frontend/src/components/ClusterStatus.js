@@ -0,0 +1,59 @@
+import React from 'react';
+import './ClusterStatus.css';
+
+// How many of the most recent log entries to list
+const RECENT_ENTRIES = 6;
+
+const ClusterStatus = ({ status }) => {
+  const peers = status.peers || [];
+  const entries = (status.log || []).slice(-RECENT_ENTRIES).reverse();
+
+  return (
+    <div className="cluster-status">
+      <h3>Raft Cluster</h3>
+
+      <div className={`cluster-node ${status.role}`}>
+        <div className="cluster-node-id">{status.nodeId} ({status.role})</div>
+        <div>term {status.term} · leader {status.leaderId || 'unknown'}</div>
+        <div>commit {status.commitIndex} · applied {status.lastApplied} · last {status.lastIndex}</div>
+      </div>
+
+      {peers.length > 0 && (
+        <table className="cluster-peers">
+          <thead>
+            <tr>
+              <th>peer</th>
+              <th>match</th>
+              <th>next</th>
+            </tr>
+          </thead>
+          <tbody>
+            {peers.map(peer => (
+              <tr key={peer.id} className={peer.reachable ? '' : 'unreachable'}>
+                <td>{peer.id}</td>
+                <td>{peer.matchIndex}</td>
+                <td>{peer.nextIndex}</td>
+              </tr>
+            ))}
+          </tbody>
+        </table>
+      )}
+
+      <ul className="cluster-log">
+        {entries.map(entry => (
+          <li key={entry.index} className={entry.committed ? 'committed' : 'pending'}>
+            #{entry.index} t{entry.term}{' '}
+            {entry.command
+              ? `→ (${entry.command.position.x}, ${entry.command.position.y})`
+              : 'no-op'}
+          </li>
+        ))}
+        {entries.length === 0 && (
+          <li className="no-entries">Log is empty</li>
+        )}
+      </ul>
+    </div>
+  );
+};
+
+export default ClusterStatus;
@@ -0,0 +1,56 @@
+import React from 'react';
+import './CoordinatorStatus.css';
+
+// CoordinatorStatus shows the two-phase commit coordinator that moves objects
+// across rooms: whether it is up, which rooms hold a prepared transaction and
+// so have to refuse moves, and the coordinator's decision log. own is the
+// room this client plays in.
+const CoordinatorStatus = ({ status, own }) => {
+  const downUntil = status.downUntil && new Date(status.downUntil);
+
+  return (
+    <div className="coordinator-status">
+      <h3>Cross-room commits</h3>
+
+      <div className={`coordinator-state ${status.up ? 'up' : 'down'}`}>
+        {status.up
+          ? 'Coordinator up'
+          : `Coordinator crashed${downUntil ? `, restarts at ${downUntil.toLocaleTimeString()}` : ', waiting for recovery'}`}
+      </div>
+
+      {status.participants.map(participant => (
+        <div
+          key={participant.room}
+          className={`coordinator-room ${participant.prepared ? 'blocked' : ''} ${participant.room === own ? 'own' : ''}`}
+        >
+          <span className="coordinator-room-name">{participant.room}</span>
+          <span>
+            v{participant.object.version} at ({participant.object.position.x}, {participant.object.position.y})
+          </span>
+          {participant.prepared && (
+            <span>
+              in doubt: {participant.prepared.transactionId} would move it to
+              ({participant.prepared.position.x}, {participant.prepared.position.y})
+              {participant.prepared.blocked > 0 && ` · ${participant.prepared.blocked} moves blocked`}
+            </span>
+          )}
+        </div>
+      ))}
+
+      <table className="coordinator-log">
+        <tbody>
+          {status.log.map(record => (
+            <tr key={record.index} className={record.kind}>
+              <td>{record.index}</td>
+              <td>{record.transactionId}</td>
+              <td>{record.kind}{record.room && ` ${record.room}`}{record.rooms && ` ${record.rooms.join(', ')}`}</td>
+              <td>{record.detail}</td>
+            </tr>
+          ))}
+        </tbody>
+      </table>
+    </div>
+  );
+};
+
+export default CoordinatorStatus;
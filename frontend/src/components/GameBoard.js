@@ -1,8 +1,10 @@
 import React, { useEffect, useCallback } from 'react';
 import './GameBoard.css';
 
-const GameBoard = ({ gameState, onMove, playerName }) => {
-  const { object, players, gridSize } = gameState;
+// markers are extra squares to highlight, such as Raft log entries or other
+// replicas' copies of the object: { position, label, solid }
+const GameBoard = ({ gameState, onMove, playerName, markers = [] }) => {
+  const { object, players, gridSize, layout } = gameState;
 
   const handleKeyPress = useCallback((event) => {
     const keyMap = {
@@ -32,15 +34,30 @@ const GameBoard = ({ gameState, onMove, playerName }) => {
 
   const renderGrid = () => {
     const cells = [];
+    const markersByCell = {};
+    markers.forEach(marker => {
+      markersByCell[`${marker.position.x}-${marker.position.y}`] = marker;
+    });
+    const walls = new Set(((layout && layout.walls) || []).map(wall => `${wall.x}-${wall.y}`));
+    const goalsByCell = {};
+    ((layout && layout.goals) || []).forEach(goal => {
+      goal.cells.forEach(cell => {
+        goalsByCell[`${cell.x}-${cell.y}`] = goal.id;
+      });
+    });
     
     for (let y = 0; y < gridSize.y; y++) {
       for (let x = 0; x < gridSize.x; x++) {
         const isObjectHere = object.position.x === x && object.position.y === y;
+        const marker = markersByCell[`${x}-${y}`];
+        const isWall = walls.has(`${x}-${y}`);
+        const goal = goalsByCell[`${x}-${y}`];
         
         cells.push(
           <div
             key={`${x}-${y}`}
-            className={`grid-cell ${isObjectHere ? 'has-object' : ''}`}
+            className={`grid-cell ${isObjectHere ? 'has-object' : ''} ${isWall ? 'wall' : ''} ${goal ? 'goal' : ''}`}
+            title={goal}
             style={{
               gridColumn: x + 1,
               gridRow: y + 1,
@@ -51,6 +68,11 @@ const GameBoard = ({ gameState, onMove, playerName }) => {
                 <div className="object-version">v{object.version}</div>
               </div>
             )}
+            {marker && !isObjectHere && (
+              <div className={`board-marker ${marker.solid ? 'solid' : 'hollow'}`}>
+                {marker.label}
+              </div>
+            )}
           </div>
         );
       }
@@ -67,6 +89,7 @@ const GameBoard = ({ gameState, onMove, playerName }) => {
         <div className="object-info">
           <span>Object Position: ({object.position.x}, {object.position.y})</span>
           <span>Version: {object.version}</span>
+          {layout && <span>Map: {layout.name}</span>}
           <span>Last Updated: {new Date(object.lastUpdated).toLocaleTimeString()}</span>
         </div>
       </div>
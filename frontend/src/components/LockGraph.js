@@ -0,0 +1,62 @@
+import React from 'react';
+import './LockGraph.css';
+
+// shortId trims transaction IDs, which embed a UUID and a timestamp
+const shortId = (id) => (id.length > 12 ? `…${id.slice(-8)}` : id);
+
+const LockGraph = ({ graph }) => {
+  const transactions = graph.transactions || [];
+  const edges = graph.edges || [];
+  const lastDeadlock = (graph.deadlocks || []).slice(-1)[0];
+  const lastAbort = (graph.aborts || []).slice(-1)[0];
+
+  return (
+    <div className="lock-graph">
+      <h3>Wait-For Graph ({graph.strategy})</h3>
+
+      <div className="lock-transactions">
+        {transactions.map(tx => (
+          <div
+            key={tx.transactionId}
+            className={`lock-transaction ${tx.waiting ? 'waiting' : 'running'}`}
+          >
+            <div className="lock-tx-id">{shortId(tx.transactionId)}</div>
+            <div className="lock-tx-held">
+              holds {tx.held.map(lock => lock.resource).join(', ') || 'nothing'}
+            </div>
+            {tx.waiting && (
+              <div className="lock-tx-waiting">waits for {tx.waiting.resource}</div>
+            )}
+          </div>
+        ))}
+        {transactions.length === 0 && (
+          <div className="no-locks">No locks held</div>
+        )}
+      </div>
+
+      {edges.length > 0 && (
+        <ul className="lock-edges">
+          {edges.map(edge => (
+            <li key={`${edge.waiter}-${edge.holder}`}>
+              {shortId(edge.waiter)} → {shortId(edge.holder)} ({edge.resource})
+            </li>
+          ))}
+        </ul>
+      )}
+
+      {lastDeadlock && (
+        <div className="lock-deadlock">
+          Deadlock {lastDeadlock.cycle.map(shortId).join(' → ')} broken by aborting {shortId(lastDeadlock.victim)}
+        </div>
+      )}
+
+      {lastAbort && (
+        <div className="lock-deadlock">
+          {shortId(lastAbort.transactionId)} {lastAbort.reason.replace(lastAbort.by, shortId(lastAbort.by))}
+        </div>
+      )}
+    </div>
+  );
+};
+
+export default LockGraph;
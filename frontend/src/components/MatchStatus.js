@@ -0,0 +1,68 @@
+import React, { useEffect, useState } from 'react';
+import './MatchStatus.css';
+
+const PHASES = {
+  lobby: 'Waiting for players',
+  countdown: 'Get ready',
+  playing: 'Playing',
+  results: 'Round over'
+};
+
+// MatchStatus shows where the room's match stands: the phase with the time
+// left in it, every team's score and the results of the last round. The
+// player's own team is highlighted.
+const MatchStatus = ({ match, team, lastRound }) => {
+  const [now, setNow] = useState(Date.now());
+
+  useEffect(() => {
+    const timer = setInterval(() => setNow(Date.now()), 250);
+    return () => clearInterval(timer);
+  }, []);
+
+  const secondsLeft = match.phaseEndsAt
+    ? Math.max(0, Math.ceil((new Date(match.phaseEndsAt).getTime() - now) / 1000))
+    : null;
+
+  return (
+    <div className={`match-status ${match.phase}`}>
+      <h3>
+        {match.round > 0 ? `Round ${match.round} of ${match.rounds}` : 'Match'}: {PHASES[match.phase]}
+        {secondsLeft !== null && ` (${secondsLeft}s)`}
+      </h3>
+      <div className="match-scores">
+        {Object.keys(match.totals).sort().map(id => (
+          <span key={id} className={id === team ? 'own' : ''}>
+            {id}: {match.scores[id] || 0}
+            <small> ({match.totals[id]} total)</small>
+          </span>
+        ))}
+      </div>
+      {lastRound && (
+        <div className="match-results">
+          <div className="match-results-title">
+            Round {lastRound.round}: {lastRound.winner ? `${lastRound.winner} wins` : 'a tie'}
+            {lastRound.final && ', match over'}
+          </div>
+          <table>
+            <thead>
+              <tr><th>Player</th><th>Team</th><th>Goals</th><th>Moves</th><th>Conflicts lost</th></tr>
+            </thead>
+            <tbody>
+              {lastRound.results.map(result => (
+                <tr key={result.playerId}>
+                  <td>{result.playerName || result.playerId.slice(0, 8)}</td>
+                  <td>{result.team}</td>
+                  <td>{result.goals}</td>
+                  <td>{result.successfulMoves}</td>
+                  <td>{result.conflictsLost}</td>
+                </tr>
+              ))}
+            </tbody>
+          </table>
+        </div>
+      )}
+    </div>
+  );
+};
+
+export default MatchStatus;
@@ -0,0 +1,61 @@
+import React from 'react';
+import './NetworkStatus.css';
+
+// NetworkStatus shows the simulated links between replicas and how far each
+// replica has got, so a partition and its recovery can be watched live. own
+// is the replica this client is connected to.
+const NetworkStatus = ({ status, own }) => {
+  const mostApplied = Math.max(0, ...status.replicas.map(replica => replica.applied));
+  const copies = new Set(status.replicas.map(replica =>
+    `${replica.version}@${replica.position.x},${replica.position.y}`)).size;
+  const leaders = status.replicas.filter(replica => replica.role === 'leader').length;
+  const ownLinkCut = status.links.some(link =>
+    link.cut && ((link.a === own && link.b === 'clients') || (link.b === own && link.a === 'clients')));
+
+  return (
+    <div className="network-status">
+      <h3>Network{status.nodeId && ` (seen from ${status.nodeId})`}</h3>
+
+      {ownLinkCut && (
+        <div className="network-warning">
+          Your link to {own} is cut: moves are refused until it heals
+        </div>
+      )}
+      <div className={`network-summary ${copies > 1 || leaders > 1 ? 'split' : ''}`}>
+        {copies > 1 ? `${copies} diverging copies` : 'All copies agree'}
+        {leaders > 1 && ` · ${leaders} nodes think they lead`}
+      </div>
+
+      {status.replicas.map(replica => (
+        <div
+          key={replica.id}
+          className={`network-replica ${replica.reachable ? '' : 'stale'} ${replica.id === own ? 'own' : ''}`}
+        >
+          <span className="network-replica-id">
+            {replica.id}{replica.role && ` (${replica.role})`}
+          </span>
+          <span>
+            applied {replica.applied}
+            {replica.applied < mostApplied && ` · ${mostApplied - replica.applied} behind`}
+          </span>
+          <span>v{replica.version} at ({replica.position.x}, {replica.position.y})</span>
+          {!replica.reachable && <span>unreachable, last known</span>}
+        </div>
+      ))}
+
+      <table className="network-links">
+        <tbody>
+          {status.links.map(link => (
+            <tr key={`${link.a}-${link.b}`} className={link.cut ? 'cut' : ''}>
+              <td>{link.a} ↔ {link.b}</td>
+              <td>{link.cut ? 'cut' : 'up'}</td>
+              <td>{link.lagMs > 0 ? `+${link.lagMs}ms` : ''}</td>
+            </tr>
+          ))}
+        </tbody>
+      </table>
+    </div>
+  );
+};
+
+export default NetworkStatus;
@@ -15,7 +15,7 @@ const PlayerList = ({ players }) => {
               style={{ backgroundColor: player.color }}
             />
             <div className="player-info">
-              <div className="player-name">{player.name}</div>
+              <div className="player-name">{player.name}{player.team && ` (${player.team})`}</div>
               <div className="player-status">
                 {player.connected ? (
                   <span className="status-online">Online</span>
@@ -0,0 +1,49 @@
+import React from 'react';
+import './ReplicaStatus.css';
+
+const formatClock = (clock) => {
+  const entries = Object.entries(clock || {}).sort(([a], [b]) => a.localeCompare(b));
+  return entries.length ? `{${entries.map(([id, count]) => `${id}:${count}`).join(' ')}}` : '{}';
+};
+
+const ReplicaStatus = ({ status }) => (
+  <div className="replica-status">
+    <h3>Replicas ({status.mergeRule})</h3>
+
+    {status.replicas.map(replica => {
+      const own = replica.id === status.replicaId;
+      return (
+        <div
+          key={replica.id}
+          className={`replica ${own ? 'own' : ''} ${replica.converged ? 'converged' : 'diverged'}`}
+        >
+          <div className="replica-id">
+            {replica.id}{own && ' (yours)'} at ({replica.object.position.x}, {replica.object.position.y})
+          </div>
+          <div className="replica-clock">{formatClock(replica.clock)}</div>
+          {!own && (
+            <div>
+              {replica.converged
+                ? 'in sync with yours'
+                : `${replica.ahead} ahead, ${replica.behind} behind yours`}
+            </div>
+          )}
+          {replica.siblings.length > 1 && (
+            <div className="replica-siblings">
+              conflict: {replica.siblings
+                .map(write => `${write.origin} → (${write.position.x}, ${write.position.y})`)
+                .join(' | ')}
+            </div>
+          )}
+          {(replica.conflicts > 0 || replica.overwritten > 0) && (
+            <div className="replica-counts">
+              {replica.conflicts} conflicts kept, {replica.overwritten} overwritten
+            </div>
+          )}
+        </div>
+      );
+    })}
+  </div>
+);
+
+export default ReplicaStatus;
@@ -0,0 +1,40 @@
+import React from 'react';
+import './SagaLog.css';
+
+// SagaLog shows the most recent sagas as they run: each step with the move
+// that undoes it, and the saga's state machine as a list of transitions, so
+// the half-finished states other players can see are easy to spot.
+const SagaLog = ({ sagas }) => (
+  <div className="saga-log">
+    <h3>Sagas</h3>
+    {sagas.map(saga => (
+      <div key={saga.id} className={`saga ${saga.state}`}>
+        <div className="saga-header">
+          <span className="saga-id">{saga.id}</span>
+          <span className="saga-state">{saga.state}</span>
+        </div>
+
+        <ol className="saga-steps">
+          {saga.steps.map((step, index) => (
+            <li key={index} className={step.state} title={step.detail || ''}>
+              {step.room}: {step.direction}
+              {step.state === 'compensated' && !step.detail && ` ↩ ${step.compensation}`}
+              <span className="saga-step-state"> {step.state}</span>
+              {step.position && ` (${step.position.x}, ${step.position.y})`}
+            </li>
+          ))}
+        </ol>
+
+        <div className="saga-transitions">
+          {saga.transitions.map((transition, index) => (
+            <span key={index} title={transition.detail || ''}>
+              {transition.step ? `${transition.step}:` : ''}{transition.to}
+            </span>
+          ))}
+        </div>
+      </div>
+    ))}
+  </div>
+);
+
+export default SagaLog;
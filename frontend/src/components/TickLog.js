@@ -0,0 +1,31 @@
+import React from 'react';
+import './TickLog.css';
+
+// TickLog shows the most recent ticks of tick mode: the moves received
+// during each tick, struck through when the rule overruled them, and the
+// one move they combined into
+const TickLog = ({ ticks }) => (
+  <div className="tick-log">
+    <h3>Ticks ({ticks[ticks.length - 1].rule}, every {ticks[ticks.length - 1].intervalMs}ms)</h3>
+    {ticks.slice().reverse().map(tick => (
+      <div key={tick.tick} className={`tick ${tick.error ? 'failed' : ''}`} title={tick.error || tick.detail}>
+        <div className="tick-header">
+          <span>#{tick.tick}</span>
+          <span>
+            ({tick.dx >= 0 ? '+' : ''}{tick.dx}, {tick.dy >= 0 ? '+' : ''}{tick.dy})
+            {tick.error ? ' failed' : ` → (${tick.position.x}, ${tick.position.y}) v${tick.version}`}
+          </span>
+        </div>
+        <div className="tick-inputs">
+          {tick.inputs.map(input => (
+            <span key={input.requestId} className={input.accepted ? 'accepted' : 'overruled'}>
+              {input.playerName || input.playerId.slice(0, 8)}: {input.direction}
+            </span>
+          ))}
+        </div>
+      </div>
+    ))}
+  </div>
+);
+
+export default TickLog;
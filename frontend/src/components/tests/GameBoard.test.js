@@ -115,6 +115,27 @@ describe('GameBoard', () => {
     expect(mockOnMove).toHaveBeenCalledWith('right');
   });
 
+  test('draws the walls and goal zones of the map', () => {
+    render(
+      <GameBoard 
+        gameState={{
+          ...mockGameState,
+          layout: {
+            name: 'test',
+            walls: [{ x: 0, y: 0 }, { x: 1, y: 0 }],
+            goals: [{ id: 'goal-1', cells: [{ x: 9, y: 9 }] }]
+          }
+        }}
+        onMove={mockOnMove}
+        playerName="Test Player"
+      />
+    );
+    
+    expect(document.querySelectorAll('.grid-cell.wall')).toHaveLength(2);
+    expect(document.querySelector('.grid-cell.goal')).toHaveAttribute('title', 'goal-1');
+    expect(screen.getByText('Map: test')).toBeInTheDocument();
+  });
+
   test('displays object at correct position', () => {
     render(
       <GameBoard 
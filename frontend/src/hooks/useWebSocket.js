@@ -16,6 +16,13 @@ const useWebSocket = (url) => {
   const maxReconnectAttempts = 10;
   const reconnectInterval = 3000;
 
+  // Logical clocks of this client. Its process name is the player ID the
+  // server puts on messages meant for us; until one arrives, only the
+  // Lamport clock ticks and the server makes up our vector clock entry.
+  const lamport = useRef(0);
+  const clock = useRef({});
+  const processName = useRef(null);
+
   const connect = useCallback(() => {
     try {
       ws.current = new WebSocket(url);
@@ -30,6 +37,18 @@ const useWebSocket = (url) => {
       ws.current.onmessage = (event) => {
         try {
           const message = JSON.parse(event.data);
+          if (message.playerId && !processName.current) {
+            processName.current = message.playerId;
+          }
+          if (message.lamport) {
+            lamport.current = Math.max(lamport.current, message.lamport) + 1;
+            Object.entries(message.clock || {}).forEach(([process, time]) => {
+              clock.current[process] = Math.max(clock.current[process] || 0, time);
+            });
+            if (processName.current) {
+              clock.current[processName.current] = (clock.current[processName.current] || 0) + 1;
+            }
+          }
           setLastMessage(message);
           
           // Update conflict statistics
@@ -39,7 +58,7 @@ const useWebSocket = (url) => {
               total: prev.total + 1,
               successRate: Math.round(((prev.total - prev.conflicts) / (prev.total + 1)) * 100)
             }));
-          } else if (message.type === 'gameState') {
+          } else if (message.type === 'moveAck') {
             setConflictStats(prev => ({
               ...prev,
               total: prev.total + 1,
@@ -81,7 +100,13 @@ const useWebSocket = (url) => {
   const sendMessage = useCallback((message) => {
     if (ws.current && ws.current.readyState === WebSocket.OPEN) {
       try {
-        ws.current.send(JSON.stringify(message));
+        lamport.current += 1;
+        const stamped = { ...message, lamport: lamport.current };
+        if (processName.current) {
+          clock.current[processName.current] = (clock.current[processName.current] || 0) + 1;
+          stamped.clock = { ...clock.current };
+        }
+        ws.current.send(JSON.stringify(stamped));
       } catch (error) {
         console.error('Failed to send message:', error);
       }